@@ -13,6 +13,8 @@ import (
 	"github.com/Asliddin3/energy-maximum/pkg/logger"
 	"github.com/Asliddin3/energy-maximum/pkg/middleware"
 	postgresdb "github.com/Asliddin3/energy-maximum/pkg/postgres"
+	"github.com/Asliddin3/energy-maximum/pkg/sms"
+	"github.com/Asliddin3/energy-maximum/pkg/utils"
 	"github.com/gin-contrib/gzip"
 	"github.com/gin-gonic/gin"
 	"github.com/mvrilo/go-redoc"
@@ -62,13 +64,57 @@ func main() {
 		log.Error("failed to migrate db", logger.Error(err))
 		return
 	}
+	if cfg.SuperuserUsername != "" {
+		var passwordHash string
+		if cfg.SuperuserPassword != "" {
+			passwordHash, err = hash.NewHasher().HashPassword(cfg.SuperuserPassword)
+			if err != nil {
+				log.Error("failed to hash superuser password", logger.Error(err))
+				return
+			}
+		}
+		err = migrate.SeedSuperuser(db, cfg.SuperuserUsername, passwordHash)
+		if err != nil {
+			log.Error("failed to seed superuser", logger.Error(err))
+			return
+		}
+	}
 	humanizer := humanizer.NewHumanizer(map[string]string{
 		"ь": "",
 		"ъ": "",
 	})
 
+	accessKeys, err := utils.NewKeyRing(utils.KeyRingConfig{
+		KeyID:      cfg.AccessTokenKeyID,
+		PrivateKey: cfg.AccessTokenPrivateKey,
+		PublicKey:  cfg.AccessTokenPublicKey,
+		Dir:        cfg.AccessTokenKeysDir,
+		VerifyKeys: cfg.AccessTokenVerifyKeys,
+	})
+	if err != nil {
+		log.Error("failed to load access token keys", logger.Error(err))
+		return
+	}
+	refreshKeys, err := utils.NewKeyRing(utils.KeyRingConfig{
+		KeyID:      cfg.RefreshTokenKeyID,
+		PrivateKey: cfg.RefreshTokenPrivateKey,
+		PublicKey:  cfg.RefreshTokenPublicKey,
+		Dir:        cfg.RefreshTokenKeysDir,
+		VerifyKeys: cfg.RefreshTokenVerifyKeys,
+	})
+	if err != nil {
+		log.Error("failed to load refresh token keys", logger.Error(err))
+		return
+	}
+
+	smsSender, err := sms.New(cfg)
+	if err != nil {
+		log.Error("failed to create sms sender", logger.Error(err))
+		return
+	}
+
 	hash := hash.NewHasher()
-	handler := controller.NewHandler(db, log, cfg, hash, humanizer)
+	handler := controller.NewHandler(db, log, cfg, hash, humanizer, accessKeys, refreshKeys, smsSender)
 
 	handler.Init(server)
 
@@ -18,7 +18,7 @@ type AboutController struct {
 
 func (h *Handler) NewAboutController(api *gin.RouterGroup) {
 	brand := &AboutController{h}
-	br := api.Group("about", h.DeserializeAdmin())
+	br := h.adminGroup(api, "about")
 	{
 		br.POST("/", brand.CreateAbout)
 		br.PUT("/:id", brand.UpdateAbout)
@@ -73,7 +73,7 @@ func (h *AboutController) CreateAbout(c *gin.Context) {
 		CreatedID:     &admin.Id,
 		CreatedAt:     timeNow(),
 	}
-	err = h.db.Debug().Clauses(clause.Returning{}).Create(&about).Error
+	err = h.db.WithContext(c).Debug().Clauses(clause.Returning{}).Create(&about).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -147,7 +147,7 @@ func (h *AboutController) UpdateAbout(c *gin.Context) {
 	}
 	columns["updated_at"] = timeNow()
 	columns["updated_id"] = admin.Id
-	err = h.db.Debug().Clauses(clause.Returning{}).Model(&about).
+	err = h.db.WithContext(c).Debug().Clauses(clause.Returning{}).Model(&about).
 		Where("id=?", inputId).Updates(columns).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
@@ -215,7 +215,7 @@ func (h *AboutController) GetByID(c *gin.Context) {
 // @Router			/api/about/{id} [DELETE]
 func (h *AboutController) DeleteAbout(c *gin.Context) {
 	id := c.Param("id")
-	err := h.db.Delete(&models.About{}, "id=?", id).Error
+	err := h.db.WithContext(c).Delete(&models.About{}, "id=?", id).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
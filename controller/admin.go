@@ -5,13 +5,12 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/Asliddin3/energy-maximum/models"
 	"github.com/Asliddin3/energy-maximum/pkg/logger"
-	"github.com/Asliddin3/energy-maximum/pkg/utils"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type AdminController struct {
@@ -21,17 +20,29 @@ type AdminController struct {
 func (h *Handler) NewAdminController(api *gin.RouterGroup) {
 	adminContr := &AdminController{h}
 	admin := api.Group("admin")
+	adminOnly := h.adminGroup(admin, "")
 	{
-		admin.POST("", h.DeserializeAdmin(), adminContr.CreateAdmin)
+		adminOnly.POST("", adminContr.CreateAdmin)
 		admin.POST("/auth", adminContr.AuthAdmin)
-		admin.PUT("/:id", h.DeserializeAdmin(), adminContr.UpdateAdmin)
-		admin.GET("", h.DeserializeAdmin(), adminContr.GetAdmins)
-		admin.PUT("/me", h.DeserializeAdmin(), adminContr.UpdateMe)
-		admin.GET("/me", h.DeserializeAdmin(), adminContr.GetMe)
-		admin.GET("/:id", h.DeserializeAdmin(), adminContr.GetAdminById)
-		admin.PUT("/activate/:id", h.DeserializeAdmin(), adminContr.ActivateAdmin)
-		admin.PUT("/deactivate/:id", h.DeserializeAdmin(), adminContr.DeactivateAdmin)
-		admin.DELETE("/:id", h.DeserializeAdmin(), adminContr.DeleteAdmin)
+		admin.POST("/refresh", adminContr.RefreshToken)
+		admin.POST("/logout", adminContr.Logout)
+		admin.POST("/logout-all", h.DeserializeAnyAdmin(), adminContr.LogoutAll)
+		admin.POST("/auth/2fa", adminContr.AuthTwoFactor)
+		admin.POST("/auth/2fa/enroll", adminContr.EnrollTwoFactor)
+		admin.POST("/2fa/setup", h.DeserializeAnyAdmin(), adminContr.SetupTwoFactor)
+		admin.POST("/2fa/confirm", h.DeserializeAnyAdmin(), adminContr.ConfirmTwoFactor)
+		admin.POST("/2fa/disable", h.DeserializeAnyAdmin(), adminContr.DisableTwoFactor)
+		admin.POST("/2fa/recovery-codes", h.DeserializeAnyAdmin(), adminContr.RegenerateRecoveryCodes)
+		adminOnly.PUT("/:id", adminContr.UpdateAdmin)
+		adminOnly.GET("", adminContr.GetAdmins)
+		admin.PUT("/me", h.DeserializeAnyAdmin(), adminContr.UpdateMe)
+		admin.GET("/me", h.DeserializeAnyAdmin(), adminContr.GetMe)
+		adminOnly.GET("/:id", adminContr.GetAdminById)
+		adminOnly.PUT("/activate/:id", adminContr.ActivateAdmin)
+		adminOnly.PUT("/deactivate/:id", adminContr.DeactivateAdmin)
+		adminOnly.PUT("/unlock/:id", adminContr.UnlockAdmin)
+		adminOnly.GET("/login-attempts", adminContr.GetLoginAttempts)
+		adminOnly.DELETE("/:id", adminContr.DeleteAdmin)
 	}
 }
 
@@ -43,10 +54,11 @@ func (h *Handler) NewAdminController(api *gin.RouterGroup) {
 // @Produce			json
 // @Param			data 	body		models.AdminsCreateRequest	true	"data body"
 // @Success			201		{object}	models.TokenResponse
-// @Failure			400,409	{object}	response
+// @Failure			400,403,409	{object}	response
 // @Failure			500		{object}	response
 // @Router			/api/admin [POST]
 func (h *AdminController) CreateAdmin(c *gin.Context) {
+	currentUser := h.GetAdmin(c)
 	var body models.AdminsCreateRequest
 	err := c.ShouldBindJSON(&body)
 	if err != nil {
@@ -54,21 +66,31 @@ func (h *AdminController) CreateAdmin(c *gin.Context) {
 		h.log.Error("failed to create category", err.Error())
 		return
 	}
-	hashed, err := h.hash.HashPassword(body.Password)
+	if body.IsSuperuser != nil && !currentUser.IsSuperuser {
+		newResponse(c, http.StatusForbidden, "only superuser can grant superuser")
+		return
+	}
+	if body.RoleID != 0 && !h.canGrantRole(c, body.RoleID) {
+		return
+	}
+	hashed, err := h.hashNewPassword(h.db.WithContext(c), models.TokenSubjectAdmin, 0, "", body.Password)
 	if err != nil {
-		newResponse(c, http.StatusBadRequest, "failed to hash password")
-		h.log.Error("error while hash password", logger.Error(err))
+		h.passwordError(c, err)
 		return
 	}
 	admin := &models.Admins{
-		Username:  body.Username,
-		Password:  hashed,
-		RoleID:    body.RoleID,
-		CreatedAt: timeNow(),
-		IsActive:  body.IsActive,
+		Username:    body.Username,
+		Password:    hashed,
+		RoleID:      body.RoleID,
+		CreatedAt:   timeNow(),
+		IsActive:    body.IsActive,
+		IsSuperuser: body.IsSuperuser,
 		// CreatedID: ,
 	}
-	err = h.db.Create(&admin).Error
+	if body.Team != nil {
+		admin.Team = strings.TrimSpace(*body.Team)
+	}
+	err = h.db.WithContext(c).Create(&admin).Error
 	if err != nil {
 		if strings.Contains(err.Error(), "duplicate key value violates unique ") {
 			newResponse(c, http.StatusBadRequest, "already exists username")
@@ -77,43 +99,90 @@ func (h *AdminController) CreateAdmin(c *gin.Context) {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
+	err = h.rememberPassword(h.db.WithContext(c), models.TokenSubjectAdmin, admin.ID, hashed)
+	if err != nil {
+		h.log.Error("failed to save password history", logger.Error(err))
+	}
 
 	c.JSON(http.StatusOK, admin)
 }
 
-// // @Summary		  Refresh access token
-// // @Description	   this api is for refresh access token
-// // @Tags			Admin
-// // @Security		BearerAuth
-// // @Accept			json
-// // @Produce			json
-// // @Param           refreshToken   query  string   true  "refresh token"
-// // @Success			201		{object}	models.AccessToken
-// // @Failure			400,409	{object}	response
-// // @Failure			500		{object}	response
-// // @Router			/api/admin/refresh [POST]
-// func (h *AdminController) RefreshToken(c *gin.Context) {
-// 	refreshToken := c.Query("refreshToken")
-// 	sub, err := utils.ValidateToken(refreshToken, h.cfg.RefreshTokenPublicKey)
-// 	if err != nil {
-// 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": err.Error()})
-// 		return
-// 	}
-// 	admin := &models.Admins{}
-// 	err = h.db.First(admin, "id=? AND is_active=true AND deleted_at IS NUll", sub).Error
-// 	if err != nil {
-// 		newResponse(c, http.StatusInternalServerError, "failed to find customer")
-// 		return
-// 	}
+// @Summary		  Refresh access token
+// @Description	   this api rotates refresh token and returns new token pair, a reused refresh token revokes the whole login
+// @Tags			Admin
+// @Accept			json
+// @Produce			json
+// @Param			data 	body		models.RefreshTokenRequest	true	"data body"
+// @Success			200		{object}	models.TokenResponse
+// @Failure			400,401	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/admin/refresh [POST]
+func (h *AdminController) RefreshToken(c *gin.Context) {
+	var body models.RefreshTokenRequest
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	tokens, err := h.rotateRefreshToken(c, models.TokenSubjectAdmin, body.RefreshToken,
+		func(tx *gorm.DB, adminID int) (bool, error) {
+			var count int64
+			err := tx.Model(&models.Admins{}).
+				Where("id=? AND is_active=true AND deleted_at IS NULL", adminID).Count(&count).Error
+			return count != 0, err
+		})
+	if err != nil {
+		h.refreshTokenError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
 
-// 	token, err := utils.CreateToken(time.Duration(time.Hour), admin.ID, h.cfg.AccessTokenPrivateKey)
-// 	if err != nil {
-// 		newResponse(c, http.StatusInternalServerError, err.Error())
-// 		h.log.Error("error while token", logger.Error(err))
-// 		return
-// 	}
-// 	c.JSON(http.StatusOK, models.AccessToken{AccessToken: token})
-// }
+// @Summary		  Logout admin
+// @Description	   this api revokes refresh token of current login
+// @Tags			Admin
+// @Accept			json
+// @Produce			json
+// @Param			data 	body		models.RefreshTokenRequest	true	"data body"
+// @Success			200		{object}	response
+// @Failure			400,401	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/admin/logout [POST]
+func (h *AdminController) Logout(c *gin.Context) {
+	var body models.RefreshTokenRequest
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	err = h.revokeTokenFamily(models.TokenSubjectAdmin, body.RefreshToken)
+	if err != nil {
+		h.refreshTokenError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response{"success"})
+}
+
+// @Summary		  Logout admin everywhere
+// @Description	   this api revokes all refresh tokens of current admin
+// @Tags			Admin
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Success			200		{object}	response
+// @Failure			400,401	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/admin/logout-all [POST]
+func (h *AdminController) LogoutAll(c *gin.Context) {
+	admin := h.GetAdmin(c)
+	err := h.RevokeSubjectTokens(h.db, models.TokenSubjectAdmin, admin.Id)
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to revoke tokens")
+		h.log.Error("failed to revoke tokens", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, response{"success"})
+}
 
 // @Summary		  auth admin
 // @Description	   this api is for auth admin
@@ -121,8 +190,10 @@ func (h *AdminController) CreateAdmin(c *gin.Context) {
 // @Accept			json
 // @Produce			json
 // @Param			data 	body		models.AdminAuth	true	"data body"
-// @Success			201		{object}	models.TokenResponse
+// @Success			200		{object}	models.TokenResponse
+// @Success			202		{object}	models.TwoFactorChallenge
 // @Failure			400,409	{object}	response
+// @Failure			429		{object}	models.RetryAfterResponse
 // @Failure			500		{object}	response
 // @Router			/api/admin/auth [POST]
 func (h *AdminController) AuthAdmin(c *gin.Context) {
@@ -138,40 +209,50 @@ func (h *AdminController) AuthAdmin(c *gin.Context) {
 	err = h.db.First(admin, "username=? AND is_active=true AND deleted_at IS NUll", body.Username).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
+			h.recordAdminLogin(c, nil, body.Username, models.LoginResultUnknownUser)
 			newResponse(c, http.StatusBadRequest, "wrong username or password")
 			return
 		}
 		newResponse(c, http.StatusInternalServerError, "failed to find admin")
 		return
 	}
+	if !h.checkAdminLock(c, admin) {
+		return
+	}
 	err = h.hash.CheckPassword(admin.Password, body.Password)
 	if err != nil {
-		newResponse(c, http.StatusInternalServerError, "wrong password or username")
+		h.adminLoginFailed(c, admin, models.LoginResultWrongPassword)
+		newResponse(c, http.StatusBadRequest, "wrong username or password")
 		return
 	}
-	token, err := utils.CreateToken(time.Duration(time.Hour*720), admin.ID, h.cfg.AccessTokenPrivateKey)
+	required, err := h.roleRequiresTwoFactor(admin.RoleID)
 	if err != nil {
-		newResponse(c, http.StatusInternalServerError, err.Error())
-		h.log.Error("error while token", logger.Error(err))
+		newResponse(c, http.StatusInternalServerError, "failed to get role")
+		return
+	}
+	if admin.TotpEnabled || required {
+		challenge, err := h.createTwoFactorChallenge(admin.ID, !admin.TotpEnabled)
+		if err != nil {
+			newResponse(c, http.StatusInternalServerError, err.Error())
+			h.log.Error("error while token", logger.Error(err))
+			return
+		}
+		h.recordAdminLogin(c, admin, admin.Username, models.LoginResultTwoFactorRequired)
+		c.JSON(http.StatusAccepted, models.TwoFactorChallenge{
+			ChallengeToken:     challenge,
+			TwoFactorRequired:  true,
+			EnrollmentRequired: !admin.TotpEnabled,
+		})
 		return
 	}
-	var moduleItemKeys []string
-	err = h.db.Model(&models.RoleItems{}).Where("role_id=?", admin.RoleID).Find(&moduleItemKeys).Error
+	tokens, err := h.adminLoginTokens(c, h.db, admin)
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
+		h.log.Error("error while token", logger.Error(err))
 		return
 	}
-	// refreshToken, err := utils.CreateToken(time.Duration(time.Hour*720), admin.ID, h.cfg.RefreshTokenPrivateKey)
-	// if err != nil {
-	// 	newResponse(c, http.StatusInternalServerError, err.Error())
-	// 	h.log.Error("error while token", logger.Error(err))
-	// 	return
-	// }
-
-	c.JSON(http.StatusOK, models.TokenResponse{
-		AccessToken:    token,
-		ModuleItemKeys: moduleItemKeys,
-	})
+	h.adminLoginSucceeded(c, admin)
+	c.JSON(http.StatusOK, tokens)
 }
 
 // @Summary		  Update admin for superuser
@@ -183,15 +264,11 @@ func (h *AdminController) AuthAdmin(c *gin.Context) {
 // @Param           id     path    int    true    "admin  id"
 // @Param			data 	query		models.AdminsCreateRequest	true	"data body"
 // @Success			201		{object}	models.TokenResponse
-// @Failure			400,409	{object}	response
+// @Failure			400,403,404,409	{object}	response
 // @Failure			500		{object}	response
 // @Router			/api/admin/{id} [PUT]
 func (h *AdminController) UpdateAdmin(c *gin.Context) {
-	// user := h.GetAdmin(c)
-	// if !user.IsSuperuser {
-	// 	newResponse(c, http.StatusForbidden, "yor are not superuser")
-	// 	return
-	// }
+	user := h.GetAdmin(c)
 	inputId := c.Param("id")
 	id, err := strconv.Atoi(inputId)
 	if err != nil {
@@ -205,26 +282,58 @@ func (h *AdminController) UpdateAdmin(c *gin.Context) {
 		h.log.Error("failed to create category", err.Error())
 		return
 	}
-	hashed, err := h.hash.HashPassword(body.Password)
-	if err != nil {
-		newResponse(c, http.StatusBadRequest, "failed to hash password")
-		h.log.Error("error while hash password", logger.Error(err))
+	if body.IsSuperuser != nil && !user.IsSuperuser {
+		newResponse(c, http.StatusForbidden, "only superuser can grant superuser")
 		return
 	}
 	admin := &models.Admins{}
-	err = h.db.First(&admin, "id=?", id).Error
+	err = h.db.WithContext(c).First(&admin, "id=?", id).Error
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			newResponse(c, http.StatusNotFound, "not found admin")
+			return
+		}
 		newResponse(c, http.StatusInternalServerError, "failed to get admin")
+		h.log.Error("failed to get admin", err.Error())
+		return
+	}
+	if admin.IsSuperuser != nil && *admin.IsSuperuser && !user.IsSuperuser {
+		newResponse(c, http.StatusForbidden, "only superuser can update superuser")
 		return
 	}
-	admin.Username = body.Username
-	admin.Password = hashed
-	admin.RoleID = body.RoleID
+	if body.RoleID != 0 && body.RoleID != admin.RoleID {
+		if admin.ID == user.Id {
+			newResponse(c, http.StatusForbidden, "you can not change your own role")
+			return
+		}
+		if !h.canGrantRole(c, body.RoleID) {
+			return
+		}
+		admin.RoleID = body.RoleID
+	}
+	passwordChanged := body.Password != ""
+	if passwordChanged {
+		hashed, err := h.hashNewPassword(h.db.WithContext(c), models.TokenSubjectAdmin, admin.ID, admin.Password, body.Password)
+		if err != nil {
+			h.passwordError(c, err)
+			return
+		}
+		admin.Password = hashed
+	}
+	if body.Username != "" {
+		admin.Username = body.Username
+	}
 	if body.IsActive != nil {
 		admin.IsActive = body.IsActive
 	}
+	if body.IsSuperuser != nil {
+		admin.IsSuperuser = body.IsSuperuser
+	}
+	if body.Team != nil {
+		admin.Team = strings.TrimSpace(*body.Team)
+	}
 	admin.UpdatedAt = timeNow()
-	err = h.db.Save(&admin).Error
+	err = h.db.WithContext(c).Save(&admin).Error
 	if err != nil {
 		if strings.Contains(err.Error(), "duplicate key value violates unique ") {
 			newResponse(c, http.StatusBadRequest, "already exists username")
@@ -233,6 +342,18 @@ func (h *AdminController) UpdateAdmin(c *gin.Context) {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
+	if passwordChanged {
+		err = h.rememberPassword(h.db.WithContext(c), models.TokenSubjectAdmin, admin.ID, admin.Password)
+		if err != nil {
+			h.log.Error("failed to save password history", logger.Error(err))
+		}
+	}
+	if passwordChanged || (admin.IsActive != nil && !*admin.IsActive) {
+		err = h.RevokeSubjectTokens(h.db.WithContext(c), models.TokenSubjectAdmin, admin.ID)
+		if err != nil {
+			h.log.Error("failed to revoke admin tokens", err.Error())
+		}
+	}
 
 	c.JSON(http.StatusOK, admin)
 }
@@ -257,22 +378,26 @@ func (h *AdminController) UpdateMe(c *gin.Context) {
 		h.log.Error("failed to create category", err.Error())
 		return
 	}
-	hashed, err := h.hash.HashPassword(body.Password)
-	if err != nil {
-		newResponse(c, http.StatusBadRequest, "failed to hash password")
-		h.log.Error("error while hash password", logger.Error(err))
-		return
-	}
 	admin := &models.Admins{}
-	err = h.db.First(&admin, "id=?", user.Id).Error
+	err = h.db.WithContext(c).First(&admin, "id=?", user.Id).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, "failed to get admin")
 		return
 	}
-	admin.Username = body.Username
-	admin.Password = hashed
+	passwordChanged := body.Password != ""
+	if passwordChanged {
+		hashed, err := h.hashNewPassword(h.db.WithContext(c), models.TokenSubjectAdmin, admin.ID, admin.Password, body.Password)
+		if err != nil {
+			h.passwordError(c, err)
+			return
+		}
+		admin.Password = hashed
+	}
+	if body.Username != "" {
+		admin.Username = body.Username
+	}
 	admin.UpdatedAt = timeNow()
-	err = h.db.Save(&admin).Error
+	err = h.db.WithContext(c).Save(&admin).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrDuplicatedKey) {
 			newResponse(c, http.StatusBadRequest, "already exists username")
@@ -281,6 +406,12 @@ func (h *AdminController) UpdateMe(c *gin.Context) {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
+	if passwordChanged {
+		err = h.rememberPassword(h.db.WithContext(c), models.TokenSubjectAdmin, admin.ID, admin.Password)
+		if err != nil {
+			h.log.Error("failed to save password history", logger.Error(err))
+		}
+	}
 
 	c.JSON(http.StatusOK, admin)
 }
@@ -306,6 +437,31 @@ func (h *AdminController) GetAdmins(c *gin.Context) {
 	c.JSON(http.StatusOK, admins)
 }
 
+// lockManagedAdmin locks the admin changed by the request, only a superuser may change a superuser.
+// It rolls back tx and writes the response when the admin can not be changed.
+func (h *AdminController) lockManagedAdmin(c *gin.Context, tx *gorm.DB, id int) bool {
+	var admin models.Admins
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id, is_superuser").
+		Where("id=? AND deleted_at IS NULL", id).Limit(1).Find(&admin).Error
+	if err != nil {
+		tx.Rollback()
+		newResponse(c, http.StatusInternalServerError, "failed to get admin")
+		h.log.Error("failed to get admin", err.Error())
+		return false
+	}
+	if admin.ID == 0 {
+		tx.Rollback()
+		newResponse(c, http.StatusNotFound, "not found admin")
+		return false
+	}
+	if admin.IsSuperuser != nil && *admin.IsSuperuser && !h.GetAdmin(c).IsSuperuser {
+		tx.Rollback()
+		newResponse(c, http.StatusForbidden, "only superuser can change superuser")
+		return false
+	}
+	return true
+}
+
 // @Summary		  Deactivate admin
 // @Description	   this api is for deactivating admin
 // @Tags			Admin
@@ -314,26 +470,34 @@ func (h *AdminController) GetAdmins(c *gin.Context) {
 // @Produce			json
 // @Param           id    path   int    true  "admin id"
 // @Success			201		{object}	response
-// @Failure			400,409	{object}	response
+// @Failure			400,403,404,409	{object}	response
 // @Failure			500		{object}	response
 // @Router			/api/admin/deactivate/{id} [PUT]
 func (h *AdminController) DeactivateAdmin(c *gin.Context) {
-	// currentUser := h.GetAdmin(c)
-	// if !currentUser.IsSuperuser {
-	// 	newResponse(c, http.StatusForbidden, "your are not superuser")
-	// 	return
-	// }
-	id := c.Param("id")
-	if id == "" {
-		newResponse(c, http.StatusBadRequest, "missing id")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, "Invalid id")
+		return
+	}
+	tx := h.db.WithContext(c).Begin()
+	if !h.lockManagedAdmin(c, tx, id) {
 		return
 	}
-	err := h.db.Model(&models.Admins{}).Where("id = ?", id).UpdateColumn("is_active", false).Error
+	err = tx.Model(&models.Admins{}).Where("id = ?", id).UpdateColumn("is_active", false).Error
 	if err != nil {
+		tx.Rollback()
 		h.log.Error("failed to update admins", err.Error())
 		newResponse(c, http.StatusInternalServerError, "failed to update admin status")
 		return
 	}
+	err = h.RevokeSubjectTokens(tx, models.TokenSubjectAdmin, id)
+	if err != nil {
+		tx.Rollback()
+		h.log.Error("failed to revoke admin tokens", err.Error())
+		newResponse(c, http.StatusInternalServerError, "failed to revoke admin tokens")
+		return
+	}
+	tx.Commit()
 	c.JSON(http.StatusOK, response{"success"})
 }
 
@@ -345,26 +509,27 @@ func (h *AdminController) DeactivateAdmin(c *gin.Context) {
 // @Produce			json
 // @Param           id    path   int    true  "admin id"
 // @Success			201		{object}	response
-// @Failure			400,409	{object}	response
+// @Failure			400,403,404,409	{object}	response
 // @Failure			500		{object}	response
 // @Router			/api/admin/activate/{id} [PUT]
 func (h *AdminController) ActivateAdmin(c *gin.Context) {
-	// currentUser := h.GetAdmin(c)
-	// if !currentUser.IsSuperuser {
-	// 	newResponse(c, http.StatusForbidden, "your are not superuser")
-	// 	return
-	// }
-	id := c.Param("id")
-	if id == "" {
-		newResponse(c, http.StatusBadRequest, "missing id")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, "Invalid id")
+		return
+	}
+	tx := h.db.WithContext(c).Begin()
+	if !h.lockManagedAdmin(c, tx, id) {
 		return
 	}
-	err := h.db.Model(&models.Admins{}).Where("id = ?", id).UpdateColumn("is_active", true).Error
+	err = tx.Model(&models.Admins{}).Where("id = ?", id).UpdateColumn("is_active", true).Error
 	if err != nil {
+		tx.Rollback()
 		h.log.Error("failed to update admins", err.Error())
 		newResponse(c, http.StatusInternalServerError, "failed to update admin status")
 		return
 	}
+	tx.Commit()
 	c.JSON(http.StatusOK, response{"success"})
 }
 
@@ -376,29 +541,37 @@ func (h *AdminController) ActivateAdmin(c *gin.Context) {
 // @Produce			json
 // @Param           id    path   int    true  "admin id"
 // @Success			201		{object}	response
-// @Failure			400,409	{object}	response
+// @Failure			400,403,404,409	{object}	response
 // @Failure			500		{object}	response
 // @Router			/api/admin/{id} [DELETE]
 func (h *AdminController) DeleteAdmin(c *gin.Context) {
-	// currentUser := h.GetAdmin(c)
-	// if !currentUser.IsSuperuser {
-	// 	newResponse(c, http.StatusForbidden, "your are not superuser")
-	// 	return
-	// }
-	id := c.Param("id")
-	if id == "" {
-		newResponse(c, http.StatusBadRequest, "missing id")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, "Invalid id")
 		return
 	}
 	columns := map[string]interface{}{
 		"deleted_at": timeNow(),
 		"is_active":  false,
 	}
-	err := h.db.Model(&models.Admins{}).Where("id = ?", id).Updates(columns).Error
+	tx := h.db.WithContext(c).Begin()
+	if !h.lockManagedAdmin(c, tx, id) {
+		return
+	}
+	err = tx.Model(&models.Admins{}).Where("id = ?", id).Updates(columns).Error
 	if err != nil {
+		tx.Rollback()
 		newResponse(c, http.StatusInternalServerError, "failed to update admin status")
 		return
 	}
+	err = h.RevokeSubjectTokens(tx, models.TokenSubjectAdmin, id)
+	if err != nil {
+		tx.Rollback()
+		h.log.Error("failed to revoke admin tokens", err.Error())
+		newResponse(c, http.StatusInternalServerError, "failed to revoke admin tokens")
+		return
+	}
+	tx.Commit()
 
 	c.JSON(http.StatusOK, response{"success"})
 }
@@ -422,15 +595,20 @@ func (h *AdminController) GetMe(c *gin.Context) {
 		h.log.Error("failed to get admin", err.Error())
 		return
 	}
-	var moduleItemKeys []string
-	err = h.db.Model(&models.RoleItems{}).Where("role_id=?", admins.RoleID).Find(&moduleItemKeys).Error
+	var permissions []models.AdminPermission
+	if admins.IsSuperuser != nil && *admins.IsSuperuser {
+		err = h.db.Model(&models.ModuleItems{}).Select("key, end_point, method").Order("key").Scan(&permissions).Error
+	} else {
+		permissions, err = h.loadRolePermissions(admins.RoleID)
+	}
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 	c.JSON(http.StatusOK, models.AdminResponse{
 		Admins:         admins,
-		ModuleItemKeys: moduleItemKeys,
+		ModuleItemKeys: permissionKeys(permissions),
+		Permissions:    permissions,
 	})
 }
 
@@ -446,10 +624,8 @@ func (h *AdminController) GetMe(c *gin.Context) {
 // @Failure			500		{object}	response
 // @Router			/api/admin/{id} [GET]
 func (h *AdminController) GetAdminById(c *gin.Context) {
-	// admin := h.GetAdmin(c)
 	id := c.Param("id")
 	var admins models.Admins
-	// if admin.IsSuperuser {
 	err := h.db.First(&admins, "id=?", id).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -460,10 +636,6 @@ func (h *AdminController) GetAdminById(c *gin.Context) {
 		h.log.Error("failed to get admin", err.Error())
 		return
 	}
-	// } else {
-	// 	newResponse(c, http.StatusForbidden, "you are not superuser")
-	// 	return
-	// }
 
 	c.JSON(http.StatusOK, admins)
 }
@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Asliddin3/energy-maximum/models"
+	"github.com/Asliddin3/energy-maximum/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// recordAdminLogin writes the login attempt, admin is nil when the username is unknown.
+func (h *Handler) recordAdminLogin(c *gin.Context, admin *models.Admins, username, result string) {
+	attempt := models.AdminLoginAttempts{
+		Username:  username,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Result:    result,
+		CreatedAt: timeNow(),
+	}
+	if admin != nil {
+		attempt.AdminID = &admin.ID
+		attempt.Username = admin.Username
+	}
+	if userAgent := []rune(attempt.UserAgent); len(userAgent) > 500 {
+		attempt.UserAgent = string(userAgent[:500])
+	}
+	err := h.db.Create(&attempt).Error
+	if err != nil {
+		h.log.Error("failed to save admin login attempt", logger.Error(err))
+	}
+}
+
+// adminLockDuration doubles the lock for every failure after the threshold.
+func (h *Handler) adminLockDuration(failures int) time.Duration {
+	if failures < h.cfg.AdminLockThreshold {
+		return 0
+	}
+	duration := h.cfg.AdminLockDuration
+	for i := h.cfg.AdminLockThreshold; i < failures && duration < h.cfg.AdminLockMaxDuration; i++ {
+		duration *= 2
+	}
+	if duration > h.cfg.AdminLockMaxDuration {
+		duration = h.cfg.AdminLockMaxDuration
+	}
+	return duration
+}
+
+// adminLoginFailed counts the failure and locks the admin when there are too many failures in a row.
+func (h *Handler) adminLoginFailed(c *gin.Context, admin *models.Admins, result string) {
+	h.recordAdminLogin(c, admin, admin.Username, result)
+	var updated models.Admins
+	err := h.db.Clauses(clause.Returning{Columns: []clause.Column{{Name: "failed_logins"}}}).Model(&updated).
+		Where("id=?", admin.ID).UpdateColumn("failed_logins", gorm.Expr("failed_logins+1")).Error
+	if err != nil {
+		h.log.Error("failed to count admin login failure", logger.Error(err))
+		return
+	}
+	duration := h.adminLockDuration(updated.FailedLogins)
+	if duration == 0 {
+		return
+	}
+	err = h.db.Model(&models.Admins{}).Where("id=?", admin.ID).UpdateColumn("locked_until", time.Now().Add(duration)).Error
+	if err != nil {
+		h.log.Error("failed to lock admin", logger.Error(err))
+		return
+	}
+	h.log.Warnf("admin %s is locked for %s after %d failed logins, ip %s", admin.Username, duration, updated.FailedLogins, c.ClientIP())
+}
+
+// adminLoginSucceeded clears failure counter of the admin.
+func (h *Handler) adminLoginSucceeded(c *gin.Context, admin *models.Admins) {
+	h.recordAdminLogin(c, admin, admin.Username, models.LoginResultSuccess)
+	if admin.FailedLogins == 0 && admin.LockedUntil == nil {
+		return
+	}
+	err := h.db.Model(&models.Admins{}).Where("id=?", admin.ID).
+		UpdateColumns(map[string]interface{}{"failed_logins": 0, "locked_until": nil}).Error
+	if err != nil {
+		h.log.Error("failed to reset admin login failures", logger.Error(err))
+	}
+}
+
+// checkAdminLock writes 429 response and returns false when the admin is locked.
+func (h *Handler) checkAdminLock(c *gin.Context, admin *models.Admins) bool {
+	if admin.LockedUntil == nil || !admin.LockedUntil.After(time.Now()) {
+		return true
+	}
+	h.recordAdminLogin(c, admin, admin.Username, models.LoginResultLocked)
+	retryAfter(c, *admin.LockedUntil, "account is locked after failed logins, try later")
+	return false
+}
+
+// @Summary		  Unlock admin
+// @Description	   this api clears failed logins and lock of admin
+// @Tags			Admin
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id     path    int    true    "admin  id"
+// @Success			200		{object}	response
+// @Failure			400,404	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/admin/unlock/{id} [PUT]
+func (h *AdminController) UnlockAdmin(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, "Invalid id")
+		return
+	}
+	result := h.db.WithContext(c).Model(&models.Admins{}).Where("id=? AND deleted_at IS NULL", id).
+		UpdateColumns(map[string]interface{}{"failed_logins": 0, "locked_until": nil})
+	if result.Error != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to unlock admin")
+		h.log.Error("failed to unlock admin", logger.Error(result.Error))
+		return
+	}
+	if result.RowsAffected == 0 {
+		newResponse(c, http.StatusNotFound, "not found admin")
+		return
+	}
+	h.log.Infof("admin %d is unlocked by admin %d", id, h.GetAdmin(c).Id)
+	c.JSON(http.StatusOK, response{"success"})
+}
+
+// @Summary		  Get admin login attempts
+// @Description	   this api returns recent admin logins, newest first
+// @Tags			Admin
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param			filter 	query		models.AdminLoginAttemptFilter	false	"filter"
+// @Success			200		{object}	models.AdminLoginAttemptResponse
+// @Failure			400,409	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/admin/login-attempts [GET]
+func (h *AdminController) GetLoginAttempts(c *gin.Context) {
+	var body models.AdminLoginAttemptFilter
+	err := c.ShouldBindQuery(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.Page == 0 {
+		body.Page = 1
+	}
+	if body.PageSize == 0 {
+		body.PageSize = 10
+	}
+	db := h.db.Model(&models.AdminLoginAttempts{})
+	if body.AdminID != 0 {
+		db = db.Where("admin_id=?", body.AdminID)
+	}
+	if body.Username != "" {
+		db = db.Where("LOWER(username) LIKE LOWER(?)", fmt.Sprintf("%%%s%%", body.Username))
+	}
+	if body.IP != "" {
+		db = db.Where("ip=?", body.IP)
+	}
+	if body.Result != "" {
+		db = db.Where("result=?", body.Result)
+	}
+	if body.DateFrom != "" {
+		db = db.Where("created_at>=?", body.DateFrom)
+	}
+	if body.DateTo != "" {
+		db = db.Where("created_at<=?", body.DateTo)
+	}
+	var count int64
+	err = db.Count(&count).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get login attempts")
+		h.log.Error("failed to count login attempts", err.Error())
+		return
+	}
+	var attempts []models.AdminLoginAttempts
+	err = db.Order("id DESC").Limit(body.PageSize).Offset((body.Page - 1) * body.PageSize).Find(&attempts).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get login attempts")
+		h.log.Error("failed to get login attempts", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, models.AdminLoginAttemptResponse{
+		Page:     body.Page,
+		PageSize: body.PageSize,
+		Count:    int(count),
+		Attempts: attempts,
+	})
+}
@@ -18,7 +18,7 @@ type AnalogController struct {
 
 func (h *Handler) NewAnalogController(api *gin.RouterGroup) {
 	analog := &AnalogController{h}
-	analogProd := api.Group("analog", h.DeserializeAdmin())
+	analogProd := h.adminGroup(api, "analog")
 	{
 		analogProd.POST("", analog.CreateAnalog)
 		analogProd.PUT("/:id", analog.UpdateAnalog)
@@ -59,7 +59,7 @@ func (h *AnalogController) CreateAnalog(c *gin.Context) {
 		CreatedID: &admin.Id,
 		CreatedAt: timeNow(),
 	}
-	err = h.db.Clauses(clause.Returning{}).Create(&news).Error
+	err = h.db.WithContext(c).Clauses(clause.Returning{}).Create(&news).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -104,7 +104,7 @@ func (h *AnalogController) UpdateAnalog(c *gin.Context) {
 	columns["updated_at"] = timeNow()
 	columns["updated_id"] = admin.Id
 	analog := &models.Analog{}
-	err = h.db.Clauses(clause.Returning{}).Model(&analog).
+	err = h.db.WithContext(c).Clauses(clause.Returning{}).Model(&analog).
 		Where("id=?", analogId).Updates(columns).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
@@ -186,7 +186,7 @@ func (h *AnalogController) GetByID(c *gin.Context) {
 func (h *AnalogController) DeleteAnalog(c *gin.Context) {
 	inputId := c.Param("id")
 	var analog models.Analog
-	err := h.db.Delete(&analog, "id=?", inputId).Error
+	err := h.db.WithContext(c).Delete(&analog, "id=?", inputId).Error
 	if err != nil {
 		newResponse(c, http.StatusBadRequest, err.Error())
 		return
@@ -248,7 +248,7 @@ func (h *AnalogController) AddAnalogProducts(c *gin.Context) {
 			ProductID: int(prodId),
 		}
 	}
-	err := h.db.Create(&items).Error
+	err := h.db.WithContext(c).Create(&items).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		h.log.Error("failed to create items", err.Error())
@@ -279,7 +279,7 @@ func (h *AnalogController) DeleteAnalogProducts(c *gin.Context) {
 	}
 	prodArr := strings.Split(products, ",")
 
-	err := h.db.Delete(&models.AnalogProduct{}, "analog_id=? AND product_id IN (?)", id, prodArr).Error
+	err := h.db.WithContext(c).Delete(&models.AnalogProduct{}, "analog_id=? AND product_id IN (?)", id, prodArr).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		h.log.Error("failed to delete items", err.Error())
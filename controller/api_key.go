@@ -0,0 +1,455 @@
+package controller
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Asliddin3/energy-maximum/models"
+	"github.com/Asliddin3/energy-maximum/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+type ApiKeyController struct {
+	*Handler
+}
+
+func (h *Handler) NewApiKeyController(api *gin.RouterGroup) {
+	keyContr := &ApiKeyController{h}
+	keys := h.adminGroup(api, "api-key")
+	{
+		keys.POST("", keyContr.CreateApiKey)
+		keys.GET("", keyContr.GetApiKeys)
+		keys.GET("/:id", keyContr.GetApiKeyById)
+		keys.POST("/rotate/:id", keyContr.RotateApiKey)
+		keys.PUT("/revoke/:id", keyContr.RevokeApiKey)
+	}
+}
+
+func hashApiKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// newApiKeySecret returns public prefix and secret, the key given to the client is "<prefix>.<secret>".
+func newApiKeySecret() (string, string, error) {
+	random := make([]byte, 36)
+	_, err := rand.Read(random)
+	if err != nil {
+		return "", "", err
+	}
+	return "em_" + hex.EncodeToString(random[:6]), base64.RawURLEncoding.EncodeToString(random[6:]), nil
+}
+
+// parseAllowedIPs validates ip addresses and CIDR ranges and joins them for storing.
+func parseAllowedIPs(list []string) (string, error) {
+	allowed := make([]string, 0, len(list))
+	for _, entry := range list {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			_, _, err := net.ParseCIDR(entry)
+			if err != nil {
+				return "", fmt.Errorf("invalid ip range %s", entry)
+			}
+		} else if net.ParseIP(entry) == nil {
+			return "", fmt.Errorf("invalid ip %s", entry)
+		}
+		allowed = append(allowed, entry)
+	}
+	return strings.Join(allowed, ","), nil
+}
+
+func ipAllowed(allowedIPs, clientIP string) bool {
+	if allowedIPs == "" {
+		return true
+	}
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+	for _, entry := range strings.Split(allowedIPs, ",") {
+		if strings.Contains(entry, "/") {
+			_, ipNet, err := net.ParseCIDR(entry)
+			if err == nil && ipNet.Contains(ip) {
+				return true
+			}
+		} else if allowed := net.ParseIP(entry); allowed != nil && allowed.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticateApiKey sets metadata of the key owner to the context and limits permissions of the
+// request to module items of the key. It aborts the request and returns false when the key is not valid.
+func (h *Handler) authenticateApiKey(ctx *gin.Context) bool {
+	prefix, secret, _ := strings.Cut(strings.TrimSpace(ctx.GetHeader(apiKeyHeader)), ".")
+	var key models.ApiKeys
+	err := h.db.First(&key, "prefix=? AND revoked_at IS NULL", prefix).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": "invalid api key"})
+			return false
+		}
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(hashApiKeySecret(secret)), []byte(key.SecretHash)) != 1 {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": "invalid api key"})
+		return false
+	}
+	if key.ExpiresAt != nil && key.ExpiresAt.Before(time.Now()) {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": "api key is expired"})
+		return false
+	}
+	if !ipAllowed(key.AllowedIPs, ctx.ClientIP()) {
+		h.log.Warnf("api key %s is used from not allowed ip %s", key.Prefix, ctx.ClientIP())
+		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": "fail", "message": "ip address is not allowed for this api key"})
+		return false
+	}
+	var owner models.Admins
+	err = h.db.First(&owner, "id=? AND deleted_at IS NULL AND is_active=true", key.OwnerID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": "owner of api key is not active"})
+			return false
+		}
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return false
+	}
+	// items of the key are limited by the current role of the owner, so taking a permission from the owner
+	// takes it from the key too
+	permissions := make([]models.AdminPermission, 0)
+	query := h.db.Table("api_key_items aki").
+		Select("mi.key, mi.end_point, mi.method").
+		Joins("JOIN module_items mi ON mi.key=aki.module_item_key").
+		Where("aki.api_key_id=?", key.ID)
+	if owner.IsSuperuser == nil || !*owner.IsSuperuser {
+		query = query.Where("aki.module_item_key IN (SELECT ri.module_item_key FROM role_items ri WHERE ri.role_id IN ("+roleChainQuery+"))",
+			owner.RoleID, maxRoleDepth)
+	}
+	err = query.Scan(&permissions).Error
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return false
+	}
+	err = h.db.Model(&models.ApiKeys{}).Where("id=?", key.ID).
+		UpdateColumns(map[string]interface{}{"last_used_at": time.Now(), "last_used_ip": ctx.ClientIP()}).Error
+	if err != nil {
+		h.log.Error("failed to update api key usage", logger.Error(err))
+	}
+	ctx.Set(adminPermissionsKey, permissions)
+	ctx.Set("admin", models.AdminMetadata{
+		Id:       owner.ID,
+		RoleID:   owner.RoleID,
+		ApiKeyID: key.ID,
+	})
+	return true
+}
+
+// checkApiKeyItems returns the first key the admin can not grant, keys of api key can not exceed admin permissions.
+func (h *Handler) checkApiKeyItems(c *gin.Context, keys []string) (string, error) {
+	var existing []string
+	err := h.db.Model(&models.ModuleItems{}).Where("key IN ?", keys).Pluck("key", &existing).Error
+	if err != nil {
+		return "", err
+	}
+	found := make(map[string]bool, len(existing))
+	for _, key := range existing {
+		found[key] = true
+	}
+	for _, key := range keys {
+		if !found[key] {
+			return key, nil
+		}
+		allowed, err := h.HasPermission(c, key)
+		if err != nil {
+			return "", err
+		}
+		if !allowed {
+			return key, nil
+		}
+	}
+	return "", nil
+}
+
+// getManagedApiKey loads the key, admins manage only own keys and superuser manages every key.
+func (h *ApiKeyController) getManagedApiKey(c *gin.Context) (*models.ApiKeys, bool) {
+	currentUser := h.GetAdmin(c)
+	if currentUser.ApiKeyID != 0 {
+		newResponse(c, http.StatusForbidden, "api keys can not be managed with api key")
+		return nil, false
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, "Invalid id")
+		return nil, false
+	}
+	var key models.ApiKeys
+	db := h.db.Preload("Items")
+	if !currentUser.IsSuperuser {
+		db = db.Where("owner_id=?", currentUser.Id)
+	}
+	err = db.First(&key, "id=?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			newResponse(c, http.StatusNotFound, "api key not found")
+			return nil, false
+		}
+		newResponse(c, http.StatusInternalServerError, "failed to get api key")
+		h.log.Error("failed to get api key", err.Error())
+		return nil, false
+	}
+	return &key, true
+}
+
+// @Summary		  Create api key
+// @Description	   this api creates api key owned by current admin, the key is shown only once. Send it in X-API-Key header
+// @Tags			ApiKey
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param			data 	body		models.ApiKeyRequest	true	"data body"
+// @Success			200		{object}	models.ApiKeySecretResponse
+// @Failure			400,403	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/api-key [POST]
+func (h *ApiKeyController) CreateApiKey(c *gin.Context) {
+	currentUser := h.GetAdmin(c)
+	if currentUser.ApiKeyID != 0 {
+		newResponse(c, http.StatusForbidden, "api keys can not be managed with api key")
+		return
+	}
+	var body models.ApiKeyRequest
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.ExpiresAt != nil && body.ExpiresAt.Before(time.Now()) {
+		newResponse(c, http.StatusBadRequest, "expires_at must be in the future")
+		return
+	}
+	allowedIPs, err := parseAllowedIPs(body.AllowedIPs)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	denied, err := h.checkApiKeyItems(c, body.ModuleItemKeys)
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to check permissions")
+		h.log.Error("failed to check api key items", err.Error())
+		return
+	}
+	if denied != "" {
+		newResponse(c, http.StatusForbidden, fmt.Sprintf("you can not grant %s", denied))
+		return
+	}
+	prefix, secret, err := newApiKeySecret()
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to create api key")
+		return
+	}
+	key := models.ApiKeys{
+		Name:       body.Name,
+		Prefix:     prefix,
+		SecretHash: hashApiKeySecret(secret),
+		OwnerID:    currentUser.Id,
+		AllowedIPs: allowedIPs,
+		ExpiresAt:  body.ExpiresAt,
+		CreatedID:  &currentUser.Id,
+		CreatedAt:  timeNow(),
+	}
+	tx := h.db.WithContext(c).Begin()
+	err = tx.Create(&key).Error
+	if err == nil && len(body.ModuleItemKeys) > 0 {
+		items := make([]models.ApiKeyItems, 0, len(body.ModuleItemKeys))
+		for _, itemKey := range body.ModuleItemKeys {
+			items = append(items, models.ApiKeyItems{ApiKeyID: key.ID, ModuleItemKey: itemKey})
+		}
+		err = tx.Create(&items).Error
+		key.Items = items
+	}
+	if err != nil {
+		tx.Rollback()
+		newResponse(c, http.StatusInternalServerError, "failed to create api key")
+		h.log.Error("failed to create api key", err.Error())
+		return
+	}
+	tx.Commit()
+	c.JSON(http.StatusOK, models.ApiKeySecretResponse{
+		ApiKey: &key,
+		Key:    prefix + "." + secret,
+	})
+}
+
+// @Summary		  Get api keys
+// @Description	   this api returns api keys, superuser sees keys of every admin
+// @Tags			ApiKey
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param			filter 	query		models.ApiKeyFilter	false	"filter"
+// @Success			200		{object}	models.ApiKeyListResponse
+// @Failure			400,409	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/api-key [GET]
+func (h *ApiKeyController) GetApiKeys(c *gin.Context) {
+	currentUser := h.GetAdmin(c)
+	var body models.ApiKeyFilter
+	err := c.ShouldBindQuery(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.Page == 0 {
+		body.Page = 1
+	}
+	if body.PageSize == 0 {
+		body.PageSize = 10
+	}
+	db := h.db.Model(&models.ApiKeys{})
+	if !currentUser.IsSuperuser {
+		db = db.Where("owner_id=?", currentUser.Id)
+	} else if body.OwnerID != 0 {
+		db = db.Where("owner_id=?", body.OwnerID)
+	}
+	if !body.WithRevoked {
+		db = db.Where("revoked_at IS NULL")
+	}
+	var count int64
+	err = db.Count(&count).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get api keys")
+		h.log.Error("failed to count api keys", err.Error())
+		return
+	}
+	var keys []models.ApiKeys
+	err = db.Preload("Items").Order("id DESC").Limit(body.PageSize).Offset((body.Page - 1) * body.PageSize).Find(&keys).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get api keys")
+		h.log.Error("failed to get api keys", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, models.ApiKeyListResponse{
+		Page:     body.Page,
+		PageSize: body.PageSize,
+		Count:    int(count),
+		Keys:     keys,
+	})
+}
+
+// @Summary		  Get api key by id
+// @Description	   this api returns api key without secret
+// @Tags			ApiKey
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "id"
+// @Success			200		{object}	models.ApiKeys
+// @Failure			400,404	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/api-key/{id} [GET]
+func (h *ApiKeyController) GetApiKeyById(c *gin.Context) {
+	key, ok := h.getManagedApiKey(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, key)
+}
+
+// @Summary		  Rotate api key
+// @Description	   this api replaces secret of api key, the old key stops working at once. The new key is shown only once
+// @Tags			ApiKey
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "id"
+// @Success			200		{object}	models.ApiKeySecretResponse
+// @Failure			400,404	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/api-key/rotate/{id} [POST]
+func (h *ApiKeyController) RotateApiKey(c *gin.Context) {
+	currentUser := h.GetAdmin(c)
+	key, ok := h.getManagedApiKey(c)
+	if !ok {
+		return
+	}
+	if key.RevokedAt != nil {
+		newResponse(c, http.StatusBadRequest, "api key is revoked")
+		return
+	}
+	prefix, secret, err := newApiKeySecret()
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to rotate api key")
+		return
+	}
+	key.Prefix = prefix
+	key.SecretHash = hashApiKeySecret(secret)
+	key.UpdatedID = &currentUser.Id
+	key.UpdatedAt = timeNow()
+	err = h.db.WithContext(c).Model(key).UpdateColumns(map[string]interface{}{
+		"prefix":      key.Prefix,
+		"secret_hash": key.SecretHash,
+		"updated_id":  key.UpdatedID,
+		"updated_at":  key.UpdatedAt,
+	}).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to rotate api key")
+		h.log.Error("failed to rotate api key", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, models.ApiKeySecretResponse{
+		ApiKey: key,
+		Key:    prefix + "." + secret,
+	})
+}
+
+// @Summary		  Revoke api key
+// @Description	   this api revokes api key, it can not be used again
+// @Tags			ApiKey
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "id"
+// @Success			200		{object}	response
+// @Failure			400,404	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/api-key/revoke/{id} [PUT]
+func (h *ApiKeyController) RevokeApiKey(c *gin.Context) {
+	currentUser := h.GetAdmin(c)
+	key, ok := h.getManagedApiKey(c)
+	if !ok {
+		return
+	}
+	if key.RevokedAt != nil {
+		c.JSON(http.StatusOK, response{"success"})
+		return
+	}
+	err := h.db.WithContext(c).Model(key).UpdateColumns(map[string]interface{}{
+		"revoked_at": timeNow(),
+		"updated_id": currentUser.Id,
+		"updated_at": timeNow(),
+	}).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to revoke api key")
+		h.log.Error("failed to revoke api key", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, response{"success"})
+}
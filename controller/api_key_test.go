@@ -0,0 +1,49 @@
+package controller
+
+import "testing"
+
+func TestParseAllowedIPs(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    []string
+		want    string
+		wantErr bool
+	}{
+		{"empty", nil, "", false},
+		{"ips and ranges", []string{" 10.0.0.1 ", "", "192.168.0.0/24", "::1"}, "10.0.0.1,192.168.0.0/24,::1", false},
+		{"invalid ip", []string{"10.0.0.256"}, "", true},
+		{"invalid range", []string{"10.0.0.0/33"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAllowedIPs(tt.list)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("parseAllowedIPs() = %q, %v, want %q, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIpAllowed(t *testing.T) {
+	tests := []struct {
+		name     string
+		allowed  string
+		clientIP string
+		want     bool
+	}{
+		{"no restriction", "", "203.0.113.5", true},
+		{"listed ip", "10.0.0.1,203.0.113.5", "203.0.113.5", true},
+		{"ip in range", "192.168.0.0/24", "192.168.0.77", true},
+		{"ip outside range", "192.168.0.0/24", "192.168.1.1", false},
+		{"other ip", "10.0.0.1", "10.0.0.2", false},
+		{"ipv6", "2001:db8::/32", "2001:db8::1", true},
+		{"client ip is not parsed", "10.0.0.1", "unknown", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ipAllowed(tt.allowed, tt.clientIP); got != tt.want {
+				t.Errorf("ipAllowed(%q, %q) = %v, want %v", tt.allowed, tt.clientIP, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,347 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/Asliddin3/energy-maximum/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	auditOldRowsKey = "audit:old_rows"
+	// auditMaxRows limits rows loaded for diff of a single bulk update or delete.
+	auditMaxRows = 1000
+)
+
+// auditSensitiveColumns are written to the log as masked values.
+var auditSensitiveColumns = []string{"password", "secret", "hash", "token"}
+
+type AuditController struct {
+	*Handler
+}
+
+func (h *Handler) NewAuditController(api *gin.RouterGroup) {
+	audit := &AuditController{h}
+	auditHandler := h.adminGroup(api, "audit-log")
+	{
+		auditHandler.GET("", audit.GetAuditLogs)
+	}
+}
+
+// registerAuditCallbacks logs creates, updates and deletes of statements which carry admin request
+// in context, handlers pass it with h.db.WithContext(c).
+func (h *Handler) registerAuditCallbacks() error {
+	callback := h.db.Callback()
+	err := callback.Create().After("gorm:create").Register("audit:after_create", h.auditAfterCreate)
+	if err != nil {
+		return err
+	}
+	err = callback.Update().Before("gorm:update").Register("audit:before_update", h.auditBeforeChange)
+	if err != nil {
+		return err
+	}
+	err = callback.Update().After("gorm:update").Register("audit:after_update", h.auditAfterUpdate)
+	if err != nil {
+		return err
+	}
+	err = callback.Delete().Before("gorm:delete").Register("audit:before_delete", h.auditBeforeChange)
+	if err != nil {
+		return err
+	}
+	return callback.Delete().After("gorm:delete").Register("audit:after_delete", h.auditAfterDelete)
+}
+
+// auditActor returns admin of the request, false means the statement is not audited.
+func auditActor(db *gorm.DB) (models.AdminMetadata, bool) {
+	stmt := db.Statement
+	if db.Error != nil || db.DryRun || stmt.Context == nil || stmt.Schema == nil || stmt.Schema.PrioritizedPrimaryField == nil {
+		return models.AdminMetadata{}, false
+	}
+	if stmt.Table == "" || stmt.Table == "audit_logs" {
+		return models.AdminMetadata{}, false
+	}
+	admin, ok := stmt.Context.Value("admin").(models.AdminMetadata)
+	return admin, ok
+}
+
+// auditSession runs on connection of the statement, so changes inside transaction are seen and
+// log entries are rolled back with the transaction.
+func auditSession(db *gorm.DB) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Table(db.Statement.Table)
+}
+
+// auditPrimaryKeys returns primary keys filled in the model or dest of the statement.
+func auditPrimaryKeys(db *gorm.DB) []interface{} {
+	stmt := db.Statement
+	field := stmt.Schema.PrioritizedPrimaryField
+	keys := make([]interface{}, 0)
+	value := reflect.Indirect(stmt.ReflectValue)
+	switch value.Kind() {
+	case reflect.Struct:
+		if key, isZero := field.ValueOf(stmt.Context, value); !isZero {
+			keys = append(keys, key)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			item := reflect.Indirect(value.Index(i))
+			if item.Kind() != reflect.Struct {
+				continue
+			}
+			if key, isZero := field.ValueOf(stmt.Context, item); !isZero {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+func auditLoadRows(db *gorm.DB, keys []interface{}) ([]map[string]interface{}, error) {
+	rows := make([]map[string]interface{}, 0)
+	if len(keys) == 0 {
+		return rows, nil
+	}
+	err := auditSession(db).Where(clause.IN{Column: db.Statement.Schema.PrioritizedPrimaryField.DBName, Values: keys}).
+		Find(&rows).Error
+	return rows, err
+}
+
+// auditBeforeChange keeps rows matched by the update or delete to compare them afterwards.
+func (h *Handler) auditBeforeChange(db *gorm.DB) {
+	if _, ok := auditActor(db); !ok {
+		return
+	}
+	query := auditSession(db)
+	conditions := 0
+	if where, ok := db.Statement.Clauses["WHERE"]; ok {
+		if exprs, ok := where.Expression.(clause.Where); ok && len(exprs.Exprs) > 0 {
+			query = query.Clauses(exprs)
+			conditions++
+		}
+	}
+	if keys := auditPrimaryKeys(db); len(keys) > 0 {
+		query = query.Where(clause.IN{Column: db.Statement.Schema.PrioritizedPrimaryField.DBName, Values: keys})
+		conditions++
+	}
+	if conditions == 0 {
+		return
+	}
+	rows := make([]map[string]interface{}, 0)
+	err := query.Limit(auditMaxRows).Find(&rows).Error
+	if err != nil {
+		h.log.Error("failed to load rows for audit log", err.Error())
+		return
+	}
+	db.InstanceSet(auditOldRowsKey, rows)
+}
+
+func (h *Handler) auditAfterCreate(db *gorm.DB) {
+	admin, ok := auditActor(db)
+	if !ok {
+		return
+	}
+	rows, err := auditLoadRows(db, auditPrimaryKeys(db))
+	if err != nil {
+		h.log.Error("failed to load rows for audit log", err.Error())
+		return
+	}
+	logs := make([]models.AuditLogs, 0, len(rows))
+	for _, row := range rows {
+		logs = h.appendAuditLog(db, logs, admin, models.AuditActionCreate, nil, row)
+	}
+	h.saveAuditLogs(db, logs)
+}
+
+func (h *Handler) auditAfterUpdate(db *gorm.DB) {
+	admin, ok := auditActor(db)
+	if !ok || db.RowsAffected == 0 {
+		return
+	}
+	oldRows := auditOldRows(db)
+	pk := db.Statement.Schema.PrioritizedPrimaryField.DBName
+	keys := make([]interface{}, 0, len(oldRows))
+	for _, row := range oldRows {
+		keys = append(keys, row[pk])
+	}
+	newRows, err := auditLoadRows(db, keys)
+	if err != nil {
+		h.log.Error("failed to load rows for audit log", err.Error())
+		return
+	}
+	byKey := make(map[string]map[string]interface{}, len(newRows))
+	for _, row := range newRows {
+		byKey[fmt.Sprint(row[pk])] = row
+	}
+	logs := make([]models.AuditLogs, 0, len(oldRows))
+	for _, row := range oldRows {
+		if newRow, ok := byKey[fmt.Sprint(row[pk])]; ok {
+			logs = h.appendAuditLog(db, logs, admin, models.AuditActionUpdate, row, newRow)
+		}
+	}
+	h.saveAuditLogs(db, logs)
+}
+
+func (h *Handler) auditAfterDelete(db *gorm.DB) {
+	admin, ok := auditActor(db)
+	if !ok || db.RowsAffected == 0 {
+		return
+	}
+	oldRows := auditOldRows(db)
+	logs := make([]models.AuditLogs, 0, len(oldRows))
+	for _, row := range oldRows {
+		logs = h.appendAuditLog(db, logs, admin, models.AuditActionDelete, row, nil)
+	}
+	h.saveAuditLogs(db, logs)
+}
+
+func auditOldRows(db *gorm.DB) []map[string]interface{} {
+	value, ok := db.InstanceGet(auditOldRowsKey)
+	if !ok {
+		return nil
+	}
+	rows, _ := value.([]map[string]interface{})
+	return rows
+}
+
+// auditValue makes value readable in json, byte columns are stored as text.
+func auditValue(column string, value interface{}) interface{} {
+	for _, sensitive := range auditSensitiveColumns {
+		if value != nil && strings.Contains(column, sensitive) {
+			return "***"
+		}
+	}
+	if bytes, ok := value.([]byte); ok {
+		return string(bytes)
+	}
+	return value
+}
+
+// appendAuditLog adds entry with changed columns, old or new row is nil for create and delete.
+func (h *Handler) appendAuditLog(db *gorm.DB, logs []models.AuditLogs, admin models.AdminMetadata, action string, oldRow, newRow map[string]interface{}) []models.AuditLogs {
+	changes := make(map[string]models.AuditChange)
+	for column, value := range newRow {
+		old, ok := oldRow[column]
+		if ok && reflect.DeepEqual(old, value) {
+			continue
+		}
+		if !ok && value == nil {
+			continue
+		}
+		changes[column] = models.AuditChange{Old: auditValue(column, old), New: auditValue(column, value)}
+	}
+	if newRow == nil {
+		for column, value := range oldRow {
+			if value != nil {
+				changes[column] = models.AuditChange{Old: auditValue(column, value)}
+			}
+		}
+	}
+	if len(changes) == 0 {
+		return logs
+	}
+	encoded, err := json.Marshal(changes)
+	if err != nil {
+		h.log.Error("failed to encode audit changes", err.Error())
+		return logs
+	}
+	row := newRow
+	if row == nil {
+		row = oldRow
+	}
+	entry := models.AuditLogs{
+		EntityType: db.Statement.Table,
+		EntityID:   fmt.Sprint(row[db.Statement.Schema.PrioritizedPrimaryField.DBName]),
+		Action:     action,
+		AdminID:    &admin.Id,
+		Changes:    encoded,
+		CreatedAt:  timeNow(),
+	}
+	if admin.ApiKeyID != 0 {
+		apiKeyID := admin.ApiKeyID
+		entry.ApiKeyID = &apiKeyID
+	}
+	if client, ok := db.Statement.Context.(interface{ ClientIP() string }); ok {
+		entry.IP = client.ClientIP()
+	}
+	return append(logs, entry)
+}
+
+func (h *Handler) saveAuditLogs(db *gorm.DB, logs []models.AuditLogs) {
+	if len(logs) == 0 {
+		return
+	}
+	err := db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Create(&logs).Error
+	if err != nil {
+		h.log.Error("failed to save audit log", err.Error())
+	}
+}
+
+// @Summary		  Get audit log
+// @Description	   this api returns changes made by admins with old and new values of changed columns, newest first
+// @Tags			Audit
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param			filter 	query		models.AuditLogFilter	false	"filter"
+// @Success			200		{object}	models.AuditLogResponse
+// @Failure			400,409	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/audit-log [GET]
+func (h *AuditController) GetAuditLogs(c *gin.Context) {
+	var body models.AuditLogFilter
+	err := c.ShouldBindQuery(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.Page == 0 {
+		body.Page = 1
+	}
+	if body.PageSize == 0 {
+		body.PageSize = 10
+	}
+	db := h.db.Model(&models.AuditLogs{})
+	if body.EntityType != "" {
+		db = db.Where("entity_type=?", body.EntityType)
+	}
+	if body.EntityID != "" {
+		db = db.Where("entity_id=?", body.EntityID)
+	}
+	if body.AdminID != 0 {
+		db = db.Where("admin_id=?", body.AdminID)
+	}
+	if body.Action != "" {
+		db = db.Where("action=?", body.Action)
+	}
+	if body.DateFrom != "" {
+		db = db.Where("created_at>=?", body.DateFrom)
+	}
+	if body.DateTo != "" {
+		db = db.Where("created_at<=?", body.DateTo)
+	}
+	var count int64
+	err = db.Count(&count).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get audit log")
+		h.log.Error("failed to count audit log", err.Error())
+		return
+	}
+	var logs []models.AuditLogs
+	err = db.Order("id DESC").Limit(body.PageSize).Offset((body.Page - 1) * body.PageSize).Find(&logs).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get audit log")
+		h.log.Error("failed to get audit log", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, models.AuditLogResponse{
+		Page:     body.Page,
+		PageSize: body.PageSize,
+		Count:    int(count),
+		Logs:     logs,
+	})
+}
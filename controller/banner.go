@@ -18,7 +18,7 @@ type BannerController struct {
 
 func (h *Handler) NewBannerController(api *gin.RouterGroup) {
 	banner := &BannerController{h}
-	Ban := api.Group("banner", h.DeserializeAdmin())
+	Ban := h.adminGroup(api, "banner")
 	{
 		Ban.POST("", banner.CreateBanner)
 		Ban.PUT("/:id", banner.UpdateBanner)
@@ -77,7 +77,7 @@ func (h *BannerController) CreateBanner(c *gin.Context) {
 		CreatedID:     &admin.Id,
 		CreatedAt:     timeNow(),
 	}
-	err = h.db.Clauses(clause.Returning{}).Create(&category).Error
+	err = h.db.WithContext(c).Clauses(clause.Returning{}).Create(&category).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -147,7 +147,7 @@ func (h *BannerController) UpdateBanner(c *gin.Context) {
 	}
 	columns["updated_at"] = timeNow()
 	columns["updated_id"] = admin.Id
-	err = h.db.Debug().Clauses(clause.Returning{}).Model(&category).
+	err = h.db.WithContext(c).Debug().Clauses(clause.Returning{}).Model(&category).
 		Where("id=?", categoryId).Updates(columns).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
@@ -221,7 +221,7 @@ func (h *BannerController) DeleteByID(c *gin.Context) {
 		newResponse(c, http.StatusBadRequest, "empty banner id")
 		return
 	}
-	err := h.db.Delete(&models.Banner{}, "id=?", bannerId).Error
+	err := h.db.WithContext(c).Delete(&models.Banner{}, "id=?", bannerId).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -19,7 +19,7 @@ type BrandController struct {
 
 func (h *Handler) NewBrandController(api *gin.RouterGroup) {
 	brand := &BrandController{h}
-	br := api.Group("brand", h.DeserializeAdmin())
+	br := h.adminGroup(api, "brand")
 	{
 		br.POST("/", brand.CreateBrand)
 		br.PUT("/:id", brand.UpdateBrand)
@@ -85,7 +85,7 @@ func (h *BrandController) CreateBrand(c *gin.Context) {
 		CreatedID:      &admin.Id,
 		CreatedAt:      timeNow(),
 	}
-	err = h.db.Debug().Clauses(clause.Returning{}).Create(&brand).Error
+	err = h.db.WithContext(c).Debug().Clauses(clause.Returning{}).Create(&brand).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -163,7 +163,7 @@ func (h *BrandController) UpdateBrand(c *gin.Context) {
 	columns["updated_at"] = timeNow()
 	columns["updated_id"] = admin.Id
 	brand := &models.Brand{}
-	err = h.db.Clauses(clause.Returning{}).Model(&brand).
+	err = h.db.WithContext(c).Clauses(clause.Returning{}).Model(&brand).
 		Where("id=?", brandId).Updates(columns).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
@@ -317,7 +317,7 @@ func (h *BrandController) DeleteByID(c *gin.Context) {
 		newResponse(c, http.StatusBadRequest, "empty brand id")
 		return
 	}
-	err := h.db.Delete(&models.Brand{}, "id=?", brandId).Error
+	err := h.db.WithContext(c).Delete(&models.Brand{}, "id=?", brandId).Error
 	if err != nil {
 		if strings.Contains(err.Error(), "foreign key constraint") {
 			newResponse(c, http.StatusBadRequest, "this brand has relation to product")
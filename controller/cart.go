@@ -0,0 +1,643 @@
+package controller
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Asliddin3/energy-maximum/models"
+	"github.com/Asliddin3/energy-maximum/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	cartTokenHeader = "X-Cart-Token"
+	cartKey         = "cart"
+)
+
+type CartController struct {
+	*Handler
+}
+
+func (h *Handler) NewCartController(api *gin.RouterGroup) {
+	cart := &CartController{h}
+	customerCart := api.Group("cart", h.DeserializeCustomer(), h.customerCart())
+	{
+		customerCart.GET("", cart.GetCart)
+		customerCart.POST("/items", cart.AddCartItem)
+		customerCart.PUT("/items/:product_id", cart.UpdateCartItem)
+		customerCart.DELETE("/items/:product_id", cart.RemoveCartItem)
+		customerCart.PUT("/promo", cart.ApplyCartPromoCode)
+		customerCart.DELETE("/promo", cart.RemoveCartPromoCode)
+		customerCart.POST("/checkout", cart.Checkout)
+	}
+	api.POST("/cart/guest", cart.CreateGuestCart)
+	guestCart := api.Group("cart/guest", h.guestCart())
+	{
+		guestCart.GET("", cart.GetCart)
+		guestCart.POST("/items", cart.AddCartItem)
+		guestCart.PUT("/items/:product_id", cart.UpdateCartItem)
+		guestCart.DELETE("/items/:product_id", cart.RemoveCartItem)
+		guestCart.PUT("/promo", cart.ApplyCartPromoCode)
+		guestCart.DELETE("/promo", cart.RemoveCartPromoCode)
+	}
+	h.adminGroup(api, "cart").GET("/all", cart.GetCarts)
+}
+
+// customerCart sets cart of the customer to the context, the cart is created on first use.
+func (h *Handler) customerCart() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		customer := h.GetCustomer(ctx)
+		var cart models.Carts
+		err := h.db.Where(models.Carts{CustomerID: &customer.Id}).
+			Attrs(models.Carts{CreatedAt: timeNow(), UpdatedAt: timeNow()}).
+			FirstOrCreate(&cart).Error
+		if err != nil {
+			h.log.Error("failed to get cart", err.Error())
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, response{"failed to get cart"})
+			return
+		}
+		ctx.Set(cartKey, cart)
+		ctx.Next()
+	}
+}
+
+// guestCart sets cart of X-Cart-Token header to the context.
+func (h *Handler) guestCart() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		token := ctx.GetHeader(cartTokenHeader)
+		if token == "" {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, response{"cart token is required"})
+			return
+		}
+		var cart models.Carts
+		err := h.db.First(&cart, "token=? AND customer_id IS NULL", token).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				ctx.AbortWithStatusJSON(http.StatusNotFound, response{"cart not found"})
+				return
+			}
+			h.log.Error("failed to get cart", err.Error())
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, response{"failed to get cart"})
+			return
+		}
+		ctx.Set(cartKey, cart)
+		ctx.Next()
+	}
+}
+
+func (h *Handler) getCart(c *gin.Context) *models.Carts {
+	cart := c.MustGet(cartKey).(models.Carts)
+	return &cart
+}
+
+// cartResponse checks lines against products, price is always the current price of the product for the customer.
+func cartResponse(cart *models.Carts) models.CartResponse {
+	res := models.CartResponse{
+		ID:         cart.ID,
+		CustomerID: cart.CustomerID,
+		Items:      make([]models.CartLine, 0, len(cart.Items)),
+		UpdatedAt:  cart.UpdatedAt,
+	}
+	for _, item := range cart.Items {
+		line := models.CartLine{
+			ProductID: item.ProductID,
+			Amount:    item.Amount,
+		}
+		if product := item.Product; product != nil {
+			line.NameUz = product.NameUz
+			line.NameRu = product.NameRu
+			line.NameEn = product.NameEn
+			line.Image = product.Image
+			line.Price = product.Price
+			line.Available = product.IsActive != nil && *product.IsActive && product.DeletedAt == nil
+		}
+		if line.Available {
+			line.Total = roundMoney(line.Price * float64(line.Amount))
+			res.Total += line.Total
+		}
+		res.Items = append(res.Items, line)
+	}
+	res.Total = roundMoney(res.Total)
+	res.Subtotal = res.Total
+	return res
+}
+
+func (h *Handler) loadCartItems(db *gorm.DB, cart *models.Carts) error {
+	err := db.Preload("Product").Where("cart_id=?", cart.ID).Order("id").Find(&cart.Items).Error
+	if err != nil {
+		return err
+	}
+	return setCartPrices(db, cart)
+}
+
+// setCartPrices replaces prices of cart products with prices of the customer group for amounts in the cart.
+// Products are copied, preload may share one product between carts.
+func setCartPrices(db *gorm.DB, cart *models.Carts) error {
+	if cart.CustomerID == nil {
+		return nil
+	}
+	products := make([]models.Products, 0, len(cart.Items))
+	for _, item := range cart.Items {
+		if item.Product != nil {
+			products = append(products, *item.Product)
+		}
+	}
+	prices, err := loadPriceList(db, *cart.CustomerID, products)
+	if err != nil || prices == nil {
+		return err
+	}
+	for i, item := range cart.Items {
+		if item.Product != nil {
+			product := *item.Product
+			product.Price = prices.price(item.Product, item.Amount)
+			cart.Items[i].Product = &product
+		}
+	}
+	return nil
+}
+
+// writeCart responds with current state of the cart, the promo code is checked again on every call.
+func (h *CartController) writeCart(c *gin.Context, cart *models.Carts) {
+	err := h.loadCartItems(h.db, cart)
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get cart")
+		h.log.Error("failed to get cart items", err.Error())
+		return
+	}
+	res := cartResponse(cart)
+	if cart.PromoCode != nil {
+		res.PromoCode = *cart.PromoCode
+		_, err = cartPromotion(h.db, cart, &res)
+		var itemErr *orderItemError
+		if err != nil && !errors.As(err, &itemErr) {
+			newResponse(c, http.StatusInternalServerError, "failed to check promo code")
+			h.log.Error("failed to check promo code of cart", err.Error())
+			return
+		}
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// cartPromotion sets discount of the promo code to the cart response. Problems of the code are set to
+// PromoError and returned as orderItemError.
+func cartPromotion(db *gorm.DB, cart *models.Carts, res *models.CartResponse) (*promoDiscount, error) {
+	lines := make([]models.OrderItems, 0, len(res.Items))
+	for _, line := range res.Items {
+		if line.Available {
+			lines = append(lines, models.OrderItems{ItemId: line.ProductID, Price: line.Price, Amount: line.Amount, Total: line.Total})
+		}
+	}
+	customerID := 0
+	if cart.CustomerID != nil {
+		customerID = *cart.CustomerID
+	}
+	promo, err := findPromotion(db, res.PromoCode, customerID, lines, false)
+	var itemErr *orderItemError
+	if errors.As(err, &itemErr) {
+		res.PromoError = itemErr.Error()
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	res.Discount = promo.discount
+	res.Total = roundMoney(res.Subtotal - promo.discount)
+	return promo, nil
+}
+
+func touchCart(db *gorm.DB, cartID int) error {
+	return db.Model(&models.Carts{}).Where("id=?", cartID).UpdateColumn("updated_at", timeNow()).Error
+}
+
+// mergeGuestCart moves lines of the guest cart from X-Cart-Token header to the customer cart, amounts
+// of the same product are added. Failures are logged, login does not depend on them.
+func (h *Handler) mergeGuestCart(c *gin.Context, customerID int) {
+	token := c.GetHeader(cartTokenHeader)
+	if token == "" {
+		return
+	}
+	tx := h.db.WithContext(c).Begin()
+	var guest models.Carts
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&guest, "token=? AND customer_id IS NULL", token).Error
+	if err != nil {
+		tx.Rollback()
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			h.log.Error("failed to get guest cart", logger.Error(err))
+		}
+		return
+	}
+	var cart models.Carts
+	err = tx.Where(models.Carts{CustomerID: &customerID}).
+		Attrs(models.Carts{CreatedAt: timeNow()}).
+		FirstOrCreate(&cart).Error
+	if err == nil {
+		err = tx.Exec(`INSERT INTO cart_items (cart_id, product_id, amount, created_at, updated_at)
+	SELECT ?, product_id, amount, now(), now() FROM cart_items WHERE cart_id=?
+	ON CONFLICT (cart_id, product_id) DO UPDATE SET amount=cart_items.amount+excluded.amount, updated_at=now()`,
+			cart.ID, guest.ID).Error
+	}
+	if err == nil && guest.PromoCode != nil && cart.PromoCode == nil {
+		err = tx.Model(&cart).UpdateColumn("promo_code", guest.PromoCode).Error
+	}
+	if err == nil {
+		err = tx.Delete(&guest).Error
+	}
+	if err == nil {
+		err = touchCart(tx, cart.ID)
+	}
+	if err != nil {
+		tx.Rollback()
+		h.log.Error("failed to merge guest cart", logger.Error(err))
+		return
+	}
+	tx.Commit()
+}
+
+// @Summary		  Create guest cart
+// @Description	   this api creates cart for not logged in customer, send the token in X-Cart-Token header to /api/cart/guest and to login so the cart is merged
+// @Tags			Cart
+// @Accept			json
+// @Produce			json
+// @Success			200		{object}	models.GuestCartResponse
+// @Failure			500		{object}	response
+// @Router			/api/cart/guest [POST]
+func (h *CartController) CreateGuestCart(c *gin.Context) {
+	random := make([]byte, 32)
+	_, err := rand.Read(random)
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to create cart")
+		return
+	}
+	token := base64.RawURLEncoding.EncodeToString(random)
+	err = h.db.Create(&models.Carts{
+		Token:     &token,
+		CreatedAt: timeNow(),
+		UpdatedAt: timeNow(),
+	}).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to create cart")
+		h.log.Error("failed to create cart", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, models.GuestCartResponse{CartToken: token})
+}
+
+// @Summary		  Get cart
+// @Description	   this api returns cart with current prices, lines of inactive or deleted products are marked not available. Guest cart is /api/cart/guest with X-Cart-Token header
+// @Tags			Cart
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Success			200		{object}	models.CartResponse
+// @Failure			401,404	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/cart [GET]
+func (h *CartController) GetCart(c *gin.Context) {
+	h.writeCart(c, h.getCart(c))
+}
+
+// @Summary		  Add product to cart
+// @Description	   this api adds amount of the product to cart
+// @Tags			Cart
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param			data 	body		models.CartItemRequest	true	"data body"
+// @Success			200		{object}	models.CartResponse
+// @Failure			400,401	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/cart/items [POST]
+func (h *CartController) AddCartItem(c *gin.Context) {
+	cart := h.getCart(c)
+	var body models.CartItemRequest
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.Amount <= 0 {
+		newResponse(c, http.StatusBadRequest, "amount must be positive")
+		return
+	}
+	var count int64
+	err = h.db.Model(&models.Products{}).Where("id=? AND is_active=true AND deleted_at IS NULL", body.ProductID).
+		Count(&count).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get product")
+		h.log.Error("failed to get product", err.Error())
+		return
+	}
+	if count == 0 {
+		newResponse(c, http.StatusBadRequest, "product is not available")
+		return
+	}
+	item := models.CartItems{
+		CartID:    cart.ID,
+		ProductID: body.ProductID,
+		Amount:    body.Amount,
+		CreatedAt: timeNow(),
+		UpdatedAt: timeNow(),
+	}
+	err = h.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "cart_id"}, {Name: "product_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"amount":     gorm.Expr("cart_items.amount+excluded.amount"),
+			"updated_at": timeNow(),
+		}),
+	}).Create(&item).Error
+	if err == nil {
+		err = touchCart(h.db, cart.ID)
+	}
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to add product to cart")
+		h.log.Error("failed to add product to cart", err.Error())
+		return
+	}
+	h.writeCart(c, cart)
+}
+
+// @Summary		  Update cart line
+// @Description	   this api sets amount of the product in cart
+// @Tags			Cart
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           product_id    path     int   true   "product id"
+// @Param			data 	body		models.CartAmountRequest	true	"data body"
+// @Success			200		{object}	models.CartResponse
+// @Failure			400,404	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/cart/items/{product_id} [PUT]
+func (h *CartController) UpdateCartItem(c *gin.Context) {
+	cart := h.getCart(c)
+	productID, err := strconv.Atoi(c.Param("product_id"))
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, "Invalid id")
+		return
+	}
+	var body models.CartAmountRequest
+	err = c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.Amount <= 0 {
+		newResponse(c, http.StatusBadRequest, "amount must be positive")
+		return
+	}
+	result := h.db.Model(&models.CartItems{}).Where("cart_id=? AND product_id=?", cart.ID, productID).
+		UpdateColumns(map[string]interface{}{"amount": body.Amount, "updated_at": timeNow()})
+	if result.Error != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to update cart")
+		h.log.Error("failed to update cart item", result.Error.Error())
+		return
+	}
+	if result.RowsAffected == 0 {
+		newResponse(c, http.StatusNotFound, "product is not in cart")
+		return
+	}
+	err = touchCart(h.db, cart.ID)
+	if err != nil {
+		h.log.Error("failed to update cart", err.Error())
+	}
+	h.writeCart(c, cart)
+}
+
+// @Summary		  Remove product from cart
+// @Description	   this api removes the product from cart
+// @Tags			Cart
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           product_id    path     int   true   "product id"
+// @Success			200		{object}	models.CartResponse
+// @Failure			400,401	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/cart/items/{product_id} [DELETE]
+func (h *CartController) RemoveCartItem(c *gin.Context) {
+	cart := h.getCart(c)
+	productID, err := strconv.Atoi(c.Param("product_id"))
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, "Invalid id")
+		return
+	}
+	err = h.db.Delete(&models.CartItems{}, "cart_id=? AND product_id=?", cart.ID, productID).Error
+	if err == nil {
+		err = touchCart(h.db, cart.ID)
+	}
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to update cart")
+		h.log.Error("failed to remove cart item", err.Error())
+		return
+	}
+	h.writeCart(c, cart)
+}
+
+// @Summary		  Apply promo code to cart
+// @Description	   this api checks the promo code against the cart and keeps it, cart response shows the discount. The code is checked again on checkout. Guest cart is /api/cart/guest/promo with X-Cart-Token header
+// @Tags			Cart
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param			data 	body		models.PromoCodeApplyRequest	true	"data body"
+// @Success			200		{object}	models.CartResponse
+// @Failure			400,401	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/cart/promo [PUT]
+func (h *CartController) ApplyCartPromoCode(c *gin.Context) {
+	cart := h.getCart(c)
+	var body models.PromoCodeApplyRequest
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	err = h.loadCartItems(h.db, cart)
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get cart")
+		h.log.Error("failed to get cart items", err.Error())
+		return
+	}
+	res := cartResponse(cart)
+	res.PromoCode = normalizePromoCode(body.Code)
+	_, err = cartPromotion(h.db, cart, &res)
+	if err != nil {
+		h.orderItemsError(c, err)
+		return
+	}
+	err = h.db.Model(&models.Carts{}).Where("id=?", cart.ID).
+		UpdateColumns(map[string]interface{}{"promo_code": res.PromoCode, "updated_at": timeNow()}).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to apply promo code")
+		h.log.Error("failed to apply promo code", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// @Summary		  Remove promo code from cart
+// @Description	   this api removes the promo code from cart
+// @Tags			Cart
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Success			200		{object}	models.CartResponse
+// @Failure			401		{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/cart/promo [DELETE]
+func (h *CartController) RemoveCartPromoCode(c *gin.Context) {
+	cart := h.getCart(c)
+	err := h.db.Model(&models.Carts{}).Where("id=?", cart.ID).
+		UpdateColumns(map[string]interface{}{"promo_code": nil, "updated_at": timeNow()}).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to remove promo code")
+		h.log.Error("failed to remove promo code", err.Error())
+		return
+	}
+	cart.PromoCode = nil
+	h.writeCart(c, cart)
+}
+
+// @Summary		  Checkout cart
+// @Description	   this api creates order from cart with current prices and empties the cart, it fails when a product is not available or the promo code does not apply
+// @Tags			Cart
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param			data 	body		models.CartCheckoutRequest	false	"data body"
+// @Success			200		{object}	models.OrderResponse
+// @Failure			400,401	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/cart/checkout [POST]
+func (h *CartController) Checkout(c *gin.Context) {
+	customer := h.GetCustomer(c)
+	cart := h.getCart(c)
+	var body models.CartCheckoutRequest
+	if c.Request.ContentLength != 0 {
+		err := c.ShouldBindJSON(&body)
+		if err != nil {
+			newResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	tx := h.db.WithContext(c).Begin()
+	var items []models.CartItems
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("cart_id=?", cart.ID).Order("id").Find(&items).Error
+	if err == nil {
+		err = tx.Delete(&models.CartItems{}, "cart_id=?", cart.ID).Error
+	}
+	if err == nil {
+		err = tx.Model(&models.Carts{}).Where("id=?", cart.ID).
+			UpdateColumns(map[string]interface{}{"promo_code": nil, "updated_at": timeNow()}).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		newResponse(c, http.StatusInternalServerError, "failed to get cart")
+		h.log.Error("failed to checkout cart", err.Error())
+		return
+	}
+	if len(items) == 0 {
+		tx.Rollback()
+		newResponse(c, http.StatusBadRequest, "cart is empty")
+		return
+	}
+	requests := make([]models.OrderItemsRequest, 0, len(items))
+	for _, item := range items {
+		requests = append(requests, models.OrderItemsRequest{ItemID: item.ProductID, Amount: item.Amount})
+	}
+	order := models.Orders{
+		Description: body.Description,
+		CustomerID:  customer.Id,
+		CreatedAt:   timeNow(),
+	}
+	if customer.ImpersonatorID != 0 {
+		order.CreatedID = &customer.ImpersonatorID
+	}
+	promoCode := body.PromoCode
+	if promoCode == "" && cart.PromoCode != nil {
+		promoCode = *cart.PromoCode
+	}
+	orderItems, ok := h.createOrder(c, tx, &order, requests, promoCode)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, models.OrderResponse{
+		Orders: &order,
+		Items:  orderItems,
+	})
+}
+
+// @Summary		  Get carts
+// @Description	   this api returns not empty carts, recently changed first. Use date_to to find abandoned carts
+// @Tags			Cart
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param			filter 	query		models.CartFilter	false	"filter"
+// @Success			200		{object}	models.CartListResponse
+// @Failure			400,409	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/cart/all [GET]
+func (h *CartController) GetCarts(c *gin.Context) {
+	var body models.CartFilter
+	err := c.ShouldBindQuery(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.Page == 0 {
+		body.Page = 1
+	}
+	if body.PageSize == 0 {
+		body.PageSize = 10
+	}
+	db := h.db.Model(&models.Carts{}).Where("EXISTS (SELECT 1 FROM cart_items ci WHERE ci.cart_id=carts.id)")
+	if !body.WithGuests {
+		db = db.Where("customer_id IS NOT NULL")
+	}
+	if body.CustomerID != 0 {
+		db = db.Where("customer_id=?", body.CustomerID)
+	}
+	if body.DateFrom != "" {
+		db = db.Where("updated_at>=?", body.DateFrom)
+	}
+	if body.DateTo != "" {
+		db = db.Where("updated_at<=?", body.DateTo)
+	}
+	var count int64
+	err = db.Count(&count).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get carts")
+		h.log.Error("failed to count carts", err.Error())
+		return
+	}
+	var carts []models.Carts
+	err = db.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).Preload("Items.Product").
+		Order("updated_at DESC").Limit(body.PageSize).Offset((body.Page - 1) * body.PageSize).Find(&carts).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get carts")
+		h.log.Error("failed to get carts", err.Error())
+		return
+	}
+	res := models.CartListResponse{
+		Page:     body.Page,
+		PageSize: body.PageSize,
+		Count:    int(count),
+		Carts:    make([]models.CartResponse, 0, len(carts)),
+	}
+	for i := range carts {
+		err = setCartPrices(h.db, &carts[i])
+		if err != nil {
+			newResponse(c, http.StatusInternalServerError, "failed to get carts")
+			h.log.Error("failed to get cart prices", err.Error())
+			return
+		}
+		res.Carts = append(res.Carts, cartResponse(&carts[i]))
+	}
+	c.JSON(http.StatusOK, res)
+}
@@ -20,7 +20,7 @@ type CategoryController struct {
 
 func (h *Handler) NewCategoryController(api *gin.RouterGroup) {
 	category := &CategoryController{h}
-	cate := api.Group("category", h.DeserializeAdmin())
+	cate := h.adminGroup(api, "category")
 	{
 		cate.POST("", category.CreateCategory)
 		cate.PUT("/:id", category.UpdateCategory)
@@ -112,7 +112,7 @@ func (h *CategoryController) CreateCategory(c *gin.Context) {
 	if body.ParentID != 0 {
 		category.CategoryID = &body.ParentID
 	}
-	err = h.db.Clauses(clause.Returning{}).Create(&category).Error
+	err = h.db.WithContext(c).Clauses(clause.Returning{}).Create(&category).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -213,7 +213,7 @@ func (h *CategoryController) UpdateCategory(c *gin.Context) {
 	}
 	columns["updated_at"] = timeNow()
 	columns["updated_id"] = admin.Id
-	err = h.db.Debug().Clauses(clause.Returning{}).Model(&category).
+	err = h.db.WithContext(c).Debug().Clauses(clause.Returning{}).Model(&category).
 		Where("id=?", categoryId).Updates(columns).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
@@ -331,7 +331,7 @@ func (h *CategoryController) DeleteByID(c *gin.Context) {
 		newResponse(c, http.StatusBadRequest, "empty category id")
 		return
 	}
-	err := h.db.Delete(&models.Category{}, "id=?", categoryId).Error
+	err := h.db.WithContext(c).Delete(&models.Category{}, "id=?", categoryId).Error
 	if err != nil {
 		if strings.Contains(err.Error(), "foreign key constraint") {
 			newResponse(c, http.StatusBadRequest, "this category has relation to product")
@@ -361,7 +361,7 @@ func (h *CategoryController) AddProductAddition(c *gin.Context) {
 		newResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
-	err = h.db.Create(&models.ProductAdditions{
+	err = h.db.WithContext(c).Create(&models.ProductAdditions{
 		ProductCategoryID:  body.ProductCategoryID,
 		AdditionCategoryID: body.AdditionCategoryID,
 	}).Error
@@ -417,7 +417,7 @@ func (h *CategoryController) DeleteProductAddition(c *gin.Context) {
 		newResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
-	err = h.db.Delete(&models.ProductAdditions{}, "product_category_id=? AND addition_category_id=?",
+	err = h.db.WithContext(c).Delete(&models.ProductAdditions{}, "product_category_id=? AND addition_category_id=?",
 		body.ProductCategoryID, body.AdditionCategoryID).Error
 	if err != nil {
 		if strings.Contains(err.Error(), "foreign key constraint") {
@@ -0,0 +1,304 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Asliddin3/energy-maximum/models"
+	"github.com/Asliddin3/energy-maximum/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var codeMessages = map[string]string{
+	models.CodePurposeLogin:         "Your code from pribor -",
+	models.CodePurposePasswordReset: "Your password reset code from pribor -",
+}
+
+func phoneLockKey(phone int) string {
+	return fmt.Sprintf("phone:%d", phone)
+}
+
+func ipLockKey(ip string) string {
+	return "ip:" + ip
+}
+
+// isTestPhone reports whether the phone gets the fixed test code instead of sms. Test phones work only
+// when both OTP_TEST_PHONES and OTP_TEST_CODE are set.
+func (h *Handler) isTestPhone(phone string) bool {
+	if h.cfg.OtpTestPhones == "" || h.cfg.OtpTestCode == "" {
+		return false
+	}
+	for _, test := range strings.Split(h.cfg.OtpTestPhones, ",") {
+		if formatPhone(test) == phone {
+			return true
+		}
+	}
+	return false
+}
+
+func retryAfter(c *gin.Context, until time.Time, message string) {
+	seconds := int(math.Ceil(time.Until(until).Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, models.RetryAfterResponse{
+		Message:    message,
+		RetryAfter: seconds,
+	})
+}
+
+// codeLockedUntil returns the latest active lockout of given keys.
+func (h *Handler) codeLockedUntil(keys ...string) (*time.Time, error) {
+	var lockouts []models.CodeLockouts
+	err := h.db.Find(&lockouts, "key IN ? AND locked_until>?", keys, time.Now()).Error
+	if err != nil {
+		return nil, err
+	}
+	var until *time.Time
+	for _, lockout := range lockouts {
+		if until == nil || lockout.LockedUntil.After(*until) {
+			until = lockout.LockedUntil
+		}
+	}
+	return until, nil
+}
+
+func (h *Handler) lockCodes(key string) (time.Time, error) {
+	until := time.Now().Add(h.cfg.OtpLockout)
+	err := h.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"locked_until", "created_at"}),
+	}).Create(&models.CodeLockouts{
+		Key:         key,
+		LockedUntil: &until,
+		CreatedAt:   timeNow(),
+	}).Error
+	if err != nil {
+		return until, err
+	}
+	h.log.Warnf("sms codes are locked for %s until %s", key, until.Format(time.RFC3339))
+	return until, nil
+}
+
+// checkCodeLockout writes 429 response and returns false when the phone or client ip is locked.
+func (h *Handler) checkCodeLockout(c *gin.Context, number int) bool {
+	until, err := h.codeLockedUntil(phoneLockKey(number), ipLockKey(c.ClientIP()))
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get lockouts")
+		h.log.Error("failed to get code lockouts", logger.Error(err))
+		return false
+	}
+	if until != nil {
+		retryAfter(c, *until, "Слишком много попыток, попробуйте позже")
+		return false
+	}
+	return true
+}
+
+// sendCode checks resend interval and sliding window limits of the phone and client ip, then
+// stores hashed code and sends it by sms. It writes the error response and returns false on failure.
+func (h *Handler) sendCode(c *gin.Context, phone string, number int, purpose string) bool {
+	return h.issueCode(c, phone, number, purpose, true)
+}
+
+// issueCode is sendCode which stores the code without sending it when deliver is false. Phones without
+// account go through the same limits, so answers do not tell which phones are registered.
+func (h *Handler) issueCode(c *gin.Context, phone string, number int, purpose string, deliver bool) bool {
+	if !h.checkCodeLockout(c, number) {
+		return false
+	}
+	ip := c.ClientIP()
+	var last models.Codes
+	err := h.db.Order("created_at DESC").First(&last, "phone=? AND purpose=?", number, purpose).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		newResponse(c, http.StatusInternalServerError, "failed to get codes")
+		h.log.Error("Error while find codes", logger.Error(err))
+		return false
+	}
+	if err == nil && last.CreatedAt != nil {
+		next := last.CreatedAt.Add(h.cfg.OtpResendInterval)
+		if next.After(time.Now()) {
+			retryAfter(c, next, fmt.Sprintf("Попробуйте еще раз через %d секунд",
+				int(math.Ceil(time.Until(next).Seconds()))))
+			return false
+		}
+	}
+
+	since := time.Now().Add(-h.cfg.OtpWindow)
+	var phoneCount, ipCount int64
+	err = h.db.Model(&models.Codes{}).Where("phone=? AND created_at>?", number, since).Count(&phoneCount).Error
+	if err == nil {
+		err = h.db.Model(&models.Codes{}).Where("ip=? AND created_at>?", ip, since).Count(&ipCount).Error
+	}
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get codes")
+		h.log.Error("Error while count codes", logger.Error(err))
+		return false
+	}
+	lockKey := ""
+	if phoneCount >= int64(h.cfg.OtpPhoneLimit) {
+		lockKey = phoneLockKey(number)
+	} else if ipCount >= int64(h.cfg.OtpIPLimit) {
+		lockKey = ipLockKey(ip)
+	}
+	if lockKey != "" {
+		until, err := h.lockCodes(lockKey)
+		if err != nil {
+			h.log.Error("failed to lock codes", logger.Error(err))
+		}
+		retryAfter(c, until, "Слишком много попыток, попробуйте позже")
+		return false
+	}
+
+	testPhone := h.isTestPhone(phone)
+	sendCode := strconv.FormatInt(genRandNum(100000, 999999), 10)
+	if testPhone {
+		sendCode = h.cfg.OtpTestCode
+	}
+	codeHash, err := h.hash.HashPassword(sendCode)
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to create code")
+		h.log.Error("Error while hash code", logger.Error(err))
+		return false
+	}
+	expiresAt := time.Now().Add(h.cfg.OtpTTL)
+	err = h.db.Create(&models.Codes{
+		Phone:     number,
+		CodeHash:  codeHash,
+		Purpose:   purpose,
+		IP:        ip,
+		ExpiresAt: &expiresAt,
+		CreatedAt: timeNow(),
+	}).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, err.Error())
+		h.log.Error("Error while create codes", logger.Error(err))
+		return false
+	}
+	if deliver && !testPhone {
+		_, err = h.queueSms(phone, codeMessages[purpose]+sendCode, purpose, sendCode)
+		if err != nil {
+			newResponse(c, http.StatusInternalServerError, "failed to send code")
+			h.log.Error("Error while send code", logger.Error(err))
+			return false
+		}
+	}
+	return true
+}
+
+// verifyCode checks the code against the latest unused code of the phone. Every wrong code is counted,
+// the phone is locked when the code runs out of attempts and the ip is locked after too many failures.
+func (h *Handler) verifyCode(c *gin.Context, number int, purpose, code string) bool {
+	if !h.checkCodeLockout(c, number) {
+		return false
+	}
+	var record models.Codes
+	err := h.db.Order("created_at DESC").
+		First(&record, "phone=? AND purpose=? AND used_at IS NULL", number, purpose).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			newResponse(c, http.StatusUnauthorized, "Неправильный код!")
+			return false
+		}
+		newResponse(c, http.StatusInternalServerError, "failed to get codes")
+		h.log.Error("Error while find codes", logger.Error(err))
+		return false
+	}
+	if record.ExpiresAt == nil || record.ExpiresAt.Before(time.Now()) {
+		newResponse(c, http.StatusUnauthorized, "Срок действия кода истек")
+		return false
+	}
+	// the attempt is claimed before the slow hash check, so parallel guesses can not share one attempt
+	claim := h.db.Clauses(clause.Returning{Columns: []clause.Column{{Name: "attempts"}}}).Model(&record).
+		Where("attempts<? AND used_at IS NULL", h.cfg.OtpMaxAttempts).UpdateColumn("attempts", gorm.Expr("attempts+1"))
+	if claim.Error != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to check code")
+		h.log.Error("Error while claim code attempt", logger.Error(claim.Error))
+		return false
+	}
+	if claim.RowsAffected != 0 && h.hash.CheckPassword(record.CodeHash, strings.TrimSpace(code)) == nil {
+		result := h.db.Model(&models.Codes{}).Where("id=? AND used_at IS NULL", record.ID).UpdateColumn("used_at", timeNow())
+		if result.Error != nil {
+			newResponse(c, http.StatusInternalServerError, "failed to update code")
+			h.log.Error("Error while update code", logger.Error(result.Error))
+			return false
+		}
+		if result.RowsAffected == 0 {
+			newResponse(c, http.StatusUnauthorized, "Неправильный код!")
+			return false
+		}
+		return true
+	}
+
+	ip := c.ClientIP()
+	err = h.db.Create(&models.CodeAttempts{Phone: number, IP: ip, CreatedAt: timeNow()}).Error
+	var ipFailures int64
+	if err == nil {
+		err = h.db.Model(&models.CodeAttempts{}).
+			Where("ip=? AND created_at>?", ip, time.Now().Add(-h.cfg.OtpWindow)).Count(&ipFailures).Error
+	}
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to check code")
+		h.log.Error("Error while count code attempts", logger.Error(err))
+		return false
+	}
+
+	lockKey := ""
+	if claim.RowsAffected == 0 || record.Attempts >= h.cfg.OtpMaxAttempts {
+		lockKey = phoneLockKey(number)
+	} else if ipFailures >= int64(h.cfg.OtpIPLimit) {
+		lockKey = ipLockKey(ip)
+	}
+	if lockKey != "" {
+		until, err := h.lockCodes(lockKey)
+		if err != nil {
+			h.log.Error("failed to lock codes", logger.Error(err))
+		}
+		retryAfter(c, until, "Слишком много попыток, попробуйте позже")
+		return false
+	}
+	newResponse(c, http.StatusUnauthorized,
+		fmt.Sprintf("Неправильный код! Осталось попыток: %d", h.cfg.OtpMaxAttempts-record.Attempts))
+	return false
+}
+
+// purgeCodes removes expired codes, failed attempts and lockouts. Codes are kept for the
+// limit window because sliding window limits count them.
+func (h *Handler) purgeCodes() error {
+	keep := h.cfg.OtpWindow
+	if h.cfg.OtpTTL > keep {
+		keep = h.cfg.OtpTTL
+	}
+	before := time.Now().Add(-keep)
+	err := h.db.Where("created_at<? AND (expires_at IS NULL OR expires_at<?)", before, time.Now()).
+		Delete(&models.Codes{}).Error
+	if err != nil {
+		return err
+	}
+	err = h.db.Where("created_at<?", time.Now().Add(-h.cfg.OtpWindow)).Delete(&models.CodeAttempts{}).Error
+	if err != nil {
+		return err
+	}
+	return h.db.Where("locked_until<?", time.Now()).Delete(&models.CodeLockouts{}).Error
+}
+
+// runCodePurge purges codes in background every OtpPurgeInterval.
+func (h *Handler) runCodePurge() {
+	ticker := time.NewTicker(h.cfg.OtpPurgeInterval)
+	defer ticker.Stop()
+	for {
+		err := h.purgeCodes()
+		if err != nil {
+			h.log.Error("failed to purge codes", logger.Error(err))
+		}
+		<-ticker.C
+	}
+}
@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/Asliddin3/energy-maximum/config"
+)
+
+func TestIsTestPhone(t *testing.T) {
+	tests := []struct {
+		name   string
+		phones string
+		code   string
+		phone  string
+		want   bool
+	}{
+		{"listed phone", "998901234567,998911234567", "1111", "998911234567", true},
+		{"phone in other format", "+998 90-123-45-67", "1111", "998901234567", true},
+		{"phone is not listed", "998901234567", "1111", "998931234567", false},
+		{"no test code", "998901234567", "", "998901234567", false},
+		{"no test phones", "", "1111", "998901234567", false},
+		{"defaults", "", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{cfg: &config.Config{OtpTestPhones: tt.phones, OtpTestCode: tt.code}}
+			if got := h.isTestPhone(tt.phone); got != tt.want {
+				t.Errorf("isTestPhone(%q) = %v, want %v", tt.phone, got, tt.want)
+			}
+		})
+	}
+}
@@ -17,13 +17,14 @@ type ContactController struct {
 func (h *Handler) NewContactController(api *gin.RouterGroup) {
 	brand := &ContactController{h}
 	br := api.Group("contact")
+	adminBr := h.adminGroup(br, "")
 	{
-		br.POST("/", h.DeserializeAdmin(), brand.CreateContact)
-		br.PUT("/:id", h.DeserializeAdmin(), brand.UpdateContact)
+		adminBr.POST("/", brand.CreateContact)
+		adminBr.PUT("/:id", brand.UpdateContact)
 		br.GET("", brand.GetContacts)
 		br.GET("/:id", brand.GetByID)
 		br.GET("/main", brand.GetMainFilial)
-		br.DELETE("/:id", h.DeserializeAdmin(), brand.DeleteContact)
+		adminBr.DELETE("/:id", brand.DeleteContact)
 	}
 }
 
@@ -62,7 +63,7 @@ func (h *ContactController) CreateContact(c *gin.Context) {
 		CreatedID:    &admin.Id,
 		CreatedAt:    timeNow(),
 	}
-	err = h.db.Debug().Clauses(clause.Returning{}).Create(&contact).Error
+	err = h.db.WithContext(c).Debug().Clauses(clause.Returning{}).Create(&contact).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -94,7 +95,7 @@ func (h *ContactController) UpdateContact(c *gin.Context) {
 	}
 
 	country := models.Contact{}
-	err = h.db.First(&country, "id=?", id).Error
+	err = h.db.WithContext(c).First(&country, "id=?", id).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		h.log.Error("failed to create country", err.Error())
@@ -126,7 +127,7 @@ func (h *ContactController) UpdateContact(c *gin.Context) {
 	}
 	country.CreatedAt = timeNow()
 	country.CreatedID = &admin.Id
-	err = h.db.Save(&country).Error
+	err = h.db.WithContext(c).Save(&country).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		h.log.Error("failed to save brand", err.Error())
@@ -213,7 +214,7 @@ func (h *ContactController) GetMainFilial(c *gin.Context) {
 // @Router			/api/contact/{id} [DELETE]
 func (h *ContactController) DeleteContact(c *gin.Context) {
 	id := c.Param("id")
-	err := h.db.Delete(&models.Contact{}, "id=?", id).Error
+	err := h.db.WithContext(c).Delete(&models.Contact{}, "id=?", id).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
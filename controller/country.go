@@ -16,7 +16,7 @@ type CountryController struct {
 
 func (h *Handler) NewCountryController(api *gin.RouterGroup) {
 	brand := &CountryController{h}
-	br := api.Group("country", h.DeserializeAdmin())
+	br := h.adminGroup(api, "country")
 	{
 		br.POST("/", brand.CreateCountry)
 		br.PUT("/:id", brand.UpdateCountry)
@@ -55,7 +55,7 @@ func (h *CountryController) CreateCountry(c *gin.Context) {
 		CreatedID: &admin.Id,
 		CreatedAt: timeNow(),
 	}
-	err = h.db.Debug().Clauses(clause.Returning{}).Create(&country).Error
+	err = h.db.WithContext(c).Debug().Clauses(clause.Returning{}).Create(&country).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -87,7 +87,7 @@ func (h *CountryController) UpdateCountry(c *gin.Context) {
 	}
 
 	country := models.Country{}
-	err = h.db.First(&country, "id=?", id).Error
+	err = h.db.WithContext(c).First(&country, "id=?", id).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		h.log.Error("failed to create country", err.Error())
@@ -98,7 +98,7 @@ func (h *CountryController) UpdateCountry(c *gin.Context) {
 	country.NameUz = body.NameUz
 	country.CreatedAt = timeNow()
 	country.CreatedID = &admin.Id
-	err = h.db.Save(&country).Error
+	err = h.db.WithContext(c).Save(&country).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		h.log.Error("failed to save brand", err.Error())
@@ -164,7 +164,7 @@ func (h *CountryController) GetByID(c *gin.Context) {
 // @Router			/api/country/{id} [DELETE]
 func (h *CountryController) DeleteCountry(c *gin.Context) {
 	id := c.Param("id")
-	err := h.db.Delete(&models.Country{}, "id=?", id).Error
+	err := h.db.WithContext(c).Delete(&models.Country{}, "id=?", id).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -3,7 +3,6 @@ package controller
 import (
 	"crypto/rand"
 	"errors"
-	"fmt"
 	"math/big"
 	"net/http"
 	"strconv"
@@ -11,9 +10,8 @@ import (
 	"time"
 
 	"github.com/Asliddin3/energy-maximum/models"
+	"github.com/Asliddin3/energy-maximum/pkg/document"
 	"github.com/Asliddin3/energy-maximum/pkg/logger"
-	"github.com/Asliddin3/energy-maximum/pkg/sms"
-	"github.com/Asliddin3/energy-maximum/pkg/utils"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -21,29 +19,33 @@ import (
 
 type CustomerController struct {
 	*Handler
-	Sms             *sms.Sms
-	DEVELOPER_PHONE string
 }
 
-func (h *Handler) NewCustomerController(api *gin.RouterGroup, sms *sms.Sms) {
-	customer := &CustomerController{
-		Handler:         h,
-		Sms:             sms,
-		DEVELOPER_PHONE: "998995117361",
-	}
+func (h *Handler) NewCustomerController(api *gin.RouterGroup) {
+	customer := &CustomerController{h}
 	custom := api.Group("customer")
+	adminCustom := h.adminGroup(custom, "")
 	{
 		custom.POST("/register", h.DeserializeCustomer(), customer.Register)
 		custom.POST("/login", customer.Login)
 		custom.POST("/check-code", customer.checkCode)
 		custom.POST("/send-code", customer.SendCode)
-		custom.GET("", h.DeserializeAdmin(), customer.GetCustomers)
+		adminCustom.GET("", customer.GetCustomers)
+		adminCustom.GET("/export", customer.ExportCustomers)
 		custom.PUT("", h.DeserializeCustomer(), customer.UpdateCustomer)
 		custom.GET("/me", h.DeserializeCustomer(), customer.GetMe)
-		custom.GET("/:id", h.DeserializeAdmin(), customer.GetCustomerById)
-		custom.POST("", h.DeserializeAdmin(), customer.CreateCustomerByAdmin)
-		// custom.POST("/refresh", customer.RefreshToken)
+		adminCustom.GET("/:id", customer.GetCustomerById)
+		adminCustom.POST("", customer.CreateCustomerByAdmin)
+		adminCustom.PUT("/assign/:id", customer.AssignCustomer)
+		adminCustom.POST("/impersonate/:id", customer.ImpersonateCustomer)
+		adminCustom.GET("/impersonation-logs", customer.GetImpersonationLogs)
+		custom.POST("/refresh", customer.RefreshToken)
+		custom.POST("/logout", customer.Logout)
+		custom.POST("/logout-all", h.DeserializeCustomer(), customer.LogoutAll)
 		custom.PUT("/password", h.DeserializeCustomer(), customer.UpdatePassword)
+		custom.POST("/password/forgot", customer.ForgotPassword)
+		custom.POST("/password/verify", customer.VerifyResetCode)
+		custom.POST("/password/reset", customer.ResetPassword)
 	}
 }
 
@@ -56,6 +58,7 @@ func (h *Handler) NewCustomerController(api *gin.RouterGroup, sms *sms.Sms) {
 // @Success			201		{object}	response
 // @Failure			400,409	{object}	response
 // @Failure			500		{object}	response
+// @Failure			429		{object}	models.RetryAfterResponse
 // @Router			/api/customer/send-code [POST]
 func (h *CustomerController) SendCode(c *gin.Context) {
 	var body models.CustomerCode
@@ -70,48 +73,13 @@ func (h *CustomerController) SendCode(c *gin.Context) {
 		newResponse(c, http.StatusUnauthorized, "Неправильный номер телефона!")
 		return
 	}
-	e := time.Now()
 	number, err := strconv.ParseInt(phone, 10, 64)
 	if err != nil {
 		newResponse(c, http.StatusUnauthorized, "Неправильный номер телефона!")
 		h.log.Error("failed to parse phone", logger.Error(err))
 		return
 	}
-	code, err := h.GetCodes(int(number))
-	if err != nil && err != gorm.ErrRecordNotFound {
-		newResponse(c, http.StatusInternalServerError, "failed to get codes")
-		h.log.Error("Error while find codes", logger.Error(err))
-		return
-	}
-	if err != gorm.ErrRecordNotFound {
-		s := code.CreatedAt
-		second := int(e.Unix()) - int(s.Unix())
-		if second < 60 {
-			newResponse(c, http.StatusUnauthorized,
-				fmt.Sprintf("Попробуйте еще раз через %d секунд", 60-second))
-			return
-		}
-	}
-	sendCode := genRandNum(99999, 999999)
-	if phone == h.DEVELOPER_PHONE {
-		sendCode = 997361
-	}
-	err = h.Sms.SendCode(phone, fmt.Sprintf("Your code from pribor -%d ", sendCode))
-	if err != nil {
-		// newResponse(c, http.StatusInternalServerError, err.Error())
-		h.log.Error("Error while send code", logger.Error(err))
-		// return
-	}
-	fmt.Println("message sended")
-
-	err = h.db.Create(&models.Codes{
-		Phone:     int(number),
-		Code:      int(sendCode),
-		CreatedAt: timeNow(),
-	}).Error
-	if err != nil {
-		newResponse(c, http.StatusInternalServerError, err.Error())
-		h.log.Error("Error while create codes", logger.Error(err))
+	if !h.sendCode(c, phone, int(number), models.CodePurposeLogin) {
 		return
 	}
 
@@ -125,20 +93,23 @@ func genRandNum(min, max int64) int64 {
 	}
 	return n.Int64() + min
 }
+
+// formatPhone returns phone as 998XXXXXXXXX or empty string. Phones are typed in any form such as
+// +998 (90) 123-45-67, so everything but digits is dropped and local numbers get the country code.
 func formatPhone(phone string) string {
-	phone = strings.ReplaceAll(phone, "+", "")
-	phone = strings.ReplaceAll(phone, "-", "")
-	phone = strings.ReplaceAll(phone, " ", "")
-	if len([]rune(phone)) != 12 {
+	digits := make([]rune, 0, len(phone))
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, r)
+		}
+	}
+	if len(digits) == 9 {
+		digits = append([]rune("998"), digits...)
+	}
+	if len(digits) != 12 {
 		return ""
 	}
-	return phone
-}
-func (h *CustomerController) GetCodes(phone int) (*models.Codes, error) {
-	var code models.Codes
-	err := h.db.Model(&models.Codes{}).Order("created_at DESC").Limit(1).
-		First(&code, "phone=?", phone).Error
-	return &code, err
+	return string(digits)
 }
 
 // @Summary		  Login customer
@@ -181,23 +152,15 @@ func (h *CustomerController) Login(c *gin.Context) {
 		newResponse(c, http.StatusInternalServerError, "wrong password or username")
 		return
 	}
-	key := fmt.Sprintf("customer:%d", customer.ID)
-	token, err := utils.CreateToken(time.Duration(time.Hour*720), key, h.cfg.AccessTokenPrivateKey)
+	tokens, _, err := h.issueTokens(c, h.db, models.TokenSubjectCustomer, customer.ID, "")
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		h.log.Error("error while token", logger.Error(err))
 		return
 	}
-	// refreshToken, err := utils.CreateToken(time.Duration(time.Hour*720), customer.ID, h.cfg.RefreshTokenPrivateKey)
-	// if err != nil {
-	// 	newResponse(c, http.StatusInternalServerError, err.Error())
-	// 	h.log.Error("error while token", logger.Error(err))
-	// 	return
-	// }
+	h.mergeGuestCart(c, customer.ID)
 
-	c.JSON(http.StatusOK, models.TokenResponse{
-		AccessToken: token,
-	})
+	c.JSON(http.StatusOK, tokens)
 }
 
 // @Summary		  Register a new customer
@@ -208,11 +171,15 @@ func (h *CustomerController) Login(c *gin.Context) {
 // @Produce			json
 // @Param			data 	body		models.CustomerRegister	true	"data body"
 // @Success			200		{object}	 models.Customer
-// @Failure			400,409	{object}	response
+// @Failure			400,403,409	{object}	response
 // @Failure			500		{object}	response
 // @Router			/api/customer/register [POST]
 func (h *CustomerController) Register(c *gin.Context) {
 	customer := h.GetCustomer(c)
+	if customer.ImpersonatorID != 0 {
+		newResponse(c, http.StatusForbidden, "account can not be registered while impersonating")
+		return
+	}
 	var body models.CustomerRegister
 	err := c.ShouldBindJSON(&body)
 	if err != nil {
@@ -225,10 +192,9 @@ func (h *CustomerController) Register(c *gin.Context) {
 		return
 	}
 
-	hashed, err := h.hash.HashPassword(body.Password)
+	hashed, err := h.hashNewPassword(h.db, models.TokenSubjectCustomer, customer.Id, "", body.Password)
 	if err != nil {
-		newResponse(c, http.StatusBadRequest, "failed to hash password")
-		h.log.Error("error while hash password", logger.Error(err))
+		h.passwordError(c, err)
 		return
 	}
 	columns := map[string]interface{}{
@@ -244,44 +210,81 @@ func (h *CustomerController) Register(c *gin.Context) {
 		h.log.Error("failed to update customer", logger.Error(err))
 		return
 	}
+	err = h.rememberPassword(h.db, models.TokenSubjectCustomer, customer.Id, hashed)
+	if err != nil {
+		h.log.Error("failed to save password history", logger.Error(err))
+	}
 	// var customer
 
 	c.JSON(http.StatusOK, custom)
 }
 
 // @Summary		  Update customer password
-// @Description	   this api is for update customer password
+// @Description	   this api changes password of current customer, every other session is logged out and new tokens are returned
 // @Tags			Customer
 // @Security		BearerAuth
 // @Accept			json
 // @Produce			json
-// @Param			password  query	string 	true	"customer password"
+// @Param			data 	body		models.ChangePasswordRequest	true	"data body"
 // @Success			200		{object}	 models.TokenResponse
-// @Failure			400,409	{object}	response
+// @Failure			400,403,409	{object}	response
 // @Failure			500		{object}	response
 // @Router			/api/customer/password [PUT]
 func (h *CustomerController) UpdatePassword(c *gin.Context) {
 	customer := h.GetCustomer(c)
-	password := c.Param("password")
-	if password != "" {
-		newResponse(c, http.StatusBadRequest, "empty password")
+	if customer.ImpersonatorID != 0 {
+		newResponse(c, http.StatusForbidden, "password can not be changed while impersonating")
 		return
 	}
-	hashed, err := h.hash.HashPassword(password)
+	var body models.ChangePasswordRequest
+	err := c.ShouldBindJSON(&body)
 	if err != nil {
-		newResponse(c, http.StatusBadRequest, "failed to hash password")
-		h.log.Error("error while hash password", logger.Error(err))
+		newResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
-	err = h.db.Model(&models.Customer{
-		ID: customer.Id,
-	}).UpdateColumn("password", hashed).Error
+	tx := h.db.WithContext(c).Begin()
+	var current models.Customer
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, "id=?", customer.Id).Error
 	if err != nil {
-		newResponse(c, http.StatusInternalServerError, err.Error())
+		tx.Rollback()
+		newResponse(c, http.StatusInternalServerError, "failed to find customer")
+		h.log.Error("failed to find customer", logger.Error(err))
+		return
+	}
+	if current.Password != "" && h.hash.CheckPassword(current.Password, body.CurrentPassword) != nil {
+		tx.Rollback()
+		newResponse(c, http.StatusBadRequest, "wrong current password")
+		return
+	}
+	hashed, err := h.hashNewPassword(tx, models.TokenSubjectCustomer, customer.Id, current.Password, body.Password)
+	if err != nil {
+		tx.Rollback()
+		h.passwordError(c, err)
+		return
+	}
+	err = tx.Model(&current).UpdateColumns(map[string]interface{}{
+		"password":            hashed,
+		"password_changed_at": time.Now().Truncate(time.Second),
+		"updated_at":          timeNow(),
+	}).Error
+	if err == nil {
+		err = h.rememberPassword(tx, models.TokenSubjectCustomer, customer.Id, hashed)
+	}
+	if err == nil {
+		err = h.RevokeSubjectTokens(tx, models.TokenSubjectCustomer, customer.Id)
+	}
+	var tokens *models.TokenResponse
+	if err == nil {
+		tokens, _, err = h.issueTokens(c, tx, models.TokenSubjectCustomer, customer.Id, "")
+	}
+	if err != nil {
+		tx.Rollback()
+		newResponse(c, http.StatusInternalServerError, "failed to update password")
 		h.log.Error("failed to update password", logger.Error(err))
 		return
 	}
-	c.JSON(http.StatusOK, response{"success"})
+	tx.Commit()
+	c.JSON(http.StatusOK, tokens)
 }
 
 // @Summary		  	Check customer code
@@ -293,6 +296,7 @@ func (h *CustomerController) UpdatePassword(c *gin.Context) {
 // @Success			200		{object}	 models.TokenResponse
 // @Failure			400,409	{object}	response
 // @Failure			500		{object}	response
+// @Failure			429		{object}	models.RetryAfterResponse
 // @Router			/api/customer/check-code [POST]
 func (h *CustomerController) checkCode(c *gin.Context) {
 	var body models.CheckCodeRequest
@@ -312,19 +316,7 @@ func (h *CustomerController) checkCode(c *gin.Context) {
 		h.log.Error("failed to parse phone", logger.Error(err))
 		return
 	}
-	code, err := h.GetCodes(int(number))
-	if err != nil && err != gorm.ErrRecordNotFound {
-		newResponse(c, http.StatusInternalServerError, "failed to get codes")
-		h.log.Error("Error while find codes", logger.Error(err))
-		return
-	}
-	codeInt, err := strconv.Atoi(body.Code)
-	if err != nil {
-		newResponse(c, http.StatusUnauthorized, "Неправильный код!")
-		return
-	}
-	if code.Code != codeInt {
-		newResponse(c, http.StatusUnauthorized, "Неправильный код!")
+	if !h.verifyCode(c, int(number), models.CodePurposeLogin, body.Code) {
 		return
 	}
 	var customer models.Customer
@@ -356,71 +348,113 @@ func (h *CustomerController) checkCode(c *gin.Context) {
 			return
 		}
 	}
-	token, err := utils.CreateToken(time.Duration(time.Hour*720), customer.ID, h.cfg.AccessTokenPrivateKey)
+	tokens, _, err := h.issueTokens(c, h.db, models.TokenSubjectCustomer, customer.ID, "")
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		h.log.Error("error while token", logger.Error(err))
 		return
 	}
-	// refreshToken, err := utils.CreateToken(time.Duration(time.Hour*720), customer.ID, h.cfg.RefreshTokenPrivateKey)
-	// if err != nil {
-	// 	newResponse(c, http.StatusInternalServerError, err.Error())
-	// 	h.log.Error("error while token", logger.Error(err))
-	// 	return
-	// }
-	c.JSON(http.StatusOK, models.TokenResponse{
-		AccessToken: token,
-	})
+	h.mergeGuestCart(c, customer.ID)
+	c.JSON(http.StatusOK, tokens)
 }
 
-// // @Summary		  Refresh access token
-// // @Description	   this api is for refresh access token
-// // @Tags			Customer
-// // @Security		BearerAuth
-// // @Accept			json
-// // @Produce			json
-// // @Param           refreshToken   query  string   true  "refresh token"
-// // @Success			201		{object}	models.AccessToken
-// // @Failure			400,409	{object}	response
-// // @Failure			500		{object}	response
-// // @Router			/api/customer/refresh [POST]
-// func (h *CustomerController) RefreshToken(c *gin.Context) {
-// 	// var refreshToken string
-// 	refreshToken := c.Query("refreshToken")
-// 	sub, err := utils.ValidateToken(refreshToken, h.cfg.RefreshTokenPublicKey)
-// 	if err != nil {
-// 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": err.Error()})
-// 		return
-// 	}
-// 	customer := &models.Customer{}
-// 	err = h.db.First(customer, "id=?", sub).Error
-// 	if err != nil {
-// 		newResponse(c, http.StatusInternalServerError, "failed to find customer")
-// 		return
-// 	}
+// @Summary		  Refresh access token
+// @Description	   this api rotates refresh token and returns new token pair, a reused refresh token revokes the whole login
+// @Tags			Customer
+// @Accept			json
+// @Produce			json
+// @Param			data 	body		models.RefreshTokenRequest	true	"data body"
+// @Success			200		{object}	models.TokenResponse
+// @Failure			400,401	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/customer/refresh [POST]
+func (h *CustomerController) RefreshToken(c *gin.Context) {
+	var body models.RefreshTokenRequest
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	tokens, err := h.rotateRefreshToken(c, models.TokenSubjectCustomer, body.RefreshToken,
+		func(tx *gorm.DB, customerID int) (bool, error) {
+			var count int64
+			err := tx.Model(&models.Customer{}).Where("id=?", customerID).Count(&count).Error
+			return count != 0, err
+		})
+	if err != nil {
+		h.refreshTokenError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// @Summary		  Logout customer
+// @Description	   this api revokes refresh token of current login
+// @Tags			Customer
+// @Accept			json
+// @Produce			json
+// @Param			data 	body		models.RefreshTokenRequest	true	"data body"
+// @Success			200		{object}	response
+// @Failure			400,401	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/customer/logout [POST]
+func (h *CustomerController) Logout(c *gin.Context) {
+	var body models.RefreshTokenRequest
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	err = h.revokeTokenFamily(models.TokenSubjectCustomer, body.RefreshToken)
+	if err != nil {
+		h.refreshTokenError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response{"success"})
+}
 
-// 	token, err := utils.CreateToken(time.Duration(time.Hour), customer.ID, h.cfg.AccessTokenPrivateKey)
-// 	if err != nil {
-// 		newResponse(c, http.StatusInternalServerError, err.Error())
-// 		h.log.Error("error while token", logger.Error(err))
-// 		return
-// 	}
-// 	c.JSON(http.StatusOK, models.AccessToken{AccessToken: token})
-// }
+// @Summary		  Logout customer everywhere
+// @Description	   this api revokes all refresh tokens of current customer
+// @Tags			Customer
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Success			200		{object}	response
+// @Failure			400,401,403	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/customer/logout-all [POST]
+func (h *CustomerController) LogoutAll(c *gin.Context) {
+	customer := h.GetCustomer(c)
+	if customer.ImpersonatorID != 0 {
+		newResponse(c, http.StatusForbidden, "sessions of customer can not be revoked while impersonating")
+		return
+	}
+	err := h.RevokeSubjectTokens(h.db, models.TokenSubjectCustomer, customer.Id)
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to revoke tokens")
+		h.log.Error("failed to revoke tokens", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, response{"success"})
+}
 
 // @Summary		  Update customer
-// @Description	   this api is for Update customer
+// @Description	   this api is for Update customer, lang is the language of sms notifications and sms_notifications false opts out of them
 // @Tags			Customer
 // @Security		BearerAuth
 // @Accept			json
 // @Produce			json
 // @Param			data 	body		models.CustomerRequest	true	"data body"
 // @Success			201		{object}	models.Customer
-// @Failure			400,409	{object}	response
+// @Failure			400,403,409	{object}	response
 // @Failure			500		{object}	response
 // @Router			/api/customer [PUT]
 func (h *CustomerController) UpdateCustomer(c *gin.Context) {
 	currentUser := h.GetCustomer(c)
+	if currentUser.ImpersonatorID != 0 {
+		newResponse(c, http.StatusForbidden, "account can not be changed while impersonating")
+		return
+	}
 	var body models.CustomerRequest
 	err := c.ShouldBindJSON(&body)
 	if err != nil {
@@ -444,6 +478,16 @@ func (h *CustomerController) UpdateCustomer(c *gin.Context) {
 	if body.Birthday != "" {
 		customer.Birthday = body.Birthday
 	}
+	if body.Lang != "" {
+		if !document.Supported(body.Lang) {
+			newResponse(c, http.StatusBadRequest, "lang must be ru, uz or en")
+			return
+		}
+		customer.Lang = body.Lang
+	}
+	if body.SmsNotifications != nil {
+		customer.SmsNotifications = body.SmsNotifications
+	}
 
 	customer.UpdatedAt = timeNow()
 	err = h.db.Save(&customer).Error
@@ -482,15 +526,16 @@ func (h *CustomerController) CreateCustomerByAdmin(c *gin.Context) {
 	}
 
 	customer := models.Customer{
-		Phone:     phone,
-		Name:      body.Name,
-		CreatedAt: timeNow(),
-		CreatedID: &admin.Id,
+		Phone:         phone,
+		Name:          body.Name,
+		CreatedAt:     timeNow(),
+		CreatedID:     &admin.Id,
+		ResponsibleID: &admin.Id,
 	}
-	err = h.db.Clauses(clause.Returning{}).Create(&customer).Error
+	err = h.db.WithContext(c).Clauses(clause.Returning{}).Create(&customer).Error
 	if err != nil {
 		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
-			err = h.db.First(&customer, "phone=?", phone).Error
+			err = h.db.WithContext(c).First(&customer, "phone=?", phone).Error
 			if err != nil {
 				newResponse(c, http.StatusInternalServerError, "failed to get customer by this phone")
 				return
@@ -541,7 +586,11 @@ func (h *CustomerController) GetMe(c *gin.Context) {
 func (h *CustomerController) GetCustomerById(c *gin.Context) {
 	id := c.Param("id")
 	var admins models.Customer
-	err := h.db.First(&admins, "id=?", id).Error
+	db, ok := h.scoped(c, h.db, scopeCustomers)
+	if !ok {
+		return
+	}
+	err := db.First(&admins, "id=?", id).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			newResponse(c, http.StatusBadRequest, "no such customer")
@@ -566,7 +615,11 @@ func (h *CustomerController) GetCustomerById(c *gin.Context) {
 // @Router			/api/customer   [GET]
 func (h *CustomerController) GetCustomers(c *gin.Context) {
 	var customers []models.Customer
-	err := h.db.Find(&customers).Error
+	db, ok := h.scoped(c, h.db, scopeCustomers)
+	if !ok {
+		return
+	}
+	err := db.Find(&customers).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		h.log.Error("failed to get customer", err.Error())
@@ -574,3 +627,19 @@ func (h *CustomerController) GetCustomers(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, customers)
 }
+
+// @Summary		  Assign customer
+// @Description	   this api sets responsible admin of the customer, orders of the customer are visible to the admin
+// @Tags			Customer
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id     path    int    true    "customer id"
+// @Param			data 	body		models.AssignRequest	true	"data body"
+// @Success			200		{object}	response
+// @Failure			400,404	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/customer/assign/{id} [PUT]
+func (h *CustomerController) AssignCustomer(c *gin.Context) {
+	h.assignResponsible(c, &models.Customer{}, "customer", scopeCustomers)
+}
@@ -0,0 +1,691 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Asliddin3/energy-maximum/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxPriceListImportSize = 5 << 20
+	maxPriceListImportRows = 20000
+)
+
+type CustomerGroupController struct {
+	*Handler
+}
+
+func (h *Handler) NewCustomerGroupController(api *gin.RouterGroup) {
+	group := &CustomerGroupController{h}
+	groups := h.adminGroup(api, "customer-group")
+	{
+		groups.GET("", group.GetCustomerGroups)
+		groups.GET("/:id", group.GetCustomerGroup)
+		groups.POST("", group.CreateCustomerGroup)
+		groups.PUT("/:id", group.UpdateCustomerGroup)
+		groups.DELETE("/:id", group.DeleteCustomerGroup)
+		groups.PUT("/customers", group.AssignCustomerGroup)
+		groups.GET("/:id/price", group.GetPriceList)
+		groups.POST("/:id/price", group.SetPriceListItem)
+		groups.POST("/:id/price/import", group.ImportPriceList)
+		groups.DELETE("/price/:id", group.DeletePriceListItem)
+	}
+}
+
+// setGroupCustomers sets count of customers to the groups.
+func (h *Handler) setGroupCustomers(groups []models.CustomerGroups) error {
+	if len(groups) == 0 {
+		return nil
+	}
+	ids := make([]int, 0, len(groups))
+	for _, group := range groups {
+		ids = append(ids, group.ID)
+	}
+	var counts []struct {
+		GroupID int
+		Count   int
+	}
+	err := h.db.Model(&models.Customer{}).Select("group_id, COUNT(*) AS count").Where("group_id IN ?", ids).
+		Group("group_id").Scan(&counts).Error
+	if err != nil {
+		return err
+	}
+	byGroup := make(map[int]int, len(counts))
+	for _, count := range counts {
+		byGroup[count.GroupID] = count.Count
+	}
+	for i := range groups {
+		groups[i].Customers = byGroup[groups[i].ID]
+	}
+	return nil
+}
+
+// validatePriceListItem checks the row and sets default MinQuantity.
+func validatePriceListItem(item *models.PriceListItemRequest) error {
+	switch item.Kind {
+	case models.PriceKindAll:
+		if item.TargetID != 0 {
+			return errors.New("target_id must be empty for kind all")
+		}
+	case models.PriceKindProduct, models.PriceKindCategory, models.PriceKindBrand:
+		if item.TargetID <= 0 {
+			return errors.New("target_id is required")
+		}
+	default:
+		return errors.New("kind must be product, category, brand or all")
+	}
+	if item.MinQuantity == 0 {
+		item.MinQuantity = 1
+	}
+	if item.MinQuantity < 0 {
+		return errors.New("min_quantity must be positive")
+	}
+	if (item.Price == nil) == (item.Percent == nil) {
+		return errors.New("one of price and percent is required")
+	}
+	if item.Price != nil && *item.Price < 0 {
+		return errors.New("price must not be negative")
+	}
+	if item.Percent != nil && (*item.Percent <= 0 || *item.Percent > 100) {
+		return errors.New("percent must be between 0 and 100")
+	}
+	return nil
+}
+
+// upsertPriceListItems writes rows of the group, row with the same kind, target and min quantity is replaced.
+func upsertPriceListItems(tx *gorm.DB, items []models.PriceListItems) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "group_id"}, {Name: "kind"}, {Name: "target_id"}, {Name: "min_quantity"}},
+		DoUpdates: clause.AssignmentColumns([]string{"price", "percent", "updated_id", "updated_at"}),
+	}).CreateInBatches(items, 500).Error
+}
+
+// @Summary		  Get customer groups
+// @Description	   this api returns customer groups with count of their customers
+// @Tags			CustomerGroup
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param			filter 	query		models.CustomerGroupFilter	false	"filter"
+// @Success			200		{object}	models.CustomerGroupListResponse
+// @Failure			400		{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/customer-group [GET]
+func (h *CustomerGroupController) GetCustomerGroups(c *gin.Context) {
+	var body models.CustomerGroupFilter
+	err := c.ShouldBindQuery(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.Page == 0 {
+		body.Page = 1
+	}
+	if body.PageSize == 0 {
+		body.PageSize = 10
+	}
+	db := h.db.Model(&models.CustomerGroups{})
+	if body.Name != "" {
+		db = db.Where("LOWER(name) LIKE LOWER(?)", fmt.Sprintf("%%%s%%", body.Name))
+	}
+	var count int64
+	err = db.Count(&count).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get customer groups")
+		h.log.Error("failed to count customer groups", err.Error())
+		return
+	}
+	groups := make([]models.CustomerGroups, 0)
+	err = db.Order("id DESC").Limit(body.PageSize).Offset((body.Page - 1) * body.PageSize).Find(&groups).Error
+	if err == nil {
+		err = h.setGroupCustomers(groups)
+	}
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get customer groups")
+		h.log.Error("failed to get customer groups", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, models.CustomerGroupListResponse{
+		Page:     body.Page,
+		PageSize: body.PageSize,
+		Count:    int(count),
+		Groups:   groups,
+	})
+}
+
+// @Summary		  Get customer group
+// @Description	   this api returns customer group by id
+// @Tags			CustomerGroup
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "id"
+// @Success			200		{object}	models.CustomerGroups
+// @Failure			404		{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/customer-group/{id} [GET]
+func (h *CustomerGroupController) GetCustomerGroup(c *gin.Context) {
+	var group models.CustomerGroups
+	err := h.db.Preload("Created", GetUserFields).First(&group, "id=?", c.Param("id")).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			newResponse(c, http.StatusNotFound, "customer group not found")
+			return
+		}
+		newResponse(c, http.StatusInternalServerError, "failed to get customer group")
+		h.log.Error("failed to get customer group", err.Error())
+		return
+	}
+	groups := []models.CustomerGroups{group}
+	err = h.setGroupCustomers(groups)
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get customer group")
+		h.log.Error("failed to count customers of group", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, groups[0])
+}
+
+// @Summary		  Create customer group
+// @Description	   this api creates customer group, prices of the group are set by its price list
+// @Tags			CustomerGroup
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param			data 	body		models.CustomerGroupRequest	true	"data body"
+// @Success			200		{object}	models.CustomerGroups
+// @Failure			400,409	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/customer-group [POST]
+func (h *CustomerGroupController) CreateCustomerGroup(c *gin.Context) {
+	admin := h.GetAdmin(c)
+	var body models.CustomerGroupRequest
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	group := models.CustomerGroups{
+		Name:        strings.TrimSpace(body.Name),
+		Description: body.Description,
+		IsActive:    body.IsActive,
+		CreatedID:   &admin.Id,
+		CreatedAt:   timeNow(),
+	}
+	err = h.db.WithContext(c).Create(&group).Error
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique") {
+			newResponse(c, http.StatusConflict, "customer group already exists")
+			return
+		}
+		newResponse(c, http.StatusInternalServerError, "failed to create customer group")
+		h.log.Error("failed to create customer group", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+// @Summary		  Update customer group
+// @Description	   this api updates customer group, inactive group keeps its customers on retail prices
+// @Tags			CustomerGroup
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "id"
+// @Param			data 	body		models.CustomerGroupRequest	true	"data body"
+// @Success			200		{object}	models.CustomerGroups
+// @Failure			400,404,409	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/customer-group/{id} [PUT]
+func (h *CustomerGroupController) UpdateCustomerGroup(c *gin.Context) {
+	admin := h.GetAdmin(c)
+	var body models.CustomerGroupRequest
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	var group models.CustomerGroups
+	err = h.db.First(&group, "id=?", c.Param("id")).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			newResponse(c, http.StatusNotFound, "customer group not found")
+			return
+		}
+		newResponse(c, http.StatusInternalServerError, "failed to get customer group")
+		h.log.Error("failed to get customer group", err.Error())
+		return
+	}
+	group.Name = strings.TrimSpace(body.Name)
+	group.Description = body.Description
+	if body.IsActive != nil {
+		group.IsActive = body.IsActive
+	}
+	group.UpdatedID = &admin.Id
+	group.UpdatedAt = timeNow()
+	err = h.db.WithContext(c).Save(&group).Error
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique") {
+			newResponse(c, http.StatusConflict, "customer group already exists")
+			return
+		}
+		newResponse(c, http.StatusInternalServerError, "failed to update customer group")
+		h.log.Error("failed to update customer group", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+// @Summary		  Delete customer group
+// @Description	   this api deletes customer group with its price list, customers of the group return to retail prices
+// @Tags			CustomerGroup
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "id"
+// @Success			200		{object}	response
+// @Failure			404		{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/customer-group/{id} [DELETE]
+func (h *CustomerGroupController) DeleteCustomerGroup(c *gin.Context) {
+	result := h.db.WithContext(c).Delete(&models.CustomerGroups{}, "id=?", c.Param("id"))
+	if result.Error != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to delete customer group")
+		h.log.Error("failed to delete customer group", result.Error.Error())
+		return
+	}
+	if result.RowsAffected == 0 {
+		newResponse(c, http.StatusNotFound, "customer group not found")
+		return
+	}
+	c.JSON(http.StatusOK, response{"success"})
+}
+
+// @Summary		  Assign customers to group
+// @Description	   this api moves customers to the group, empty group_id returns them to retail prices. Placed orders keep their prices
+// @Tags			CustomerGroup
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param			data 	body		models.CustomerGroupAssignRequest	true	"data body"
+// @Success			200		{object}	response
+// @Failure			400,404	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/customer-group/customers [PUT]
+func (h *CustomerGroupController) AssignCustomerGroup(c *gin.Context) {
+	admin := h.GetAdmin(c)
+	var body models.CustomerGroupAssignRequest
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(body.CustomerIDs) == 0 {
+		newResponse(c, http.StatusBadRequest, "customer_ids are required")
+		return
+	}
+	if body.GroupID != nil {
+		var count int64
+		err = h.db.Model(&models.CustomerGroups{}).Where("id=?", *body.GroupID).Count(&count).Error
+		if err != nil {
+			newResponse(c, http.StatusInternalServerError, "failed to get customer group")
+			h.log.Error("failed to get customer group", err.Error())
+			return
+		}
+		if count == 0 {
+			newResponse(c, http.StatusNotFound, "customer group not found")
+			return
+		}
+	}
+	db, ok := h.scoped(c, h.db.WithContext(c).Model(&models.Customer{}), scopeCustomers)
+	if !ok {
+		return
+	}
+	result := db.Where("customer.id IN ?", body.CustomerIDs).Updates(map[string]interface{}{
+		"group_id":   body.GroupID,
+		"updated_at": timeNow(),
+		"updated_id": admin.Id,
+	})
+	if result.Error != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to assign customers")
+		h.log.Error("failed to assign customers to group", result.Error.Error())
+		return
+	}
+	if result.RowsAffected == 0 {
+		newResponse(c, http.StatusNotFound, "customers not found")
+		return
+	}
+	c.JSON(http.StatusOK, response{"success"})
+}
+
+// @Summary		  Get price list
+// @Description	   this api returns rows of the price list of the group
+// @Tags			CustomerGroup
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "group id"
+// @Param			filter 	query		models.PriceListFilter	false	"filter"
+// @Success			200		{object}	models.PriceListResponse
+// @Failure			400		{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/customer-group/{id}/price [GET]
+func (h *CustomerGroupController) GetPriceList(c *gin.Context) {
+	var body models.PriceListFilter
+	err := c.ShouldBindQuery(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.Page == 0 {
+		body.Page = 1
+	}
+	if body.PageSize == 0 {
+		body.PageSize = 10
+	}
+	db := h.db.Model(&models.PriceListItems{}).Where("group_id=?", c.Param("id"))
+	if body.Kind != "" {
+		db = db.Where("kind=?", body.Kind)
+	}
+	if body.TargetID != 0 {
+		db = db.Where("target_id=?", body.TargetID)
+	}
+	var count int64
+	err = db.Count(&count).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get price list")
+		h.log.Error("failed to count price list", err.Error())
+		return
+	}
+	items := make([]models.PriceListItems, 0)
+	err = db.Order("kind, target_id, min_quantity").Limit(body.PageSize).Offset((body.Page - 1) * body.PageSize).
+		Find(&items).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get price list")
+		h.log.Error("failed to get price list", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, models.PriceListResponse{
+		Page:     body.Page,
+		PageSize: body.PageSize,
+		Count:    int(count),
+		Items:    items,
+	})
+}
+
+// @Summary		  Set price list row
+// @Description	   this api sets price of product, category, brand or all products for the group from min_quantity pieces. Price replaces the retail price, percent is taken off it. Row with the same kind, target and min_quantity is replaced
+// @Tags			CustomerGroup
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "group id"
+// @Param			data 	body		models.PriceListItemRequest	true	"data body"
+// @Success			200		{object}	models.PriceListItems
+// @Failure			400,404	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/customer-group/{id}/price [POST]
+func (h *CustomerGroupController) SetPriceListItem(c *gin.Context) {
+	admin := h.GetAdmin(c)
+	groupID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, "Invalid id")
+		return
+	}
+	var body models.PriceListItemRequest
+	err = c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	err = validatePriceListItem(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	item := models.PriceListItems{
+		GroupID:     groupID,
+		Kind:        body.Kind,
+		TargetID:    body.TargetID,
+		MinQuantity: body.MinQuantity,
+		Price:       body.Price,
+		Percent:     body.Percent,
+		UpdatedID:   &admin.Id,
+		UpdatedAt:   timeNow(),
+	}
+	err = upsertPriceListItems(h.db.WithContext(c), []models.PriceListItems{item})
+	if err != nil {
+		if strings.Contains(err.Error(), "violates foreign key") {
+			newResponse(c, http.StatusNotFound, "customer group not found")
+			return
+		}
+		newResponse(c, http.StatusInternalServerError, "failed to set price")
+		h.log.Error("failed to set price list item", err.Error())
+		return
+	}
+	err = h.db.First(&item, "group_id=? AND kind=? AND target_id=? AND min_quantity=?",
+		groupID, item.Kind, item.TargetID, item.MinQuantity).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get price")
+		h.log.Error("failed to get price list item", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, item)
+}
+
+// @Summary		  Delete price list row
+// @Description	   this api deletes row of the price list
+// @Tags			CustomerGroup
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "price list row id"
+// @Success			200		{object}	response
+// @Failure			404		{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/customer-group/price/{id} [DELETE]
+func (h *CustomerGroupController) DeletePriceListItem(c *gin.Context) {
+	result := h.db.WithContext(c).Delete(&models.PriceListItems{}, "id=?", c.Param("id"))
+	if result.Error != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to delete price")
+		h.log.Error("failed to delete price list item", result.Error.Error())
+		return
+	}
+	if result.RowsAffected == 0 {
+		newResponse(c, http.StatusNotFound, "price not found")
+		return
+	}
+	c.JSON(http.StatusOK, response{"success"})
+}
+
+// readPriceList parses csv with header kind, target_id, min_quantity, price, percent. Columns may go in any
+// order, min_quantity, price and percent may be missing. Comma and semicolon separated files are accepted.
+func readPriceList(data []byte) ([]models.PriceListItemRequest, error) {
+	data = bytes.TrimPrefix(data, []byte("\uFEFF"))
+	reader := csv.NewReader(bytes.NewReader(data))
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("file must start with header kind, target_id, min_quantity, price, percent")
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["kind"]; !ok {
+		return nil, errors.New("kind column is required")
+	}
+	cell := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	number := func(record []string, name string, line int) (*float64, error) {
+		value := strings.ReplaceAll(cell(record, name), ",", ".")
+		if value == "" {
+			return nil, nil
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s must be a number", line, name)
+		}
+		return &f, nil
+	}
+	items := make([]models.PriceListItemRequest, 0)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err.Error())
+		}
+		if len(strings.Join(record, "")) == 0 {
+			continue
+		}
+		if len(items) == maxPriceListImportRows {
+			return nil, fmt.Errorf("file must have at most %d rows", maxPriceListImportRows)
+		}
+		item := models.PriceListItemRequest{Kind: strings.ToLower(cell(record, "kind"))}
+		for name, dest := range map[string]*int{"target_id": &item.TargetID, "min_quantity": &item.MinQuantity} {
+			if value := cell(record, name); value != "" {
+				*dest, err = strconv.Atoi(value)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %s must be an integer", line, name)
+				}
+			}
+		}
+		item.Price, err = number(record, "price", line)
+		if err == nil {
+			item.Percent, err = number(record, "percent", line)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = validatePriceListItem(&item)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err.Error())
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// @Summary		  Import price list
+// @Description	   this api imports rows of the price list from csv file with header kind, target_id, min_quantity, price, percent. Rows replace rows with the same kind, target and min_quantity, with replace=true other rows of the group are deleted. Nothing is imported when a row is invalid
+// @Tags			CustomerGroup
+// @Security		BearerAuth
+// @Accept			multipart/form-data
+// @Produce			json
+// @Param           id    path     int   true   "group id"
+// @Param           file  formData file  true   "csv file"
+// @Param           replace  query    bool   false   "delete rows which are not in the file"
+// @Success			200		{object}	models.PriceListImportResponse
+// @Failure			400,404	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/customer-group/{id}/price/import [POST]
+func (h *CustomerGroupController) ImportPriceList(c *gin.Context) {
+	admin := h.GetAdmin(c)
+	groupID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, "Invalid id")
+		return
+	}
+	file, err := c.FormFile("file")
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, "file is required")
+		return
+	}
+	if file.Size > maxPriceListImportSize {
+		newResponse(c, http.StatusBadRequest, "file is too large")
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, "failed to read file")
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(f, maxPriceListImportSize))
+	f.Close()
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, "failed to read file")
+		return
+	}
+	rows, err := readPriceList(data)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	// the last row wins when the file repeats a row, one insert can not update the same row twice
+	type rowKey struct {
+		kind        string
+		targetID    int
+		minQuantity int
+	}
+	byKey := map[rowKey]int{}
+	items := make([]models.PriceListItems, 0, len(rows))
+	for _, row := range rows {
+		item := models.PriceListItems{
+			GroupID:     groupID,
+			Kind:        row.Kind,
+			TargetID:    row.TargetID,
+			MinQuantity: row.MinQuantity,
+			Price:       row.Price,
+			Percent:     row.Percent,
+			UpdatedID:   &admin.Id,
+			UpdatedAt:   timeNow(),
+		}
+		key := rowKey{row.Kind, row.TargetID, row.MinQuantity}
+		if i, ok := byKey[key]; ok {
+			items[i] = item
+			continue
+		}
+		byKey[key] = len(items)
+		items = append(items, item)
+	}
+	tx := h.db.WithContext(c).Begin()
+	var found []int
+	err = tx.Model(&models.CustomerGroups{}).Clauses(clause.Locking{Strength: "UPDATE"}).Where("id=?", groupID).Pluck("id", &found).Error
+	if err != nil {
+		tx.Rollback()
+		newResponse(c, http.StatusInternalServerError, "failed to get customer group")
+		h.log.Error("failed to get customer group", err.Error())
+		return
+	}
+	if len(found) == 0 {
+		tx.Rollback()
+		newResponse(c, http.StatusNotFound, "customer group not found")
+		return
+	}
+	if c.Query("replace") == "true" {
+		err = tx.Delete(&models.PriceListItems{}, "group_id=?", groupID).Error
+	}
+	if err == nil && len(items) != 0 {
+		err = upsertPriceListItems(tx, items)
+	}
+	if err != nil {
+		tx.Rollback()
+		newResponse(c, http.StatusInternalServerError, "failed to import price list")
+		h.log.Error("failed to import price list", err.Error())
+		return
+	}
+	tx.Commit()
+	c.JSON(http.StatusOK, models.PriceListImportResponse{Imported: len(items)})
+}
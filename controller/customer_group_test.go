@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Asliddin3/energy-maximum/models"
+)
+
+func TestReadPriceList(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []models.PriceListItemRequest
+		wantErr string
+	}{
+		{
+			name: "comma separated",
+			data: "kind,target_id,min_quantity,price,percent\nproduct,7,10,150.5,\nall,,,,5\n",
+			want: []models.PriceListItemRequest{
+				{Kind: models.PriceKindProduct, TargetID: 7, MinQuantity: 10, Price: floatPtr(150.5)},
+				{Kind: models.PriceKindAll, MinQuantity: 1, Percent: floatPtr(5)},
+			},
+		},
+		{
+			name: "semicolons, comma decimals and byte order mark",
+			data: string(rune(0xFEFF)) + "Kind;Target_ID;Price\r\nCategory;3;99,90\r\n",
+			want: []models.PriceListItemRequest{
+				{Kind: models.PriceKindCategory, TargetID: 3, MinQuantity: 1, Price: floatPtr(99.9)},
+			},
+		},
+		{
+			name: "columns in any order and empty lines",
+			data: "percent,kind,target_id\n\n12,brand,4\n",
+			want: []models.PriceListItemRequest{
+				{Kind: models.PriceKindBrand, TargetID: 4, MinQuantity: 1, Percent: floatPtr(12)},
+			},
+		},
+		{name: "empty file", data: "", wantErr: "file must start with header"},
+		{name: "no kind column", data: "target_id,price\n1,2\n", wantErr: "kind column is required"},
+		{name: "not integer target", data: "kind,target_id,price\nproduct,x,2\n", wantErr: "line 2: target_id must be an integer"},
+		{name: "not number price", data: "kind,target_id,price\nproduct,1,abc\n", wantErr: "line 2: price must be a number"},
+		{name: "price and percent", data: "kind,target_id,price,percent\nproduct,1,2,3\n", wantErr: "line 2: one of price and percent is required"},
+		{name: "unknown kind", data: "kind,target_id,price\nservice,1,2\n", wantErr: "line 2: kind must be"},
+		{name: "target for all", data: "kind,target_id,price\nall,1,2\n", wantErr: "line 2: target_id must be empty for kind all"},
+		{name: "percent above 100", data: "kind,percent\nall,120\n", wantErr: "line 2: percent must be between 0 and 100"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readPriceList([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("readPriceList() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readPriceList() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readPriceList() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("too many rows", func(t *testing.T) {
+		data := "kind,price\n" + strings.Repeat("all,1\n", maxPriceListImportRows+1)
+		_, err := readPriceList([]byte(data))
+		if err == nil || !strings.Contains(err.Error(), "at most") {
+			t.Fatalf("readPriceList() error = %v, want row limit error", err)
+		}
+	})
+}
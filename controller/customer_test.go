@@ -0,0 +1,27 @@
+package controller
+
+import "testing"
+
+func TestFormatPhone(t *testing.T) {
+	tests := []struct {
+		phone string
+		want  string
+	}{
+		{"998901234567", "998901234567"},
+		{"+998 90-123-45-67", "998901234567"},
+		{"+998 (90) 123-45-67", "998901234567"},
+		{"(90) 123-45-67", "998901234567"},
+		{"901234567", "998901234567"},
+		{"90123456", ""},
+		{"9989012345678", ""},
+		{"phone", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.phone, func(t *testing.T) {
+			if got := formatPhone(tt.phone); got != tt.want {
+				t.Errorf("formatPhone(%q) = %q, want %q", tt.phone, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Asliddin3/energy-maximum/models"
+	"github.com/Asliddin3/energy-maximum/pkg/document"
+	"github.com/Asliddin3/energy-maximum/pkg/export"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// exportColumn is the title of an exported column in uzbek, russian and english.
+type exportColumn struct {
+	uz, ru, en string
+}
+
+// exportTable streams rows of db to the client as CSV or XLSX, format and lang are taken from the query.
+// Every row is scanned to dest and written as cells returned by row. Once the first byte is sent the
+// status can not be changed, so later errors are only logged and the client gets a cut file.
+func (h *Handler) exportTable(c *gin.Context, name string, columns []exportColumn, db *gorm.DB,
+	dest interface{}, row func() []interface{}) {
+	format := c.DefaultQuery("format", export.FormatXLSX)
+	if !export.Supported(format) {
+		newResponse(c, http.StatusBadRequest, "format must be csv or xlsx")
+		return
+	}
+	lang := c.DefaultQuery("lang", document.LangRu)
+	if !document.Supported(lang) {
+		newResponse(c, http.StatusBadRequest, "lang must be ru, uz or en")
+		return
+	}
+	rows, err := db.Rows()
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to export "+name)
+		h.log.Error("failed to export "+name, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.%s"`, name, time.Now().Format("2006-01-02"), format))
+	c.Header("Content-Type", export.ContentType(format))
+	c.Status(http.StatusOK)
+	w, err := export.New(c.Writer, format, name)
+	if err != nil {
+		h.log.Error("failed to export "+name, err.Error())
+		return
+	}
+	titles := make([]interface{}, len(columns))
+	for i, col := range columns {
+		titles[i] = localized(lang, col.uz, col.ru, col.en)
+	}
+	err = w.WriteRow(titles...)
+	for err == nil && rows.Next() {
+		err = db.ScanRows(rows, dest)
+		if err == nil {
+			err = w.WriteRow(row()...)
+		}
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		h.log.Error("failed to export "+name, err.Error())
+	}
+}
+
+type orderExportRow struct {
+	ID            int
+	CreatedAt     *time.Time
+	Status        string
+	CustomerName  string
+	CustomerPhone string
+	Warehouse     string
+	Description   string
+	Discount      float64
+	Total         float64
+}
+
+// @Summary		  Export orders
+// @Description	   this api streams orders matching the filter of the order list as csv or xlsx, deleted orders are skipped
+// @Tags			Order
+// @Security		BearerAuth
+// @Produce			application/vnd.openxmlformats-officedocument.spreadsheetml.sheet,text/csv
+// @Param			filter   query   models.AdminOrderFilter  false "filter"
+// @Param           format  query    string   false   "xlsx or csv"
+// @Param           lang  query    string   false   "ru, uz or en"
+// @Success			200		{file}		file
+// @Failure			400		{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/order/export  [GET]
+func (h *OrderController) ExportOrders(c *gin.Context) {
+	var body models.AdminOrderFilter
+	err := c.ShouldBindQuery(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	db, ok := h.scoped(c, h.db.Table("orders"), scopeOrders)
+	if !ok {
+		return
+	}
+	db = filterOrders(db, body).
+		Select(`orders.id, orders.created_at, orders.status, customer.name AS customer_name,
+			customer.phone AS customer_phone, warehouses.name AS warehouse, orders.description, orders.discount, orders.total`).
+		Joins("LEFT JOIN customer ON customer.id=orders.customer_id").
+		Joins("LEFT JOIN warehouses ON warehouses.id=orders.warehouse_id").
+		Where("orders.deleted_at IS NULL").
+		Order("orders.id")
+	var order orderExportRow
+	h.exportTable(c, "orders", []exportColumn{
+		{"Buyurtma №", "Заказ №", "Order No"},
+		{"Sana", "Дата", "Date"},
+		{"Holati", "Статус", "Status"},
+		{"Xaridor", "Покупатель", "Customer"},
+		{"Telefon", "Телефон", "Phone"},
+		{"Ombor", "Склад", "Warehouse"},
+		{"Izoh", "Комментарий", "Comment"},
+		{"Chegirma", "Скидка", "Discount"},
+		{"Summa", "Сумма", "Amount"},
+	}, db, &order, func() []interface{} {
+		return []interface{}{order.ID, order.CreatedAt, order.Status, order.CustomerName, order.CustomerPhone,
+			order.Warehouse, order.Description, order.Discount, order.Total}
+	})
+}
+
+type orderLineExportRow struct {
+	OrderID       int
+	CreatedAt     *time.Time
+	Status        string
+	CustomerName  string
+	CustomerPhone string
+	ItemId        int
+	NameUz        string
+	NameRu        string
+	NameEn        string
+	Price         float64
+	Amount        int
+	Total         float64
+}
+
+// @Summary		  Export order lines
+// @Description	   this api streams lines of orders matching the filter of the order list as csv or xlsx, one row per line
+// @Tags			Order
+// @Security		BearerAuth
+// @Produce			application/vnd.openxmlformats-officedocument.spreadsheetml.sheet,text/csv
+// @Param			filter   query   models.AdminOrderFilter  false "filter"
+// @Param           format  query    string   false   "xlsx or csv"
+// @Param           lang  query    string   false   "ru, uz or en"
+// @Success			200		{file}		file
+// @Failure			400		{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/order/export/items  [GET]
+func (h *OrderController) ExportOrderItems(c *gin.Context) {
+	var body models.AdminOrderFilter
+	err := c.ShouldBindQuery(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	db, ok := h.scoped(c, h.db.Table("order_items").Joins("JOIN orders ON orders.id=order_items.order_id"), scopeOrders)
+	if !ok {
+		return
+	}
+	db = filterOrders(db, body).
+		Select(`orders.id AS order_id, orders.created_at, orders.status, customer.name AS customer_name,
+			customer.phone AS customer_phone, order_items.item_id, order_items.name_uz, order_items.name_ru,
+			order_items.name_en, order_items.price, order_items.amount, order_items.total`).
+		Joins("LEFT JOIN customer ON customer.id=orders.customer_id").
+		Where("orders.deleted_at IS NULL").
+		Order("orders.id, order_items.id")
+	lang := c.DefaultQuery("lang", document.LangRu)
+	var line orderLineExportRow
+	h.exportTable(c, "order-items", []exportColumn{
+		{"Buyurtma №", "Заказ №", "Order No"},
+		{"Sana", "Дата", "Date"},
+		{"Holati", "Статус", "Status"},
+		{"Xaridor", "Покупатель", "Customer"},
+		{"Telefon", "Телефон", "Phone"},
+		{"Mahsulot ID", "ID товара", "Product ID"},
+		{"Mahsulot", "Товар", "Product"},
+		{"Narxi", "Цена", "Price"},
+		{"Soni", "Кол-во", "Qty"},
+		{"Summa", "Сумма", "Amount"},
+	}, db, &line, func() []interface{} {
+		return []interface{}{line.OrderID, line.CreatedAt, line.Status, line.CustomerName, line.CustomerPhone,
+			line.ItemId, localized(lang, line.NameUz, line.NameRu, line.NameEn), line.Price, line.Amount, line.Total}
+	})
+}
+
+// @Summary		  Export order applicants
+// @Description	   this api streams order applicants matching the filter of the applicant list as csv or xlsx
+// @Tags			Order
+// @Security		BearerAuth
+// @Produce			application/vnd.openxmlformats-officedocument.spreadsheetml.sheet,text/csv
+// @Param			filter   query   models.OrderApplicantFilter  false "filter"
+// @Param           format  query    string   false   "xlsx or csv"
+// @Param           lang  query    string   false   "ru, uz or en"
+// @Success			200		{file}		file
+// @Failure			400		{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/order/applicant/export  [GET]
+func (h *OrderController) ExportOrderApplicants(c *gin.Context) {
+	var body models.OrderApplicantFilter
+	err := c.ShouldBindQuery(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	db, ok := h.scoped(c, h.db.Model(&models.OrderApplicant{}), scopeOrderApplicants)
+	if !ok {
+		return
+	}
+	db = filterOrderApplicants(db, body).Select("id, created_at, full_name, phone, message, status, follow_up_at").Order("id")
+	var applicant models.OrderApplicant
+	h.exportTable(c, "order-applicants", []exportColumn{
+		{"№", "№", "No"},
+		{"Sana", "Дата", "Date"},
+		{"F.I.Sh.", "ФИО", "Full name"},
+		{"Telefon", "Телефон", "Phone"},
+		{"Xabar", "Сообщение", "Message"},
+		{"Holati", "Статус", "Status"},
+		{"Keyingi aloqa", "Следующий контакт", "Follow up"},
+	}, db, &applicant, func() []interface{} {
+		return []interface{}{applicant.ID, applicant.CreatedAt, applicant.FullName, applicant.Phone, applicant.Message,
+			applicant.Status, applicant.FollowUpAt}
+	})
+}
+
+// @Summary		  Export customers
+// @Description	   this api streams customers visible to the admin as csv or xlsx
+// @Tags			Customer
+// @Security		BearerAuth
+// @Produce			application/vnd.openxmlformats-officedocument.spreadsheetml.sheet,text/csv
+// @Param           format  query    string   false   "xlsx or csv"
+// @Param           lang  query    string   false   "ru, uz or en"
+// @Success			200		{file}		file
+// @Failure			400		{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/customer/export  [GET]
+func (h *CustomerController) ExportCustomers(c *gin.Context) {
+	db, ok := h.scoped(c, h.db.Model(&models.Customer{}), scopeCustomers)
+	if !ok {
+		return
+	}
+	db = db.Select("id, name, phone, email, birthday, created_at, last_visit").Order("id")
+	var customer models.Customer
+	h.exportTable(c, "customers", []exportColumn{
+		{"№", "№", "No"},
+		{"F.I.Sh.", "ФИО", "Full name"},
+		{"Telefon", "Телефон", "Phone"},
+		{"E-mail", "E-mail", "E-mail"},
+		{"Tug‘ilgan sana", "Дата рождения", "Birthday"},
+		{"Ro‘yxatdan o‘tgan", "Дата регистрации", "Registered"},
+		{"Oxirgi tashrif", "Последний визит", "Last visit"},
+	}, db, &customer, func() []interface{} {
+		return []interface{}{customer.ID, customer.Name, customer.Phone, customer.Email, customer.Birthday,
+			customer.CreatedAt, customer.LastVisit}
+	})
+}
@@ -0,0 +1,202 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Asliddin3/energy-maximum/models"
+	"github.com/Asliddin3/energy-maximum/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// logImpersonation writes the request to impersonation trail.
+func (h *Handler) logImpersonation(c *gin.Context, adminID, customerID int, sessionID, reason string) {
+	path := []rune(c.Request.URL.RequestURI())
+	if len(path) > 500 {
+		path = path[:500]
+	}
+	entry := models.ImpersonationLogs{
+		SessionID:  sessionID,
+		AdminID:    &adminID,
+		CustomerID: customerID,
+		Method:     c.Request.Method,
+		Path:       string(path),
+		Status:     c.Writer.Status(),
+		Reason:     reason,
+		IP:         c.ClientIP(),
+		CreatedAt:  timeNow(),
+	}
+	err := h.db.Create(&entry).Error
+	if err != nil {
+		h.log.Error("failed to save impersonation log", logger.Error(err))
+	}
+}
+
+// impersonateEndPoint is the route which issues impersonation tokens, the admin must keep the permission for it
+// while the token is used.
+const impersonateEndPoint = "/api/customer/impersonate/:id"
+
+// authenticateImpersonation checks customer token issued to an admin, the admin must still be active and
+// allowed to impersonate customers. It aborts the request and returns false when the token can not be used.
+func (h *Handler) authenticateImpersonation(ctx *gin.Context, user *models.CustomerMetadata, claims map[string]interface{}) bool {
+	adminID, _ := claims["imp"].(float64)
+	sessionID, _ := claims["jti"].(string)
+	if adminID == 0 || sessionID == "" {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": "invalid token"})
+		return false
+	}
+	var admins []models.Admins
+	err := h.db.Where("id=? AND deleted_at IS NULL AND is_active=true", int(adminID)).Limit(1).Find(&admins).Error
+	allowed := err == nil && len(admins) != 0
+	if allowed && (admins[0].IsSuperuser == nil || !*admins[0].IsSuperuser) {
+		var permissions []models.AdminPermission
+		permissions, err = h.loadRolePermissions(admins[0].RoleID)
+		allowed = false
+		for _, permission := range permissions {
+			if matchPermission(permission, http.MethodPost, impersonateEndPoint) {
+				allowed = true
+				break
+			}
+		}
+	}
+	var count int64
+	if err == nil && allowed {
+		err = h.db.Model(&models.Customer{}).Where("id=?", user.Id).Count(&count).Error
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return false
+	}
+	if count == 0 {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": "impersonation is expired"})
+		return false
+	}
+	user.ImpersonatorID = int(adminID)
+	user.ImpersonationID = sessionID
+	return true
+}
+
+// @Summary		  Impersonate customer
+// @Description	   this api issues short-lived customer access token for support, requests with the token are logged and last visit of the customer is not changed
+// @Tags			Customer
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id     path    int    true    "customer id"
+// @Param			data 	body		models.ImpersonateRequest	true	"data body"
+// @Success			200		{object}	models.ImpersonationTokenResponse
+// @Failure			400,403	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/customer/impersonate/{id} [POST]
+func (h *CustomerController) ImpersonateCustomer(c *gin.Context) {
+	admin := h.GetAdmin(c)
+	if admin.ApiKeyID != 0 {
+		newResponse(c, http.StatusForbidden, "customers can not be impersonated with api key")
+		return
+	}
+	var body models.ImpersonateRequest
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	db, ok := h.scoped(c, h.db, scopeCustomers)
+	if !ok {
+		return
+	}
+	var customer models.Customer
+	err = db.First(&customer, "id=?", c.Param("id")).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			newResponse(c, http.StatusBadRequest, "no such customer")
+			return
+		}
+		newResponse(c, http.StatusInternalServerError, "failed to get customer")
+		h.log.Error("failed to get customer", err.Error())
+		return
+	}
+	sessionID := uuid.NewString()
+	expiresAt := time.Now().Add(h.cfg.ImpersonationTTL)
+	token, err := h.accessKeys.CreateToken(h.cfg.ImpersonationTTL, customer.ID, map[string]interface{}{
+		"role": models.TokenSubjectCustomer,
+		"imp":  admin.Id,
+		"jti":  sessionID,
+	})
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to create token")
+		h.log.Error("failed to create impersonation token", logger.Error(err))
+		return
+	}
+	h.logImpersonation(c, admin.Id, customer.ID, sessionID, body.Reason)
+	h.log.Infof("admin %d impersonates customer %d, session %s", admin.Id, customer.ID, sessionID)
+	c.JSON(http.StatusOK, models.ImpersonationTokenResponse{
+		AccessToken: token,
+		SessionID:   sessionID,
+		ExpiresAt:   &expiresAt,
+	})
+}
+
+// @Summary		  Get impersonation logs
+// @Description	   this api returns issued impersonation tokens and requests made with them, newest first
+// @Tags			Customer
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param			filter 	query		models.ImpersonationLogFilter	false	"filter"
+// @Success			200		{object}	models.ImpersonationLogResponse
+// @Failure			400,409	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/customer/impersonation-logs [GET]
+func (h *CustomerController) GetImpersonationLogs(c *gin.Context) {
+	var body models.ImpersonationLogFilter
+	err := c.ShouldBindQuery(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.Page == 0 {
+		body.Page = 1
+	}
+	if body.PageSize == 0 {
+		body.PageSize = 10
+	}
+	db := h.db.Model(&models.ImpersonationLogs{})
+	if body.AdminID != 0 {
+		db = db.Where("admin_id=?", body.AdminID)
+	}
+	if body.CustomerID != 0 {
+		db = db.Where("customer_id=?", body.CustomerID)
+	}
+	if body.SessionID != "" {
+		db = db.Where("session_id=?", body.SessionID)
+	}
+	if body.DateFrom != "" {
+		db = db.Where("created_at>=?", body.DateFrom)
+	}
+	if body.DateTo != "" {
+		db = db.Where("created_at<=?", body.DateTo)
+	}
+	var count int64
+	err = db.Count(&count).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get impersonation logs")
+		h.log.Error("failed to count impersonation logs", err.Error())
+		return
+	}
+	var logs []models.ImpersonationLogs
+	err = db.Order("id DESC").Limit(body.PageSize).Offset((body.Page - 1) * body.PageSize).Find(&logs).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get impersonation logs")
+		h.log.Error("failed to get impersonation logs", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, models.ImpersonationLogResponse{
+		Page:     body.Page,
+		PageSize: body.PageSize,
+		Count:    int(count),
+		Logs:     logs,
+	})
+}
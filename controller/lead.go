@@ -0,0 +1,403 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Asliddin3/energy-maximum/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// leadStatuses can be set by admins, won is set only by conversion and won lead is not changed.
+var leadStatuses = map[string]bool{
+	models.LeadStatusNew:       true,
+	models.LeadStatusContacted: true,
+	models.LeadStatusQualified: true,
+	models.LeadStatusLost:      true,
+}
+
+// lockLead returns the lead visible to the admin locked for update in tx. It writes the response and
+// returns false on failure, tx is rolled back then.
+func (h *OrderController) lockLead(c *gin.Context, tx *gorm.DB) (*models.OrderApplicant, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		tx.Rollback()
+		newResponse(c, http.StatusBadRequest, "Invalid id")
+		return nil, false
+	}
+	db, ok := h.scoped(c, tx.Model(&models.OrderApplicant{}), scopeOrderApplicants)
+	if !ok {
+		tx.Rollback()
+		return nil, false
+	}
+	var lead models.OrderApplicant
+	err = db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&lead, "order_applicant.id=?", id).Error
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			newResponse(c, http.StatusNotFound, "not found order applicant")
+			return nil, false
+		}
+		newResponse(c, http.StatusInternalServerError, "failed to get order applicant")
+		h.log.Error("failed to get order applicant", err.Error())
+		return nil, false
+	}
+	return &lead, true
+}
+
+// @Summary		  Change lead status
+// @Description	   this api moves order applicant to new, contacted, qualified or lost status, the comment is written to the timeline. Won status is set by conversion
+// @Tags			Order
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "order applicant id"
+// @Param			data 	body		models.LeadStatusRequest	true	"data body"
+// @Success			200		{object}	models.OrderApplicant
+// @Failure			400,404,409	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/order/applicant/status/{id} [PUT]
+func (h *OrderController) SetLeadStatus(c *gin.Context) {
+	admin := h.GetAdmin(c)
+	var body models.LeadStatusRequest
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !leadStatuses[body.Status] {
+		newResponse(c, http.StatusBadRequest, "status must be new, contacted, qualified or lost")
+		return
+	}
+	tx := h.db.WithContext(c).Begin()
+	lead, ok := h.lockLead(c, tx)
+	if !ok {
+		return
+	}
+	if lead.Status == models.LeadStatusWon {
+		tx.Rollback()
+		newResponse(c, http.StatusConflict, "order applicant is converted")
+		return
+	}
+	from := lead.Status
+	lead.Status = body.Status
+	lead.UpdatedAt = timeNow()
+	err = tx.Model(lead).Updates(map[string]interface{}{
+		"status":     lead.Status,
+		"updated_at": lead.UpdatedAt,
+	}).Error
+	if err == nil {
+		err = tx.Create(&models.LeadNotes{
+			LeadID:     lead.ID,
+			Text:       body.Comment,
+			FromStatus: from,
+			ToStatus:   lead.Status,
+			AdminID:    &admin.Id,
+			CreatedAt:  timeNow(),
+		}).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		newResponse(c, http.StatusInternalServerError, "failed to update order applicant status")
+		h.log.Error("failed to update order applicant status", err.Error())
+		return
+	}
+	tx.Commit()
+	c.JSON(http.StatusOK, lead)
+}
+
+// @Summary		  Set lead follow up
+// @Description	   this api plans the next contact with order applicant, null follow_up_at clears it
+// @Tags			Order
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "order applicant id"
+// @Param			data 	body		models.LeadFollowUpRequest	true	"data body"
+// @Success			200		{object}	models.OrderApplicant
+// @Failure			400,404	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/order/applicant/follow-up/{id} [PUT]
+func (h *OrderController) SetLeadFollowUp(c *gin.Context) {
+	var body models.LeadFollowUpRequest
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	tx := h.db.WithContext(c).Begin()
+	lead, ok := h.lockLead(c, tx)
+	if !ok {
+		return
+	}
+	lead.FollowUpAt = body.FollowUpAt
+	lead.UpdatedAt = timeNow()
+	err = tx.Model(lead).Updates(map[string]interface{}{
+		"follow_up_at": lead.FollowUpAt,
+		"updated_at":   lead.UpdatedAt,
+	}).Error
+	if err != nil {
+		tx.Rollback()
+		newResponse(c, http.StatusInternalServerError, "failed to update order applicant")
+		h.log.Error("failed to update order applicant follow up", err.Error())
+		return
+	}
+	tx.Commit()
+	c.JSON(http.StatusOK, lead)
+}
+
+// @Summary		  Add lead note
+// @Description	   this api writes a note to the timeline of order applicant
+// @Tags			Order
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "order applicant id"
+// @Param			data 	body		models.LeadNoteRequest	true	"data body"
+// @Success			200		{object}	models.LeadNotes
+// @Failure			400,404	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/order/applicant/note/{id} [POST]
+func (h *OrderController) CreateLeadNote(c *gin.Context) {
+	admin := h.GetAdmin(c)
+	var body models.LeadNoteRequest
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	tx := h.db.WithContext(c).Begin()
+	lead, ok := h.lockLead(c, tx)
+	if !ok {
+		return
+	}
+	note := models.LeadNotes{
+		LeadID:    lead.ID,
+		Text:      body.Text,
+		AdminID:   &admin.Id,
+		CreatedAt: timeNow(),
+	}
+	err = tx.Create(&note).Error
+	if err == nil {
+		err = tx.Model(lead).UpdateColumn("updated_at", timeNow()).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		newResponse(c, http.StatusInternalServerError, "failed to create note")
+		h.log.Error("failed to create lead note", err.Error())
+		return
+	}
+	tx.Commit()
+	c.JSON(http.StatusOK, note)
+}
+
+// @Summary		  Get lead timeline
+// @Description	   this api returns notes and status changes of order applicant, oldest first
+// @Tags			Order
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "order applicant id"
+// @Success			200		{object}	[]models.LeadNotes
+// @Failure			404		{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/order/applicant/note/{id} [GET]
+func (h *OrderController) GetLeadNotes(c *gin.Context) {
+	db, ok := h.scoped(c, h.db.Model(&models.OrderApplicant{}), scopeOrderApplicants)
+	if !ok {
+		return
+	}
+	var count int64
+	err := db.Where("order_applicant.id=?", c.Param("id")).Count(&count).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get order applicant")
+		h.log.Error("failed to get order applicant", err.Error())
+		return
+	}
+	if count == 0 {
+		newResponse(c, http.StatusNotFound, "not found order applicant")
+		return
+	}
+	notes := make([]models.LeadNotes, 0)
+	err = h.db.Where("lead_id=?", c.Param("id")).Order("id").Find(&notes).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get notes")
+		h.log.Error("failed to get lead notes", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, notes)
+}
+
+// @Summary		  Convert lead
+// @Description	   this api finds customer by phone of order applicant or creates one, opens draft order of the customer linked to the applicant and marks the applicant won. Items are added to the draft by order update, the draft reserves stock when it moves to new
+// @Tags			Order
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "order applicant id"
+// @Param			data 	body		models.LeadConvertRequest	false	"data body"
+// @Success			200		{object}	models.LeadConvertResponse
+// @Failure			400,404,409	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/order/applicant/convert/{id} [POST]
+func (h *OrderController) ConvertLead(c *gin.Context) {
+	admin := h.GetAdmin(c)
+	var body models.LeadConvertRequest
+	if c.Request.ContentLength != 0 {
+		err := c.ShouldBindJSON(&body)
+		if err != nil {
+			newResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	tx := h.db.WithContext(c).Begin()
+	lead, ok := h.lockLead(c, tx)
+	if !ok {
+		return
+	}
+	if lead.Status == models.LeadStatusWon {
+		tx.Rollback()
+		newResponse(c, http.StatusConflict, "order applicant is already converted")
+		return
+	}
+	phone := formatPhone(lead.Phone)
+	if phone == "" {
+		tx.Rollback()
+		newResponse(c, http.StatusBadRequest, "phone of order applicant is invalid")
+		return
+	}
+	responsibleID := lead.ResponsibleID
+	if responsibleID == nil {
+		responsibleID = &admin.Id
+	}
+	customer := models.Customer{
+		Phone:         phone,
+		Name:          lead.FullName,
+		CreatedAt:     timeNow(),
+		CreatedID:     &admin.Id,
+		ResponsibleID: responsibleID,
+	}
+	err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "phone"}}, DoNothing: true}).
+		Create(&customer).Error
+	if err == nil && customer.ID == 0 {
+		err = tx.First(&customer, "phone=?", phone).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		newResponse(c, http.StatusInternalServerError, "failed to get customer")
+		h.log.Error("failed to get customer of lead", err.Error())
+		return
+	}
+	description := body.Description
+	if description == "" {
+		description = lead.Message
+	}
+	order := models.Orders{
+		CustomerID:    customer.ID,
+		Description:   description,
+		Status:        models.OrderStatusDraft,
+		CreatedAt:     timeNow(),
+		CreatedID:     &admin.Id,
+		ResponsibleID: responsibleID,
+		LeadID:        &lead.ID,
+	}
+	err = tx.Clauses(clause.Returning{}).Create(&order).Error
+	if err == nil {
+		err = recordOrderStatus(tx, &order, "", &admin.Id, fmt.Sprintf("order applicant %d", lead.ID))
+	}
+	from := lead.Status
+	lead.Status = models.LeadStatusWon
+	lead.CustomerID = &customer.ID
+	lead.ConvertedAt = timeNow()
+	lead.UpdatedAt = lead.ConvertedAt
+	if err == nil {
+		err = tx.Model(lead).Updates(map[string]interface{}{
+			"status":       lead.Status,
+			"customer_id":  lead.CustomerID,
+			"converted_at": lead.ConvertedAt,
+			"updated_at":   lead.UpdatedAt,
+		}).Error
+	}
+	if err == nil {
+		err = tx.Create(&models.LeadNotes{
+			LeadID:     lead.ID,
+			Text:       fmt.Sprintf("order %d", order.ID),
+			FromStatus: from,
+			ToStatus:   lead.Status,
+			AdminID:    &admin.Id,
+			CreatedAt:  timeNow(),
+		}).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		newResponse(c, http.StatusInternalServerError, "failed to convert order applicant")
+		h.log.Error("failed to convert order applicant", err.Error())
+		return
+	}
+	tx.Commit()
+	c.JSON(http.StatusOK, models.LeadConvertResponse{
+		Lead:     lead,
+		Customer: &customer,
+		Order:    &order,
+	})
+}
+
+// @Summary		  Get lead metrics
+// @Description	   this api counts order applicants created in the period by responsible admin and status, conversion rate is the share of won applicants
+// @Tags			Order
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param			filter   query   models.LeadMetricsFilter  false "filter"
+// @Success			200		{object}	[]models.LeadMetrics
+// @Failure			400		{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/order/applicant/metrics [GET]
+func (h *OrderController) GetLeadMetrics(c *gin.Context) {
+	var body models.LeadMetricsFilter
+	err := c.ShouldBindQuery(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	db, ok := h.scoped(c, h.db.Model(&models.OrderApplicant{}), scopeOrderApplicants)
+	if !ok {
+		return
+	}
+	if body.DateFrom != "" {
+		db = db.Where("order_applicant.created_at>=?", body.DateFrom)
+	}
+	if body.DateTo != "" {
+		db = db.Where("order_applicant.created_at<=?", body.DateTo)
+	}
+	metrics := make([]models.LeadMetrics, 0)
+	err = db.Select(`order_applicant.responsible_id AS admin_id, MAX(admins.username) AS username, COUNT(*) AS total,
+			COUNT(*) FILTER (WHERE order_applicant.status=?) AS new,
+			COUNT(*) FILTER (WHERE order_applicant.status=?) AS contacted,
+			COUNT(*) FILTER (WHERE order_applicant.status=?) AS qualified,
+			COUNT(*) FILTER (WHERE order_applicant.status=?) AS won,
+			COUNT(*) FILTER (WHERE order_applicant.status=?) AS lost,
+			COALESCE(SUM(orders.total) FILTER (WHERE orders.status NOT IN ?), 0) AS order_total`,
+		models.LeadStatusNew, models.LeadStatusContacted, models.LeadStatusQualified, models.LeadStatusWon,
+		models.LeadStatusLost, []string{models.OrderStatusCancelled, models.OrderStatusReturned}).
+		Joins("LEFT JOIN admins ON admins.id=order_applicant.responsible_id").
+		Joins("LEFT JOIN orders ON orders.lead_id=order_applicant.id AND orders.deleted_at IS NULL").
+		Group("order_applicant.responsible_id").
+		Order("order_applicant.responsible_id").
+		Scan(&metrics).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get lead metrics")
+		h.log.Error("failed to get lead metrics", err.Error())
+		return
+	}
+	for i := range metrics {
+		if metrics[i].Total != 0 {
+			metrics[i].ConversionRate = float64(metrics[i].Won) / float64(metrics[i].Total)
+		}
+	}
+	c.JSON(http.StatusOK, metrics)
+}
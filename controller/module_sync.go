@@ -0,0 +1,174 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/Asliddin3/energy-maximum/models"
+	"github.com/gin-gonic/gin"
+)
+
+// adminRouter registers routes guarded by DeserializeAdmin and records them for SyncModuleItems.
+type adminRouter struct {
+	h     *Handler
+	group *gin.RouterGroup
+}
+
+// adminGroup returns a router whose routes are guarded by DeserializeAdmin, an empty path
+// guards single routes of a group that also serves customers.
+func (h *Handler) adminGroup(rg *gin.RouterGroup, relativePath string) *adminRouter {
+	return &adminRouter{h: h, group: rg.Group(relativePath, h.DeserializeAdmin())}
+}
+
+func (r *adminRouter) Handle(method, relativePath string, handlers ...gin.HandlerFunc) {
+	r.group.Handle(method, relativePath, handlers...)
+	if r.h.adminRouteKeys == nil {
+		r.h.adminRouteKeys = make(map[string]bool)
+	}
+	r.h.adminRouteKeys[method+" "+joinRoutePath(r.group.BasePath(), relativePath)] = true
+}
+
+func (r *adminRouter) GET(relativePath string, handlers ...gin.HandlerFunc) {
+	r.Handle(http.MethodGet, relativePath, handlers...)
+}
+
+func (r *adminRouter) POST(relativePath string, handlers ...gin.HandlerFunc) {
+	r.Handle(http.MethodPost, relativePath, handlers...)
+}
+
+func (r *adminRouter) PUT(relativePath string, handlers ...gin.HandlerFunc) {
+	r.Handle(http.MethodPut, relativePath, handlers...)
+}
+
+func (r *adminRouter) DELETE(relativePath string, handlers ...gin.HandlerFunc) {
+	r.Handle(http.MethodDelete, relativePath, handlers...)
+}
+
+// joinRoutePath joins paths the way gin does, a trailing slash of the relative path is kept.
+func joinRoutePath(basePath, relativePath string) string {
+	if relativePath == "" {
+		return basePath
+	}
+	joined := path.Join(basePath, relativePath)
+	if strings.HasSuffix(relativePath, "/") && !strings.HasSuffix(joined, "/") {
+		joined += "/"
+	}
+	return joined
+}
+
+// adminRoutes returns routes of the server registered through adminGroup.
+func (h *Handler) adminRoutes(server *gin.Engine) []gin.RouteInfo {
+	routes := make([]gin.RouteInfo, 0, len(h.adminRouteKeys))
+	for _, route := range server.Routes() {
+		if h.adminRouteKeys[route.Method+" "+route.Path] {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// routeModuleName returns the controller group of the route, "/api/order/:id" belongs to "order".
+func routeModuleName(path string) string {
+	path = strings.TrimPrefix(normalizeEndPoint(path), "/api")
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if parts[0] == "" {
+		return "api"
+	}
+	return parts[0]
+}
+
+// routeHandlerName turns "controller.(*OrderController).CreateOrder-fm" into "CreateOrder".
+func routeHandlerName(handler string) string {
+	handler = strings.TrimSuffix(handler, "-fm")
+	if i := strings.LastIndex(handler, "."); i != -1 {
+		handler = handler[i+1:]
+	}
+	return handler
+}
+
+// SyncModuleItems upserts one module item per admin route and one module per controller group.
+// Items whose route is gone are flagged as stale, they are removed by hand after review.
+func (h *Handler) SyncModuleItems(server *gin.Engine) error {
+	routes := h.adminRoutes(server)
+
+	var items []models.ModuleItems
+	err := h.db.Find(&items).Error
+	if err != nil {
+		return fmt.Errorf("get module items: %w", err)
+	}
+	existing := make(map[string]models.ModuleItems, len(items))
+	for _, item := range items {
+		existing[strings.ToUpper(item.Method)+" "+normalizeEndPoint(item.EndPoint)] = item
+	}
+
+	tx := h.db.Begin()
+	modules := make(map[string]models.Modules)
+	seen := make(map[string]bool, len(routes))
+	created := 0
+	for _, route := range routes {
+		routeKey := route.Method + " " + normalizeEndPoint(route.Path)
+		if seen[routeKey] {
+			continue
+		}
+		seen[routeKey] = true
+		if item, ok := existing[routeKey]; ok {
+			if item.IsStale != nil && *item.IsStale {
+				err = tx.Model(&models.ModuleItems{}).Where("key=?", item.Key).UpdateColumn("is_stale", false).Error
+				if err != nil {
+					tx.Rollback()
+					return fmt.Errorf("update module item: %w", err)
+				}
+			}
+			continue
+		}
+
+		moduleName := routeModuleName(route.Path)
+		module, ok := modules[moduleName]
+		if !ok {
+			err = tx.Where(models.Modules{Name: moduleName}).
+				Attrs(models.Modules{Description: "registered from routes"}).
+				FirstOrCreate(&module).Error
+			if err != nil {
+				tx.Rollback()
+				return fmt.Errorf("create module %s: %w", moduleName, err)
+			}
+			modules[moduleName] = module
+		}
+		err = tx.Create(&models.ModuleItems{
+			Key:         routeKey,
+			Name:        routeHandlerName(route.Handler),
+			EndPoint:    route.Path,
+			Method:      route.Method,
+			Description: route.Handler,
+			ModuleID:    module.ID,
+		}).Error
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("create module item %s: %w", routeKey, err)
+		}
+		created++
+	}
+
+	staleKeys := make([]string, 0)
+	for routeKey, item := range existing {
+		if !seen[routeKey] && (item.IsStale == nil || !*item.IsStale) {
+			staleKeys = append(staleKeys, item.Key)
+		}
+	}
+	if len(staleKeys) > 0 {
+		err = tx.Model(&models.ModuleItems{}).Where("key IN ?", staleKeys).UpdateColumn("is_stale", true).Error
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("flag stale module items: %w", err)
+		}
+		h.log.Warnf("module items without route are flagged as stale: %s", strings.Join(staleKeys, ", "))
+	}
+	err = tx.Commit().Error
+	if err != nil {
+		return err
+	}
+	h.log.Infof("module items synced: %d routes, %d created, %d stale", len(seen), created, len(staleKeys))
+	return nil
+}
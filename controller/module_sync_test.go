@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &Handler{}
+	server := gin.New()
+	api := server.Group("api")
+	noop := func(c *gin.Context) {}
+
+	// public and admin routes share the prefix
+	public := api.Group("order")
+	public.GET("", noop)
+	public.GET("/:id", noop)
+	admin := h.adminGroup(api, "order")
+	admin.GET("/all", noop)
+	admin.PUT("/:id", noop)
+
+	contact := api.Group("contact")
+	contact.GET("", noop)
+	h.adminGroup(contact, "").POST("/", noop)
+
+	want := map[string]bool{
+		"GET /api/order/all": true,
+		"PUT /api/order/:id": true,
+		"POST /api/contact/": true,
+	}
+	routes := h.adminRoutes(server)
+	if len(routes) != len(want) {
+		t.Fatalf("adminRoutes() = %v, want %d routes", routes, len(want))
+	}
+	for _, route := range routes {
+		if !want[route.Method+" "+route.Path] {
+			t.Errorf("adminRoutes() returns %s %s", route.Method, route.Path)
+		}
+	}
+}
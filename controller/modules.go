@@ -18,13 +18,13 @@ type ModuleController struct {
 
 func (h *Handler) NewModuleController(rg *gin.RouterGroup) {
 	controller := ModuleController{h}
-	router := rg.Group("modules", h.DeserializeAdmin())
+	router := h.adminGroup(rg, "modules")
 	router.POST("", controller.ModuleCreate)
 	router.PUT("/:id", controller.ModuleUpdate)
 	router.GET("", controller.ModulesGet)
 	router.GET("/:id", controller.ModuleGetByID)
 	router.DELETE("/:id", controller.ModuleDelete)
-	routerItems := rg.Group("modules-items", h.DeserializeAdmin())
+	routerItems := h.adminGroup(rg, "modules-items")
 	routerItems.POST("", controller.ModuleItemsCreate)
 	routerItems.PUT("/:id", controller.ModuleItemUpdate)
 	routerItems.GET("", controller.ModulesItemsGet)
@@ -59,7 +59,7 @@ func (h *ModuleController) ModuleCreate(c *gin.Context) {
 		Description: body.Description,
 	}
 
-	err = h.db.Select(
+	err = h.db.WithContext(c).Select(
 		"name",
 		"description",
 	).Create(&module).Error
@@ -112,7 +112,7 @@ func (h *ModuleController) ModuleUpdate(c *gin.Context) {
 		"description": body.Description,
 	}
 
-	err = h.db.Clauses(clause.Returning{}).Model(&module).Updates(columns).Error
+	err = h.db.WithContext(c).Clauses(clause.Returning{}).Model(&module).Updates(columns).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		h.log.Error("failed to update menu", err.Error())
@@ -206,7 +206,7 @@ func (h *ModuleController) ModuleDelete(c *gin.Context) {
 		return
 	}
 
-	result := h.db.Delete(&models.Modules{
+	result := h.db.WithContext(c).Delete(&models.Modules{
 		ID: uint32(ID),
 	})
 	if result.Error != nil {
@@ -251,7 +251,7 @@ func (h *ModuleController) ModuleItemsCreate(c *gin.Context) {
 		return
 	}
 	module := models.ModuleItems{}
-	err = h.db.First(&module, "key=?", body.Key).Error
+	err = h.db.WithContext(c).First(&module, "key=?", body.Key).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		h.log.Error("failed to update", err.Error())
@@ -326,6 +326,7 @@ func (h *ModuleController) ModuleItemUpdate(c *gin.Context) {
 //	@Accept			json
 //	@Produce		json
 //	@Param 			moduleId query uint false "Module ID"
+//	@Param 			is_stale query bool false "only items whose route no longer exists"
 //	@Success		200	{object}	[]models.ModuleItems
 //	@Failure		500	{object}	response
 //	@Router			/api/modules-items [GET]
@@ -343,6 +344,9 @@ func (h *ModuleController) ModulesItemsGet(c *gin.Context) {
 	if filter.ModuleID != nil {
 		db = db.Where("module_id", filter.ModuleID)
 	}
+	if filter.IsStale != nil {
+		db = db.Where("is_stale=?", filter.IsStale)
+	}
 	err := db.Find(&modules).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
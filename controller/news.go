@@ -18,7 +18,7 @@ type NewsController struct {
 
 func (h *Handler) NewNewsController(api *gin.RouterGroup) {
 	news := &NewsController{h}
-	nw := api.Group("news", h.DeserializeAdmin())
+	nw := h.adminGroup(api, "news")
 	{
 		nw.POST("", news.CreateNews)
 		nw.PUT("/:id", news.UpdateNews)
@@ -72,7 +72,7 @@ func (h *NewsController) CreateNews(c *gin.Context) {
 		CreatedID:     &admin.Id,
 		CreatedAt:     timeNow(),
 	}
-	err = h.db.Clauses(clause.Returning{}).Create(&news).Error
+	err = h.db.WithContext(c).Clauses(clause.Returning{}).Create(&news).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -141,7 +141,7 @@ func (h *NewsController) UpdateNews(c *gin.Context) {
 	columns["updated_at"] = timeNow()
 	columns["updated_id"] = admin.Id
 	news := &models.News{}
-	err = h.db.Clauses(clause.Returning{}).Model(&news).
+	err = h.db.WithContext(c).Clauses(clause.Returning{}).Model(&news).
 		Where("id=?", newsId).Updates(columns).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
@@ -228,7 +228,7 @@ func (h *NewsController) GetByID(c *gin.Context) {
 func (h *NewsController) DeleteNew(c *gin.Context) {
 	inputId := c.Param("id")
 	var news models.News
-	err := h.db.Delete(&news, "id=?", inputId).Error
+	err := h.db.WithContext(c).Delete(&news, "id=?", inputId).Error
 	if err != nil {
 		newResponse(c, http.StatusBadRequest, err.Error())
 		return
@@ -0,0 +1,379 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Asliddin3/energy-maximum/models"
+	"github.com/Asliddin3/energy-maximum/pkg/document"
+	"github.com/Asliddin3/energy-maximum/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// orderStatusNames are printed in place of {status} of sms templates.
+var orderStatusNames = map[string]map[string]string{
+	document.LangRu: {
+		models.OrderStatusDraft:      "черновик",
+		models.OrderStatusNew:        "новый",
+		models.OrderStatusConfirmed:  "подтверждён",
+		models.OrderStatusProcessing: "в обработке",
+		models.OrderStatusShipped:    "отправлен",
+		models.OrderStatusCompleted:  "выполнен",
+		models.OrderStatusCancelled:  "отменён",
+		models.OrderStatusReturned:   "возвращён",
+	},
+	document.LangUz: {
+		models.OrderStatusDraft:      "qoralama",
+		models.OrderStatusNew:        "yangi",
+		models.OrderStatusConfirmed:  "tasdiqlandi",
+		models.OrderStatusProcessing: "tayyorlanmoqda",
+		models.OrderStatusShipped:    "jo‘natildi",
+		models.OrderStatusCompleted:  "yakunlandi",
+		models.OrderStatusCancelled:  "bekor qilindi",
+		models.OrderStatusReturned:   "qaytarildi",
+	},
+	document.LangEn: {
+		models.OrderStatusDraft:      "draft",
+		models.OrderStatusNew:        "new",
+		models.OrderStatusConfirmed:  "confirmed",
+		models.OrderStatusProcessing: "processing",
+		models.OrderStatusShipped:    "shipped",
+		models.OrderStatusCompleted:  "completed",
+		models.OrderStatusCancelled:  "cancelled",
+		models.OrderStatusReturned:   "returned",
+	},
+}
+
+var notificationEvents = []string{
+	models.NotificationOrderCreated,
+	models.NotificationOrderStatus,
+	models.NotificationOrderComment,
+}
+
+// notifyOrder sends sms about the event to the customer of the order in background, so requests are
+// not held by the database or the sms provider. It must be called after the transaction is committed.
+func (h *Handler) notifyOrder(event string, order models.Orders, comment string) {
+	go func() {
+		err := h.sendOrderNotification(event, order, comment)
+		if err != nil {
+			h.log.Error("failed to notify customer of order", logger.Error(err))
+		}
+	}()
+}
+
+// sendOrderNotification skips customers who opted out and events without active template.
+func (h *Handler) sendOrderNotification(event string, order models.Orders, comment string) error {
+	var customer models.Customer
+	err := h.db.Select("id, name, phone, lang, sms_notifications").Limit(1).Find(&customer, "id=?", order.CustomerID).Error
+	if err != nil || customer.ID == 0 {
+		return err
+	}
+	phone := formatPhone(customer.Phone)
+	if phone == "" || (customer.SmsNotifications != nil && !*customer.SmsNotifications) {
+		return nil
+	}
+	lang := customer.Lang
+	if !document.Supported(lang) {
+		lang = document.LangRu
+	}
+	status := ""
+	if event == models.NotificationOrderStatus {
+		status = order.Status
+	}
+	// template in the language of the customer is preferred to the status specific one, inactive templates
+	// are skipped so the next one is used
+	var template models.SmsTemplates
+	err = h.db.Where("event=? AND lang IN ? AND status IN ? AND is_active IS NOT FALSE", event,
+		[]string{lang, document.LangRu}, []string{status, ""}).
+		Order(clause.Expr{SQL: "lang=? DESC, status=? DESC", Vars: []interface{}{lang, status}}).
+		Limit(1).Find(&template).Error
+	if err != nil || template.ID == 0 {
+		return err
+	}
+	text := strings.NewReplacer(
+		"{order_id}", strconv.Itoa(order.ID),
+		"{customer}", customer.Name,
+		"{status}", orderStatusNames[template.Lang][order.Status],
+		"{total}", fmt.Sprintf("%.2f", order.Total),
+		"{comment}", comment,
+	).Replace(template.Text)
+	_, err = h.queueSms(phone, strings.TrimSpace(text), event)
+	return err
+}
+
+func validateSmsTemplate(body *models.SmsTemplateRequest) error {
+	known := false
+	for _, event := range notificationEvents {
+		known = known || event == body.Event
+	}
+	if !known {
+		return fmt.Errorf("event must be one of %s", strings.Join(notificationEvents, ", "))
+	}
+	if body.Status != "" {
+		if body.Event != models.NotificationOrderStatus {
+			return errors.New("status is set only for order_status templates")
+		}
+		if _, ok := orderTransitions[body.Status]; !ok {
+			return errors.New("unknown order status " + body.Status)
+		}
+	}
+	if !document.Supported(body.Lang) {
+		return errors.New("lang must be ru, uz or en")
+	}
+	return nil
+}
+
+// @Summary		  Get sms templates
+// @Description	   this api returns templates of sms notifications
+// @Tags			Sms
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param			filter 	query		models.SmsTemplateFilter	false	"filter"
+// @Success			200		{object}	[]models.SmsTemplates
+// @Failure			400		{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/sms-template [GET]
+func (h *SmsController) GetSmsTemplates(c *gin.Context) {
+	var body models.SmsTemplateFilter
+	err := c.ShouldBindQuery(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	db := h.db.Model(&models.SmsTemplates{})
+	if body.Event != "" {
+		db = db.Where("event=?", body.Event)
+	}
+	if body.Lang != "" {
+		db = db.Where("lang=?", body.Lang)
+	}
+	templates := make([]models.SmsTemplates, 0)
+	err = db.Order("event, status, lang").Find(&templates).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get sms templates")
+		h.log.Error("failed to get sms templates", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, templates)
+}
+
+// @Summary		  Create sms template
+// @Description	   this api creates template of sms notification. Events are order_created, order_status and order_comment, status chooses template of order_status event by the new status. Placeholders {order_id}, {customer}, {status}, {total} and {comment} are replaced on sending
+// @Tags			Sms
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param			data 	body		models.SmsTemplateRequest	true	"data body"
+// @Success			200		{object}	models.SmsTemplates
+// @Failure			400,409	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/sms-template [POST]
+func (h *SmsController) CreateSmsTemplate(c *gin.Context) {
+	admin := h.GetAdmin(c)
+	var body models.SmsTemplateRequest
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	err = validateSmsTemplate(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	template := models.SmsTemplates{
+		Event:     body.Event,
+		Status:    body.Status,
+		Lang:      body.Lang,
+		Text:      body.Text,
+		IsActive:  body.IsActive,
+		UpdatedID: &admin.Id,
+		UpdatedAt: timeNow(),
+	}
+	err = h.db.WithContext(c).Create(&template).Error
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique") {
+			newResponse(c, http.StatusConflict, "template of the event, status and lang already exists")
+			return
+		}
+		newResponse(c, http.StatusInternalServerError, "failed to create sms template")
+		h.log.Error("failed to create sms template", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, template)
+}
+
+// @Summary		  Update sms template
+// @Description	   this api updates template of sms notification, placeholders are {order_id}, {customer}, {status}, {total} and {comment}
+// @Tags			Sms
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "id"
+// @Param			data 	body		models.SmsTemplateRequest	true	"data body"
+// @Success			200		{object}	models.SmsTemplates
+// @Failure			400,404,409	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/sms-template/{id} [PUT]
+func (h *SmsController) UpdateSmsTemplate(c *gin.Context) {
+	admin := h.GetAdmin(c)
+	var body models.SmsTemplateRequest
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	err = validateSmsTemplate(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	var template models.SmsTemplates
+	err = h.db.First(&template, "id=?", c.Param("id")).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			newResponse(c, http.StatusNotFound, "sms template not found")
+			return
+		}
+		newResponse(c, http.StatusInternalServerError, "failed to get sms template")
+		h.log.Error("failed to get sms template", err.Error())
+		return
+	}
+	template.Event = body.Event
+	template.Status = body.Status
+	template.Lang = body.Lang
+	template.Text = body.Text
+	if body.IsActive != nil {
+		template.IsActive = body.IsActive
+	}
+	template.UpdatedID = &admin.Id
+	template.UpdatedAt = timeNow()
+	err = h.db.WithContext(c).Save(&template).Error
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique") {
+			newResponse(c, http.StatusConflict, "template of the event, status and lang already exists")
+			return
+		}
+		newResponse(c, http.StatusInternalServerError, "failed to update sms template")
+		h.log.Error("failed to update sms template", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, template)
+}
+
+// @Summary		  Delete sms template
+// @Description	   this api deletes template of sms notification, the event is not sent in the language without template
+// @Tags			Sms
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "id"
+// @Success			200		{object}	response
+// @Failure			404		{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/sms-template/{id} [DELETE]
+func (h *SmsController) DeleteSmsTemplate(c *gin.Context) {
+	result := h.db.WithContext(c).Delete(&models.SmsTemplates{}, "id=?", c.Param("id"))
+	if result.Error != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to delete sms template")
+		h.log.Error("failed to delete sms template", result.Error.Error())
+		return
+	}
+	if result.RowsAffected == 0 {
+		newResponse(c, http.StatusNotFound, "sms template not found")
+		return
+	}
+	c.JSON(http.StatusOK, response{"success"})
+}
+
+// @Summary		  Comment order for customer
+// @Description	   this api writes comment of the order to the customer, the customer is notified by sms
+// @Tags			Order
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "order id"
+// @Param			data 	body		models.OrderCommentRequest	true	"data body"
+// @Success			200		{object}	models.OrderComments
+// @Failure			400,404	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/order/comment/{id} [POST]
+func (h *OrderController) CommentOrder(c *gin.Context) {
+	admin := h.GetAdmin(c)
+	var body models.OrderCommentRequest
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	db, ok := h.scoped(c, h.db.Model(&models.Orders{}), scopeOrders)
+	if !ok {
+		return
+	}
+	var order models.Orders
+	err = db.First(&order, "orders.id=? AND orders.deleted_at IS NULL", c.Param("id")).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			newResponse(c, http.StatusNotFound, "not found order")
+			return
+		}
+		newResponse(c, http.StatusInternalServerError, "failed to get order")
+		h.log.Error("failed to get order", err.Error())
+		return
+	}
+	comment := models.OrderComments{
+		OrderID:   order.ID,
+		Text:      body.Text,
+		AdminID:   &admin.Id,
+		CreatedAt: timeNow(),
+	}
+	err = h.db.WithContext(c).Create(&comment).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to create order comment")
+		h.log.Error("failed to create order comment", err.Error())
+		return
+	}
+	h.notifyOrder(models.NotificationOrderComment, order, comment.Text)
+	c.JSON(http.StatusOK, comment)
+}
+
+// @Summary		  Get order comments
+// @Description	   this api returns comments of admins to own order, oldest first
+// @Tags			Order
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "order id"
+// @Success			200		{object}	[]models.OrderComments
+// @Failure			404		{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/order/{id}/comments [GET]
+func (h *OrderController) GetOrderComments(c *gin.Context) {
+	customer := h.GetCustomer(c)
+	var count int64
+	err := h.db.Model(&models.Orders{}).
+		Where("id=? AND customer_id=? AND deleted_at IS NULL", c.Param("id"), customer.Id).Count(&count).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get order")
+		h.log.Error("failed to get order", err.Error())
+		return
+	}
+	if count == 0 {
+		newResponse(c, http.StatusNotFound, "not found order")
+		return
+	}
+	comments := make([]models.OrderComments, 0)
+	err = h.db.Where("order_id=?", c.Param("id")).Order("id").Find(&comments).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get order comments")
+		h.log.Error("failed to get order comments", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, comments)
+}
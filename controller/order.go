@@ -24,17 +24,37 @@ func (h *Handler) NewOrderController(api *gin.RouterGroup) {
 		// orderHandler.PUT("/:id", order.UpdateOrder)
 		orderHandler.GET("", order.GetOrders)
 		orderHandler.GET("/:id", order.GetByID)
+		orderHandler.GET("/:id/invoice", order.GetOrderInvoice)
+		orderHandler.GET("/:id/delivery-note", order.GetOrderDeliveryNote)
+		orderHandler.GET("/:id/comments", order.GetOrderComments)
 	}
 	api.POST("/order/applicant", order.CreateOrderApplicant)
 
-	adminHandler := api.Group("order", h.DeserializeAdmin())
+	adminHandler := h.adminGroup(api, "order")
 	{
 		adminHandler.GET("/applicant", order.GetOrdersApplicant)
+		adminHandler.GET("/applicant/export", order.ExportOrderApplicants)
+		adminHandler.GET("/export", order.ExportOrders)
+		adminHandler.GET("/export/items", order.ExportOrderItems)
 		adminHandler.POST("/:id", order.CreateOrderByAdmin)
 		adminHandler.PUT("/:id", order.UpdateOrderByAdmin)
 		adminHandler.GET("/all", order.GetAllOrders)
-		adminHandler.PUT("/cancel/:id", order.CancelOrder)
-		adminHandler.PUT("/finish/:id", order.FinishOrder)
+		adminHandler.GET("/all/:id", order.GetOrderByAdmin)
+		adminHandler.GET("/all/:id/invoice", order.GetOrderInvoiceByAdmin)
+		adminHandler.GET("/all/:id/delivery-note", order.GetOrderDeliveryNoteByAdmin)
+		adminHandler.GET("/applicant/:id", order.GetOrderApplicantByID)
+		adminHandler.PUT("/assign/:id", order.AssignOrder)
+		adminHandler.PUT("/applicant/assign/:id", order.AssignOrderApplicant)
+		adminHandler.PUT("/applicant/status/:id", order.SetLeadStatus)
+		adminHandler.PUT("/applicant/follow-up/:id", order.SetLeadFollowUp)
+		adminHandler.POST("/applicant/note/:id", order.CreateLeadNote)
+		adminHandler.GET("/applicant/note/:id", order.GetLeadNotes)
+		adminHandler.POST("/applicant/convert/:id", order.ConvertLead)
+		adminHandler.GET("/applicant/metrics", order.GetLeadMetrics)
+		adminHandler.PUT("/status/:id", order.TransitOrder)
+		adminHandler.POST("/comment/:id", order.CommentOrder)
+		adminHandler.PUT("/warehouse/:id", order.SetOrderWarehouse)
+		adminHandler.GET("/history/:id", order.GetOrderStatusHistory)
 		adminHandler.DELETE("/:id", order.DeleteOrder)
 		adminHandler.DELETE("/applicant/:id", order.DeleteOrderApplicantByID)
 
@@ -94,7 +114,10 @@ func (h *OrderController) GetOrdersApplicant(c *gin.Context) {
 	}
 
 	var orders []models.OrderApplicant
-	db := h.db.Debug().Model(&models.OrderApplicant{})
+	db, ok := h.scoped(c, h.db.Debug().Model(&models.OrderApplicant{}), scopeOrderApplicants)
+	if !ok {
+		return
+	}
 
 	if body.Page == 0 {
 		body.Page = 1
@@ -102,13 +125,7 @@ func (h *OrderController) GetOrdersApplicant(c *gin.Context) {
 	if body.PageSize == 0 {
 		body.PageSize = 10
 	}
-	if body.Phone != "" {
-		db = db.Where("phone LIKE ?", fmt.Sprintf("%%%s%%", body.Phone))
-	}
-
-	if body.FullName != "" {
-		db = db.Where("LOWER(full_name) LIKE LOWER(?)", fmt.Sprintf("%%%s%%", body.FullName))
-	}
+	db = filterOrderApplicants(db, body)
 	var count int
 	err = db.Model(&models.OrderApplicant{}).Select("COUNT(*)").Scan(&count).Error
 	if err != nil {
@@ -131,6 +148,25 @@ func (h *OrderController) GetOrdersApplicant(c *gin.Context) {
 	c.JSON(http.StatusOK, res)
 }
 
+func filterOrderApplicants(db *gorm.DB, body models.OrderApplicantFilter) *gorm.DB {
+	if body.Phone != "" {
+		db = db.Where("phone LIKE ?", fmt.Sprintf("%%%s%%", body.Phone))
+	}
+	if body.FullName != "" {
+		db = db.Where("LOWER(full_name) LIKE LOWER(?)", fmt.Sprintf("%%%s%%", body.FullName))
+	}
+	if body.Status != "" {
+		db = db.Where("order_applicant.status=?", body.Status)
+	}
+	if body.ResponsibleID != 0 {
+		db = db.Where("order_applicant.responsible_id=?", body.ResponsibleID)
+	}
+	if body.FollowUpTo != "" {
+		db = db.Where("order_applicant.follow_up_at<=?", body.FollowUpTo)
+	}
+	return db
+}
+
 // @Summary		  delete by id order applicant
 // @Description	   this api is to delete by id order applicant
 // @Tags			Order
@@ -139,19 +175,22 @@ func (h *OrderController) GetOrdersApplicant(c *gin.Context) {
 // @Produce			json
 // @Param           id    path     int   true   "id"
 // @Success			201		{object}	response
-// @Failure			400,409	{object}	response
+// @Failure			400,404,409	{object}	response
 // @Failure			500		{object}	response
 // @Router			/api/order/applicant/{id} [DELETE]
 func (h *OrderController) DeleteOrderApplicantByID(c *gin.Context) {
-	orderId := c.Param("id")
-	var order models.OrderApplicant
-	err := h.db.Delete(&order, "id=?", orderId).Error
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			newResponse(c, http.StatusBadRequest, "not found order")
-			return
-		}
-		newResponse(c, http.StatusBadRequest, err.Error())
+	db, ok := h.scoped(c, h.db.WithContext(c), scopeOrderApplicants)
+	if !ok {
+		return
+	}
+	result := db.Where("order_applicant.id=?", c.Param("id")).Delete(&models.OrderApplicant{})
+	if result.Error != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to delete order applicant")
+		h.log.Error("failed to delete order applicant", result.Error.Error())
+		return
+	}
+	if result.RowsAffected == 0 {
+		newResponse(c, http.StatusNotFound, "not found order applicant")
 		return
 	}
 	c.JSON(http.StatusOK, response{"success"})
@@ -181,32 +220,15 @@ func (h *OrderController) CreateOrder(c *gin.Context) {
 	order := models.Orders{
 		Description: body.Description,
 		CustomerID:  customer.Id,
-		Total:       body.Total,
 		CreatedAt:   timeNow(),
 	}
-	err = h.db.Debug().Clauses(clause.Returning{}).Create(&order).Error
-	if err != nil {
-		newResponse(c, http.StatusInternalServerError, err.Error())
-		return
+	if customer.ImpersonatorID != 0 {
+		order.CreatedID = &customer.ImpersonatorID
 	}
-	orderItems := make([]models.OrderItems, len(body.Items))
-	for i, item := range body.Items {
-		orderItems[i] = models.OrderItems{
-			Price:  item.Price,
-			Amount: item.Amount,
-			ItemId: item.ItemID,
-		}
-	}
-	fmt.Println("orderItems: ", orderItems)
-	if len(orderItems) > 0 {
-		err = h.db.Clauses(clause.Returning{}).Create(&orderItems).Error
-		if err != nil {
-			newResponse(c, http.StatusInternalServerError, err.Error())
-			h.log.Error("failed to create order", err.Error())
-			return
-		}
+	orderItems, ok := h.createOrder(c, h.db.WithContext(c).Begin(), &order, body.Items, body.PromoCode)
+	if !ok {
+		return
 	}
-
 	c.JSON(http.StatusOK, models.OrderResponse{
 		Orders: &order,
 		Items:  orderItems,
@@ -248,17 +270,14 @@ func (h *OrderController) GetOrders(c *gin.Context) {
 	if body.PageSize == 0 {
 		body.PageSize = 10
 	}
-	if body.Status != nil {
-		db = db.Where("status=?", body.Status)
-	}
 	var statusCounts []struct {
-		Status int
+		Status string
 		Count  int
 	}
 
-	err = db.Debug().Model(&models.Orders{}).
+	err = db.Session(&gorm.Session{}).
 		Select("status, COUNT(*) as count").
-		Group("status").Where("customer_id=?", customer.Id).
+		Group("status").
 		Scan(&statusCounts).
 		Error
 	if err != nil {
@@ -267,18 +286,28 @@ func (h *OrderController) GetOrders(c *gin.Context) {
 		return
 	}
 	res := models.CustomOrderResponse{
-		Page:     body.Page,
-		PageSize: body.PageSize,
+		Page:         body.Page,
+		PageSize:     body.PageSize,
+		StatusCounts: make(map[string]int, len(statusCounts)),
 	}
 	for _, status := range statusCounts {
-		if status.Status == 0 {
-			res.ActiveCount = status.Count
-		} else if status.Status == 1 {
-			res.FinishedCount = status.Count
-		} else if status.Status == 2 {
-			res.CancelledCount = status.Count
+		res.StatusCounts[status.Status] = status.Count
+		switch status.Status {
+		case models.OrderStatusCompleted:
+			res.FinishedCount += status.Count
+		case models.OrderStatusCancelled:
+			res.CancelledCount += status.Count
+		case models.OrderStatusReturned:
+			res.ReturnedCount += status.Count
+		default:
+			res.ActiveCount += status.Count
 		}
-		res.Count += status.Count
+		if body.Status == "" || body.Status == status.Status {
+			res.Count += status.Count
+		}
+	}
+	if body.Status != "" {
+		db = db.Where("status=?", body.Status)
 	}
 	err = db.Debug().Select("*").Limit(body.PageSize).Offset((body.Page - 1) * body.PageSize).Find(&orders).Error
 	if err != nil {
@@ -304,30 +333,22 @@ func (h *OrderController) GetAllOrders(c *gin.Context) {
 	var body models.AdminOrderFilter
 	err := c.ShouldBindQuery(&body)
 	if err != nil {
-		newResponse(c, http.StatusInternalServerError, err.Error())
+		newResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
 	var orders []models.Orders
-	db := h.db.Model(&models.Orders{})
-	if body.CustomerID != 0 {
-		db = db.Where("customer_id=?", body.CustomerID)
-	}
-	if body.DateFrom != "" {
-		db = db.Where("created_at>=?", body.DateFrom)
-	}
-	if body.DateTo != "" {
-		db = db.Where("created_at<=?", body.DateTo)
-	}
-	if body.Status != nil {
-		db = db.Where("status=?", body.Status)
+	db, ok := h.scoped(c, h.db.Model(&models.Orders{}), scopeOrders)
+	if !ok {
+		return
 	}
+	db = filterOrders(db, body)
 	if body.Page == 0 {
 		body.Page = 1
 	}
 	if body.PageSize == 0 {
 		body.PageSize = 10
 	}
-	err = db.Limit(body.Page).Offset((body.PageSize - 1) * body.PageSize).Find(&orders).Error
+	err = db.Order("orders.id DESC").Limit(body.PageSize).Offset((body.Page - 1) * body.PageSize).Find(&orders).Error
 	if err != nil {
 		newResponse(c, http.StatusBadRequest, err.Error())
 		return
@@ -335,6 +356,23 @@ func (h *OrderController) GetAllOrders(c *gin.Context) {
 	c.JSON(http.StatusOK, orders)
 }
 
+// filterOrders applies filter of the admin order list, columns are qualified so orders can be joined.
+func filterOrders(db *gorm.DB, body models.AdminOrderFilter) *gorm.DB {
+	if body.CustomerID != 0 {
+		db = db.Where("orders.customer_id=?", body.CustomerID)
+	}
+	if body.DateFrom != "" {
+		db = db.Where("orders.created_at>=?", body.DateFrom)
+	}
+	if body.DateTo != "" {
+		db = db.Where("orders.created_at<=?", body.DateTo)
+	}
+	if body.Status != "" {
+		db = db.Where("orders.status=?", body.Status)
+	}
+	return db
+}
+
 // @Summary		  Create new order by admin
 // @Description	   this api is create new order admin
 // @Tags			Order
@@ -362,35 +400,18 @@ func (h *OrderController) CreateOrderByAdmin(c *gin.Context) {
 		return
 	}
 
+	admin := h.GetAdmin(c)
 	order := models.Orders{
-		Description: body.Description,
-		CustomerID:  int(id),
-		Total:       body.Total,
-		CreatedAt:   timeNow(),
-	}
-	err = h.db.Debug().Clauses(clause.Returning{}).Create(&order).Error
-	if err != nil {
-		newResponse(c, http.StatusInternalServerError, err.Error())
+		Description:   body.Description,
+		CustomerID:    int(id),
+		CreatedAt:     timeNow(),
+		CreatedID:     &admin.Id,
+		ResponsibleID: &admin.Id,
+	}
+	orderItems, ok := h.createOrder(c, h.db.WithContext(c).Begin(), &order, body.Items, body.PromoCode)
+	if !ok {
 		return
 	}
-	orderItems := make([]models.OrderItems, len(body.Items))
-	for i, item := range body.Items {
-		orderItems[i] = models.OrderItems{
-			Price:  item.Price,
-			Amount: item.Amount,
-			ItemId: item.ItemID,
-		}
-	}
-	fmt.Println("orderItems: ", orderItems)
-	if len(orderItems) > 0 {
-		err = h.db.Clauses(clause.Returning{}).Create(&orderItems).Error
-		if err != nil {
-			newResponse(c, http.StatusInternalServerError, err.Error())
-			h.log.Error("failed to create order", err.Error())
-			return
-		}
-	}
-
 	c.JSON(http.StatusOK, models.OrderResponse{
 		Orders: &order,
 		Items:  orderItems,
@@ -406,7 +427,7 @@ func (h *OrderController) CreateOrderByAdmin(c *gin.Context) {
 // @Param           id     path     int   true   "order id"
 // @Param			data 	formData		models.OrderRequest	false	"data body"
 // @Success			201		{object}	models.OrderResponse
-// @Failure			400,409	{object}	response
+// @Failure			400,404,409	{object}	response
 // @Failure			500		{object}	response
 // @Router			/api/order/{id} [PUT]
 func (h *OrderController) UpdateOrderByAdmin(c *gin.Context) {
@@ -438,40 +459,115 @@ func (h *OrderController) UpdateOrderByAdmin(c *gin.Context) {
 	if body.CustomerId != 0 {
 		columns["customer_id"] = body.CustomerId
 	}
-	if body.Total != nil {
-		columns["total"] = body.Total
+	tr := h.db.WithContext(c).Begin()
+	db, ok := h.scoped(c, tr.Model(&models.Orders{}), scopeOrders)
+	if !ok {
+		tr.Rollback()
+		return
 	}
-	tr := h.db.Begin()
-	err = tr.Debug().Clauses(clause.Returning{}).Model(&order).
-		Updates(columns).Error
+	var current models.Orders
+	err = db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("orders.id=? AND orders.deleted_at IS NULL", id).Limit(1).Find(&current).Error
 	if err != nil {
-		newResponse(c, http.StatusInternalServerError, err.Error())
 		tr.Rollback()
+		newResponse(c, http.StatusInternalServerError, "failed to get order")
+		h.log.Error("failed to get order", err.Error())
 		return
 	}
-	err = tr.Delete(&models.OrderItems{}, "order_id=?", id).Error
-	if err != nil {
-		newResponse(c, http.StatusInternalServerError, err.Error())
+	if current.ID == 0 {
 		tr.Rollback()
+		newResponse(c, http.StatusNotFound, "not found order")
 		return
 	}
-	orderItems := make([]models.OrderItems, len(body.Items))
-	for i, item := range body.Items {
-		orderItems[i] = models.OrderItems{
-			Price:  item.Price,
-			Amount: item.Amount,
-			ItemId: item.ItemID,
+	customerChanged := body.CustomerId != 0 && body.CustomerId != current.CustomerID
+	status := current.Status
+	if (body.Items != nil || customerChanged) &&
+		status != models.OrderStatusDraft && status != models.OrderStatusNew && status != models.OrderStatusConfirmed {
+		tr.Rollback()
+		newResponse(c, http.StatusConflict, "items and customer can be changed only in draft, new or confirmed order")
+		return
+	}
+	items := body.Items
+	if items == nil && customerChanged {
+		// prices depend on the customer group, so lines are priced again for the new customer
+		var lines []models.OrderItems
+		err = tr.Where("order_id=?", id).Order("id").Find(&lines).Error
+		if err != nil {
+			tr.Rollback()
+			newResponse(c, http.StatusInternalServerError, "failed to get order items")
+			h.log.Error("failed to get order items", err.Error())
+			return
+		}
+		for _, line := range lines {
+			items = append(items, models.OrderItemsRequest{ItemID: line.ItemId, Amount: line.Amount})
 		}
 	}
-	fmt.Println("orderItems: ", orderItems)
-	if len(orderItems) > 0 {
-		err = tr.Clauses(clause.Returning{}).Create(&orderItems).Error
+	var orderItems []models.OrderItems
+	var discount float64
+	if items != nil {
+		if customerChanged {
+			current.CustomerID = body.CustomerId
+		}
+		var total float64
+		orderItems, total, err = priceOrderItems(tr, current.CustomerID, items)
+		if err == nil && customerChanged && current.PromotionID != nil {
+			err = checkPromotionCustomer(tr, *current.PromotionID, current.CustomerID, current.ID)
+		}
 		if err != nil {
 			tr.Rollback()
-			newResponse(c, http.StatusInternalServerError, err.Error())
-			h.log.Error("failed to create order", err.Error())
+			h.orderItemsError(c, err)
 			return
 		}
+		if current.PromotionID != nil {
+			// discount of the promotion follows the new lines, it drops to zero when they no longer match
+			discount, err = orderPromotionDiscount(tr, *current.PromotionID, orderItems)
+			if err != nil {
+				tr.Rollback()
+				newResponse(c, http.StatusInternalServerError, "failed to get promotion")
+				h.log.Error("failed to get promotion of order", err.Error())
+				return
+			}
+			columns["discount"] = discount
+			total = roundMoney(total - discount)
+		}
+		columns["total"] = total
+	}
+	result := tr.Clauses(clause.Returning{}).Model(&order).Updates(columns)
+	if result.Error != nil {
+		newResponse(c, http.StatusInternalServerError, result.Error.Error())
+		tr.Rollback()
+		return
+	}
+	if result.RowsAffected == 0 {
+		tr.Rollback()
+		newResponse(c, http.StatusNotFound, "not found order")
+		return
+	}
+	if items != nil {
+		err = moveOrderStock(tr, &order, models.StockMovementRelease, &admin.Id)
+		if err == nil {
+			err = tr.Delete(&models.OrderItems{}, "order_id=?", id).Error
+		}
+		if err == nil {
+			for i := range orderItems {
+				orderItems[i].OrderID = order.ID
+			}
+			err = tr.Create(&orderItems).Error
+		}
+		if err == nil && orderHoldsStock(order.Status) {
+			err = reserveOrderStock(tr, &order, &admin.Id, order.WarehouseID)
+		}
+		if err == nil && order.PromotionID != nil {
+			err = tr.Model(&models.PromotionRedemptions{}).Where("order_id=?", order.ID).
+				UpdateColumns(map[string]interface{}{"discount": discount, "customer_id": order.CustomerID}).Error
+		}
+	} else {
+		err = tr.Where("order_id=?", id).Order("id").Find(&orderItems).Error
+	}
+	if err != nil {
+		tr.Rollback()
+		h.stockError(c, err, "failed to update order items")
+		return
 	}
 	tr.Commit()
 	c.JSON(http.StatusOK, models.OrderResponse{
@@ -487,14 +583,15 @@ func (h *OrderController) UpdateOrderByAdmin(c *gin.Context) {
 // @Accept			json
 // @Produce			json
 // @Param           id    path     int   true   "id"
-// @Success			201		{object}	[]models.Orders
+// @Success			201		{object}	models.OrderResponse
 // @Failure			400,409	{object}	response
 // @Failure			500		{object}	response
 // @Router			/api/order/{id} [GET]
 func (h *OrderController) GetByID(c *gin.Context) {
+	customer := h.GetCustomer(c)
 	orderId := c.Param("id")
 	var order models.Orders
-	err := h.db.First(&order, "id=?", orderId).Error
+	err := h.db.First(&order, "id=? AND customer_id=?", orderId, customer.Id).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			newResponse(c, http.StatusBadRequest, "not found order")
@@ -503,91 +600,159 @@ func (h *OrderController) GetByID(c *gin.Context) {
 		newResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, order)
+	var orderItems []models.OrderItems
+	err = h.db.Where("order_id=?", order.ID).Order("id").Find(&orderItems).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get order items")
+		h.log.Error("failed to get order items", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, models.OrderResponse{
+		Orders: &order,
+		Items:  orderItems,
+	})
 }
 
-// @Summary		  Cancel  order
-// @Description	   this api is for cancel order this api change status to 3
+// @Summary		 	Delete Order
+// @Description	   	this api marks order deleted, reserved stock of the order is released
 // @Tags			Order
 // @Security		BearerAuth
 // @Accept			json
 // @Produce			json
-// @Param           id    path     int   true   "id"
-// @Success			201		{object}	[]models.Orders
-// @Failure			400,409	{object}	response
+// @Param           id   	path     int   true   "id"
+// @Success			201		{object}	models.Orders
+// @Failure			400,404,409	{object}	response
 // @Failure			500		{object}	response
-// @Router			/api/order/cancel/{id} [PUT]
-func (h *OrderController) CancelOrder(c *gin.Context) {
+// @Router			/api/order/{id} [DELETE]
+func (h *OrderController) DeleteOrder(c *gin.Context) {
 	admin := h.GetAdmin(c)
 	orderId := c.Param("id")
 	var order models.Orders
 	columns := map[string]interface{}{
-		"status":     3,
-		"updated_at": timeNow(),
-		"updated_id": admin.Id,
+		"deleted_at": timeNow(),
+		"deleted_id": admin.Id,
+	}
+	tx := h.db.WithContext(c).Begin()
+	db, ok := h.scoped(c, tx.Clauses(clause.Returning{}).Model(&order), scopeOrders)
+	if !ok {
+		tx.Rollback()
+		return
+	}
+	err := db.Where("orders.id=? AND orders.deleted_at IS NULL", orderId).Updates(columns).Error
+	if err == nil && orderHoldsStock(order.Status) {
+		err = moveOrderStock(tx, &order, models.StockMovementRelease, &admin.Id)
 	}
-	err := h.db.Clauses(clause.Returning{}).Model(&order).Where("id=?", orderId).Updates(columns).Error
 	if err != nil {
-		newResponse(c, http.StatusBadRequest, err.Error())
+		tx.Rollback()
+		newResponse(c, http.StatusInternalServerError, "failed to update order")
 		h.log.Error("failed to update order", err.Error())
 		return
 	}
+	if order.ID == 0 {
+		tx.Rollback()
+		newResponse(c, http.StatusNotFound, "not found order")
+		return
+	}
+	tx.Commit()
 	c.JSON(http.StatusOK, order)
 }
 
-// @Summary		 	Finish Order
-// @Description	   	this api is finish order this api change status to 2
+// @Summary		  Get order by id for admin
+// @Description	   this api is to get order by id from admin
 // @Tags			Order
 // @Security		BearerAuth
 // @Accept			json
 // @Produce			json
-// @Param           id   	path     int   true   "id"
-// @Success			201		{object}	models.Orders
-// @Failure			400,409	{object}	response
+// @Param           id    path     int   true   "id"
+// @Success			200		{object}	models.OrderResponse
+// @Failure			400,404	{object}	response
 // @Failure			500		{object}	response
-// @Router			/api/order/finish/{id} [PUT]
-func (h *OrderController) FinishOrder(c *gin.Context) {
-	admin := h.GetAdmin(c)
-	orderId := c.Param("id")
+// @Router			/api/order/all/{id} [GET]
+func (h *OrderController) GetOrderByAdmin(c *gin.Context) {
+	db, ok := h.scoped(c, h.db.Model(&models.Orders{}), scopeOrders)
+	if !ok {
+		return
+	}
 	var order models.Orders
-	columns := map[string]interface{}{
-		"status":     2,
-		"updated_at": timeNow(),
-		"updated_id": admin.Id,
+	err := db.First(&order, "orders.id=?", c.Param("id")).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			newResponse(c, http.StatusNotFound, "not found order")
+			return
+		}
+		newResponse(c, http.StatusInternalServerError, err.Error())
+		return
 	}
-	err := h.db.Clauses(clause.Returning{}).Model(&order).Where("id=?", orderId).Updates(columns).Error
+	var orderItems []models.OrderItems
+	err = h.db.Where("order_id=?", order.ID).Order("id").Find(&orderItems).Error
 	if err != nil {
-		newResponse(c, http.StatusInternalServerError, "failed to update order")
-		h.log.Error("failed to update order", err.Error())
+		newResponse(c, http.StatusInternalServerError, "failed to get order items")
+		h.log.Error("failed to get order items", err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, order)
+	c.JSON(http.StatusOK, models.OrderResponse{
+		Orders: &order,
+		Items:  orderItems,
+	})
 }
 
-// @Summary		 	Delete Order
-// @Description	   	this api is delete order this api change status to 2
+// @Summary		  Get order applicant by id
+// @Description	   this api is to get order applicant by id
 // @Tags			Order
 // @Security		BearerAuth
 // @Accept			json
 // @Produce			json
-// @Param           id   	path     int   true   "id"
-// @Success			201		{object}	models.Orders
-// @Failure			400,409	{object}	response
+// @Param           id    path     int   true   "id"
+// @Success			200		{object}	models.OrderApplicant
+// @Failure			400,404	{object}	response
 // @Failure			500		{object}	response
-// @Router			/api/order/{id} [DELETE]
-func (h *OrderController) DeleteOrder(c *gin.Context) {
-	admin := h.GetAdmin(c)
-	orderId := c.Param("id")
-	var order models.Orders
-	columns := map[string]interface{}{
-		"deleted_at": timeNow(),
-		"deleted_id": admin.Id,
+// @Router			/api/order/applicant/{id} [GET]
+func (h *OrderController) GetOrderApplicantByID(c *gin.Context) {
+	db, ok := h.scoped(c, h.db.Model(&models.OrderApplicant{}), scopeOrderApplicants)
+	if !ok {
+		return
 	}
-	err := h.db.Clauses(clause.Returning{}).Model(&order).Where("id=?", orderId).Updates(columns).Error
+	var applicant models.OrderApplicant
+	err := db.First(&applicant, "order_applicant.id=?", c.Param("id")).Error
 	if err != nil {
-		newResponse(c, http.StatusInternalServerError, "failed to update order")
-		h.log.Error("failed to update order", err.Error())
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			newResponse(c, http.StatusNotFound, "not found order applicant")
+			return
+		}
+		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
-	c.JSON(http.StatusOK, order)
+	c.JSON(http.StatusOK, applicant)
+}
+
+// @Summary		  Assign order
+// @Description	   this api sets responsible admin of the order
+// @Tags			Order
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id     path    int    true    "order id"
+// @Param			data 	body		models.AssignRequest	true	"data body"
+// @Success			200		{object}	response
+// @Failure			400,404	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/order/assign/{id} [PUT]
+func (h *OrderController) AssignOrder(c *gin.Context) {
+	h.assignResponsible(c, &models.Orders{}, "orders", scopeOrders)
+}
+
+// @Summary		  Assign order applicant
+// @Description	   this api sets responsible admin of the order applicant
+// @Tags			Order
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id     path    int    true    "order applicant id"
+// @Param			data 	body		models.AssignRequest	true	"data body"
+// @Success			200		{object}	response
+// @Failure			400,404	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/order/applicant/assign/{id} [PUT]
+func (h *OrderController) AssignOrderApplicant(c *gin.Context) {
+	h.assignResponsible(c, &models.OrderApplicant{}, "order_applicant", scopeOrderApplicants)
 }
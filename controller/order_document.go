@@ -0,0 +1,224 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Asliddin3/energy-maximum/models"
+	"github.com/Asliddin3/energy-maximum/pkg/document"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	documentInvoice      = "invoice"
+	documentDeliveryNote = "delivery-note"
+)
+
+// orderInvoice returns invoice of the order and numbers the order on first call. The invoices table is
+// locked while the next number is taken, so numbers go without gaps.
+func (h *Handler) orderInvoice(orderID int) (*models.Invoices, error) {
+	var invoice models.Invoices
+	err := h.db.Where("order_id=?", orderID).Limit(1).Find(&invoice).Error
+	if err != nil || invoice.ID != 0 {
+		return &invoice, err
+	}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec("LOCK TABLE invoices IN SHARE ROW EXCLUSIVE MODE").Error
+		if err != nil {
+			return err
+		}
+		err = tx.Where("order_id=?", orderID).Limit(1).Find(&invoice).Error
+		if err != nil || invoice.ID != 0 {
+			return err
+		}
+		var seq int
+		err = tx.Model(&models.Invoices{}).Select("COALESCE(MAX(seq), 0)").Scan(&seq).Error
+		if err != nil {
+			return err
+		}
+		seq++
+		invoice = models.Invoices{
+			OrderID:   orderID,
+			Seq:       seq,
+			Number:    fmt.Sprintf("%s%06d", h.cfg.InvoicePrefix, seq),
+			CreatedAt: timeNow(),
+		}
+		return tx.Create(&invoice).Error
+	})
+	return &invoice, err
+}
+
+func localized(lang, uz, ru, en string) string {
+	switch {
+	case lang == document.LangUz && uz != "":
+		return uz
+	case lang == document.LangEn && en != "":
+		return en
+	}
+	return ru
+}
+
+// writeOrderDocument responds with PDF document of the order found in db. Language is taken from lang
+// query, russian is the default.
+func (h *Handler) writeOrderDocument(c *gin.Context, db *gorm.DB, kind string) {
+	lang := c.DefaultQuery("lang", document.LangRu)
+	if !document.Supported(lang) {
+		newResponse(c, http.StatusBadRequest, "lang must be ru, uz or en")
+		return
+	}
+	var order models.Orders
+	err := db.Preload("Customer").First(&order, "orders.id=? AND orders.deleted_at IS NULL", c.Param("id")).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			newResponse(c, http.StatusNotFound, "not found order")
+			return
+		}
+		newResponse(c, http.StatusInternalServerError, "failed to get order")
+		h.log.Error("failed to get order", err.Error())
+		return
+	}
+	if order.Status == models.OrderStatusCancelled {
+		newResponse(c, http.StatusConflict, "order is cancelled")
+		return
+	}
+	var items []models.OrderItems
+	err = h.db.Where("order_id=?", order.ID).Order("id").Find(&items).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get order items")
+		h.log.Error("failed to get order items", err.Error())
+		return
+	}
+	var contact models.Contact
+	err = h.db.Order("is_main DESC, id").Limit(1).Find(&contact).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get contact")
+		h.log.Error("failed to get contact", err.Error())
+		return
+	}
+	invoice, err := h.orderInvoice(order.ID)
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get invoice number")
+		h.log.Error("failed to get invoice number", err.Error())
+		return
+	}
+	doc := document.Order{
+		Number:  invoice.Number,
+		OrderID: order.ID,
+		Date:    time.Now(),
+		Supplier: document.Party{
+			Name:    localized(lang, contact.NameUz, contact.NameRu, contact.NameEn),
+			Address: contact.Address,
+			Phone:   contact.Phone,
+			Email:   contact.Email,
+		},
+		Comment:  order.Description,
+		Subtotal: order.Total + order.Discount,
+		Discount: order.Discount,
+		Total:    order.Total,
+	}
+	if invoice.CreatedAt != nil {
+		doc.Date = *invoice.CreatedAt
+	}
+	if order.Customer != nil {
+		doc.Customer = document.Party{
+			Name:  order.Customer.Name,
+			Phone: order.Customer.Phone,
+			Email: order.Customer.Email,
+		}
+	}
+	for _, item := range items {
+		doc.Lines = append(doc.Lines, document.Line{
+			Name:   localized(lang, item.NameUz, item.NameRu, item.NameEn),
+			Amount: item.Amount,
+			Price:  item.Price,
+			Total:  item.Total,
+		})
+	}
+	var buf bytes.Buffer
+	if kind == documentInvoice {
+		err = document.Invoice(&buf, lang, doc)
+	} else {
+		err = document.DeliveryNote(&buf, lang, doc)
+	}
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to create document")
+		h.log.Error("failed to create order document", err.Error())
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.pdf"`, kind, invoice.Number))
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
+
+// @Summary		  Download order invoice
+// @Description	   this api returns proforma invoice of own order in PDF, the invoice number is given on first download
+// @Tags			Order
+// @Security		BearerAuth
+// @Produce			application/pdf
+// @Param           id    path     int   true   "order id"
+// @Param           lang  query    string   false   "ru, uz or en"
+// @Success			200		{file}		file
+// @Failure			400,404,409	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/order/{id}/invoice [GET]
+func (h *OrderController) GetOrderInvoice(c *gin.Context) {
+	customer := h.GetCustomer(c)
+	h.writeOrderDocument(c, h.db.Where("orders.customer_id=?", customer.Id), documentInvoice)
+}
+
+// @Summary		  Download order delivery note
+// @Description	   this api returns delivery note of own order in PDF
+// @Tags			Order
+// @Security		BearerAuth
+// @Produce			application/pdf
+// @Param           id    path     int   true   "order id"
+// @Param           lang  query    string   false   "ru, uz or en"
+// @Success			200		{file}		file
+// @Failure			400,404,409	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/order/{id}/delivery-note [GET]
+func (h *OrderController) GetOrderDeliveryNote(c *gin.Context) {
+	customer := h.GetCustomer(c)
+	h.writeOrderDocument(c, h.db.Where("orders.customer_id=?", customer.Id), documentDeliveryNote)
+}
+
+// @Summary		  Download order invoice by admin
+// @Description	   this api returns proforma invoice of the order in PDF, the invoice number is given on first download
+// @Tags			Order
+// @Security		BearerAuth
+// @Produce			application/pdf
+// @Param           id    path     int   true   "order id"
+// @Param           lang  query    string   false   "ru, uz or en"
+// @Success			200		{file}		file
+// @Failure			400,404,409	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/order/all/{id}/invoice [GET]
+func (h *OrderController) GetOrderInvoiceByAdmin(c *gin.Context) {
+	db, ok := h.scoped(c, h.db.Model(&models.Orders{}), scopeOrders)
+	if !ok {
+		return
+	}
+	h.writeOrderDocument(c, db, documentInvoice)
+}
+
+// @Summary		  Download order delivery note by admin
+// @Description	   this api returns delivery note of the order in PDF
+// @Tags			Order
+// @Security		BearerAuth
+// @Produce			application/pdf
+// @Param           id    path     int   true   "order id"
+// @Param           lang  query    string   false   "ru, uz or en"
+// @Success			200		{file}		file
+// @Failure			400,404,409	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/order/all/{id}/delivery-note [GET]
+func (h *OrderController) GetOrderDeliveryNoteByAdmin(c *gin.Context) {
+	db, ok := h.scoped(c, h.db.Model(&models.Orders{}), scopeOrders)
+	if !ok {
+		return
+	}
+	h.writeOrderDocument(c, db, documentDeliveryNote)
+}
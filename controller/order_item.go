@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/Asliddin3/energy-maximum/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// orderItemError is shown to the user as is.
+type orderItemError struct {
+	message string
+}
+
+func (e *orderItemError) Error() string {
+	return e.message
+}
+
+func roundMoney(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// priceOrderItems builds order lines from active products with their current price and returns the total.
+// Lines of the same product are merged. Prices come from the price list of the customer group if it has one.
+func priceOrderItems(tx *gorm.DB, customerID int, items []models.OrderItemsRequest) ([]models.OrderItems, float64, error) {
+	if len(items) == 0 {
+		return nil, 0, &orderItemError{"order has no items"}
+	}
+	ids := make([]int, 0, len(items))
+	amounts := make(map[int]int, len(items))
+	for _, item := range items {
+		if item.Amount <= 0 {
+			return nil, 0, &orderItemError{fmt.Sprintf("amount of product %d must be positive", item.ItemID)}
+		}
+		if _, ok := amounts[item.ItemID]; !ok {
+			ids = append(ids, item.ItemID)
+		}
+		amounts[item.ItemID] += item.Amount
+	}
+	var products []models.Products
+	err := tx.Where("id IN ? AND is_active=true AND deleted_at IS NULL", ids).Find(&products).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	prices, err := loadPriceList(tx, customerID, products)
+	if err != nil {
+		return nil, 0, err
+	}
+	byID := make(map[int]models.Products, len(products))
+	for _, product := range products {
+		byID[product.ID] = product
+	}
+	lines := make([]models.OrderItems, 0, len(ids))
+	var total float64
+	for _, id := range ids {
+		product, ok := byID[id]
+		if !ok {
+			return nil, 0, &orderItemError{fmt.Sprintf("product %d is not available", id)}
+		}
+		price := prices.price(&product, amounts[id])
+		line := models.OrderItems{
+			ItemId:    id,
+			NameUz:    product.NameUz,
+			NameRu:    product.NameRu,
+			NameEn:    product.NameEn,
+			Price:     price,
+			Amount:    amounts[id],
+			Total:     roundMoney(price * float64(amounts[id])),
+			CreatedAt: timeNow(),
+		}
+		total += line.Total
+		lines = append(lines, line)
+	}
+	return lines, roundMoney(total), nil
+}
+
+func (h *Handler) orderItemsError(c *gin.Context, err error) {
+	var itemErr *orderItemError
+	if errors.As(err, &itemErr) {
+		newResponse(c, http.StatusBadRequest, itemErr.Error())
+		return
+	}
+	newResponse(c, http.StatusInternalServerError, "failed to get products")
+	h.log.Error("failed to price order items", err.Error())
+}
+
+// createOrder prices the items, applies the promo code, creates the order with its lines and reserves
+// their stock in the transaction, it commits or rolls back tx and notifies the customer. It writes the
+// response and returns false on failure.
+func (h *Handler) createOrder(c *gin.Context, tx *gorm.DB, order *models.Orders, items []models.OrderItemsRequest, promoCode string) ([]models.OrderItems, bool) {
+	orderItems, total, err := priceOrderItems(tx, order.CustomerID, items)
+	var redemption *models.PromotionRedemptions
+	if err == nil {
+		order.Total = total
+		if promoCode != "" {
+			redemption, err = applyPromotion(tx, promoCode, order, orderItems)
+		}
+	}
+	if err != nil {
+		tx.Rollback()
+		h.orderItemsError(c, err)
+		return nil, false
+	}
+	order.Status = models.OrderStatusNew
+	err = tx.Clauses(clause.Returning{}).Create(order).Error
+	if err == nil {
+		for i := range orderItems {
+			orderItems[i].OrderID = order.ID
+		}
+		err = tx.Create(&orderItems).Error
+	}
+	if err == nil && redemption != nil {
+		redemption.OrderID = order.ID
+		err = tx.Create(redemption).Error
+	}
+	if err == nil {
+		err = recordOrderStatus(tx, order, "", order.CreatedID, "")
+	}
+	if err == nil {
+		err = reserveOrderStock(tx, order, order.CreatedID, nil)
+	}
+	if err != nil {
+		tx.Rollback()
+		h.stockError(c, err, "failed to create order")
+		return nil, false
+	}
+	err = tx.Commit().Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to create order")
+		h.log.Error("failed to commit order", err.Error())
+		return nil, false
+	}
+	h.notifyOrder(models.NotificationOrderCreated, *order, "")
+	return orderItems, true
+}
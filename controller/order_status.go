@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Asliddin3/energy-maximum/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// orderTransitions lists statuses the order can move to from each status.
+var orderTransitions = map[string][]string{
+	models.OrderStatusDraft:      {models.OrderStatusNew, models.OrderStatusCancelled},
+	models.OrderStatusNew:        {models.OrderStatusConfirmed, models.OrderStatusCancelled},
+	models.OrderStatusConfirmed:  {models.OrderStatusProcessing, models.OrderStatusCancelled},
+	models.OrderStatusProcessing: {models.OrderStatusShipped, models.OrderStatusCancelled},
+	models.OrderStatusShipped:    {models.OrderStatusCompleted, models.OrderStatusReturned},
+	models.OrderStatusCompleted:  {models.OrderStatusReturned},
+	models.OrderStatusCancelled:  {},
+	models.OrderStatusReturned:   {},
+}
+
+func canTransitOrder(from, to string) bool {
+	for _, status := range orderTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// recordOrderStatus writes status change of the order, author is admin when adminID is set.
+func recordOrderStatus(tx *gorm.DB, order *models.Orders, from string, adminID *int, comment string) error {
+	entry := models.OrderStatusHistory{
+		OrderID:    order.ID,
+		FromStatus: from,
+		ToStatus:   order.Status,
+		Comment:    comment,
+		AdminID:    adminID,
+		CreatedAt:  timeNow(),
+	}
+	if adminID == nil {
+		entry.CustomerID = &order.CustomerID
+	}
+	return tx.Create(&entry).Error
+}
+
+// reserveDraftOrder reserves stock of the draft order placed as new, the draft must have items.
+func reserveDraftOrder(tx *gorm.DB, order *models.Orders, adminID *int) error {
+	var count int64
+	err := tx.Model(&models.OrderItems{}).Where("order_id=?", order.ID).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return &stockError{"draft order has no items"}
+	}
+	return reserveOrderStock(tx, order, adminID, nil)
+}
+
+// @Summary		  Change order status
+// @Description	   this api moves order to the status, moves are draft -> new -> confirmed -> processing -> shipped -> completed, not shipped order can be cancelled, shipped and completed order can be returned. Stock is reserved when draft becomes new, released on cancel, deducted on complete and put back on return
+// @Tags			Order
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "order id"
+// @Param			data 	body		models.OrderTransitionRequest	true	"data body"
+// @Success			200		{object}	models.Orders
+// @Failure			400,404,409	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/order/status/{id} [PUT]
+func (h *OrderController) TransitOrder(c *gin.Context) {
+	admin := h.GetAdmin(c)
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, "Invalid id")
+		return
+	}
+	var body models.OrderTransitionRequest
+	err = c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, ok := orderTransitions[body.Status]; !ok {
+		newResponse(c, http.StatusBadRequest, "unknown order status "+body.Status)
+		return
+	}
+	tx := h.db.WithContext(c).Begin()
+	db, ok := h.scoped(c, tx.Model(&models.Orders{}), scopeOrders)
+	if !ok {
+		tx.Rollback()
+		return
+	}
+	var order models.Orders
+	err = db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&order, "orders.id=? AND orders.deleted_at IS NULL", id).Error
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			newResponse(c, http.StatusNotFound, "not found order")
+			return
+		}
+		newResponse(c, http.StatusInternalServerError, "failed to get order")
+		h.log.Error("failed to get order", err.Error())
+		return
+	}
+	from := order.Status
+	if !canTransitOrder(from, body.Status) {
+		tx.Rollback()
+		newResponse(c, http.StatusConflict, fmt.Sprintf("order can not move from %s to %s", from, body.Status))
+		return
+	}
+	order.Status = body.Status
+	err = tx.Model(&order).Updates(map[string]interface{}{
+		"status":     order.Status,
+		"updated_at": timeNow(),
+		"updated_id": admin.Id,
+	}).Error
+	if err == nil {
+		err = recordOrderStatus(tx, &order, from, &admin.Id, body.Comment)
+	}
+	if from == models.OrderStatusDraft && order.Status == models.OrderStatusNew && err == nil {
+		err = reserveDraftOrder(tx, &order, &admin.Id)
+	} else if movement := orderStockMovement(from, order.Status); err == nil && movement != "" {
+		err = moveOrderStock(tx, &order, movement, &admin.Id)
+	}
+	if err != nil {
+		tx.Rollback()
+		h.stockError(c, err, "failed to update order status")
+		return
+	}
+	tx.Commit()
+	h.notifyOrder(models.NotificationOrderStatus, order, body.Comment)
+	c.JSON(http.StatusOK, order)
+}
+
+// @Summary		  Get order status history
+// @Description	   this api returns status changes of the order, oldest first
+// @Tags			Order
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "order id"
+// @Success			200		{object}	[]models.OrderStatusHistory
+// @Failure			400,404	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/order/history/{id} [GET]
+func (h *OrderController) GetOrderStatusHistory(c *gin.Context) {
+	db, ok := h.scoped(c, h.db.Model(&models.Orders{}), scopeOrders)
+	if !ok {
+		return
+	}
+	var count int64
+	err := db.Where("orders.id=?", c.Param("id")).Count(&count).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get order")
+		h.log.Error("failed to get order", err.Error())
+		return
+	}
+	if count == 0 {
+		newResponse(c, http.StatusNotFound, "not found order")
+		return
+	}
+	history := make([]models.OrderStatusHistory, 0)
+	err = h.db.Where("order_id=?", c.Param("id")).Order("id").Find(&history).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get order history")
+		h.log.Error("failed to get order history", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/Asliddin3/energy-maximum/models"
+)
+
+func TestCanTransitOrder(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{models.OrderStatusDraft, models.OrderStatusNew, true},
+		{models.OrderStatusDraft, models.OrderStatusCancelled, true},
+		{models.OrderStatusDraft, models.OrderStatusConfirmed, false},
+		{models.OrderStatusNew, models.OrderStatusConfirmed, true},
+		{models.OrderStatusNew, models.OrderStatusShipped, false},
+		{models.OrderStatusConfirmed, models.OrderStatusProcessing, true},
+		{models.OrderStatusConfirmed, models.OrderStatusNew, false},
+		{models.OrderStatusProcessing, models.OrderStatusShipped, true},
+		{models.OrderStatusProcessing, models.OrderStatusCompleted, false},
+		{models.OrderStatusShipped, models.OrderStatusCompleted, true},
+		{models.OrderStatusShipped, models.OrderStatusReturned, true},
+		{models.OrderStatusShipped, models.OrderStatusCancelled, false},
+		{models.OrderStatusCompleted, models.OrderStatusReturned, true},
+		{models.OrderStatusCompleted, models.OrderStatusCancelled, false},
+		{models.OrderStatusCancelled, models.OrderStatusNew, false},
+		{models.OrderStatusReturned, models.OrderStatusCompleted, false},
+		{models.OrderStatusNew, models.OrderStatusNew, false},
+		{"unknown", models.OrderStatusNew, false},
+		{models.OrderStatusNew, "unknown", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+"->"+tt.to, func(t *testing.T) {
+			if got := canTransitOrder(tt.from, tt.to); got != tt.want {
+				t.Errorf("canTransitOrder(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
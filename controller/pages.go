@@ -20,7 +20,7 @@ type PagesController struct {
 
 func (h *Handler) NewPagesController(api *gin.RouterGroup) {
 	category := &PagesController{h}
-	cate := api.Group("pages", h.DeserializeAdmin())
+	cate := h.adminGroup(api, "pages")
 	{
 		cate.POST("", category.CreateCategory)
 		cate.PUT("/:id", category.UpdateCategory)
@@ -91,7 +91,7 @@ func (h *PagesController) CreateCategory(c *gin.Context) {
 	if body.Position != nil {
 		category.Position = body.Position
 	}
-	err = h.db.Clauses(clause.Returning{}).Create(&category).Error
+	err = h.db.WithContext(c).Clauses(clause.Returning{}).Create(&category).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -188,7 +188,7 @@ func (h *PagesController) UpdateCategory(c *gin.Context) {
 	}
 	columns["updated_at"] = timeNow()
 	columns["updated_id"] = admin.Id
-	err = h.db.Debug().Clauses(clause.Returning{}).Model(&category).
+	err = h.db.WithContext(c).Debug().Clauses(clause.Returning{}).Model(&category).
 		Where("id=?", categoryId).Updates(columns).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
@@ -304,7 +304,7 @@ func (h *PagesController) DeleteByID(c *gin.Context) {
 		newResponse(c, http.StatusBadRequest, "empty category id")
 		return
 	}
-	err := h.db.Delete(&models.Pages{}, "id=?", categoryId).Error
+	err := h.db.WithContext(c).Delete(&models.Pages{}, "id=?", categoryId).Error
 	if err != nil {
 		if strings.Contains(err.Error(), "foreign key constraint") {
 			newResponse(c, http.StatusBadRequest, "this category has relation to product")
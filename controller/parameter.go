@@ -18,7 +18,7 @@ type ParameterController struct {
 
 func (h *Handler) NewParameterController(api *gin.RouterGroup) {
 	brand := &ParameterController{h}
-	br := api.Group("parameter", h.DeserializeAdmin())
+	br := h.adminGroup(api, "parameter")
 	{
 		br.POST("/", brand.CreateParameter)
 		br.PUT("/:id", brand.UpdateParameter)
@@ -57,7 +57,7 @@ func (h *ParameterController) CreateParameter(c *gin.Context) {
 		CreatedID: &admin.Id,
 		CreatedAt: timeNow(),
 	}
-	err = h.db.Debug().Clauses(clause.Returning{}).Create(&parameter).Error
+	err = h.db.WithContext(c).Debug().Clauses(clause.Returning{}).Create(&parameter).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -89,7 +89,7 @@ func (h *ParameterController) UpdateParameter(c *gin.Context) {
 	}
 
 	parameter := models.Parameters{}
-	err = h.db.First(&parameter, "id=?", id).Error
+	err = h.db.WithContext(c).First(&parameter, "id=?", id).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		h.log.Error("failed to create parameter", err.Error())
@@ -109,7 +109,7 @@ func (h *ParameterController) UpdateParameter(c *gin.Context) {
 	}
 	parameter.CreatedAt = timeNow()
 	parameter.CreatedID = &admin.Id
-	err = h.db.Save(&parameter).Error
+	err = h.db.WithContext(c).Save(&parameter).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		h.log.Error("failed to save brand", err.Error())
@@ -211,7 +211,7 @@ func (h *ParameterController) GetByID(c *gin.Context) {
 // @Router			/api/parameter/{id} [DELETE]
 func (h *ParameterController) DeleteParameter(c *gin.Context) {
 	id := c.Param("id")
-	err := h.db.Model(&models.Parameters{}).Where("id=?", id).UpdateColumn("is_deleted", true).Error
+	err := h.db.WithContext(c).Model(&models.Parameters{}).Where("id=?", id).UpdateColumn("is_deleted", true).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -0,0 +1,308 @@
+package controller
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Asliddin3/energy-maximum/models"
+	"github.com/Asliddin3/energy-maximum/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// passwordPolicyError is shown to the user as is.
+type passwordPolicyError struct {
+	message string
+}
+
+func (e *passwordPolicyError) Error() string {
+	return e.message
+}
+
+var errResetTokenInvalid = errors.New("invalid or expired reset token")
+
+// validatePassword checks the password against length and character class rules from config.
+func (h *Handler) validatePassword(password string) error {
+	var upper, lower, digit, special bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			special = true
+		}
+	}
+	missing := make([]string, 0)
+	if h.cfg.PasswordRequireUpper && !upper {
+		missing = append(missing, "uppercase letter")
+	}
+	if h.cfg.PasswordRequireLower && !lower {
+		missing = append(missing, "lowercase letter")
+	}
+	if h.cfg.PasswordRequireDigit && !digit {
+		missing = append(missing, "digit")
+	}
+	if h.cfg.PasswordRequireSpecial && !special {
+		missing = append(missing, "special character")
+	}
+	if len([]rune(password)) < h.cfg.PasswordMinLength {
+		return &passwordPolicyError{fmt.Sprintf("password must be at least %d characters", h.cfg.PasswordMinLength)}
+	}
+	if len(missing) > 0 {
+		return &passwordPolicyError{"password must contain " + strings.Join(missing, ", ")}
+	}
+	return nil
+}
+
+// hashNewPassword validates the password, rejects the current and last N passwords of the
+// subject and returns the hash. SubjectID is 0 for a subject which is not created yet.
+func (h *Handler) hashNewPassword(db *gorm.DB, subjectType string, subjectID int, currentHash, password string) (string, error) {
+	err := h.validatePassword(password)
+	if err != nil {
+		return "", err
+	}
+	previous := make([]string, 0)
+	if currentHash != "" {
+		previous = append(previous, currentHash)
+	}
+	if subjectID != 0 && h.cfg.PasswordHistory > 0 {
+		var hashes []string
+		err = db.Model(&models.PasswordHistory{}).Where("subject_type=? AND subject_id=?", subjectType, subjectID).
+			Order("id DESC").Limit(h.cfg.PasswordHistory).Pluck("password_hash", &hashes).Error
+		if err != nil {
+			return "", err
+		}
+		previous = append(previous, hashes...)
+	}
+	for _, hash := range previous {
+		if h.hash.CheckPassword(hash, password) == nil {
+			return "", &passwordPolicyError{fmt.Sprintf("password must differ from the last %d passwords", h.cfg.PasswordHistory)}
+		}
+	}
+	return h.hash.HashPassword(password)
+}
+
+// rememberPassword adds the hash to password history and drops entries older than the last N.
+func (h *Handler) rememberPassword(db *gorm.DB, subjectType string, subjectID int, hash string) error {
+	if h.cfg.PasswordHistory <= 0 {
+		return nil
+	}
+	err := db.Create(&models.PasswordHistory{
+		SubjectType:  subjectType,
+		SubjectID:    subjectID,
+		PasswordHash: hash,
+		CreatedAt:    timeNow(),
+	}).Error
+	if err != nil {
+		return err
+	}
+	keep := db.Model(&models.PasswordHistory{}).Select("id").
+		Where("subject_type=? AND subject_id=?", subjectType, subjectID).Order("id DESC").Limit(h.cfg.PasswordHistory)
+	return db.Where("subject_type=? AND subject_id=? AND id NOT IN (?)", subjectType, subjectID, keep).
+		Delete(&models.PasswordHistory{}).Error
+}
+
+func (h *Handler) passwordError(c *gin.Context, err error) {
+	var policyErr *passwordPolicyError
+	if errors.As(err, &policyErr) {
+		newResponse(c, http.StatusBadRequest, policyErr.Error())
+		return
+	}
+	newResponse(c, http.StatusInternalServerError, "failed to hash password")
+	h.log.Error("error while hash password", logger.Error(err))
+}
+
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// @Summary		  Forgot customer password
+// @Description	   this api sends password reset code, the answer is the same when the phone is not registered
+// @Tags			Customer
+// @Accept			json
+// @Produce			json
+// @Param			data 	body		models.ForgotPasswordRequest	true	"data body"
+// @Success			200		{object}	response
+// @Failure			400,401	{object}	response
+// @Failure			429		{object}	models.RetryAfterResponse
+// @Failure			500		{object}	response
+// @Router			/api/customer/password/forgot [POST]
+func (h *CustomerController) ForgotPassword(c *gin.Context) {
+	var body models.ForgotPasswordRequest
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	phone := formatPhone(body.Phone)
+	number, err := strconv.ParseInt(phone, 10, 64)
+	if phone == "" || err != nil {
+		newResponse(c, http.StatusUnauthorized, "Неправильный номер телефона!")
+		return
+	}
+	var count int64
+	err = h.db.Model(&models.Customer{}).Where("phone=?", phone).Count(&count).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to find customer")
+		h.log.Error("failed to find customer", logger.Error(err))
+		return
+	}
+	if !h.issueCode(c, phone, int(number), models.CodePurposePasswordReset, count != 0) {
+		return
+	}
+	c.JSON(http.StatusOK, response{"success"})
+}
+
+// @Summary		  Check password reset code
+// @Description	   this api checks reset code and returns single-use reset token
+// @Tags			Customer
+// @Accept			json
+// @Produce			json
+// @Param			data 	body		models.ResetCodeRequest	true	"data body"
+// @Success			200		{object}	models.ResetTokenResponse
+// @Failure			400,401	{object}	response
+// @Failure			429		{object}	models.RetryAfterResponse
+// @Failure			500		{object}	response
+// @Router			/api/customer/password/verify [POST]
+func (h *CustomerController) VerifyResetCode(c *gin.Context) {
+	var body models.ResetCodeRequest
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	phone := formatPhone(body.Phone)
+	number, err := strconv.ParseInt(phone, 10, 64)
+	if phone == "" || err != nil {
+		newResponse(c, http.StatusUnauthorized, "Неправильный номер телефона!")
+		return
+	}
+	if !h.verifyCode(c, int(number), models.CodePurposePasswordReset, body.Code) {
+		return
+	}
+	var customer models.Customer
+	err = h.db.First(&customer, "phone=?", phone).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			newResponse(c, http.StatusUnauthorized, "Неправильный код!")
+			return
+		}
+		newResponse(c, http.StatusInternalServerError, "failed to find customer")
+		h.log.Error("failed to find customer", logger.Error(err))
+		return
+	}
+	random := make([]byte, 32)
+	_, err = rand.Read(random)
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to create reset token")
+		return
+	}
+	token := base64.RawURLEncoding.EncodeToString(random)
+	expiresAt := time.Now().Add(h.cfg.PasswordResetTTL)
+	tx := h.db.Begin()
+	err = tx.Model(&models.PasswordResetTokens{}).Where("customer_id=? AND used_at IS NULL", customer.ID).
+		UpdateColumn("used_at", timeNow()).Error
+	if err == nil {
+		err = tx.Create(&models.PasswordResetTokens{
+			CustomerID: customer.ID,
+			TokenHash:  hashResetToken(token),
+			ExpiresAt:  &expiresAt,
+			CreatedAt:  timeNow(),
+		}).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		newResponse(c, http.StatusInternalServerError, "failed to create reset token")
+		h.log.Error("failed to create reset token", logger.Error(err))
+		return
+	}
+	tx.Commit()
+	c.JSON(http.StatusOK, models.ResetTokenResponse{
+		ResetToken: token,
+		ExpiresAt:  &expiresAt,
+	})
+}
+
+// @Summary		  Reset customer password
+// @Description	   this api sets new password by reset token and logs out every session of the customer
+// @Tags			Customer
+// @Accept			json
+// @Produce			json
+// @Param			data 	body		models.ResetPasswordRequest	true	"data body"
+// @Success			200		{object}	response
+// @Failure			400,401	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/customer/password/reset [POST]
+func (h *CustomerController) ResetPassword(c *gin.Context) {
+	var body models.ResetPasswordRequest
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	tx := h.db.Begin()
+	var token models.PasswordResetTokens
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&token, "token_hash=? AND used_at IS NULL AND expires_at>?", hashResetToken(body.ResetToken), time.Now()).Error
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			newResponse(c, http.StatusUnauthorized, errResetTokenInvalid.Error())
+			return
+		}
+		newResponse(c, http.StatusInternalServerError, "failed to get reset token")
+		h.log.Error("failed to get reset token", logger.Error(err))
+		return
+	}
+	var customer models.Customer
+	err = tx.First(&customer, "id=?", token.CustomerID).Error
+	if err != nil {
+		tx.Rollback()
+		newResponse(c, http.StatusInternalServerError, "failed to find customer")
+		h.log.Error("failed to find customer", logger.Error(err))
+		return
+	}
+	hashed, err := h.hashNewPassword(tx, models.TokenSubjectCustomer, customer.ID, customer.Password, body.Password)
+	if err != nil {
+		tx.Rollback()
+		h.passwordError(c, err)
+		return
+	}
+	err = tx.Model(&customer).UpdateColumns(map[string]interface{}{
+		"password":            hashed,
+		"password_changed_at": time.Now().Truncate(time.Second),
+		"updated_at":          timeNow(),
+	}).Error
+	if err == nil {
+		err = h.rememberPassword(tx, models.TokenSubjectCustomer, customer.ID, hashed)
+	}
+	if err == nil {
+		err = tx.Model(&token).UpdateColumn("used_at", timeNow()).Error
+	}
+	if err == nil {
+		err = h.RevokeSubjectTokens(tx, models.TokenSubjectCustomer, customer.ID)
+	}
+	if err != nil {
+		tx.Rollback()
+		newResponse(c, http.StatusInternalServerError, "failed to reset password")
+		h.log.Error("failed to reset password", logger.Error(err))
+		return
+	}
+	tx.Commit()
+	c.JSON(http.StatusOK, response{"success"})
+}
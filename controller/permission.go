@@ -0,0 +1,236 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/Asliddin3/energy-maximum/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const adminPermissionsKey = "admin_permissions"
+
+var swaggerParamPattern = regexp.MustCompile(`\{([^/{}]+)\}`)
+
+// normalizeEndPoint brings stored end points and gin route templates to one form,
+// so "/api/admin/{id}/" and "/api/admin/:id" are treated as the same route.
+func normalizeEndPoint(endPoint string) string {
+	endPoint = strings.TrimSpace(endPoint)
+	endPoint = swaggerParamPattern.ReplaceAllString(endPoint, ":$1")
+	if !strings.HasPrefix(endPoint, "/") {
+		endPoint = "/" + endPoint
+	}
+	if len(endPoint) > 1 {
+		endPoint = strings.TrimRight(endPoint, "/")
+	}
+	return endPoint
+}
+
+func matchPermission(permission models.AdminPermission, method, endPoint string) bool {
+	return strings.EqualFold(permission.Method, method) &&
+		normalizeEndPoint(permission.EndPoint) == normalizeEndPoint(endPoint)
+}
+
+// maxRoleDepth limits walking up parent roles, it also stops a broken chain with a cycle.
+const maxRoleDepth = 10
+
+// roleChainQuery selects the role and its parents, an inactive or deleted role breaks the chain.
+const roleChainQuery = `WITH RECURSIVE chain AS (
+	SELECT id, parent_id, 1 AS depth FROM roles WHERE id=? AND is_active=true AND is_deleted=false
+	UNION ALL
+	SELECT r.id, r.parent_id, chain.depth+1 FROM roles r JOIN chain ON r.id=chain.parent_id
+	WHERE r.is_active=true AND r.is_deleted=false AND chain.depth<?
+) SELECT id FROM chain`
+
+// loadRolePermissions returns module items granted to the role and inherited from its parents.
+// Inactive and deleted roles grant nothing.
+func (h *Handler) loadRolePermissions(roleID int) ([]models.AdminPermission, error) {
+	permissions := make([]models.AdminPermission, 0)
+	err := h.db.Table("module_items mi").
+		Select("mi.key, mi.end_point, mi.method").
+		Where("mi.key IN (SELECT ri.module_item_key FROM role_items ri WHERE ri.role_id IN ("+roleChainQuery+"))", roleID, maxRoleDepth).
+		Order("mi.key").
+		Scan(&permissions).Error
+	return permissions, err
+}
+
+// permissionKeys returns keys of the permissions.
+func permissionKeys(permissions []models.AdminPermission) []string {
+	keys := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		keys = append(keys, permission.Key)
+	}
+	return keys
+}
+
+// checkRoleParent returns an error shown to the user when the parent can not be set to the role.
+// RoleID is 0 for a role which is not created yet.
+func (h *Handler) checkRoleParent(db *gorm.DB, roleID int, parentID *int) (string, error) {
+	if parentID == nil {
+		return "", nil
+	}
+	if *parentID == roleID {
+		return "role can not be parent of itself", nil
+	}
+	var parent models.Roles
+	err := db.First(&parent, "id=? AND is_deleted=false", *parentID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "parent role not found", nil
+		}
+		return "", err
+	}
+	var ancestors []int
+	err = db.Raw(`WITH RECURSIVE chain AS (
+	SELECT id, parent_id, 1 AS depth FROM roles WHERE id=?
+	UNION ALL
+	SELECT r.id, r.parent_id, chain.depth+1 FROM roles r JOIN chain ON r.id=chain.parent_id WHERE chain.depth<=?
+) SELECT id FROM chain`, *parentID, maxRoleDepth).Scan(&ancestors).Error
+	if err != nil {
+		return "", err
+	}
+	if len(ancestors) >= maxRoleDepth {
+		return fmt.Sprintf("role hierarchy can not be deeper than %d levels", maxRoleDepth), nil
+	}
+	for _, id := range ancestors {
+		if id == roleID {
+			return "parent role can not be a child of the role", nil
+		}
+	}
+	return "", nil
+}
+
+// checkRoleGrant returns an error shown to the user when the current admin can not give the role to an admin.
+// The role may not grant anything the current admin does not have.
+func (h *Handler) checkRoleGrant(c *gin.Context, roleID int) (string, error) {
+	var count int64
+	err := h.db.Model(&models.Roles{}).Where("id=? AND is_deleted=false", roleID).Count(&count).Error
+	if err != nil {
+		return "", err
+	}
+	if count == 0 {
+		return "role not found", nil
+	}
+	permissions, err := h.loadRolePermissions(roleID)
+	if err != nil {
+		return "", err
+	}
+	key, err := h.checkApiKeyItems(c, permissionKeys(permissions))
+	if err != nil || key == "" {
+		return "", err
+	}
+	return fmt.Sprintf("role grants %s which you do not have", key), nil
+}
+
+// canGrantRole checks that the current admin may give the role, it writes the response and returns false
+// when the role is not allowed.
+func (h *Handler) canGrantRole(c *gin.Context, roleID int) bool {
+	message, err := h.checkRoleGrant(c, roleID)
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to check role")
+		h.log.Error("failed to check role", err.Error())
+		return false
+	}
+	if message != "" {
+		newResponse(c, http.StatusForbidden, message)
+		return false
+	}
+	return true
+}
+
+// canGrantKeys checks that the current admin has every module item given to a role, it writes the response
+// and returns false when one of them is not allowed.
+func (h *Handler) canGrantKeys(c *gin.Context, keys []string) bool {
+	key, err := h.checkApiKeyItems(c, keys)
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to check module items")
+		h.log.Error("failed to check module items", err.Error())
+		return false
+	}
+	if key != "" {
+		newResponse(c, http.StatusForbidden, fmt.Sprintf("you can not grant %s", key))
+		return false
+	}
+	return true
+}
+
+// GetAdminPermissions returns permissions of the current admin, they are loaded once per request.
+func (h *Handler) GetAdminPermissions(c *gin.Context) ([]models.AdminPermission, error) {
+	if cached, ok := c.Get(adminPermissionsKey); ok {
+		return cached.([]models.AdminPermission), nil
+	}
+	admin := h.GetAdmin(c)
+	permissions, err := h.loadRolePermissions(admin.RoleID)
+	if err != nil {
+		return nil, err
+	}
+	c.Set(adminPermissionsKey, permissions)
+	return permissions, nil
+}
+
+// HasPermission reports whether the current admin may use the module item with given key.
+func (h *Handler) HasPermission(c *gin.Context, key string) (bool, error) {
+	admin := h.GetAdmin(c)
+	if admin.IsSuperuser {
+		return true, nil
+	}
+	permissions, err := h.GetAdminPermissions(c)
+	if err != nil {
+		return false, err
+	}
+	for _, permission := range permissions {
+		if permission.Key == key {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// checkAdminPermission matches the route template and method of the request against
+// the admin role items. It aborts the request and returns false when nothing matches.
+func (h *Handler) checkAdminPermission(c *gin.Context) bool {
+	admin := h.GetAdmin(c)
+	if admin.IsSuperuser {
+		return true
+	}
+	method := c.Request.Method
+	endPoint := c.FullPath()
+	permissions, err := h.GetAdminPermissions(c)
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get admin permissions")
+		h.log.Error("failed to get admin permissions", err.Error())
+		return false
+	}
+	for _, permission := range permissions {
+		if matchPermission(permission, method, endPoint) {
+			return true
+		}
+	}
+
+	denied := models.PermissionDenied{
+		Message:  "you have no permission for this action",
+		Method:   method,
+		EndPoint: endPoint,
+	}
+	var moduleItems []models.AdminPermission
+	err = h.db.Model(&models.ModuleItems{}).Select("key, end_point, method").
+		Where("UPPER(method)=?", strings.ToUpper(method)).Scan(&moduleItems).Error
+	if err != nil {
+		h.log.Error("failed to get module items", err.Error())
+	}
+	for _, item := range moduleItems {
+		if matchPermission(item, method, endPoint) {
+			denied.Permission = item.Key
+			break
+		}
+	}
+	if denied.Permission == "" {
+		denied.Message = "no permission is registered for this action"
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden, denied)
+	return false
+}
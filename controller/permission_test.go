@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/Asliddin3/energy-maximum/models"
+)
+
+func TestNormalizeEndPoint(t *testing.T) {
+	tests := []struct {
+		endPoint string
+		want     string
+	}{
+		{"/api/admin/:id", "/api/admin/:id"},
+		{"/api/admin/{id}", "/api/admin/:id"},
+		{"/api/admin/{id}/", "/api/admin/:id"},
+		{"api/admin", "/api/admin"},
+		{"  /api/admin/  ", "/api/admin"},
+		{"/api/order/{id}/item/{item_id}", "/api/order/:id/item/:item_id"},
+		{"/", "/"},
+		{"", "/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.endPoint, func(t *testing.T) {
+			if got := normalizeEndPoint(tt.endPoint); got != tt.want {
+				t.Errorf("normalizeEndPoint(%q) = %q, want %q", tt.endPoint, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchPermission(t *testing.T) {
+	permission := models.AdminPermission{Key: "admin-update", EndPoint: "/api/admin/{id}", Method: "put"}
+	tests := []struct {
+		name     string
+		method   string
+		endPoint string
+		want     bool
+	}{
+		{"same route", "PUT", "/api/admin/:id", true},
+		{"trailing slash", "PUT", "/api/admin/:id/", true},
+		{"other method", "DELETE", "/api/admin/:id", false},
+		{"other route", "PUT", "/api/admin/:id/role", false},
+		{"route prefix", "PUT", "/api/admin", false},
+		{"literal id is not template", "PUT", "/api/admin/5", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchPermission(permission, tt.method, tt.endPoint); got != tt.want {
+				t.Errorf("matchPermission(%s %s) = %v, want %v", tt.method, tt.endPoint, got, tt.want)
+			}
+		})
+	}
+}
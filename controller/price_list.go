@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"sort"
+
+	"github.com/Asliddin3/energy-maximum/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxCategoryDepth stops walking up the category tree when parents make a loop.
+const maxCategoryDepth = 20
+
+// priceList resolves prices of products for the customer group, nil priceList keeps base prices.
+type priceList struct {
+	rows []models.PriceListItems
+	// categories has the category of a product followed by its parents, nearest first
+	categories map[int][]int
+}
+
+// loadPriceList loads rows of the group of the customer which may apply to the products. It returns nil
+// for guests, customers without group and inactive groups.
+func loadPriceList(db *gorm.DB, customerID int, products []models.Products) (*priceList, error) {
+	if customerID == 0 || len(products) == 0 {
+		return nil, nil
+	}
+	db = db.Session(&gorm.Session{NewDB: true})
+	var groupIDs []int
+	err := db.Table("customer").Joins("JOIN customer_groups ON customer_groups.id=customer.group_id").
+		Where("customer.id=? AND customer_groups.is_active=true", customerID).Pluck("customer.group_id", &groupIDs).Error
+	if err != nil || len(groupIDs) == 0 {
+		return nil, err
+	}
+	ids := make([]int, 0, len(products))
+	brands := make([]int, 0)
+	leaves := make([]int, 0)
+	for _, product := range products {
+		ids = append(ids, product.ID)
+		if product.BrandID != nil {
+			brands = append(brands, *product.BrandID)
+		}
+		if product.ParentID != nil {
+			leaves = append(leaves, *product.ParentID)
+		}
+	}
+	res := &priceList{categories: map[int][]int{}}
+	categories := make([]int, 0)
+	if len(leaves) != 0 {
+		var tree []struct {
+			Leaf int
+			ID   int
+		}
+		err = db.Raw(`WITH RECURSIVE up AS (
+	SELECT id AS leaf, id, category_id, 0 AS depth FROM category WHERE id IN ?
+	UNION ALL SELECT up.leaf, category.id, category.category_id, up.depth+1 FROM category
+	JOIN up ON category.id=up.category_id WHERE up.depth<?
+) SELECT leaf, id FROM up ORDER BY leaf, depth`, leaves, maxCategoryDepth).Scan(&tree).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range tree {
+			res.categories[node.Leaf] = append(res.categories[node.Leaf], node.ID)
+			categories = append(categories, node.ID)
+		}
+	}
+	err = db.Where("group_id=?", groupIDs[0]).
+		Where(db.Where("kind=?", models.PriceKindAll).
+			Or("kind=? AND target_id IN ?", models.PriceKindProduct, ids).
+			Or("kind=? AND target_id IN ?", models.PriceKindBrand, append(brands, 0)).
+			Or("kind=? AND target_id IN ?", models.PriceKindCategory, append(categories, 0))).
+		Order("min_quantity").Find(&res.rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// rank returns how specific the row is for the product, lower is more specific, false when it does not match.
+func (p *priceList) rank(row *models.PriceListItems, product *models.Products) (int, bool) {
+	switch row.Kind {
+	case models.PriceKindProduct:
+		return 0, row.TargetID == product.ID
+	case models.PriceKindCategory:
+		if product.ParentID != nil {
+			for depth, id := range p.categories[*product.ParentID] {
+				if id == row.TargetID {
+					return 1 + depth, true
+				}
+			}
+		}
+	case models.PriceKindBrand:
+		return 1 + maxCategoryDepth + 1, product.BrandID != nil && *product.BrandID == row.TargetID
+	case models.PriceKindAll:
+		return 1 + maxCategoryDepth + 2, true
+	}
+	return 0, false
+}
+
+// price returns the price of the product when amount pieces are ordered. The most specific row wins and
+// among rows of one target the one with the largest MinQuantity not above amount.
+func (p *priceList) price(product *models.Products, amount int) float64 {
+	if p == nil {
+		return product.Price
+	}
+	var best *models.PriceListItems
+	bestRank := 0
+	for i := range p.rows {
+		row := &p.rows[i]
+		if row.MinQuantity > amount {
+			continue
+		}
+		rank, ok := p.rank(row, product)
+		if ok && (best == nil || rank < bestRank || rank == bestRank && row.MinQuantity > best.MinQuantity) {
+			best, bestRank = row, rank
+		}
+	}
+	switch {
+	case best == nil:
+		return product.Price
+	case best.Price != nil:
+		return *best.Price
+	case best.Percent != nil:
+		return roundMoney(product.Price * (100 - *best.Percent) / 100)
+	}
+	return product.Price
+}
+
+// apply sets prices of the price list to products, Price is the price of one piece, BasePrice keeps the
+// retail price and PriceBreaks has prices for larger quantities.
+func (p *priceList) apply(products []models.Products) {
+	if p == nil {
+		return
+	}
+	for i := range products {
+		product := &products[i]
+		quantities := map[int]bool{}
+		for j := range p.rows {
+			if _, ok := p.rank(&p.rows[j], product); ok && p.rows[j].MinQuantity > 1 {
+				quantities[p.rows[j].MinQuantity] = true
+			}
+		}
+		price := p.price(product, 1)
+		var breaks []models.PriceBreak
+		for quantity := range quantities {
+			breaks = append(breaks, models.PriceBreak{MinQuantity: quantity, Price: p.price(product, quantity)})
+		}
+		sort.Slice(breaks, func(a, b int) bool { return breaks[a].MinQuantity < breaks[b].MinQuantity })
+		if price == product.Price && len(breaks) == 0 {
+			continue
+		}
+		base := product.Price
+		product.BasePrice = &base
+		product.Price = price
+		product.PriceBreaks = breaks
+	}
+}
+
+// setCustomerPrices sets prices of the customer group to products when the request has a customer token.
+func (h *Handler) setCustomerPrices(c *gin.Context, products []models.Products) error {
+	prices, err := loadPriceList(h.db, h.optionalCustomerID(c), products)
+	if err != nil {
+		return err
+	}
+	prices.apply(products)
+	return nil
+}
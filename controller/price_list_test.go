@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/Asliddin3/energy-maximum/models"
+)
+
+func intPtr(v int) *int { return &v }
+
+func floatPtr(v float64) *float64 { return &v }
+
+func TestPriceListRank(t *testing.T) {
+	prices := &priceList{categories: map[int][]int{10: {10, 5, 1}}}
+	product := &models.Products{ID: 7, Price: 100, BrandID: intPtr(3), ParentID: intPtr(10)}
+	tests := []struct {
+		name string
+		row  models.PriceListItems
+		rank int
+		ok   bool
+	}{
+		{"product", models.PriceListItems{Kind: models.PriceKindProduct, TargetID: 7}, 0, true},
+		{"other product", models.PriceListItems{Kind: models.PriceKindProduct, TargetID: 8}, 0, false},
+		{"own category", models.PriceListItems{Kind: models.PriceKindCategory, TargetID: 10}, 1, true},
+		{"parent category", models.PriceListItems{Kind: models.PriceKindCategory, TargetID: 5}, 2, true},
+		{"root category", models.PriceListItems{Kind: models.PriceKindCategory, TargetID: 1}, 3, true},
+		{"other category", models.PriceListItems{Kind: models.PriceKindCategory, TargetID: 4}, 0, false},
+		{"brand", models.PriceListItems{Kind: models.PriceKindBrand, TargetID: 3}, maxCategoryDepth + 2, true},
+		{"other brand", models.PriceListItems{Kind: models.PriceKindBrand, TargetID: 2}, maxCategoryDepth + 2, false},
+		{"all", models.PriceListItems{Kind: models.PriceKindAll}, maxCategoryDepth + 3, true},
+		{"unknown kind", models.PriceListItems{Kind: "unknown", TargetID: 7}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rank, ok := prices.rank(&tt.row, product)
+			if ok != tt.ok || ok && rank != tt.rank {
+				t.Errorf("rank() = %d, %v, want %d, %v", rank, ok, tt.rank, tt.ok)
+			}
+		})
+	}
+
+	t.Run("product without category and brand", func(t *testing.T) {
+		bare := &models.Products{ID: 9, Price: 100}
+		for _, row := range []models.PriceListItems{
+			{Kind: models.PriceKindCategory, TargetID: 10},
+			{Kind: models.PriceKindBrand, TargetID: 3},
+		} {
+			if _, ok := prices.rank(&row, bare); ok {
+				t.Errorf("rank() of %s row matches product without %s", row.Kind, row.Kind)
+			}
+		}
+	})
+}
+
+func TestPriceListPrice(t *testing.T) {
+	product := &models.Products{ID: 7, Price: 200, BrandID: intPtr(3), ParentID: intPtr(10)}
+	categories := map[int][]int{10: {10, 5}}
+	tests := []struct {
+		name   string
+		rows   []models.PriceListItems
+		amount int
+		want   float64
+	}{
+		{"no rows", nil, 1, 200},
+		{"fixed price for all", []models.PriceListItems{
+			{Kind: models.PriceKindAll, MinQuantity: 1, Price: floatPtr(150)},
+		}, 1, 150},
+		{"percent is rounded", []models.PriceListItems{
+			{Kind: models.PriceKindAll, MinQuantity: 1, Percent: floatPtr(33.333)},
+		}, 1, 133.33},
+		{"product wins over category", []models.PriceListItems{
+			{Kind: models.PriceKindCategory, TargetID: 10, MinQuantity: 1, Price: floatPtr(120)},
+			{Kind: models.PriceKindProduct, TargetID: 7, MinQuantity: 1, Price: floatPtr(180)},
+		}, 1, 180},
+		{"nearer category wins over parent", []models.PriceListItems{
+			{Kind: models.PriceKindCategory, TargetID: 5, MinQuantity: 1, Price: floatPtr(100)},
+			{Kind: models.PriceKindCategory, TargetID: 10, MinQuantity: 1, Price: floatPtr(170)},
+		}, 1, 170},
+		{"category wins over brand", []models.PriceListItems{
+			{Kind: models.PriceKindBrand, TargetID: 3, MinQuantity: 1, Price: floatPtr(100)},
+			{Kind: models.PriceKindCategory, TargetID: 5, MinQuantity: 1, Price: floatPtr(160)},
+		}, 1, 160},
+		{"brand wins over all", []models.PriceListItems{
+			{Kind: models.PriceKindAll, MinQuantity: 1, Price: floatPtr(100)},
+			{Kind: models.PriceKindBrand, TargetID: 3, MinQuantity: 1, Price: floatPtr(190)},
+		}, 1, 190},
+		{"quantity break below amount", []models.PriceListItems{
+			{Kind: models.PriceKindProduct, TargetID: 7, MinQuantity: 1, Price: floatPtr(190)},
+			{Kind: models.PriceKindProduct, TargetID: 7, MinQuantity: 10, Price: floatPtr(170)},
+		}, 9, 190},
+		{"quantity break at amount", []models.PriceListItems{
+			{Kind: models.PriceKindProduct, TargetID: 7, MinQuantity: 1, Price: floatPtr(190)},
+			{Kind: models.PriceKindProduct, TargetID: 7, MinQuantity: 10, Price: floatPtr(170)},
+			{Kind: models.PriceKindProduct, TargetID: 7, MinQuantity: 50, Price: floatPtr(150)},
+		}, 10, 170},
+		{"product break applies only from its quantity", []models.PriceListItems{
+			{Kind: models.PriceKindAll, MinQuantity: 1, Percent: floatPtr(10)},
+			{Kind: models.PriceKindProduct, TargetID: 7, MinQuantity: 5, Price: floatPtr(150)},
+		}, 4, 180},
+		{"other product keeps base price", []models.PriceListItems{
+			{Kind: models.PriceKindProduct, TargetID: 8, MinQuantity: 1, Price: floatPtr(100)},
+		}, 1, 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prices := &priceList{rows: tt.rows, categories: categories}
+			if got := prices.price(product, tt.amount); got != tt.want {
+				t.Errorf("price() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("nil price list", func(t *testing.T) {
+		var prices *priceList
+		if got := prices.price(product, 100); got != 200 {
+			t.Errorf("price() = %v, want base price 200", got)
+		}
+	})
+}
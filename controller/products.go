@@ -21,7 +21,7 @@ type ProductController struct {
 
 func (h *Handler) NewProductController(api *gin.RouterGroup) {
 	product := &ProductController{h}
-	prod := api.Group("product", h.DeserializeAdmin())
+	prod := h.adminGroup(api, "product")
 	{
 		prod.POST("", product.CreateProduct)
 		prod.PUT("/:id", product.UpdateProduct)
@@ -38,9 +38,9 @@ func (h *Handler) NewProductController(api *gin.RouterGroup) {
 		// prod.POST("/recommend/", product.AddProductRecommend)
 		// prod.DELETE("/recommend/:id", product.DeleteProductRecommend)
 	}
-	api.GET("/product", product.GetProducts)
-	api.POST("/product/list", product.GetProductsByIds)
-	api.GET("/product/:id", product.GetByID)
+	api.GET("/product", h.OptionalCustomer(), product.GetProducts)
+	api.POST("/product/list", h.OptionalCustomer(), product.GetProductsByIds)
+	api.GET("/product/:id", h.OptionalCustomer(), product.GetByID)
 
 	// customProd := api.Group("product", h.DeserializeCustomer())
 	{
@@ -128,7 +128,7 @@ func (h *ProductController) CreateProduct(c *gin.Context) {
 	if body.BrandID != 0 {
 		product.BrandID = &body.BrandID
 	}
-	err = h.db.Clauses(clause.Returning{}).Create(&product).Error
+	err = h.db.WithContext(c).Clauses(clause.Returning{}).Create(&product).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -159,7 +159,7 @@ func (h *ProductController) CreateProductParameter(c *gin.Context) {
 		newResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
-	tr := h.db.Begin()
+	tr := h.db.WithContext(c).Begin()
 	// err = tr.Delete(&models.ProductParameters{}, "product_id=?", id).Error
 	// if err != nil {
 	// 	tr.Rollback()
@@ -220,7 +220,7 @@ func (h *ProductController) DeleteProductParameter(c *gin.Context) {
 		newResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
-	err = h.db.Delete(&models.ProductParameters{}, "product_id=? AND parameter_id IN ?", id, body.Parameters).Error
+	err = h.db.WithContext(c).Delete(&models.ProductParameters{}, "product_id=? AND parameter_id IN ?", id, body.Parameters).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		h.log.Error("failed to delete product params")
@@ -247,7 +247,7 @@ func (h *ProductController) UpdateProductParameter(c *gin.Context) {
 		newResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
-	err = h.db.Model(&models.ProductParameters{}).Where("product_id=? AND parameter_id=?", body.ProductID, body.ParameterID).Updates(map[string]interface{}{
+	err = h.db.WithContext(c).Model(&models.ProductParameters{}).Where("product_id=? AND parameter_id=?", body.ProductID, body.ParameterID).Updates(map[string]interface{}{
 		"val_ru": body.ValRu,
 		"val_en": body.ValEn,
 		"val_uz": body.ValUz,
@@ -360,7 +360,7 @@ func (h *ProductController) UpdateProduct(c *gin.Context) {
 	}
 	columns["updated_at"] = timeNow()
 	columns["updated_id"] = admin.Id
-	err = h.db.Clauses(clause.Returning{}).Model(&product).
+	err = h.db.WithContext(c).Clauses(clause.Returning{}).Model(&product).
 		Where("id=?", productId).Updates(columns).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
@@ -425,7 +425,7 @@ func (h *ProductController) AddProductMedia(c *gin.Context) {
 		Position:  &body.Position,
 		Media:     body.Media,
 	}
-	err = h.db.Create(&media).Error
+	err = h.db.WithContext(c).Create(&media).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		h.log.Error("failed to create media", err.Error())
@@ -455,12 +455,12 @@ func (h *ProductController) DeleteProductMedia(c *gin.Context) {
 		return
 	}
 	media := models.ProductMedia{ID: id}
-	err = h.db.First(&media).Error
+	err = h.db.WithContext(c).First(&media).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
-	err = h.db.Delete(&media).Error
+	err = h.db.WithContext(c).Delete(&media).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		h.log.Error("failed to create media", err.Error())
@@ -475,8 +475,9 @@ func (h *ProductController) DeleteProductMedia(c *gin.Context) {
 }
 
 // @Summary		  get product
-// @Description	   this api is get product
+// @Description	   this api is get product, with customer token prices are taken from the price list of the customer group, base_price keeps the retail price
 // @Tags			Product
+// @Security		BearerAuth
 // @Accept			json
 // @Produce			json
 // @Param 			data   body       models.ProductsIds  false "product brand ids"
@@ -502,12 +503,25 @@ func (h *ProductController) GetProductsByIds(c *gin.Context) {
 		h.log.Error("failed to find products", err.Error())
 		return
 	}
+	err = h.setAvailability(products)
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get products stock")
+		h.log.Error("failed to get products stock", err.Error())
+		return
+	}
+	err = h.setCustomerPrices(c, products)
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get products prices")
+		h.log.Error("failed to get products prices", err.Error())
+		return
+	}
 	c.JSON(http.StatusOK, products)
 }
 
 // @Summary		  get product
-// @Description	   this api is get product
+// @Description	   this api is get product, with customer token prices are taken from the price list of the customer group, base_price keeps the retail price
 // @Tags			Product
+// @Security		BearerAuth
 // @Accept			json
 // @Produce			json
 // @Param           data    query    	models.ProductsFilter   true   "product filter"
@@ -575,6 +589,18 @@ func (h *ProductController) GetProducts(c *gin.Context) {
 		h.log.Error("failed to find products", err.Error())
 		return
 	}
+	err = h.setAvailability(products)
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get products stock")
+		h.log.Error("failed to get products stock", err.Error())
+		return
+	}
+	err = h.setCustomerPrices(c, products)
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get products prices")
+		h.log.Error("failed to get products prices", err.Error())
+		return
+	}
 	c.JSON(http.StatusOK, models.ProductsList{
 		Products: products,
 		Page:     body.Page,
@@ -668,7 +694,7 @@ func (h *ProductController) GetAllProducts(c *gin.Context) {
 }
 
 // @Summary		  Update product
-// @Description	   this api is Update product
+// @Description	   this api is Update product, with customer token prices are taken from the price list of the customer group, base_price keeps the retail price
 // @Tags			Product
 // @Security		BearerAuth
 // @Accept			json
@@ -708,6 +734,20 @@ func (h *ProductController) GetByID(c *gin.Context) {
 		h.log.Error("failed to get product media", err.Error())
 		return
 	}
+	stock := []models.Products{product}
+	err = h.setAvailability(stock)
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get product stock")
+		h.log.Error("failed to get product stock", err.Error())
+		return
+	}
+	err = h.setCustomerPrices(c, stock)
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get product price")
+		h.log.Error("failed to get product price", err.Error())
+		return
+	}
+	product = stock[0]
 	c.JSON(http.StatusOK, models.ProductResponse{
 		Products:   &product,
 		Media:      media,
@@ -733,7 +773,7 @@ func (h *ProductController) DeleteProduct(c *gin.Context) {
 		newResponse(c, http.StatusBadRequest, "empty id")
 		return
 	}
-	err := h.db.Model(&models.Products{}).Where("id=?", inputId).Updates(map[string]interface{}{
+	err := h.db.WithContext(c).Model(&models.Products{}).Where("id=?", inputId).Updates(map[string]interface{}{
 		"deleted_at": time.Now(),
 		"deleted_id": admin.Id,
 	}).Error
@@ -0,0 +1,792 @@
+package controller
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Asliddin3/energy-maximum/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	promoCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	promoCodeLength   = 8
+	maxPromoCodes     = 1000
+	// promoCodeAttempts bounds regeneration of codes that collide with existing ones
+	promoCodeAttempts = 5
+)
+
+type PromotionController struct {
+	*Handler
+}
+
+func (h *Handler) NewPromotionController(api *gin.RouterGroup) {
+	promotion := &PromotionController{h}
+	promotions := h.adminGroup(api, "promotion")
+	{
+		promotions.GET("", promotion.GetPromotions)
+		promotions.GET("/:id", promotion.GetPromotion)
+		promotions.POST("", promotion.CreatePromotion)
+		promotions.PUT("/:id", promotion.UpdatePromotion)
+		promotions.DELETE("/:id", promotion.DeletePromotion)
+		promotions.POST("/:id/code", promotion.CreatePromoCodes)
+		promotions.PUT("/code/:id", promotion.UpdatePromoCode)
+		promotions.GET("/:id/report", promotion.GetPromotionReport)
+	}
+}
+
+// promoDiscount is the discount the code gives to order lines.
+type promoDiscount struct {
+	promotion models.Promotions
+	code      models.PromoCodes
+	discount  float64
+}
+
+func normalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// findPromotion checks the code and returns its discount of the lines, problems of the code are
+// returned as orderItemError. With lock the code and its promotion stay locked till the end of tx, so
+// usage limits hold for parallel orders. Limit per customer is not checked when customerID is 0.
+func findPromotion(tx *gorm.DB, code string, customerID int, lines []models.OrderItems, lock bool) (*promoDiscount, error) {
+	db := tx
+	if lock {
+		db = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Session(&gorm.Session{})
+	}
+	var res promoDiscount
+	err := db.Where("code=?", normalizePromoCode(code)).Limit(1).Find(&res.code).Error
+	if err != nil {
+		return nil, err
+	}
+	if res.code.ID == 0 {
+		return nil, &orderItemError{"promo code not found"}
+	}
+	err = db.Where("id=? AND deleted_at IS NULL", res.code.PromotionID).Limit(1).Find(&res.promotion).Error
+	if err != nil {
+		return nil, err
+	}
+	if res.promotion.ID == 0 || !isTrue(res.code.IsActive) || !isTrue(res.promotion.IsActive) {
+		return nil, &orderItemError{"promo code is not active"}
+	}
+	now := time.Now()
+	if res.promotion.StartsAt != nil && now.Before(*res.promotion.StartsAt) {
+		return nil, &orderItemError{"promotion has not started yet"}
+	}
+	if res.promotion.EndsAt != nil && now.After(*res.promotion.EndsAt) {
+		return nil, &orderItemError{"promotion has ended"}
+	}
+	type usageLimit struct {
+		limit   *int
+		where   string
+		args    []interface{}
+		message string
+	}
+	limits := []usageLimit{
+		{res.code.UsageLimit, "promotion_redemptions.promo_code_id=?", []interface{}{res.code.ID}, "promo code is used up"},
+		{res.promotion.UsageLimit, "promotion_redemptions.promotion_id=?", []interface{}{res.promotion.ID}, "promotion is used up"},
+	}
+	if customerID != 0 {
+		limits = append(limits, usageLimit{res.promotion.PerCustomerLimit,
+			"promotion_redemptions.promotion_id=? AND promotion_redemptions.customer_id=?",
+			[]interface{}{res.promotion.ID, customerID}, "promo code is already used"})
+	}
+	for _, limit := range limits {
+		if limit.limit == nil {
+			continue
+		}
+		var count int64
+		err = tx.Session(&gorm.Session{NewDB: true}).Model(&models.PromotionRedemptions{}).
+			Joins("JOIN orders ON orders.id=promotion_redemptions.order_id").
+			Where("orders.status<>?", models.OrderStatusCancelled).
+			Where(limit.where, limit.args...).Count(&count).Error
+		if err != nil {
+			return nil, err
+		}
+		if count >= int64(*limit.limit) {
+			return nil, &orderItemError{limit.message}
+		}
+	}
+	res.discount, err = promotionDiscount(tx, &res.promotion, lines)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// promotionDiscount returns discount of the promotion for the lines. Lines must reach minimal amount of
+// the promotion and one of them must match its targets.
+func promotionDiscount(tx *gorm.DB, promotion *models.Promotions, lines []models.OrderItems) (float64, error) {
+	var subtotal float64
+	for _, line := range lines {
+		subtotal += line.Total
+	}
+	if subtotal < promotion.MinOrderAmount {
+		return 0, &orderItemError{fmt.Sprintf("order amount must be at least %.2f for the promo code", promotion.MinOrderAmount)}
+	}
+	matching, err := promotionLines(tx, promotion, lines)
+	if err != nil {
+		return 0, err
+	}
+	var sum float64
+	for _, line := range matching {
+		sum += line.Total
+	}
+	if sum == 0 {
+		return 0, &orderItemError{"promo code does not apply to products of the order"}
+	}
+	discount := promotion.Value
+	if promotion.Type == models.PromotionPercent {
+		discount = sum * promotion.Value / 100
+	}
+	if discount > sum {
+		discount = sum
+	}
+	return roundMoney(discount), nil
+}
+
+// orderPromotionDiscount is the discount of the promotion for changed lines of an order, it is zero
+// when the lines no longer reach the minimum amount.
+func orderPromotionDiscount(tx *gorm.DB, promotionID int, lines []models.OrderItems) (float64, error) {
+	var promotion models.Promotions
+	err := tx.Session(&gorm.Session{NewDB: true}).Where("id=?", promotionID).Take(&promotion).Error
+	if err != nil {
+		return 0, err
+	}
+	discount, err := promotionDiscount(tx, &promotion, lines)
+	var itemErr *orderItemError
+	if errors.As(err, &itemErr) {
+		return 0, nil
+	}
+	return discount, err
+}
+
+// checkPromotionCustomer checks limit per customer of the promotion when the order moves to another customer.
+func checkPromotionCustomer(tx *gorm.DB, promotionID, customerID, orderID int) error {
+	var promotion models.Promotions
+	err := tx.Session(&gorm.Session{NewDB: true}).Select("per_customer_limit").Where("id=?", promotionID).Take(&promotion).Error
+	if err != nil || promotion.PerCustomerLimit == nil {
+		return err
+	}
+	var count int64
+	err = tx.Session(&gorm.Session{NewDB: true}).Model(&models.PromotionRedemptions{}).
+		Joins("JOIN orders ON orders.id=promotion_redemptions.order_id").
+		Where("orders.status<>? AND promotion_redemptions.promotion_id=? AND promotion_redemptions.customer_id=?",
+			models.OrderStatusCancelled, promotionID, customerID).
+		Where("promotion_redemptions.order_id<>?", orderID).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count >= int64(*promotion.PerCustomerLimit) {
+		return &orderItemError{"promo code is already used by the customer"}
+	}
+	return nil
+}
+
+// promotionLines returns lines matching targets of the promotion.
+func promotionLines(tx *gorm.DB, promotion *models.Promotions, lines []models.OrderItems) ([]models.OrderItems, error) {
+	targets := promotion.Targets
+	if targets == nil {
+		err := tx.Session(&gorm.Session{NewDB: true}).Where("promotion_id=?", promotion.ID).Find(&targets).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(targets) == 0 {
+		return lines, nil
+	}
+	products, brands, categories := map[int]bool{}, map[int]bool{}, []int{}
+	for _, target := range targets {
+		switch target.Kind {
+		case models.PromotionTargetProduct:
+			products[target.TargetID] = true
+		case models.PromotionTargetBrand:
+			brands[target.TargetID] = true
+		case models.PromotionTargetCategory:
+			categories = append(categories, target.TargetID)
+		}
+	}
+	ids := make([]int, 0, len(lines))
+	for _, line := range lines {
+		ids = append(ids, line.ItemId)
+	}
+	var found []models.Products
+	err := tx.Session(&gorm.Session{NewDB: true}).Select("id, brand_id, parent_id").Where("id IN ?", ids).Find(&found).Error
+	if err != nil {
+		return nil, err
+	}
+	inCategory := map[int]bool{}
+	if len(categories) != 0 {
+		var tree []int
+		err = tx.Session(&gorm.Session{NewDB: true}).Raw(`WITH RECURSIVE tree AS (
+	SELECT id FROM category WHERE id IN ?
+	UNION SELECT category.id FROM category JOIN tree ON category.category_id=tree.id
+) SELECT id FROM tree`, categories).Scan(&tree).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range tree {
+			inCategory[id] = true
+		}
+	}
+	matches := map[int]bool{}
+	for _, product := range found {
+		matches[product.ID] = products[product.ID] ||
+			(product.BrandID != nil && brands[*product.BrandID]) ||
+			(product.ParentID != nil && inCategory[*product.ParentID])
+	}
+	matching := make([]models.OrderItems, 0, len(lines))
+	for _, line := range lines {
+		if matches[line.ItemId] {
+			matching = append(matching, line)
+		}
+	}
+	return matching, nil
+}
+
+func isTrue(value *bool) bool {
+	return value == nil || *value
+}
+
+// applyPromotion takes discount of the code from the order and returns redemption to be written once
+// the order is created.
+func applyPromotion(tx *gorm.DB, code string, order *models.Orders, lines []models.OrderItems) (*models.PromotionRedemptions, error) {
+	promo, err := findPromotion(tx, code, order.CustomerID, lines, true)
+	if err != nil {
+		return nil, err
+	}
+	order.PromotionID = &promo.promotion.ID
+	order.PromoCodeID = &promo.code.ID
+	order.Discount = promo.discount
+	order.Total = roundMoney(order.Total - promo.discount)
+	return &models.PromotionRedemptions{
+		PromotionID: promo.promotion.ID,
+		PromoCodeID: &promo.code.ID,
+		CustomerID:  order.CustomerID,
+		Discount:    promo.discount,
+		CreatedAt:   timeNow(),
+	}, nil
+}
+
+func validatePromotion(body *models.PromotionRequest) error {
+	switch body.Type {
+	case models.PromotionPercent:
+		if body.Value <= 0 || body.Value > 100 {
+			return errors.New("percent value must be between 0 and 100")
+		}
+	case models.PromotionFixed:
+		if body.Value <= 0 {
+			return errors.New("fixed value must be positive")
+		}
+	default:
+		return errors.New("type must be percent or fixed")
+	}
+	if body.MinOrderAmount < 0 {
+		return errors.New("min_order_amount must not be negative")
+	}
+	if body.StartsAt != nil && body.EndsAt != nil && !body.EndsAt.After(*body.StartsAt) {
+		return errors.New("ends_at must be after starts_at")
+	}
+	for _, limit := range []*int{body.UsageLimit, body.PerCustomerLimit} {
+		if limit != nil && *limit <= 0 {
+			return errors.New("usage limits must be positive")
+		}
+	}
+	for _, target := range body.Targets {
+		switch target.Kind {
+		case models.PromotionTargetCategory, models.PromotionTargetBrand, models.PromotionTargetProduct:
+		default:
+			return errors.New("target kind must be category, brand or product")
+		}
+	}
+	return nil
+}
+
+func promotionTargets(promotionID int, targets []models.PromotionTargetRequest) []models.PromotionTargets {
+	res := make([]models.PromotionTargets, 0, len(targets))
+	seen := map[models.PromotionTargetRequest]bool{}
+	for _, target := range targets {
+		if seen[target] {
+			continue
+		}
+		seen[target] = true
+		res = append(res, models.PromotionTargets{PromotionID: promotionID, Kind: target.Kind, TargetID: target.TargetID})
+	}
+	return res
+}
+
+// @Summary		  Get promotions
+// @Description	   this api returns promotions with their targets
+// @Tags			Promotion
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param			filter 	query		models.PromotionFilter	false	"filter"
+// @Success			200		{object}	models.PromotionListResponse
+// @Failure			400		{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/promotion [GET]
+func (h *PromotionController) GetPromotions(c *gin.Context) {
+	var body models.PromotionFilter
+	err := c.ShouldBindQuery(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.Page == 0 {
+		body.Page = 1
+	}
+	if body.PageSize == 0 {
+		body.PageSize = 10
+	}
+	db := h.db.Model(&models.Promotions{}).Where("deleted_at IS NULL")
+	if body.Name != "" {
+		db = db.Where("LOWER(name) LIKE LOWER(?)", fmt.Sprintf("%%%s%%", body.Name))
+	}
+	if body.IsActive != nil {
+		db = db.Where("is_active=?", *body.IsActive)
+	}
+	var count int64
+	err = db.Count(&count).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get promotions")
+		h.log.Error("failed to count promotions", err.Error())
+		return
+	}
+	promotions := make([]models.Promotions, 0)
+	err = db.Preload("Targets").Order("id DESC").Limit(body.PageSize).Offset((body.Page - 1) * body.PageSize).
+		Find(&promotions).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get promotions")
+		h.log.Error("failed to get promotions", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, models.PromotionListResponse{
+		Page:       body.Page,
+		PageSize:   body.PageSize,
+		Count:      int(count),
+		Promotions: promotions,
+	})
+}
+
+// @Summary		  Get promotion
+// @Description	   this api returns promotion with targets and codes
+// @Tags			Promotion
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "id"
+// @Success			200		{object}	models.Promotions
+// @Failure			404		{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/promotion/{id} [GET]
+func (h *PromotionController) GetPromotion(c *gin.Context) {
+	var promotion models.Promotions
+	err := h.db.Preload("Targets").Preload("Codes", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		First(&promotion, "id=? AND deleted_at IS NULL", c.Param("id")).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			newResponse(c, http.StatusNotFound, "promotion not found")
+			return
+		}
+		newResponse(c, http.StatusInternalServerError, "failed to get promotion")
+		h.log.Error("failed to get promotion", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, promotion)
+}
+
+// @Summary		  Create promotion
+// @Description	   this api creates promotion. Type is percent or fixed, targets of kind category, brand or product limit discounted products, promotion without targets discounts every product. Codes are added separately
+// @Tags			Promotion
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param			data 	body		models.PromotionRequest	true	"data body"
+// @Success			200		{object}	models.Promotions
+// @Failure			400		{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/promotion [POST]
+func (h *PromotionController) CreatePromotion(c *gin.Context) {
+	admin := h.GetAdmin(c)
+	var body models.PromotionRequest
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	err = validatePromotion(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	promotion := models.Promotions{
+		Name:             body.Name,
+		Type:             body.Type,
+		Value:            body.Value,
+		MinOrderAmount:   body.MinOrderAmount,
+		StartsAt:         body.StartsAt,
+		EndsAt:           body.EndsAt,
+		UsageLimit:       body.UsageLimit,
+		PerCustomerLimit: body.PerCustomerLimit,
+		IsActive:         body.IsActive,
+		CreatedID:        &admin.Id,
+		CreatedAt:        timeNow(),
+	}
+	tx := h.db.WithContext(c).Begin()
+	err = tx.Omit("Targets", "Codes").Create(&promotion).Error
+	if err == nil && len(body.Targets) != 0 {
+		promotion.Targets = promotionTargets(promotion.ID, body.Targets)
+		err = tx.Create(&promotion.Targets).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		newResponse(c, http.StatusInternalServerError, "failed to create promotion")
+		h.log.Error("failed to create promotion", err.Error())
+		return
+	}
+	tx.Commit()
+	c.JSON(http.StatusOK, promotion)
+}
+
+// @Summary		  Update promotion
+// @Description	   this api updates promotion and replaces its targets, placed orders keep their discount
+// @Tags			Promotion
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "id"
+// @Param			data 	body		models.PromotionRequest	true	"data body"
+// @Success			200		{object}	models.Promotions
+// @Failure			400,404	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/promotion/{id} [PUT]
+func (h *PromotionController) UpdatePromotion(c *gin.Context) {
+	admin := h.GetAdmin(c)
+	var body models.PromotionRequest
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	err = validatePromotion(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	tx := h.db.WithContext(c).Begin()
+	var promotion models.Promotions
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&promotion, "id=? AND deleted_at IS NULL", c.Param("id")).Error
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			newResponse(c, http.StatusNotFound, "promotion not found")
+			return
+		}
+		newResponse(c, http.StatusInternalServerError, "failed to get promotion")
+		h.log.Error("failed to get promotion", err.Error())
+		return
+	}
+	promotion.Name = body.Name
+	promotion.Type = body.Type
+	promotion.Value = body.Value
+	promotion.MinOrderAmount = body.MinOrderAmount
+	promotion.StartsAt = body.StartsAt
+	promotion.EndsAt = body.EndsAt
+	promotion.UsageLimit = body.UsageLimit
+	promotion.PerCustomerLimit = body.PerCustomerLimit
+	if body.IsActive != nil {
+		promotion.IsActive = body.IsActive
+	}
+	promotion.UpdatedID = &admin.Id
+	promotion.UpdatedAt = timeNow()
+	err = tx.Omit("Targets", "Codes").Save(&promotion).Error
+	if err == nil {
+		err = tx.Delete(&models.PromotionTargets{}, "promotion_id=?", promotion.ID).Error
+	}
+	promotion.Targets = promotionTargets(promotion.ID, body.Targets)
+	if err == nil && len(promotion.Targets) != 0 {
+		err = tx.Create(&promotion.Targets).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		newResponse(c, http.StatusInternalServerError, "failed to update promotion")
+		h.log.Error("failed to update promotion", err.Error())
+		return
+	}
+	tx.Commit()
+	c.JSON(http.StatusOK, promotion)
+}
+
+// @Summary		  Delete promotion
+// @Description	   this api deletes promotion, its codes stop working and placed orders keep their discount
+// @Tags			Promotion
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "id"
+// @Success			200		{object}	response
+// @Failure			404		{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/promotion/{id} [DELETE]
+func (h *PromotionController) DeletePromotion(c *gin.Context) {
+	admin := h.GetAdmin(c)
+	result := h.db.WithContext(c).Model(&models.Promotions{}).Where("id=? AND deleted_at IS NULL", c.Param("id")).
+		Updates(map[string]interface{}{
+			"deleted_at": timeNow(),
+			"deleted_id": admin.Id,
+		})
+	if result.Error != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to delete promotion")
+		h.log.Error("failed to delete promotion", result.Error.Error())
+		return
+	}
+	if result.RowsAffected == 0 {
+		newResponse(c, http.StatusNotFound, "promotion not found")
+		return
+	}
+	c.JSON(http.StatusOK, response{"success"})
+}
+
+func randomPromoCode(prefix string) (string, error) {
+	var b strings.Builder
+	b.WriteString(prefix)
+	for i := 0; i < promoCodeLength; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(promoCodeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(promoCodeAlphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+// generatePromoCodes returns count single use codes which are not taken by other codes yet.
+func generatePromoCodes(db *gorm.DB, promotionID int, prefix string, count int) ([]models.PromoCodes, error) {
+	single := 1
+	codes := make([]models.PromoCodes, 0, count)
+	seen := make(map[string]bool, count)
+	for attempt := 0; len(codes) < count; attempt++ {
+		if attempt == promoCodeAttempts {
+			return nil, errors.New("failed to generate unique promo codes")
+		}
+		batch := make([]string, 0, count-len(codes))
+		for len(batch) < count-len(codes) {
+			code, err := randomPromoCode(prefix)
+			if err != nil {
+				return nil, err
+			}
+			if !seen[code] {
+				seen[code] = true
+				batch = append(batch, code)
+			}
+		}
+		var taken []string
+		err := db.Model(&models.PromoCodes{}).Where("code IN ?", batch).Pluck("code", &taken).Error
+		if err != nil {
+			return nil, err
+		}
+		takenCodes := make(map[string]bool, len(taken))
+		for _, code := range taken {
+			takenCodes[code] = true
+		}
+		for _, code := range batch {
+			if !takenCodes[code] {
+				codes = append(codes, models.PromoCodes{PromotionID: promotionID, Code: code, UsageLimit: &single, CreatedAt: timeNow()})
+			}
+		}
+	}
+	return codes, nil
+}
+
+// @Summary		  Create promo codes
+// @Description	   this api adds code to the promotion, usage_limit 1 makes it single use and empty usage_limit is unlimited. When code is empty count single use codes are generated with the prefix
+// @Tags			Promotion
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "promotion id"
+// @Param			data 	body		models.PromoCodeRequest	true	"data body"
+// @Success			200		{object}	[]models.PromoCodes
+// @Failure			400,404,409	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/promotion/{id}/code [POST]
+func (h *PromotionController) CreatePromoCodes(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, "Invalid id")
+		return
+	}
+	var body models.PromoCodeRequest
+	err = c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.UsageLimit != nil && *body.UsageLimit <= 0 {
+		newResponse(c, http.StatusBadRequest, "usage_limit must be positive")
+		return
+	}
+	var count int64
+	err = h.db.Model(&models.Promotions{}).Where("id=? AND deleted_at IS NULL", id).Count(&count).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get promotion")
+		h.log.Error("failed to get promotion", err.Error())
+		return
+	}
+	if count == 0 {
+		newResponse(c, http.StatusNotFound, "promotion not found")
+		return
+	}
+	code := normalizePromoCode(body.Code)
+	if code == "" && (body.Count <= 0 || body.Count > maxPromoCodes) {
+		newResponse(c, http.StatusBadRequest, fmt.Sprintf("code or count from 1 to %d is required", maxPromoCodes))
+		return
+	}
+	var codes []models.PromoCodes
+	// generated codes are checked before insert, the insert is retried when other request takes one of them in between
+	for attempt := 1; ; attempt++ {
+		if code != "" {
+			codes = []models.PromoCodes{{PromotionID: id, Code: code, UsageLimit: body.UsageLimit, CreatedAt: timeNow()}}
+		} else {
+			codes, err = generatePromoCodes(h.db.WithContext(c), id, normalizePromoCode(body.Prefix), body.Count)
+			if err != nil {
+				newResponse(c, http.StatusInternalServerError, "failed to generate promo codes")
+				h.log.Error("failed to generate promo codes", err.Error())
+				return
+			}
+		}
+		err = h.db.WithContext(c).Create(&codes).Error
+		if err == nil || !strings.Contains(err.Error(), "duplicate key value violates unique") {
+			break
+		}
+		if code != "" || attempt == promoCodeAttempts {
+			newResponse(c, http.StatusConflict, "promo code already exists")
+			return
+		}
+	}
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to create promo codes")
+		h.log.Error("failed to create promo codes", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, codes)
+}
+
+// @Summary		  Update promo code
+// @Description	   this api changes usage limit of the code or deactivates it
+// @Tags			Promotion
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "promo code id"
+// @Param			data 	body		models.PromoCodeUpdateRequest	true	"data body"
+// @Success			200		{object}	models.PromoCodes
+// @Failure			400,404	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/promotion/code/{id} [PUT]
+func (h *PromotionController) UpdatePromoCode(c *gin.Context) {
+	var body models.PromoCodeUpdateRequest
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.UsageLimit != nil && *body.UsageLimit <= 0 {
+		newResponse(c, http.StatusBadRequest, "usage_limit must be positive")
+		return
+	}
+	var code models.PromoCodes
+	err = h.db.First(&code, "id=?", c.Param("id")).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			newResponse(c, http.StatusNotFound, "promo code not found")
+			return
+		}
+		newResponse(c, http.StatusInternalServerError, "failed to get promo code")
+		h.log.Error("failed to get promo code", err.Error())
+		return
+	}
+	code.UsageLimit = body.UsageLimit
+	if body.IsActive != nil {
+		code.IsActive = body.IsActive
+	}
+	err = h.db.WithContext(c).Save(&code).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to update promo code")
+		h.log.Error("failed to update promo code", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, code)
+}
+
+// @Summary		  Get promotion report
+// @Description	   this api returns redemptions of the promotion, newest first. Totals skip cancelled orders
+// @Tags			Promotion
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "promotion id"
+// @Param			filter 	query		models.PromotionReportFilter	false	"filter"
+// @Success			200		{object}	models.PromotionReportResponse
+// @Failure			400		{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/promotion/{id}/report [GET]
+func (h *PromotionController) GetPromotionReport(c *gin.Context) {
+	var body models.PromotionReportFilter
+	err := c.ShouldBindQuery(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.Page == 0 {
+		body.Page = 1
+	}
+	if body.PageSize == 0 {
+		body.PageSize = 10
+	}
+	db := h.db.Model(&models.PromotionRedemptions{}).
+		Joins("JOIN orders ON orders.id=promotion_redemptions.order_id").
+		Where("promotion_redemptions.promotion_id=?", c.Param("id"))
+	if body.DateFrom != "" {
+		db = db.Where("promotion_redemptions.created_at>=?", body.DateFrom)
+	}
+	if body.DateTo != "" {
+		db = db.Where("promotion_redemptions.created_at<=?", body.DateTo)
+	}
+	res := models.PromotionReportResponse{
+		Page:     body.Page,
+		PageSize: body.PageSize,
+		Items:    make([]models.PromotionRedemptionRow, 0),
+	}
+	err = db.Session(&gorm.Session{}).Select(`COUNT(*) AS count,
+		COUNT(*) FILTER (WHERE orders.status<>?) AS redemptions,
+		COUNT(DISTINCT promotion_redemptions.customer_id) FILTER (WHERE orders.status<>?) AS customers,
+		COALESCE(SUM(promotion_redemptions.discount) FILTER (WHERE orders.status<>?), 0) AS discount_total,
+		COALESCE(SUM(orders.total) FILTER (WHERE orders.status<>?), 0) AS orders_total`,
+		models.OrderStatusCancelled, models.OrderStatusCancelled, models.OrderStatusCancelled, models.OrderStatusCancelled).
+		Scan(&res).Error
+	if err == nil {
+		err = db.Select(`promotion_redemptions.id, promo_codes.code, orders.id AS order_id, orders.status AS order_status,
+			orders.total AS order_total, orders.customer_id, customer.name AS customer_name, customer.phone AS customer_phone,
+			promotion_redemptions.discount, promotion_redemptions.created_at`).
+			Joins("LEFT JOIN promo_codes ON promo_codes.id=promotion_redemptions.promo_code_id").
+			Joins("LEFT JOIN customer ON customer.id=orders.customer_id").
+			Order("promotion_redemptions.id DESC").Limit(body.PageSize).Offset((body.Page - 1) * body.PageSize).
+			Scan(&res.Items).Error
+	}
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get promotion report")
+		h.log.Error("failed to get promotion report", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
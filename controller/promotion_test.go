@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Asliddin3/energy-maximum/models"
+)
+
+func TestPromotionDiscount(t *testing.T) {
+	lines := []models.OrderItems{{ItemId: 1, Total: 300}, {ItemId: 2, Total: 150.55}}
+	tests := []struct {
+		name      string
+		promotion models.Promotions
+		want      float64
+		wantErr   bool
+	}{
+		{"percent", models.Promotions{Type: models.PromotionPercent, Value: 10}, 45.06, false},
+		{"percent is capped by sum", models.Promotions{Type: models.PromotionPercent, Value: 150}, 450.55, false},
+		{"fixed", models.Promotions{Type: models.PromotionFixed, Value: 50}, 50, false},
+		{"fixed is capped by sum", models.Promotions{Type: models.PromotionFixed, Value: 1000}, 450.55, false},
+		{"minimum amount is reached", models.Promotions{Type: models.PromotionFixed, Value: 50, MinOrderAmount: 450.55}, 50, false},
+		{"minimum amount is not reached", models.Promotions{Type: models.PromotionFixed, Value: 50, MinOrderAmount: 500}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// empty targets apply the promotion to every line without loading anything from the database
+			tt.promotion.Targets = []models.PromotionTargets{}
+			got, err := promotionDiscount(nil, &tt.promotion, lines)
+			if tt.wantErr {
+				var itemErr *orderItemError
+				if !errors.As(err, &itemErr) {
+					t.Fatalf("promotionDiscount() error = %v, want orderItemError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("promotionDiscount() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("promotionDiscount() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -16,7 +16,7 @@ type PublicOfferController struct {
 
 func (h *Handler) NewPublicOfferController(api *gin.RouterGroup) {
 	brand := &PublicOfferController{h}
-	br := api.Group("public-offer", h.DeserializeAdmin())
+	br := h.adminGroup(api, "public-offer")
 	{
 		br.POST("/", brand.CreatePublicOffer)
 		// br.PUT("/", brand.UpdatePublic)
@@ -47,7 +47,7 @@ func (h *PublicOfferController) CreatePublicOffer(c *gin.Context) {
 		return
 	}
 	offer := models.PublicOffer{}
-	err = h.db.First(&offer).Error
+	err = h.db.WithContext(c).First(&offer).Error
 	if err != nil {
 		if !errors.Is(err, gorm.ErrRecordNotFound) {
 			newResponse(c, http.StatusInternalServerError, err.Error())
@@ -65,7 +65,7 @@ func (h *PublicOfferController) CreatePublicOffer(c *gin.Context) {
 		CreatedID:     &admin.Id,
 		CreatedAt:     timeNow(),
 	}
-	err = h.db.Debug().Clauses(clause.Returning{}).Save(&about).Error
+	err = h.db.WithContext(c).Debug().Clauses(clause.Returning{}).Save(&about).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -172,7 +172,7 @@ func (h *PublicOfferController) GetByID(c *gin.Context) {
 // @Failure			500		{object}	response
 // @Router			/api/public-offer/ [DELETE]
 func (h *PublicOfferController) DeletePublic(c *gin.Context) {
-	err := h.db.Delete(&models.PublicOffer{}, "created_id IS NOT NUlL").Error
+	err := h.db.WithContext(c).Delete(&models.PublicOffer{}, "created_id IS NOT NUlL").Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
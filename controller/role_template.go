@@ -0,0 +1,29 @@
+package controller
+
+import "github.com/Asliddin3/energy-maximum/models"
+
+// roleTemplates are built-in permission sets, modules are names of route groups.
+var roleTemplates = []models.RoleTemplate{
+	{Key: "content_editor", Title: "Content editor", Modules: []string{"news", "banner", "pages", "vacancy"}},
+	{Key: "sales", Title: "Sales", Modules: []string{"order", "customer"}},
+}
+
+func findRoleTemplate(key string) (models.RoleTemplate, bool) {
+	for _, template := range roleTemplates {
+		if template.Key == key {
+			return template, true
+		}
+	}
+	return models.RoleTemplate{}, false
+}
+
+// roleTemplateKeys returns keys of module items of the template modules, stale items are skipped.
+func (h *Handler) roleTemplateKeys(template models.RoleTemplate) ([]string, error) {
+	keys := make([]string, 0)
+	err := h.db.Table("module_items mi").
+		Joins("JOIN modules m ON m.id=mi.module_id").
+		Where("m.name IN ? AND (mi.is_stale IS NULL OR mi.is_stale=false)", template.Modules).
+		Order("mi.key").
+		Pluck("mi.key", &keys).Error
+	return keys, err
+}
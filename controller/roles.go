@@ -20,16 +20,18 @@ func (h *Handler) NewRolesController(api *gin.RouterGroup) {
 	role := &RolesController{
 		Handler: h,
 	}
-	custom := api.Group("roles", h.DeserializeAdmin())
+	custom := h.adminGroup(api, "roles")
 	{
 		custom.GET("", role.GetRoles)
+		custom.GET("/templates", role.GetRoleTemplates)
+		custom.POST("/clone/:id", role.CloneRole)
 		custom.PUT("/:id", role.UpdateRole)
 		custom.GET("/:id", role.GetRoleById)
 		custom.POST("", role.CreateRole)
 		custom.DELETE("/:id", role.DeleteRole)
 		// custom.POST("/refresh", customer.RefreshToken)
 	}
-	roleItem := api.Group("role-items", h.DeserializeAdmin())
+	roleItem := h.adminGroup(api, "role-items")
 	{
 		roleItem.GET("", role.GetRoleItems)
 		roleItem.PUT("/list", role.RoleModuleItemsUpdate)
@@ -48,7 +50,7 @@ func (h *Handler) NewRolesController(api *gin.RouterGroup) {
 // @Produce			json
 // @Param			data 	body		models.RolesRequest	true	"data body"
 // @Success			201		{object}	models.Roles
-// @Failure			400,409	{object}	response
+// @Failure			400,403,404,409	{object}	response
 // @Failure			500		{object}	response
 // @Router			/api/roles/{id}  [PUT]
 func (h *RolesController) UpdateRole(c *gin.Context) {
@@ -62,22 +64,56 @@ func (h *RolesController) UpdateRole(c *gin.Context) {
 	}
 	var customer models.Roles
 	id := c.Param("id")
-	err = h.db.First(&customer, "id=?", id).Error
+	err = h.db.WithContext(c).First(&customer, "id=?", id).Error
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			newResponse(c, http.StatusNotFound, "no such role")
+			return
+		}
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		h.log.Error("failed to get customer", err.Error())
 		return
 	}
+	// a role can be changed only by admins who already have all it grants, and not by its own admins,
+	// otherwise they could give themselves a richer parent or turn off two factor
+	if !currentUser.IsSuperuser && customer.ID == currentUser.RoleID {
+		newResponse(c, http.StatusForbidden, "you can not change your own role")
+		return
+	}
+	if !h.canGrantRole(c, customer.ID) {
+		return
+	}
+	message, err := h.checkRoleParent(h.db, customer.ID, body.ParentID)
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to check parent role")
+		h.log.Error("failed to check parent role", err.Error())
+		return
+	}
+	if message != "" {
+		newResponse(c, http.StatusBadRequest, message)
+		return
+	}
+	if body.ParentID != nil && !h.canGrantRole(c, *body.ParentID) {
+		return
+	}
+	dataScope, ok := roleDataScope(body.DataScope)
+	if !ok {
+		newResponse(c, http.StatusBadRequest, "data scope must be one of all, own, team")
+		return
+	}
 	columns := map[string]interface{}{
-		"title":      body.Title,
-		"comment":    body.Comment,
-		"key":        body.Key,
-		"is_active":  body.IsActive,
-		"updated_at": timeNow(),
-		"updated_id": currentUser.Id,
+		"data_scope":         dataScope,
+		"parent_id":          body.ParentID,
+		"title":              body.Title,
+		"comment":            body.Comment,
+		"key":                body.Key,
+		"is_active":          body.IsActive,
+		"updated_at":         timeNow(),
+		"require_two_factor": body.RequireTwoFactor,
+		"updated_id":         currentUser.Id,
 	}
 	customer.UpdatedAt = timeNow()
-	err = h.db.Clauses(clause.Returning{}).Model(&customer).UpdateColumns(columns).Error
+	err = h.db.WithContext(c).Clauses(clause.Returning{}).Model(&customer).UpdateColumns(columns).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -94,7 +130,7 @@ func (h *RolesController) UpdateRole(c *gin.Context) {
 // @Produce			json
 // @Param			data 	body		models.RolesRequest	true	"data body"
 // @Success			201		{object}	models.Roles
-// @Failure			400,409	{object}	response
+// @Failure			400,403,409	{object}	response
 // @Failure			500		{object}	response
 // @Router			/api/roles [POST]
 func (h *RolesController) CreateRole(c *gin.Context) {
@@ -107,24 +143,194 @@ func (h *RolesController) CreateRole(c *gin.Context) {
 		return
 	}
 
-	customer := models.Roles{
-		Title:     body.Title,
-		Key:       body.Key,
-		Comment:   body.Comment,
-		IsActive:  body.IsActive,
-		CreatedAt: timeNow(),
-		CreatedID: &admin.Id,
-	}
-	err = h.db.Clauses(clause.Returning{}).Create(&customer).Error
+	message, err := h.checkRoleParent(h.db, 0, body.ParentID)
 	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to check parent role")
+		h.log.Error("failed to check parent role", err.Error())
+		return
+	}
+	if message != "" {
+		newResponse(c, http.StatusBadRequest, message)
+		return
+	}
+	dataScope, ok := roleDataScope(body.DataScope)
+	if !ok {
+		newResponse(c, http.StatusBadRequest, "data scope must be one of all, own, team")
+		return
+	}
+	var templateKeys []string
+	if body.Template != "" {
+		template, ok := findRoleTemplate(body.Template)
+		if !ok {
+			newResponse(c, http.StatusBadRequest, "no such role template")
+			return
+		}
+		templateKeys, err = h.roleTemplateKeys(template)
+		if err != nil {
+			newResponse(c, http.StatusInternalServerError, "failed to get role template")
+			h.log.Error("failed to get role template", err.Error())
+			return
+		}
+		if !h.canGrantKeys(c, templateKeys) {
+			return
+		}
+	}
+	if body.ParentID != nil && !h.canGrantRole(c, *body.ParentID) {
+		return
+	}
 
+	customer := models.Roles{
+		Title:            body.Title,
+		Key:              body.Key,
+		Comment:          body.Comment,
+		IsActive:         body.IsActive,
+		CreatedAt:        timeNow(),
+		CreatedID:        &admin.Id,
+		RequireTwoFactor: body.RequireTwoFactor,
+		ParentID:         body.ParentID,
+		DataScope:        dataScope,
+	}
+	tx := h.db.WithContext(c).Begin()
+	err = tx.Clauses(clause.Returning{}).Create(&customer).Error
+	if err == nil {
+		err = createRoleItems(tx, customer.ID, templateKeys, admin.Id)
+	}
+	if err != nil {
+		tx.Rollback()
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
+	tx.Commit()
 
 	c.JSON(http.StatusOK, customer)
 }
 
+// roleDataScope validates data scope of the request, empty scope is all.
+func roleDataScope(scope string) (string, bool) {
+	switch scope {
+	case "":
+		return models.DataScopeAll, true
+	case models.DataScopeAll, models.DataScopeOwn, models.DataScopeTeam:
+		return scope, true
+	}
+	return "", false
+}
+
+// createRoleItems grants module items to the role.
+func createRoleItems(tx *gorm.DB, roleID int, keys []string, adminID int) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	items := make([]models.RoleItems, 0, len(keys))
+	for _, key := range keys {
+		items = append(items, models.RoleItems{
+			RoleID:        roleID,
+			ModuleItemKey: key,
+			CreatedAt:     timeNow(),
+			CreatedID:     &adminID,
+		})
+	}
+	return tx.Create(&items).Error
+}
+
+// @Summary		  Clone role
+// @Description	   this api creates a new role with parent, settings and module items of the role
+// @Tags			Roles
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id     path  int  true  "role id"
+// @Param			data 	body		models.RoleCloneRequest	true	"data body"
+// @Success			200		{object}	models.RoleWithModuleItems
+// @Failure			400,403,404	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/roles/clone/{id} [POST]
+func (h *RolesController) CloneRole(c *gin.Context) {
+	admin := h.GetAdmin(c)
+	var body models.RoleCloneRequest
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.Key == "" || body.Title == "" {
+		newResponse(c, http.StatusBadRequest, "key and title are required")
+		return
+	}
+	var source models.Roles
+	err = h.db.First(&source, "id=? AND is_deleted=false", c.Param("id")).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			newResponse(c, http.StatusNotFound, "no such role")
+			return
+		}
+		newResponse(c, http.StatusInternalServerError, err.Error())
+		h.log.Error("failed to get role", err.Error())
+		return
+	}
+	if !h.canGrantRole(c, source.ID) {
+		return
+	}
+	var keys []string
+	err = h.db.Model(&models.RoleItems{}).Where("role_id=?", source.ID).Pluck("module_item_key", &keys).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, err.Error())
+		h.log.Error("failed to get role items", err.Error())
+		return
+	}
+	role := models.Roles{
+		Title:            body.Title,
+		Key:              body.Key,
+		Comment:          body.Comment,
+		IsActive:         source.IsActive,
+		RequireTwoFactor: source.RequireTwoFactor,
+		ParentID:         source.ParentID,
+		DataScope:        source.DataScope,
+		CreatedAt:        timeNow(),
+		CreatedID:        &admin.Id,
+	}
+	tx := h.db.WithContext(c).Begin()
+	err = tx.Clauses(clause.Returning{}).Create(&role).Error
+	if err == nil {
+		err = createRoleItems(tx, role.ID, keys, admin.Id)
+	}
+	if err != nil {
+		tx.Rollback()
+		newResponse(c, http.StatusInternalServerError, err.Error())
+		h.log.Error("failed to clone role", err.Error())
+		return
+	}
+	tx.Commit()
+	c.JSON(http.StatusOK, models.RoleWithModuleItems{
+		Roles:          role,
+		ModuleItemKeys: keys,
+	})
+}
+
+// @Summary		  Get role templates
+// @Description	   this api returns built-in permission templates, template key is passed to role create
+// @Tags			Roles
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Success			200		{object}	[]models.RoleTemplate
+// @Failure			500		{object}	response
+// @Router			/api/roles/templates [GET]
+func (h *RolesController) GetRoleTemplates(c *gin.Context) {
+	templates := make([]models.RoleTemplate, 0, len(roleTemplates))
+	for _, template := range roleTemplates {
+		keys, err := h.roleTemplateKeys(template)
+		if err != nil {
+			newResponse(c, http.StatusInternalServerError, "failed to get role templates")
+			h.log.Error("failed to get role templates", err.Error())
+			return
+		}
+		template.ModuleItemKeys = keys
+		templates = append(templates, template)
+	}
+	c.JSON(http.StatusOK, templates)
+}
+
 // @Summary		  Get by id
 // @Description	   this api is for Get by id
 // @Tags			Roles
@@ -150,14 +356,25 @@ func (h *RolesController) GetRoleById(c *gin.Context) {
 		return
 	}
 	var modulesKeys []string
-	err = h.db.Model(&models.RoleItems{}).Select("module_item_key").Find(&modulesKeys).Error
+	err = h.db.Model(&models.RoleItems{}).Where("role_id=?", roles.ID).Pluck("module_item_key", &modulesKeys).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
+	inheritedKeys := make([]string, 0)
+	if roles.ParentID != nil {
+		permissions, err := h.loadRolePermissions(*roles.ParentID)
+		if err != nil {
+			newResponse(c, http.StatusInternalServerError, err.Error())
+			h.log.Error("failed to get parent role permissions", err.Error())
+			return
+		}
+		inheritedKeys = permissionKeys(permissions)
+	}
 	c.JSON(http.StatusOK, models.RoleWithModuleItems{
 		Roles:          roles,
 		ModuleItemKeys: modulesKeys,
+		InheritedKeys:  inheritedKeys,
 	})
 }
 
@@ -171,7 +388,7 @@ func (h *RolesController) GetRoleById(c *gin.Context) {
 //	@Produce		json
 //	@Param			data	body		models.UpdateRoleItemsList	true	"data body"
 //	@Success		200		{object}	[]models.RoleItems
-//	@Failure		400,409	{object}	response
+//	@Failure		400,403,409	{object}	response
 //	@Failure		500		{object}	response
 //	@Router			/api/role-items/list [PUT]
 func (h *RolesController) RoleModuleItemsUpdate(c *gin.Context) {
@@ -184,7 +401,10 @@ func (h *RolesController) RoleModuleItemsUpdate(c *gin.Context) {
 		newResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
-	tx := h.db.Begin()
+	if !h.canGrantKeys(c, body.ModuleItemKeys) {
+		return
+	}
+	tx := h.db.WithContext(c).Begin()
 
 	err = tx.Delete(&models.RoleItems{}, "role_id=?", body.RoleID).Error
 	if err != nil {
@@ -283,7 +503,7 @@ func (h *RolesController) DeleteRole(c *gin.Context) {
 		"deleted_at": timeNow(),
 		"deleted_id": admin.Id,
 	}
-	err := h.db.Model(&models.Roles{}).Where("id=?", id).Updates(columns).Error
+	err := h.db.WithContext(c).Model(&models.Roles{}).Where("id=?", id).Updates(columns).Error
 	if err != nil {
 		h.log.Error("failed to delete role", err.Error())
 		newResponse(c, http.StatusInternalServerError, err.Error())
@@ -301,7 +521,7 @@ func (h *RolesController) DeleteRole(c *gin.Context) {
 // @Param			id 		path     int  true "id"
 // @Param			data 	body		models.RoleItemRequest	true	"data body"
 // @Success			201		{object}	models.RoleItems
-// @Failure			400,409	{object}	response
+// @Failure			400,403,409	{object}	response
 // @Failure			500		{object}	response
 // @Router			/api/role-items/{id} [PUT]
 func (h *RolesController) UpdateRoleItem(c *gin.Context) {
@@ -314,6 +534,9 @@ func (h *RolesController) UpdateRoleItem(c *gin.Context) {
 		h.log.Error("failed to create role", err.Error())
 		return
 	}
+	if !h.canGrantKeys(c, []string{body.Key}) {
+		return
+	}
 	var customer models.RoleItems
 	columns := map[string]interface{}{
 		"role_id":         body.RoleID,
@@ -321,7 +544,7 @@ func (h *RolesController) UpdateRoleItem(c *gin.Context) {
 		"updated_id":      admin.Id,
 		"updated_at":      timeNow(),
 	}
-	err = h.db.Clauses(clause.Returning{}).Model(&customer).Where("id=?", id).UpdateColumns(columns).Error
+	err = h.db.WithContext(c).Clauses(clause.Returning{}).Model(&customer).Where("id=?", id).UpdateColumns(columns).Error
 	if err != nil {
 		h.log.Error("failed to update role item", err.Error())
 		newResponse(c, http.StatusInternalServerError, err.Error())
@@ -339,7 +562,7 @@ func (h *RolesController) UpdateRoleItem(c *gin.Context) {
 // @Produce			json
 // @Param			data 	body		models.RoleItemRequest	true	"data body"
 // @Success			201		{object}	models.RoleItems
-// @Failure			400,409	{object}	response
+// @Failure			400,403,409	{object}	response
 // @Failure			500		{object}	response
 // @Router			/api/role-items [POST]
 func (h *RolesController) CreateRoleItem(c *gin.Context) {
@@ -351,14 +574,16 @@ func (h *RolesController) CreateRoleItem(c *gin.Context) {
 		h.log.Error("failed to create role", err.Error())
 		return
 	}
-
+	if !h.canGrantKeys(c, []string{body.Key}) {
+		return
+	}
 	customer := models.RoleItems{
 		RoleID:        body.RoleID,
 		ModuleItemKey: body.Key,
 		CreatedAt:     timeNow(),
 		CreatedID:     &admin.Id,
 	}
-	err = h.db.Clauses(clause.Returning{}).Create(&customer).Error
+	err = h.db.WithContext(c).Clauses(clause.Returning{}).Create(&customer).Error
 	if err != nil {
 		h.log.Error("failed to create role item", err.Error())
 		newResponse(c, http.StatusInternalServerError, err.Error())
@@ -449,7 +674,7 @@ func (h *RolesController) DeleteRoleItem(c *gin.Context) {
 	columns := map[string]interface{}{
 		"is_deleted": true,
 	}
-	err := h.db.Model(&models.RoleItems{}).Where("id=?", id).Updates(columns).Error
+	err := h.db.WithContext(c).Model(&models.RoleItems{}).Where("id=?", id).Updates(columns).Error
 	if err != nil {
 		h.log.Error("failed to delete role item", err.Error())
 		newResponse(c, http.StatusInternalServerError, err.Error())
@@ -16,6 +16,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Handler struct {
@@ -25,9 +26,15 @@ type Handler struct {
 	cfg          *config.Config
 	hash         *hash.Hash
 	humanizer    *humanizer.ManagerHumanizer
+	accessKeys   *utils.KeyRing
+	refreshKeys  *utils.KeyRing
+	smsSender    sms.Sender
+	// adminRouteKeys holds "METHOD /path" of routes registered through adminGroup
+	adminRouteKeys map[string]bool
 }
 
-func NewHandler(db *gorm.DB, log *logger.MyLogger, cfg config.Config, hash *hash.Hash, hum *humanizer.ManagerHumanizer) *Handler {
+func NewHandler(db *gorm.DB, log *logger.MyLogger, cfg config.Config, hash *hash.Hash, hum *humanizer.ManagerHumanizer,
+	accessKeys, refreshKeys *utils.KeyRing, smsSender sms.Sender) *Handler {
 	return &Handler{
 		db:           db,
 		log:          log,
@@ -35,11 +42,42 @@ func NewHandler(db *gorm.DB, log *logger.MyLogger, cfg config.Config, hash *hash
 		cfg:          &cfg,
 		hash:         hash,
 		humanizer:    hum,
+		accessKeys:   accessKeys,
+		refreshKeys:  refreshKeys,
+		smsSender:    smsSender,
 	}
 }
 
 func (h *Handler) Init(server *gin.Engine) {
-	sms := sms.NewSmsSender()
+	err := h.registerAuditCallbacks()
+	if err != nil {
+		h.log.Error("failed to register audit callbacks", err.Error())
+	}
+	h.registerRoutes(server)
+	err = h.SyncModuleItems(server)
+	if err != nil {
+		h.log.Error("failed to sync module items", err.Error())
+	}
+	go h.resumeSms()
+	if h.cfg.OtpPurgeInterval > 0 {
+		go h.runCodePurge()
+	}
+	server.GET("/.well-known/jwks.json", h.GetJWKS)
+	server.StaticFS("/public/", http.Dir(h.cfg.StaticFilePath))
+}
+
+// @Summary		  JSON Web Key Set
+// @Description	   public keys for verifying access tokens, tokens name their key in kid header
+// @Tags			Auth
+// @Produce			json
+// @Success			200		{object}	utils.JWKSet
+// @Router			/.well-known/jwks.json [GET]
+func (h *Handler) GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.accessKeys.JWKS())
+}
+
+func (h *Handler) registerRoutes(server *gin.Engine) {
 	api := server.Group("api")
 	{
 		h.NewAnalogController(api)
@@ -51,18 +89,25 @@ func (h *Handler) Init(server *gin.Engine) {
 		h.NewServiceController(api)
 		h.NewCountryController(api)
 		h.NewAboutController(api)
-		h.NewCustomerController(api, sms)
+		h.NewCustomerController(api)
+		h.NewSmsController(api)
 		h.NewContactController(api)
 		h.NewBannerController(api)
 		h.NewProductController(api)
 		h.NewParameterController(api)
 		h.NewAdminController(api)
+		h.NewApiKeyController(api)
+		h.NewAuditController(api)
 		h.NewBrandController(api)
 		h.NewNewsController(api)
 		h.NewVacancyController(api)
 		h.NewOrderController(api)
+		h.NewCartController(api)
+		h.NewStockController(api)
+		h.NewWarehouseController(api)
+		h.NewPromotionController(api)
+		h.NewCustomerGroupController(api)
 	}
-	server.StaticFS("/public/", http.Dir(h.cfg.StaticFilePath))
 }
 func (h *Handler) GetAdmin(c *gin.Context) *models.AdminMetadata {
 	admin := c.MustGet("admin").(models.AdminMetadata)
@@ -73,37 +118,90 @@ func (h *Handler) GetCustomer(c *gin.Context) *models.CustomerMetadata {
 	return &customer
 }
 
-func (h *Handler) DeserializeCustomer() gin.HandlerFunc {
+// accessToken returns token of the Authorization header, or of the access_token cookie without the header.
+func accessToken(ctx *gin.Context) string {
+	fields := strings.Fields(ctx.Request.Header.Get("Authorization"))
+	if len(fields) != 0 {
+		if fields[0] == "Bearer" {
+			if len(fields) > 1 {
+				return fields[1]
+			}
+			return ""
+		}
+		return fields[0]
+	}
+	cookie, err := ctx.Cookie("access_token")
+	if err == nil {
+		return cookie
+	}
+	return ""
+}
+
+// OptionalCustomer sets customer to the context when the request has a valid customer token, other
+// requests pass as guests. Use it for public routes which depend on the customer such as prices.
+func (h *Handler) OptionalCustomer() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		var access_token string
-		cookie, err := ctx.Cookie("access_token")
-		authorizationHeader := ctx.Request.Header.Get("Authorization")
-		fields := strings.Fields(authorizationHeader)
-		if len(fields) != 0 {
-			if fields[0] == "Bearer" {
-				access_token = fields[1]
-			} else if fields[0] != "" {
-				access_token = fields[0]
+		if token := accessToken(ctx); token != "" {
+			claims, err := h.accessKeys.GetClaims(token)
+			if err == nil {
+				sub, ok := claims["sub"].(float64)
+				if ok && claims["role"] == models.TokenSubjectCustomer {
+					ctx.Set("customer", models.CustomerMetadata{Id: int(sub)})
+				}
 			}
-		} else if err == nil {
-			access_token = cookie
 		}
+		ctx.Next()
+	}
+}
+
+// optionalCustomerID returns id of the customer set by OptionalCustomer, 0 for guests.
+func (h *Handler) optionalCustomerID(c *gin.Context) int {
+	if customer, ok := c.Get("customer"); ok {
+		return customer.(models.CustomerMetadata).Id
+	}
+	return 0
+}
+
+func (h *Handler) DeserializeCustomer() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		access_token := accessToken(ctx)
 		if access_token == "" {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": "You are not logged in"})
 			return
 		}
-		sub, err := utils.ValidateToken(access_token, h.cfg.AccessTokenPublicKey)
+		claims, err := h.accessKeys.GetClaims(access_token)
 		if err != nil {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": err.Error()})
 			return
 		}
+		sub, ok := claims["sub"].(float64)
+		if !ok || claims["role"] != models.TokenSubjectCustomer {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": "invalid token"})
+			return
+		}
 		user := models.CustomerMetadata{
-			Id: int(sub.(float64)),
+			Id: int(sub),
+		}
+		if _, ok := claims["imp"]; ok {
+			if !h.authenticateImpersonation(ctx, &user, claims) {
+				return
+			}
+			ctx.Set("customer", user)
+			ctx.Next()
+			h.logImpersonation(ctx, user.ImpersonatorID, user.Id, user.ImpersonationID, "")
+			return
 		}
 
-		err = h.db.Model(&models.Customer{}).Where("id=?", user.Id).UpdateColumn("last_visit", time.Now()).Error
-		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		iat, _ := claims["iat"].(float64)
+		result := h.db.Model(&models.Customer{}).
+			Where("id=? AND (password_changed_at IS NULL OR password_changed_at<=to_timestamp(?))", user.Id, int64(iat)).
+			UpdateColumn("last_visit", time.Now())
+		if result.Error != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, result.Error.Error())
+			return
+		}
+		if result.RowsAffected == 0 {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": "session is expired, login again"})
 			return
 		}
 		ctx.Set("customer", user)
@@ -113,44 +211,68 @@ func (h *Handler) DeserializeCustomer() gin.HandlerFunc {
 }
 
 // DeserializeUser this method will getting user id and branch.Use it for separate data by branches.
+// It also checks that the admin role grants the requested route. Requests with X-API-Key header
+// are authenticated by api key and may call only module items of the key.
 func (h *Handler) DeserializeAdmin() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		var access_token string
-		cookie, err := ctx.Cookie("access_token")
-		authorizationHeader := ctx.Request.Header.Get("Authorization")
-		fields := strings.Fields(authorizationHeader)
-		if len(fields) != 0 {
-			if fields[0] == "Bearer" {
-				access_token = fields[1]
-			} else if fields[0] != "" {
-				access_token = fields[0]
-			}
-		} else if err == nil {
-			access_token = cookie
-		}
-		if access_token == "" {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": "You are not logged in"})
-			return
+		var authenticated bool
+		if ctx.GetHeader(apiKeyHeader) != "" {
+			authenticated = h.authenticateApiKey(ctx)
+		} else {
+			authenticated = h.authenticateAdmin(ctx)
 		}
-		sub, err := utils.ValidateToken(access_token, h.cfg.AccessTokenPublicKey)
-		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": err.Error()})
+		if !authenticated || !h.checkAdminPermission(ctx) {
 			return
 		}
-		user := models.AdminMetadata{
-			Id: int(sub.(float64)),
-		}
+		// initializers.RateLimit(ctx)
+		ctx.Next()
+	}
+}
 
-		rows := h.db.Model(&models.Admins{}).Where("id=? AND deleted_at IS NULL AND is_active=true", sub).UpdateColumn("last_visit", time.Now())
-		if rows.Error != nil {
-			ctx.AbortWithStatusJSON(http.StatusInternalServerError, rows.Error.Error())
-			return
-		} else if rows.RowsAffected == 0 {
-			newResponse(ctx, http.StatusUnauthorized, "not found admin")
+// DeserializeAnyAdmin authenticates admin without checking role permissions, use it for routes
+// every admin needs such as own profile.
+func (h *Handler) DeserializeAnyAdmin() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !h.authenticateAdmin(ctx) {
 			return
 		}
-		ctx.Set("admin", user)
-		// initializers.RateLimit(ctx)
 		ctx.Next()
 	}
 }
+
+// authenticateAdmin sets admin metadata to the context, it aborts the request and returns false
+// when the token is invalid or the admin is not active.
+func (h *Handler) authenticateAdmin(ctx *gin.Context) bool {
+	access_token := accessToken(ctx)
+	if access_token == "" {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": "You are not logged in"})
+		return false
+	}
+	claims, err := h.accessKeys.GetClaims(access_token)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": err.Error()})
+		return false
+	}
+	sub, ok := claims["sub"].(float64)
+	if !ok || claims["role"] != models.TokenSubjectAdmin {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": "invalid token"})
+		return false
+	}
+
+	var admin models.Admins
+	rows := h.db.Clauses(clause.Returning{}).Model(&admin).
+		Where("id=? AND deleted_at IS NULL AND is_active=true", int(sub)).UpdateColumn("last_visit", time.Now())
+	if rows.Error != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, rows.Error.Error())
+		return false
+	} else if rows.RowsAffected == 0 {
+		newResponse(ctx, http.StatusUnauthorized, "not found admin")
+		return false
+	}
+	ctx.Set("admin", models.AdminMetadata{
+		Id:          admin.ID,
+		RoleID:      admin.RoleID,
+		IsSuperuser: admin.IsSuperuser != nil && *admin.IsSuperuser,
+	})
+	return true
+}
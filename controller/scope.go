@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Asliddin3/energy-maximum/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const adminScopeKey = "admin_scope"
+
+// adminScope returns ids of admins whose customers, orders and applicants the current admin sees,
+// nil means every row. It is loaded once per request.
+func (h *Handler) adminScope(c *gin.Context) ([]int, error) {
+	if cached, ok := c.Get(adminScopeKey); ok {
+		return cached.([]int), nil
+	}
+	admin := h.GetAdmin(c)
+	var ids []int
+	if !admin.IsSuperuser {
+		var role models.Roles
+		err := h.db.Select("data_scope").First(&role, "id=?", admin.RoleID).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		switch role.DataScope {
+		case models.DataScopeAll:
+		case models.DataScopeTeam:
+			err = h.db.Model(&models.Admins{}).
+				Where("team<>'' AND team=(SELECT team FROM admins WHERE id=?)", admin.Id).
+				Pluck("id", &ids).Error
+			if err != nil {
+				return nil, err
+			}
+			if len(ids) == 0 {
+				ids = []int{admin.Id}
+			}
+		default:
+			ids = []int{admin.Id}
+		}
+	}
+	c.Set(adminScopeKey, ids)
+	return ids, nil
+}
+
+// scoped applies scope of the current admin to db. It writes the response and returns false on failure.
+func (h *Handler) scoped(c *gin.Context, db *gorm.DB, scope func(*gorm.DB, []int) *gorm.DB) (*gorm.DB, bool) {
+	ids, err := h.adminScope(c)
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get admin scope")
+		h.log.Error("failed to get admin scope", err.Error())
+		return nil, false
+	}
+	if ids == nil {
+		return db, true
+	}
+	return scope(db, ids), true
+}
+
+func scopeCustomers(db *gorm.DB, ids []int) *gorm.DB {
+	return db.Where("customer.responsible_id IN ?", ids)
+}
+
+// scopeOrders shows orders assigned to the admins and orders of their customers.
+func scopeOrders(db *gorm.DB, ids []int) *gorm.DB {
+	return db.Where("(orders.responsible_id IN ? OR orders.customer_id IN (SELECT id FROM customer WHERE responsible_id IN ?))", ids, ids)
+}
+
+func scopeOrderApplicants(db *gorm.DB, ids []int) *gorm.DB {
+	return db.Where("order_applicant.responsible_id IN ?", ids)
+}
+
+// scopeApplicants shows applicants assigned to the admins and applicants for their vacancies.
+func scopeApplicants(db *gorm.DB, ids []int) *gorm.DB {
+	return db.Where("(applicant.responsible_id IN ? OR applicant.vacancy_id IN (SELECT id FROM vacancy WHERE responsible_id IN ?))", ids, ids)
+}
+
+// assignResponsible sets responsible admin of the row with id from path. With scope the admin may assign
+// only rows visible to them, table qualifies the id column for the scope.
+func (h *Handler) assignResponsible(c *gin.Context, model interface{}, table string, scope func(*gorm.DB, []int) *gorm.DB) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, "Invalid id")
+		return
+	}
+	var body models.AssignRequest
+	err = c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.ResponsibleID != nil {
+		var count int64
+		err = h.db.Model(&models.Admins{}).Where("id=? AND deleted_at IS NULL AND is_active=true", *body.ResponsibleID).
+			Count(&count).Error
+		if err != nil {
+			newResponse(c, http.StatusInternalServerError, "failed to get admin")
+			h.log.Error("failed to get admin", err.Error())
+			return
+		}
+		if count == 0 {
+			newResponse(c, http.StatusBadRequest, "responsible admin not found")
+			return
+		}
+	}
+	db := h.db.WithContext(c).Model(model)
+	if scope != nil {
+		var ok bool
+		db, ok = h.scoped(c, db, scope)
+		if !ok {
+			return
+		}
+	}
+	result := db.Where(table+".id=?", id).UpdateColumn("responsible_id", body.ResponsibleID)
+	if result.Error != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to assign responsible admin")
+		h.log.Error("failed to assign responsible admin", result.Error.Error())
+		return
+	}
+	if result.RowsAffected == 0 {
+		newResponse(c, http.StatusNotFound, "not found")
+		return
+	}
+	c.JSON(http.StatusOK, response{"success"})
+}
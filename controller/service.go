@@ -18,7 +18,7 @@ type ServiceController struct {
 
 func (h *Handler) NewServiceController(api *gin.RouterGroup) {
 	service := &ServiceController{h}
-	Ban := api.Group("service", h.DeserializeAdmin())
+	Ban := h.adminGroup(api, "service")
 	{
 		Ban.POST("", service.CreateService)
 		Ban.PUT("/:id", service.UpdateService)
@@ -77,7 +77,7 @@ func (h *ServiceController) CreateService(c *gin.Context) {
 		CreatedID:     &admin.Id,
 		CreatedAt:     timeNow(),
 	}
-	err = h.db.Clauses(clause.Returning{}).Create(&category).Error
+	err = h.db.WithContext(c).Clauses(clause.Returning{}).Create(&category).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -147,7 +147,7 @@ func (h *ServiceController) UpdateService(c *gin.Context) {
 	}
 	columns["updated_at"] = timeNow()
 	columns["updated_id"] = admin.Id
-	err = h.db.Debug().Clauses(clause.Returning{}).Model(&category).
+	err = h.db.WithContext(c).Debug().Clauses(clause.Returning{}).Model(&category).
 		Where("id=?", categoryId).Updates(columns).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
@@ -221,7 +221,7 @@ func (h *ServiceController) DeleteByID(c *gin.Context) {
 		newResponse(c, http.StatusBadRequest, "empty service id")
 		return
 	}
-	err := h.db.Delete(&models.Service{}, "id=?", serviceId).Error
+	err := h.db.WithContext(c).Delete(&models.Service{}, "id=?", serviceId).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
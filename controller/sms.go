@@ -0,0 +1,220 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Asliddin3/energy-maximum/models"
+	"github.com/Asliddin3/energy-maximum/pkg/logger"
+	"github.com/Asliddin3/energy-maximum/pkg/sms"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type SmsController struct {
+	*Handler
+}
+
+func (h *Handler) NewSmsController(api *gin.RouterGroup) {
+	smsContr := &SmsController{h}
+	messages := h.adminGroup(api, "sms-messages")
+	{
+		messages.GET("", smsContr.GetSmsMessages)
+		messages.GET("/:id", smsContr.GetSmsMessageById)
+	}
+	templates := h.adminGroup(api, "sms-template")
+	{
+		templates.GET("", smsContr.GetSmsTemplates)
+		templates.POST("", smsContr.CreateSmsTemplate)
+		templates.PUT("/:id", smsContr.UpdateSmsTemplate)
+		templates.DELETE("/:id", smsContr.DeleteSmsTemplate)
+	}
+}
+
+// queueSms logs the message and delivers it in background. Secrets are masked in the stored text.
+func (h *Handler) queueSms(phone, text, purpose string, secrets ...string) (*models.SmsMessages, error) {
+	logText := text
+	hasSecret := false
+	for _, secret := range secrets {
+		if secret != "" {
+			logText = strings.ReplaceAll(logText, secret, strings.Repeat("*", len(secret)))
+			hasSecret = true
+		}
+	}
+	record := models.SmsMessages{
+		Recipient: phone,
+		Text:      logText,
+		Purpose:   purpose,
+		Provider:  h.smsSender.Name(),
+		Status:    models.SmsStatusPending,
+		HasSecret: hasSecret,
+		CreatedAt: timeNow(),
+	}
+	err := h.db.Create(&record).Error
+	if err != nil {
+		return nil, err
+	}
+	go h.deliverSms(record.ID, phone, text, 0)
+	return &record, nil
+}
+
+// resumeSms picks up messages left pending when the service stopped. Messages younger than OtpTTL
+// are sent again, masked codes can not be rebuilt and older messages are outdated, they are marked failed.
+func (h *Handler) resumeSms() {
+	var messages []models.SmsMessages
+	err := h.db.Where("status=?", models.SmsStatusPending).Order("id").Find(&messages).Error
+	if err != nil {
+		h.log.Error("failed to get pending sms messages", logger.Error(err))
+		return
+	}
+	since := time.Now().Add(-h.cfg.OtpTTL)
+	resumed := 0
+	for _, message := range messages {
+		reason := ""
+		if message.HasSecret {
+			reason = "delivery was interrupted, masked code can not be sent again"
+		} else if message.CreatedAt == nil || message.CreatedAt.Before(since) {
+			reason = "delivery was interrupted, message is outdated"
+		}
+		if reason == "" {
+			go h.deliverSms(message.ID, message.Recipient, message.Text, message.Attempts)
+			resumed++
+			continue
+		}
+		err = h.db.Model(&models.SmsMessages{}).Where("id=? AND status=?", message.ID, models.SmsStatusPending).
+			UpdateColumns(map[string]interface{}{
+				"status":     models.SmsStatusFailed,
+				"last_error": reason,
+				"updated_at": timeNow(),
+			}).Error
+		if err != nil {
+			h.log.Error("failed to update sms message", logger.Error(err))
+		}
+	}
+	if len(messages) > 0 {
+		h.log.Infof("pending sms messages: %d resumed, %d failed", resumed, len(messages)-resumed)
+	}
+}
+
+// deliverSms sends the message and retries temporary provider errors with exponential backoff.
+// attempts is the number of attempts made before, it is not zero for resumed messages.
+func (h *Handler) deliverSms(id int, phone, text string, attempts int) {
+	backoff := h.cfg.SmsRetryBackoff
+	for attempt := attempts + 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), h.cfg.SmsTimeout)
+		messageID, err := h.smsSender.Send(ctx, phone, text)
+		cancel()
+
+		columns := map[string]interface{}{
+			"attempts":   attempt,
+			"updated_at": timeNow(),
+		}
+		retry := false
+		if err == nil {
+			columns["status"] = models.SmsStatusSent
+			columns["provider_message_id"] = messageID
+			columns["sent_at"] = timeNow()
+		} else {
+			columns["last_error"] = err.Error()
+			retry = sms.IsTemporary(err) && attempt < h.cfg.SmsMaxAttempts
+			if !retry {
+				columns["status"] = models.SmsStatusFailed
+			}
+			h.log.Warnf("failed to send sms %d to %s, attempt %d: %s", id, phone, attempt, err.Error())
+		}
+		updateErr := h.db.Model(&models.SmsMessages{}).Where("id=?", id).UpdateColumns(columns).Error
+		if updateErr != nil {
+			h.log.Error("failed to update sms message", logger.Error(updateErr))
+		}
+		if !retry {
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// @Summary		  Get sms messages
+// @Description	   this api returns sms delivery log, codes are masked
+// @Tags			Sms
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param			filter 	query		models.SmsMessageFilter	false	"filter"
+// @Success			200		{object}	models.SmsMessageResponse
+// @Failure			400,409	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/sms-messages [GET]
+func (h *SmsController) GetSmsMessages(c *gin.Context) {
+	var body models.SmsMessageFilter
+	err := c.ShouldBindQuery(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.Page == 0 {
+		body.Page = 1
+	}
+	if body.PageSize == 0 {
+		body.PageSize = 10
+	}
+	db := h.db.Model(&models.SmsMessages{})
+	if body.Recipient != "" {
+		db = db.Where("recipient=?", formatPhone(body.Recipient))
+	}
+	if body.Status != "" {
+		db = db.Where("status=?", body.Status)
+	}
+	if body.Purpose != "" {
+		db = db.Where("purpose=?", body.Purpose)
+	}
+	var count int64
+	err = db.Count(&count).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get sms messages")
+		h.log.Error("failed to count sms messages", err.Error())
+		return
+	}
+	var messages []models.SmsMessages
+	err = db.Order("id DESC").Limit(body.PageSize).Offset((body.Page - 1) * body.PageSize).Find(&messages).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get sms messages")
+		h.log.Error("failed to get sms messages", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, models.SmsMessageResponse{
+		Page:     body.Page,
+		PageSize: body.PageSize,
+		Count:    int(count),
+		Messages: messages,
+	})
+}
+
+// @Summary		  Get sms message by id
+// @Description	   this api returns one sms of delivery log
+// @Tags			Sms
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "id"
+// @Success			200		{object}	models.SmsMessages
+// @Failure			404		{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/sms-messages/{id} [GET]
+func (h *SmsController) GetSmsMessageById(c *gin.Context) {
+	var message models.SmsMessages
+	err := h.db.First(&message, "id=?", c.Param("id")).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			newResponse(c, http.StatusNotFound, "sms message not found")
+			return
+		}
+		newResponse(c, http.StatusInternalServerError, "failed to get sms message")
+		h.log.Error("failed to get sms message", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, message)
+}
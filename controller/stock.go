@@ -0,0 +1,558 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Asliddin3/energy-maximum/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// stockError is shown to the user as is.
+type stockError struct {
+	message string
+}
+
+func (e *stockError) Error() string {
+	return e.message
+}
+
+func (h *Handler) stockError(c *gin.Context, err error, msg string) {
+	var stockErr *stockError
+	if errors.As(err, &stockErr) {
+		newResponse(c, http.StatusBadRequest, stockErr.Error())
+		return
+	}
+	newResponse(c, http.StatusInternalServerError, msg)
+	h.log.Error(msg, err.Error())
+}
+
+// moveStock changes stock of the product in the warehouse under row lock, keeps product total and writes
+// the movement to the ledger. Stock can not go below reserved quantity, so placed orders are never oversold.
+func moveStock(tx *gorm.DB, movement *models.StockMovements, onHand, reserved int) error {
+	where := "warehouse_id=? AND product_id=?"
+	err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.WarehouseStocks{
+		WarehouseID: *movement.WarehouseID,
+		ProductID:   movement.ProductID,
+		UpdatedAt:   timeNow(),
+	}).Error
+	if err != nil {
+		return err
+	}
+	var stock models.WarehouseStocks
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&stock, where, *movement.WarehouseID, movement.ProductID).Error
+	if err != nil {
+		return err
+	}
+	stock.OnHand += onHand
+	stock.Reserved += reserved
+	if stock.Reserved < 0 || stock.OnHand < stock.Reserved {
+		return &stockError{fmt.Sprintf("not enough stock of product %d", movement.ProductID)}
+	}
+	err = tx.Model(&models.WarehouseStocks{}).Where(where, *movement.WarehouseID, movement.ProductID).
+		Updates(map[string]interface{}{
+			"on_hand":    stock.OnHand,
+			"reserved":   stock.Reserved,
+			"updated_at": timeNow(),
+		}).Error
+	if err != nil {
+		return err
+	}
+	err = tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "product_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"on_hand":    gorm.Expr("product_stocks.on_hand+?", onHand),
+			"reserved":   gorm.Expr("product_stocks.reserved+?", reserved),
+			"updated_at": timeNow(),
+		}),
+	}).Create(&models.ProductStocks{
+		ProductID: movement.ProductID,
+		OnHand:    onHand,
+		Reserved:  reserved,
+		UpdatedAt: timeNow(),
+	}).Error
+	if err != nil {
+		return err
+	}
+	movement.OnHand = stock.OnHand
+	movement.Reserved = stock.Reserved
+	movement.CreatedAt = timeNow()
+	return tx.Create(movement).Error
+}
+
+// trackedOrderProducts returns products of the order lines whose stock is tracked. A product is tracked
+// once it has a stock row in any warehouse, products without stock rows are sold without reservation.
+// Other movements follow the reservation, so only lines reserved for the order are released or sold.
+func trackedOrderProducts(tx *gorm.DB, orderID int, movementType string, lines []models.OrderItems) (map[int]bool, error) {
+	productIDs := make([]int, 0, len(lines))
+	for _, line := range lines {
+		productIDs = append(productIDs, line.ItemId)
+	}
+	var tracked []int
+	var err error
+	if movementType == models.StockMovementReserve {
+		err = tx.Model(&models.WarehouseStocks{}).Where("product_id IN ?", productIDs).
+			Distinct("product_id").Pluck("product_id", &tracked).Error
+	} else {
+		err = tx.Model(&models.StockMovements{}).Where("order_id=? AND type=? AND product_id IN ?",
+			orderID, models.StockMovementReserve, productIDs).Distinct("product_id").Pluck("product_id", &tracked).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[int]bool, len(tracked))
+	for _, id := range tracked {
+		res[id] = true
+	}
+	return res, nil
+}
+
+// moveOrderStock applies the movement to every tracked line of the order in warehouse of the order. Lines
+// are locked in product order to avoid deadlocks between concurrent orders. Orders placed before stock was
+// tracked have no warehouse, their stock is not changed.
+func moveOrderStock(tx *gorm.DB, order *models.Orders, movementType string, adminID *int) error {
+	if order.WarehouseID == nil {
+		return nil
+	}
+	var lines []models.OrderItems
+	err := tx.Where("order_id=?", order.ID).Order("item_id").Find(&lines).Error
+	if err != nil || len(lines) == 0 {
+		return err
+	}
+	tracked, err := trackedOrderProducts(tx, order.ID, movementType, lines)
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if !tracked[line.ItemId] {
+			continue
+		}
+		var onHand, reserved int
+		switch movementType {
+		case models.StockMovementReserve:
+			reserved = line.Amount
+		case models.StockMovementRelease:
+			reserved = -line.Amount
+		case models.StockMovementSale:
+			onHand, reserved = -line.Amount, -line.Amount
+		case models.StockMovementReturn:
+			onHand = line.Amount
+		}
+		quantity := onHand
+		if quantity == 0 {
+			quantity = reserved
+		}
+		err = moveStock(tx, &models.StockMovements{
+			ProductID:   line.ItemId,
+			WarehouseID: order.WarehouseID,
+			Type:        movementType,
+			Quantity:    quantity,
+			OrderID:     &order.ID,
+			AdminID:     adminID,
+		}, onHand, reserved)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reserveOrderStock picks warehouse for the order and reserves its lines there.
+func reserveOrderStock(tx *gorm.DB, order *models.Orders, adminID *int, preferred *int) error {
+	var lines []models.OrderItems
+	err := tx.Where("order_id=?", order.ID).Find(&lines).Error
+	if err != nil {
+		return err
+	}
+	warehouseID, err := pickWarehouse(tx, lines, preferred)
+	if err != nil {
+		return err
+	}
+	err = tx.Model(&models.Orders{}).Where("id=?", order.ID).UpdateColumn("warehouse_id", warehouseID).Error
+	if err != nil {
+		return err
+	}
+	order.WarehouseID = &warehouseID
+	return moveOrderStock(tx, order, models.StockMovementReserve, adminID)
+}
+
+// pickWarehouse returns active warehouse which has every line, preferred warehouse is checked first and
+// then warehouses by priority. Orders are not split between warehouses. Products without stock rows are
+// not tracked and fit every warehouse, a tracked product without a row in the warehouse has no stock there.
+func pickWarehouse(tx *gorm.DB, lines []models.OrderItems, preferred *int) (int, error) {
+	var warehouses []models.Warehouses
+	err := tx.Where("is_active=true AND deleted_at IS NULL").Order("priority, id").Find(&warehouses).Error
+	if err != nil {
+		return 0, err
+	}
+	productIDs := make([]int, 0, len(lines))
+	for _, line := range lines {
+		productIDs = append(productIDs, line.ItemId)
+	}
+	var stocks []models.WarehouseStocks
+	err = tx.Where("product_id IN ?", productIDs).Find(&stocks).Error
+	if err != nil {
+		return 0, err
+	}
+	available := make(map[[2]int]int, len(stocks))
+	tracked := make(map[int]bool, len(stocks))
+	for _, stock := range stocks {
+		available[[2]int{stock.WarehouseID, stock.ProductID}] = stock.OnHand - stock.Reserved
+		tracked[stock.ProductID] = true
+	}
+	fits := func(warehouseID int) bool {
+		for _, line := range lines {
+			if tracked[line.ItemId] && available[[2]int{warehouseID, line.ItemId}] < line.Amount {
+				return false
+			}
+		}
+		return true
+	}
+	if preferred != nil {
+		for _, warehouse := range warehouses {
+			if warehouse.ID == *preferred && fits(warehouse.ID) {
+				return warehouse.ID, nil
+			}
+		}
+	}
+	for _, warehouse := range warehouses {
+		if fits(warehouse.ID) {
+			return warehouse.ID, nil
+		}
+	}
+	if len(warehouses) == 0 {
+		return 0, &stockError{"there is no active warehouse"}
+	}
+	for _, line := range lines {
+		if !tracked[line.ItemId] {
+			continue
+		}
+		var total int
+		for _, warehouse := range warehouses {
+			total += available[[2]int{warehouse.ID, line.ItemId}]
+		}
+		if total < line.Amount {
+			return 0, &stockError{fmt.Sprintf("not enough stock of product %d", line.ItemId)}
+		}
+	}
+	return 0, &stockError{"no warehouse has every product of the order"}
+}
+
+// orderHoldsStock reports whether stock of the order is reserved in the status.
+func orderHoldsStock(status string) bool {
+	switch status {
+	case models.OrderStatusNew, models.OrderStatusConfirmed, models.OrderStatusProcessing, models.OrderStatusShipped:
+		return true
+	}
+	return false
+}
+
+// orderStockMovement returns movement of the order stock when the order moves between statuses.
+func orderStockMovement(from, to string) string {
+	switch {
+	case to == models.OrderStatusCancelled:
+		return models.StockMovementRelease
+	case to == models.OrderStatusCompleted:
+		return models.StockMovementSale
+	case to == models.OrderStatusReturned && from == models.OrderStatusCompleted:
+		return models.StockMovementReturn
+	case to == models.OrderStatusReturned:
+		return models.StockMovementRelease
+	}
+	return ""
+}
+
+func (h *Handler) availability(available int) string {
+	switch {
+	case available <= 0:
+		return models.AvailabilityOutOfStock
+	case available <= h.cfg.StockLowThreshold:
+		return models.AvailabilityLowStock
+	}
+	return models.AvailabilityInStock
+}
+
+// setAvailability sets availability status of the products, customers do not see quantities.
+func (h *Handler) setAvailability(products []models.Products) error {
+	if len(products) == 0 {
+		return nil
+	}
+	ids := make([]int, 0, len(products))
+	for _, product := range products {
+		ids = append(ids, product.ID)
+	}
+	var stocks []models.ProductStocks
+	err := h.db.Where("product_id IN ?", ids).Find(&stocks).Error
+	if err != nil {
+		return err
+	}
+	available := make(map[int]int, len(stocks))
+	for _, stock := range stocks {
+		available[stock.ProductID] = stock.OnHand - stock.Reserved
+	}
+	for i := range products {
+		products[i].Availability = h.availability(available[products[i].ID])
+	}
+	return nil
+}
+
+type StockController struct {
+	*Handler
+}
+
+func (h *Handler) NewStockController(api *gin.RouterGroup) {
+	stock := &StockController{h}
+	adminHandler := h.adminGroup(api, "stock")
+	{
+		adminHandler.GET("", stock.GetStocks)
+		adminHandler.GET("/:product_id", stock.GetStock)
+		adminHandler.POST("/movement", stock.CreateStockMovement)
+		adminHandler.GET("/movement", stock.GetStockMovements)
+	}
+}
+
+// @Summary		  Get stocks
+// @Description	   this api returns stock of products, low_stock returns products with available quantity not above STOCK_LOW_THRESHOLD
+// @Tags			Stock
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param			filter 	query		models.StockFilter	false	"filter"
+// @Success			200		{object}	models.StockListResponse
+// @Failure			400,409	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/stock [GET]
+func (h *StockController) GetStocks(c *gin.Context) {
+	var body models.StockFilter
+	err := c.ShouldBindQuery(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.Page == 0 {
+		body.Page = 1
+	}
+	if body.PageSize == 0 {
+		body.PageSize = 10
+	}
+	db := h.db.Table("products AS p").Joins("LEFT JOIN product_stocks AS s ON s.product_id=p.id").
+		Where("p.deleted_at IS NULL")
+	if body.ProductID != 0 {
+		db = db.Where("p.id=?", body.ProductID)
+	}
+	if body.Name != "" {
+		field := fmt.Sprintf("%%%s%%", body.Name)
+		db = db.Where("(LOWER(p.name_ru) LIKE LOWER(?) OR LOWER(p.name_en) LIKE LOWER(?) OR LOWER(p.name_uz) LIKE LOWER(?))", field, field, field)
+	}
+	if body.LowStock {
+		db = db.Where("COALESCE(s.on_hand-s.reserved, 0)<=?", h.cfg.StockLowThreshold)
+	}
+	var count int64
+	err = db.Count(&count).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get stocks")
+		h.log.Error("failed to count stocks", err.Error())
+		return
+	}
+	stocks := make([]models.ProductStockResponse, 0)
+	err = db.Select(`p.id AS product_id, p.name_uz, p.name_ru, p.name_en, COALESCE(s.on_hand, 0) AS on_hand,
+	COALESCE(s.reserved, 0) AS reserved, COALESCE(s.on_hand-s.reserved, 0) AS available, s.updated_at`).
+		Order("p.id DESC").Limit(body.PageSize).Offset((body.Page - 1) * body.PageSize).Scan(&stocks).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get stocks")
+		h.log.Error("failed to get stocks", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, models.StockListResponse{
+		Page:     body.Page,
+		PageSize: body.PageSize,
+		Count:    int(count),
+		Stocks:   stocks,
+	})
+}
+
+// @Summary		  Get product stock
+// @Description	   this api returns stock of the product in total and by warehouses
+// @Tags			Stock
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           product_id    path     int   true   "product id"
+// @Success			200		{object}	models.ProductStockResponse
+// @Failure			400,404	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/stock/{product_id} [GET]
+func (h *StockController) GetStock(c *gin.Context) {
+	var stock models.ProductStockResponse
+	result := h.db.Table("products AS p").Joins("LEFT JOIN product_stocks AS s ON s.product_id=p.id").
+		Select(`p.id AS product_id, p.name_uz, p.name_ru, p.name_en, COALESCE(s.on_hand, 0) AS on_hand,
+	COALESCE(s.reserved, 0) AS reserved, COALESCE(s.on_hand-s.reserved, 0) AS available, s.updated_at`).
+		Where("p.id=?", c.Param("product_id")).Scan(&stock)
+	if result.Error != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get stock")
+		h.log.Error("failed to get stock", result.Error.Error())
+		return
+	}
+	if result.RowsAffected == 0 {
+		newResponse(c, http.StatusNotFound, "not found product")
+		return
+	}
+	stock.Warehouses = make([]models.WarehouseStockResponse, 0)
+	err := h.db.Table("warehouse_stocks AS s").Joins("INNER JOIN warehouses AS w ON w.id=s.warehouse_id").
+		Select(`w.id AS warehouse_id, w.name, w.contact_id, s.on_hand, s.reserved, s.on_hand-s.reserved AS available,
+	s.updated_at`).Where("s.product_id=?", stock.ProductID).Order("w.priority, w.id").Scan(&stock.Warehouses).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get stock")
+		h.log.Error("failed to get warehouse stocks", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, stock)
+}
+
+// @Summary		  Create stock movement
+// @Description	   this api writes receipt, adjustment or return of the product, sales are written by orders. Quantity of adjustment may be negative
+// @Tags			Stock
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param			data 	body		models.StockMovementRequest	true	"data body"
+// @Success			200		{object}	models.StockMovements
+// @Failure			400,404	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/stock/movement [POST]
+func (h *StockController) CreateStockMovement(c *gin.Context) {
+	admin := h.GetAdmin(c)
+	var body models.StockMovementRequest
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	switch body.Type {
+	case models.StockMovementReceipt, models.StockMovementReturn:
+		if body.Quantity <= 0 {
+			newResponse(c, http.StatusBadRequest, "quantity must be positive")
+			return
+		}
+	case models.StockMovementAdjustment:
+	default:
+		newResponse(c, http.StatusBadRequest, "type must be receipt, adjustment or return")
+		return
+	}
+	var count int64
+	err = h.db.Model(&models.Products{}).Where("id=? AND deleted_at IS NULL", body.ProductID).Count(&count).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get product")
+		h.log.Error("failed to get product", err.Error())
+		return
+	}
+	if count == 0 {
+		newResponse(c, http.StatusNotFound, "not found product")
+		return
+	}
+	err = h.db.Model(&models.Warehouses{}).Where("id=? AND deleted_at IS NULL", body.WarehouseID).Count(&count).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get warehouse")
+		h.log.Error("failed to get warehouse", err.Error())
+		return
+	}
+	if count == 0 {
+		newResponse(c, http.StatusNotFound, "not found warehouse")
+		return
+	}
+	movement := models.StockMovements{
+		ProductID:   body.ProductID,
+		WarehouseID: &body.WarehouseID,
+		Type:        body.Type,
+		Quantity:    body.Quantity,
+		AdminID:     &admin.Id,
+		Reason:      body.Reason,
+	}
+	tx := h.db.WithContext(c).Begin()
+	err = moveStock(tx, &movement, body.Quantity, 0)
+	if err != nil {
+		tx.Rollback()
+		h.stockError(c, err, "failed to create stock movement")
+		return
+	}
+	err = tx.Commit().Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to create stock movement")
+		h.log.Error("failed to commit stock movement", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, movement)
+}
+
+// @Summary		  Get stock movements
+// @Description	   this api returns stock ledger, newest first
+// @Tags			Stock
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param			filter 	query		models.StockMovementFilter	false	"filter"
+// @Success			200		{object}	models.StockMovementResponse
+// @Failure			400,409	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/stock/movement [GET]
+func (h *StockController) GetStockMovements(c *gin.Context) {
+	var body models.StockMovementFilter
+	err := c.ShouldBindQuery(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.Page == 0 {
+		body.Page = 1
+	}
+	if body.PageSize == 0 {
+		body.PageSize = 10
+	}
+	db := h.db.Model(&models.StockMovements{})
+	if body.ProductID != 0 {
+		db = db.Where("product_id=?", body.ProductID)
+	}
+	if body.WarehouseID != 0 {
+		db = db.Where("warehouse_id=?", body.WarehouseID)
+	}
+	if body.OrderID != 0 {
+		db = db.Where("order_id=?", body.OrderID)
+	}
+	if body.AdminID != 0 {
+		db = db.Where("admin_id=?", body.AdminID)
+	}
+	if body.Type != "" {
+		db = db.Where("type=?", body.Type)
+	}
+	if body.DateFrom != "" {
+		db = db.Where("created_at>=?", body.DateFrom)
+	}
+	if body.DateTo != "" {
+		db = db.Where("created_at<=?", body.DateTo)
+	}
+	var count int64
+	err = db.Count(&count).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get stock movements")
+		h.log.Error("failed to count stock movements", err.Error())
+		return
+	}
+	movements := make([]models.StockMovements, 0)
+	err = db.Preload("Admin", GetUserFields).Order("id DESC").Limit(body.PageSize).
+		Offset((body.Page - 1) * body.PageSize).Find(&movements).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get stock movements")
+		h.log.Error("failed to get stock movements", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, models.StockMovementResponse{
+		Page:      body.Page,
+		PageSize:  body.PageSize,
+		Count:     int(count),
+		Movements: movements,
+	})
+}
@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Asliddin3/energy-maximum/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errRefreshTokenInvalid = errors.New("invalid refresh token")
+	errRefreshTokenRevoked = errors.New("refresh token is revoked")
+	errRefreshTokenExpired = errors.New("refresh token is expired")
+	errRefreshTokenReused  = errors.New("refresh token was already used, all sessions of this login are revoked")
+)
+
+// createAccessToken signs a short-lived access token, role claim keeps admin and customer tokens apart.
+func (h *Handler) createAccessToken(subjectType string, subjectID int) (string, error) {
+	return h.accessKeys.CreateToken(h.cfg.AccessTokenExpiresIn, subjectID,
+		map[string]interface{}{"role": subjectType})
+}
+
+// issueTokens creates access and refresh tokens and stores the refresh token.
+// Family is empty for a new login and is kept when a token is rotated.
+func (h *Handler) issueTokens(c *gin.Context, db *gorm.DB, subjectType string, subjectID int, family string) (*models.TokenResponse, *models.RefreshTokens, error) {
+	accessToken, err := h.createAccessToken(subjectType, subjectID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if family == "" {
+		family = uuid.NewString()
+	}
+	userAgent := []rune(c.Request.UserAgent())
+	if len(userAgent) > 500 {
+		userAgent = userAgent[:500]
+	}
+	expiresAt := time.Now().Add(h.cfg.RefreshTokenExpiresIn)
+	record := models.RefreshTokens{
+		TokenID:     uuid.NewString(),
+		Family:      family,
+		SubjectType: subjectType,
+		SubjectID:   subjectID,
+		UserAgent:   string(userAgent),
+		IP:          c.ClientIP(),
+		ExpiresAt:   &expiresAt,
+		CreatedAt:   timeNow(),
+	}
+	refreshToken, err := h.refreshKeys.CreateToken(h.cfg.RefreshTokenExpiresIn, subjectID,
+		map[string]interface{}{"role": subjectType, "jti": record.TokenID})
+	if err != nil {
+		return nil, nil, err
+	}
+	err = db.Create(&record).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	return &models.TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, &record, nil
+}
+
+// parseRefreshToken validates the refresh token and returns its id.
+func (h *Handler) parseRefreshToken(token, subjectType string) (string, error) {
+	claims, err := h.refreshKeys.GetClaims(token)
+	if err != nil {
+		return "", errRefreshTokenInvalid
+	}
+	role, _ := claims["role"].(string)
+	tokenID, _ := claims["jti"].(string)
+	if role != subjectType || tokenID == "" {
+		return "", errRefreshTokenInvalid
+	}
+	return tokenID, nil
+}
+
+// rotateRefreshToken exchanges the refresh token for a new pair from the same family.
+// isActive is called inside the transaction, an inactive subject loses all refresh tokens.
+func (h *Handler) rotateRefreshToken(c *gin.Context, subjectType, token string, isActive func(tx *gorm.DB, subjectID int) (bool, error)) (*models.TokenResponse, error) {
+	tokenID, err := h.parseRefreshToken(token, subjectType)
+	if err != nil {
+		return nil, err
+	}
+	tx := h.db.Begin()
+	var record models.RefreshTokens
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&record, "token_id=? AND subject_type=?", tokenID, subjectType).Error
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errRefreshTokenInvalid
+		}
+		return nil, err
+	}
+	if record.RevokedAt != nil {
+		tx.Rollback()
+		return nil, errRefreshTokenRevoked
+	}
+	if record.UsedAt != nil {
+		err = revokeRefreshTokens(tx, "family=?", record.Family)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		tx.Commit()
+		h.log.Warnf("refresh token reuse detected: %s %d family %s ip %s", subjectType, record.SubjectID, record.Family, c.ClientIP())
+		return nil, errRefreshTokenReused
+	}
+	if record.ExpiresAt.Before(time.Now()) {
+		tx.Rollback()
+		return nil, errRefreshTokenExpired
+	}
+	active, err := isActive(tx, record.SubjectID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if !active {
+		err = h.RevokeSubjectTokens(tx, subjectType, record.SubjectID)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		tx.Commit()
+		return nil, errRefreshTokenRevoked
+	}
+
+	tokens, next, err := h.issueTokens(c, tx, subjectType, record.SubjectID, record.Family)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	err = tx.Model(&record).Updates(map[string]interface{}{
+		"used_at":        timeNow(),
+		"replaced_by_id": next.ID,
+	}).Error
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return tokens, tx.Commit().Error
+}
+
+func revokeRefreshTokens(db *gorm.DB, query string, args ...interface{}) error {
+	return db.Model(&models.RefreshTokens{}).Where("revoked_at IS NULL").Where(query, args...).
+		UpdateColumn("revoked_at", time.Now()).Error
+}
+
+// RevokeSubjectTokens revokes every refresh token of the admin or customer.
+func (h *Handler) RevokeSubjectTokens(db *gorm.DB, subjectType string, subjectID int) error {
+	return revokeRefreshTokens(db, "subject_type=? AND subject_id=?", subjectType, subjectID)
+}
+
+// revokeTokenFamily revokes the login the refresh token belongs to.
+func (h *Handler) revokeTokenFamily(subjectType, token string) error {
+	tokenID, err := h.parseRefreshToken(token, subjectType)
+	if err != nil {
+		return err
+	}
+	var record models.RefreshTokens
+	err = h.db.First(&record, "token_id=? AND subject_type=?", tokenID, subjectType).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errRefreshTokenInvalid
+		}
+		return err
+	}
+	return revokeRefreshTokens(h.db, "family=?", record.Family)
+}
+
+func (h *Handler) refreshTokenError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errRefreshTokenInvalid), errors.Is(err, errRefreshTokenRevoked),
+		errors.Is(err, errRefreshTokenExpired), errors.Is(err, errRefreshTokenReused):
+		newResponse(c, http.StatusUnauthorized, err.Error())
+	default:
+		newResponse(c, http.StatusInternalServerError, "failed to refresh token")
+		h.log.Error("failed to refresh token", err.Error())
+	}
+}
@@ -0,0 +1,483 @@
+package controller
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Asliddin3/energy-maximum/models"
+	"github.com/Asliddin3/energy-maximum/pkg/totp"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	twoFactorChallengeTTL = 5 * time.Minute
+	twoFactorChallenge    = "admin_2fa"
+	recoveryCodesCount    = 10
+)
+
+var errTwoFactorChallenge = errors.New("invalid or expired two-factor challenge")
+
+// createTwoFactorChallenge signs token which proves the password step of login is passed.
+// Enroll is set when the role requires 2FA but the admin has not enabled it yet.
+func (h *Handler) createTwoFactorChallenge(adminID int, enroll bool) (string, error) {
+	return h.accessKeys.CreateToken(twoFactorChallengeTTL, adminID,
+		map[string]interface{}{"role": twoFactorChallenge, "enroll": enroll})
+}
+
+func (h *Handler) parseTwoFactorChallenge(token string) (*models.Admins, bool, error) {
+	claims, err := h.accessKeys.GetClaims(token)
+	if err != nil {
+		return nil, false, errTwoFactorChallenge
+	}
+	sub, ok := claims["sub"].(float64)
+	if !ok || claims["role"] != twoFactorChallenge {
+		return nil, false, errTwoFactorChallenge
+	}
+	enroll, _ := claims["enroll"].(bool)
+	admin := &models.Admins{}
+	err = h.db.First(admin, "id=? AND is_active=true AND deleted_at IS NULL", int(sub)).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, errTwoFactorChallenge
+		}
+		return nil, false, err
+	}
+	return admin, enroll, nil
+}
+
+func (h *Handler) roleRequiresTwoFactor(roleID int) (bool, error) {
+	if roleID == 0 {
+		return false, nil
+	}
+	var require []bool
+	err := h.db.Model(&models.Roles{}).Where("id=?", roleID).Pluck("require_two_factor", &require).Error
+	if err != nil {
+		return false, err
+	}
+	return len(require) != 0 && require[0], nil
+}
+
+// startTotpSetup stores new pending secret, it is enabled only after the first code is confirmed.
+func (h *Handler) startTotpSetup(admin *models.Admins) (*models.TwoFactorSetup, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	err = h.db.Model(&models.Admins{}).Where("id=?", admin.ID).Updates(map[string]interface{}{
+		"totp_secret":  secret,
+		"totp_enabled": false,
+		"totp_step":    0,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+	return &models.TwoFactorSetup{
+		Secret: secret,
+		Uri:    totp.ProvisioningURI(h.cfg.TotpIssuer, admin.Username, secret),
+	}, nil
+}
+
+// enableTotp checks the first code from authenticator app against pending secret and
+// returns new recovery codes.
+func (h *Handler) enableTotp(tx *gorm.DB, admin *models.Admins, code string) ([]string, bool, error) {
+	if admin.TotpSecret == "" {
+		return nil, false, nil
+	}
+	step, ok := totp.Validate(admin.TotpSecret, code, time.Now(), 0)
+	if !ok {
+		return nil, false, nil
+	}
+	err := tx.Model(&models.Admins{}).Where("id=?", admin.ID).Updates(map[string]interface{}{
+		"totp_enabled": true,
+		"totp_step":    step,
+	}).Error
+	if err != nil {
+		return nil, false, err
+	}
+	codes, err := h.replaceRecoveryCodes(tx, admin.ID)
+	if err != nil {
+		return nil, false, err
+	}
+	return codes, true, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
+
+// replaceRecoveryCodes drops old recovery codes and returns new ones, only hashes are stored.
+func (h *Handler) replaceRecoveryCodes(tx *gorm.DB, adminID int) ([]string, error) {
+	err := tx.Delete(&models.AdminRecoveryCodes{}, "admin_id=?", adminID).Error
+	if err != nil {
+		return nil, err
+	}
+	codes := make([]string, recoveryCodesCount)
+	records := make([]models.AdminRecoveryCodes, recoveryCodesCount)
+	for i := range codes {
+		random := make([]byte, 5)
+		_, err = rand.Read(random)
+		if err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(random))
+		hashed, err := h.hash.HashPassword(code)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = fmt.Sprintf("%s-%s", code[:4], code[4:])
+		records[i] = models.AdminRecoveryCodes{
+			AdminID:   adminID,
+			CodeHash:  hashed,
+			CreatedAt: timeNow(),
+		}
+	}
+	err = tx.Create(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// checkSecondFactor accepts current TOTP code or unused recovery code. Accepted codes can not be used again.
+func (h *Handler) checkSecondFactor(tx *gorm.DB, admin *models.Admins, code string, allowRecovery bool) (bool, error) {
+	if step, ok := totp.Validate(admin.TotpSecret, code, time.Now(), admin.TotpStep); ok {
+		result := tx.Model(&models.Admins{}).Where("id=? AND totp_step<?", admin.ID, step).UpdateColumn("totp_step", step)
+		return result.RowsAffected != 0, result.Error
+	}
+	if !allowRecovery {
+		return false, nil
+	}
+	code = normalizeRecoveryCode(code)
+	if len(code) != 8 {
+		return false, nil
+	}
+	var recoveryCodes []models.AdminRecoveryCodes
+	err := tx.Find(&recoveryCodes, "admin_id=? AND used_at IS NULL", admin.ID).Error
+	if err != nil {
+		return false, err
+	}
+	for _, recovery := range recoveryCodes {
+		if h.hash.CheckPassword(recovery.CodeHash, code) != nil {
+			continue
+		}
+		result := tx.Model(&models.AdminRecoveryCodes{}).Where("id=? AND used_at IS NULL", recovery.ID).
+			UpdateColumn("used_at", timeNow())
+		return result.RowsAffected != 0, result.Error
+	}
+	return false, nil
+}
+
+// adminLoginTokens issues tokens with permission keys of the admin role and its parents.
+func (h *Handler) adminLoginTokens(c *gin.Context, db *gorm.DB, admin *models.Admins) (*models.TokenResponse, error) {
+	tokens, _, err := h.issueTokens(c, db, models.TokenSubjectAdmin, admin.ID, "")
+	if err != nil {
+		return nil, err
+	}
+	permissions, err := h.loadRolePermissions(admin.RoleID)
+	if err != nil {
+		return nil, err
+	}
+	tokens.ModuleItemKeys = permissionKeys(permissions)
+	return tokens, nil
+}
+
+// @Summary		  Second step of admin auth
+// @Description	   this api exchanges challenge token and TOTP or recovery code for access token, when enrollment is required the code confirms the new authenticator
+// @Tags			Admin
+// @Accept			json
+// @Produce			json
+// @Param			data 	body		models.TwoFactorLogin	true	"data body"
+// @Success			200		{object}	models.TokenResponse
+// @Failure			400,401	{object}	response
+// @Failure			429		{object}	models.RetryAfterResponse
+// @Failure			500		{object}	response
+// @Router			/api/admin/auth/2fa [POST]
+func (h *AdminController) AuthTwoFactor(c *gin.Context) {
+	var body models.TwoFactorLogin
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	admin, enroll, err := h.parseTwoFactorChallenge(body.ChallengeToken)
+	if err != nil {
+		if errors.Is(err, errTwoFactorChallenge) {
+			newResponse(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+		newResponse(c, http.StatusInternalServerError, "failed to find admin")
+		return
+	}
+	if !h.checkAdminLock(c, admin) {
+		return
+	}
+	tx := h.db.Begin()
+	var recoveryCodes []string
+	var ok bool
+	if admin.TotpEnabled {
+		ok, err = h.checkSecondFactor(tx, admin, body.Code, true)
+	} else if enroll {
+		recoveryCodes, ok, err = h.enableTotp(tx, admin, body.Code)
+	}
+	if err != nil {
+		tx.Rollback()
+		newResponse(c, http.StatusInternalServerError, "failed to check code")
+		h.log.Error("failed to check two-factor code", err.Error())
+		return
+	}
+	if !ok {
+		tx.Rollback()
+		h.adminLoginFailed(c, admin, models.LoginResultWrongTwoFactor)
+		newResponse(c, http.StatusUnauthorized, "wrong two-factor code")
+		return
+	}
+	tokens, err := h.adminLoginTokens(c, tx, admin)
+	if err != nil {
+		tx.Rollback()
+		newResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	tx.Commit()
+	h.adminLoginSucceeded(c, admin)
+	tokens.RecoveryCodes = recoveryCodes
+	c.JSON(http.StatusOK, tokens)
+}
+
+// @Summary		  Start required 2FA enrollment
+// @Description	   this api returns TOTP secret for admin whose role requires 2FA, confirm it with /api/admin/auth/2fa
+// @Tags			Admin
+// @Accept			json
+// @Produce			json
+// @Param			data 	body		models.TwoFactorEnroll	true	"data body"
+// @Success			200		{object}	models.TwoFactorSetup
+// @Failure			400,401	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/admin/auth/2fa/enroll [POST]
+func (h *AdminController) EnrollTwoFactor(c *gin.Context) {
+	var body models.TwoFactorEnroll
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	admin, enroll, err := h.parseTwoFactorChallenge(body.ChallengeToken)
+	if err != nil {
+		if errors.Is(err, errTwoFactorChallenge) {
+			newResponse(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+		newResponse(c, http.StatusInternalServerError, "failed to find admin")
+		return
+	}
+	if !enroll || admin.TotpEnabled {
+		newResponse(c, http.StatusBadRequest, "two-factor enrollment is not required")
+		return
+	}
+	setup, err := h.startTotpSetup(admin)
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to setup two-factor")
+		h.log.Error("failed to setup two-factor", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, setup)
+}
+
+// @Summary		  Setup 2FA
+// @Description	   this api returns new TOTP secret and provisioning uri for QR code, 2FA is enabled after confirm
+// @Tags			Admin
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Success			200		{object}	models.TwoFactorSetup
+// @Failure			400,401	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/admin/2fa/setup [POST]
+func (h *AdminController) SetupTwoFactor(c *gin.Context) {
+	currentUser := h.GetAdmin(c)
+	var admin models.Admins
+	err := h.db.WithContext(c).First(&admin, "id=?", currentUser.Id).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get admin")
+		return
+	}
+	if admin.TotpEnabled {
+		newResponse(c, http.StatusBadRequest, "two-factor is already enabled")
+		return
+	}
+	setup, err := h.startTotpSetup(&admin)
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to setup two-factor")
+		h.log.Error("failed to setup two-factor", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, setup)
+}
+
+// @Summary		  Confirm 2FA
+// @Description	   this api enables 2FA with the first code from authenticator app and returns recovery codes, they are shown only once
+// @Tags			Admin
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param			data 	body		models.TwoFactorCode	true	"data body"
+// @Success			200		{object}	models.RecoveryCodesResponse
+// @Failure			400,401	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/admin/2fa/confirm [POST]
+func (h *AdminController) ConfirmTwoFactor(c *gin.Context) {
+	currentUser := h.GetAdmin(c)
+	var body models.TwoFactorCode
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	var admin models.Admins
+	err = h.db.WithContext(c).First(&admin, "id=?", currentUser.Id).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get admin")
+		return
+	}
+	if admin.TotpEnabled {
+		newResponse(c, http.StatusBadRequest, "two-factor is already enabled")
+		return
+	}
+	tx := h.db.WithContext(c).Begin()
+	codes, ok, err := h.enableTotp(tx, &admin, body.Code)
+	if err != nil {
+		tx.Rollback()
+		newResponse(c, http.StatusInternalServerError, "failed to enable two-factor")
+		h.log.Error("failed to enable two-factor", err.Error())
+		return
+	}
+	if !ok {
+		tx.Rollback()
+		newResponse(c, http.StatusBadRequest, "wrong two-factor code")
+		return
+	}
+	tx.Commit()
+	c.JSON(http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// @Summary		  Disable 2FA
+// @Description	   this api disables 2FA of current admin, it is not allowed when the role requires 2FA
+// @Tags			Admin
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param			data 	body		models.TwoFactorCode	true	"data body"
+// @Success			200		{object}	response
+// @Failure			400,403	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/admin/2fa/disable [POST]
+func (h *AdminController) DisableTwoFactor(c *gin.Context) {
+	currentUser := h.GetAdmin(c)
+	var body models.TwoFactorCode
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	var admin models.Admins
+	err = h.db.WithContext(c).First(&admin, "id=?", currentUser.Id).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get admin")
+		return
+	}
+	if !admin.TotpEnabled {
+		newResponse(c, http.StatusBadRequest, "two-factor is not enabled")
+		return
+	}
+	required, err := h.roleRequiresTwoFactor(admin.RoleID)
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get role")
+		return
+	}
+	if required {
+		newResponse(c, http.StatusForbidden, "your role requires two-factor")
+		return
+	}
+	tx := h.db.WithContext(c).Begin()
+	ok, err := h.checkSecondFactor(tx, &admin, body.Code, true)
+	if err == nil && ok {
+		err = tx.Model(&models.Admins{}).Where("id=?", admin.ID).Updates(map[string]interface{}{
+			"totp_secret":  nil,
+			"totp_enabled": false,
+			"totp_step":    0,
+		}).Error
+	}
+	if err == nil && ok {
+		err = tx.Delete(&models.AdminRecoveryCodes{}, "admin_id=?", admin.ID).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		newResponse(c, http.StatusInternalServerError, "failed to disable two-factor")
+		h.log.Error("failed to disable two-factor", err.Error())
+		return
+	}
+	if !ok {
+		tx.Rollback()
+		newResponse(c, http.StatusBadRequest, "wrong two-factor code")
+		return
+	}
+	tx.Commit()
+	c.JSON(http.StatusOK, response{"success"})
+}
+
+// @Summary		  Regenerate recovery codes
+// @Description	   this api replaces recovery codes of current admin, it needs code from authenticator app
+// @Tags			Admin
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param			data 	body		models.TwoFactorCode	true	"data body"
+// @Success			200		{object}	models.RecoveryCodesResponse
+// @Failure			400,401	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/admin/2fa/recovery-codes [POST]
+func (h *AdminController) RegenerateRecoveryCodes(c *gin.Context) {
+	currentUser := h.GetAdmin(c)
+	var body models.TwoFactorCode
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	var admin models.Admins
+	err = h.db.WithContext(c).First(&admin, "id=?", currentUser.Id).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get admin")
+		return
+	}
+	if !admin.TotpEnabled {
+		newResponse(c, http.StatusBadRequest, "two-factor is not enabled")
+		return
+	}
+	tx := h.db.WithContext(c).Begin()
+	ok, err := h.checkSecondFactor(tx, &admin, body.Code, false)
+	var codes []string
+	if err == nil && ok {
+		codes, err = h.replaceRecoveryCodes(tx, admin.ID)
+	}
+	if err != nil {
+		tx.Rollback()
+		newResponse(c, http.StatusInternalServerError, "failed to create recovery codes")
+		h.log.Error("failed to create recovery codes", err.Error())
+		return
+	}
+	if !ok {
+		tx.Rollback()
+		newResponse(c, http.StatusBadRequest, "wrong two-factor code")
+		return
+	}
+	tx.Commit()
+	c.JSON(http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
@@ -18,7 +18,7 @@ type VacancyController struct {
 
 func (h *Handler) NewVacancyController(api *gin.RouterGroup) {
 	vacancy := &VacancyController{h}
-	vac := api.Group("vacancy", h.DeserializeAdmin())
+	vac := h.adminGroup(api, "vacancy")
 	{
 		vac.POST("", vacancy.CreateVacancy)
 		vac.PUT("/:id", vacancy.UpdateVacancy)
@@ -30,6 +30,8 @@ func (h *Handler) NewVacancyController(api *gin.RouterGroup) {
 		vac.PUT("/applicant/accept/:id", vacancy.AcceptApplicant)
 		vac.PUT("/applicant/save/:id", vacancy.SaveApplicant)
 		vac.DELETE("/applicant/:id", vacancy.DeleteApplicantById)
+		vac.PUT("/assign/:id", vacancy.AssignVacancy)
+		vac.PUT("/applicant/assign/:id", vacancy.AssignApplicant)
 	}
 	api.GET("/vacancy", vacancy.GetCustomerVacancy)
 	api.GET("/vacancy/:id", vacancy.GetByID)
@@ -91,7 +93,7 @@ func (h *VacancyController) CreateVacancy(c *gin.Context) {
 		CreatedID:        &admin.Id,
 		CreatedAt:        timeNow(),
 	}
-	err = h.db.Clauses(clause.Returning{}).Create(&vacancy).Error
+	err = h.db.WithContext(c).Clauses(clause.Returning{}).Create(&vacancy).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -184,7 +186,7 @@ func (h *VacancyController) UpdateVacancy(c *gin.Context) {
 	columns["updated_at"] = timeNow()
 	columns["updated_id"] = admin.Id
 	vacancy := &models.Vacancy{}
-	err = h.db.Clauses(clause.Returning{}).Model(&vacancy).
+	err = h.db.WithContext(c).Clauses(clause.Returning{}).Model(&vacancy).
 		Where("id=?", vacancyId).Updates(columns).Error
 	if err != nil {
 		newResponse(c, http.StatusInternalServerError, err.Error())
@@ -274,7 +276,7 @@ func (h *VacancyController) GetByID(c *gin.Context) {
 func (h *VacancyController) Delete(c *gin.Context) {
 	inputId := c.Param("id")
 	var vacancy models.Vacancy
-	err := h.db.Debug().Model(&vacancy).Where("id=?", inputId).Update("is_deleted", true).Error
+	err := h.db.WithContext(c).Debug().Model(&vacancy).Where("id=?", inputId).Update("is_deleted", true).Error
 	if err != nil {
 		newResponse(c, http.StatusBadRequest, err.Error())
 		return
@@ -351,7 +353,10 @@ func (h *VacancyController) GetApplicant(c *gin.Context) {
 		return
 	}
 	var applicants []models.Applicant
-	db := h.db.Debug().Model(&models.Applicant{})
+	db, ok := h.scoped(c, h.db.Debug().Model(&models.Applicant{}), scopeApplicants)
+	if !ok {
+		return
+	}
 	if body.Page == 0 {
 		body.Page = 1
 	}
@@ -373,6 +378,27 @@ func (h *VacancyController) GetApplicant(c *gin.Context) {
 	c.JSON(http.StatusOK, applicants)
 }
 
+// setApplicantStatus changes status of the applicant from path, the admin may change only applicants in
+// their scope.
+func (h *VacancyController) setApplicantStatus(c *gin.Context, status int) {
+	applicant := models.Applicant{}
+	db, ok := h.scoped(c, h.db.WithContext(c).Clauses(clause.Returning{}).Model(&applicant), scopeApplicants)
+	if !ok {
+		return
+	}
+	result := db.Where("applicant.id=?", c.Param("id")).UpdateColumn("status", status)
+	if result.Error != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to update status")
+		h.log.Error("failed to update status", result.Error.Error())
+		return
+	}
+	if result.RowsAffected == 0 {
+		newResponse(c, http.StatusNotFound, "not found applicant")
+		return
+	}
+	c.JSON(http.StatusOK, applicant)
+}
+
 // @Summary		 	Reject applicant
 // @Description	   this api is for change status applicant to 3
 // @Tags			Vacancy
@@ -381,20 +407,11 @@ func (h *VacancyController) GetApplicant(c *gin.Context) {
 // @Produce			json
 // @Param           id    	path     int   true   "applicant id"
 // @Success			201		{object}	models.Applicant
-// @Failure			400,409	{object}	response
+// @Failure			400,404,409	{object}	response
 // @Failure			500		{object}	response
 // @Router			/api/vacancy/applicant/reject/{id} [PUT]
 func (h *VacancyController) RejectedApplicant(c *gin.Context) {
-	id := c.Param("id")
-	applicant := models.Applicant{}
-	err := h.db.Clauses(clause.Returning{}).Model(&applicant).Where("id=?", id).
-		UpdateColumn("status", 4).Error
-	if err != nil {
-		newResponse(c, http.StatusInternalServerError, "failed to update status")
-		h.log.Error("failed to update status", err.Error())
-		return
-	}
-	c.JSON(http.StatusOK, applicant)
+	h.setApplicantStatus(c, 4)
 }
 
 // @Summary		 	Accept applicant
@@ -405,20 +422,11 @@ func (h *VacancyController) RejectedApplicant(c *gin.Context) {
 // @Produce			json
 // @Param           id    	path     int   true   "applicant id"
 // @Success			201		{object}	models.Applicant
-// @Failure			400,409	{object}	response
+// @Failure			400,404,409	{object}	response
 // @Failure			500		{object}	response
 // @Router			/api/vacancy/applicant/accept/{id} [PUT]
 func (h *VacancyController) AcceptApplicant(c *gin.Context) {
-	id := c.Param("id")
-	applicant := models.Applicant{}
-	err := h.db.Clauses(clause.Returning{}).Model(&applicant).Where("id=?", id).
-		UpdateColumn("status", 2).Error
-	if err != nil {
-		newResponse(c, http.StatusInternalServerError, "failed to update status")
-		h.log.Error("failed to update status", err.Error())
-		return
-	}
-	c.JSON(http.StatusOK, applicant)
+	h.setApplicantStatus(c, 2)
 }
 
 // @Summary		  Save applicant
@@ -429,20 +437,11 @@ func (h *VacancyController) AcceptApplicant(c *gin.Context) {
 // @Produce			json
 // @Param           id    	path     int   true   "applicant id"
 // @Success			201		{object}	models.Applicant
-// @Failure			400,409	{object}	response
+// @Failure			400,404,409	{object}	response
 // @Failure			500		{object}	response
 // @Router			/api/vacancy/applicant/save/{id} [PUT]
 func (h *VacancyController) SaveApplicant(c *gin.Context) {
-	id := c.Param("id")
-	applicant := models.Applicant{}
-	err := h.db.Clauses(clause.Returning{}).Model(&applicant).Where("id=?", id).
-		UpdateColumn("status", 3).Error
-	if err != nil {
-		newResponse(c, http.StatusInternalServerError, "failed to update status")
-		h.log.Error("failed to update status", err.Error())
-		return
-	}
-	c.JSON(http.StatusOK, applicant)
+	h.setApplicantStatus(c, 3)
 }
 
 // @Summary		  Create applicant to vacancy
@@ -459,7 +458,11 @@ func (h *VacancyController) SaveApplicant(c *gin.Context) {
 func (h *VacancyController) GetApplicantById(c *gin.Context) {
 	id := c.Param("id")
 	var applicants models.Applicant
-	err := h.db.First(&applicants, "id=?", id).Error
+	db, ok := h.scoped(c, h.db.Model(&models.Applicant{}), scopeApplicants)
+	if !ok {
+		return
+	}
+	err := db.First(&applicants, "applicant.id=?", id).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			newResponse(c, http.StatusBadRequest, "not found applicant")
@@ -480,17 +483,55 @@ func (h *VacancyController) GetApplicantById(c *gin.Context) {
 // @Produce			json
 // @Param           id    	path     int   true   "vacancy id"
 // @Success			201		{object}	response
-// @Failure			400,409	{object}	response
+// @Failure			400,404,409	{object}	response
 // @Failure			500		{object}	response
 // @Router			/api/vacancy/applicant/{id} [DELETE]
 func (h *VacancyController) DeleteApplicantById(c *gin.Context) {
-	id := c.Param("id")
-	var applicants models.Applicant
-	err := h.db.Delete(&applicants, "id=?", id).Error
-	if err != nil {
+	db, ok := h.scoped(c, h.db.WithContext(c), scopeApplicants)
+	if !ok {
+		return
+	}
+	result := db.Where("applicant.id=?", c.Param("id")).Delete(&models.Applicant{})
+	if result.Error != nil {
 		newResponse(c, http.StatusInternalServerError, "failed to applicant")
-		h.log.Error("Failed to get application", err.Error())
+		h.log.Error("Failed to get application", result.Error.Error())
+		return
+	}
+	if result.RowsAffected == 0 {
+		newResponse(c, http.StatusNotFound, "not found applicant")
 		return
 	}
 	c.JSON(http.StatusOK, response{"success"})
 }
+
+// @Summary		  Assign vacancy
+// @Description	   this api sets responsible admin of the vacancy, applicants of the vacancy are visible to the admin
+// @Tags			Vacancy
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    	path     int   true   "vacancy id"
+// @Param			data 	body		models.AssignRequest	true	"data body"
+// @Success			200		{object}	response
+// @Failure			400,404	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/vacancy/assign/{id} [PUT]
+func (h *VacancyController) AssignVacancy(c *gin.Context) {
+	h.assignResponsible(c, &models.Vacancy{}, "vacancy", nil)
+}
+
+// @Summary		  Assign applicant
+// @Description	   this api sets responsible admin of the applicant
+// @Tags			Vacancy
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    	path     int   true   "applicant id"
+// @Param			data 	body		models.AssignRequest	true	"data body"
+// @Success			200		{object}	response
+// @Failure			400,404	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/vacancy/applicant/assign/{id} [PUT]
+func (h *VacancyController) AssignApplicant(c *gin.Context) {
+	h.assignResponsible(c, &models.Applicant{}, "applicant", scopeApplicants)
+}
@@ -0,0 +1,605 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/Asliddin3/energy-maximum/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WarehouseController struct {
+	*Handler
+}
+
+func (h *Handler) NewWarehouseController(api *gin.RouterGroup) {
+	warehouse := &WarehouseController{h}
+	adminHandler := h.adminGroup(api, "warehouse")
+	{
+		adminHandler.POST("", warehouse.CreateWarehouse)
+		adminHandler.PUT("/:id", warehouse.UpdateWarehouse)
+		adminHandler.GET("", warehouse.GetWarehouses)
+		adminHandler.GET("/:id", warehouse.GetWarehouseByID)
+		adminHandler.DELETE("/:id", warehouse.DeleteWarehouse)
+		adminHandler.POST("/transfer", warehouse.CreateTransfer)
+		adminHandler.GET("/transfer", warehouse.GetTransfers)
+		adminHandler.GET("/transfer/:id", warehouse.GetTransferByID)
+	}
+	api.GET("/product/availability/:id", warehouse.GetProductAvailability)
+}
+
+// checkContact writes the response and returns false when contact of the warehouse does not exist.
+func (h *WarehouseController) checkContact(c *gin.Context, contactID *int) bool {
+	if contactID == nil {
+		return true
+	}
+	var count int64
+	err := h.db.Model(&models.Contact{}).Where("id=?", *contactID).Count(&count).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get contact")
+		h.log.Error("failed to get contact", err.Error())
+		return false
+	}
+	if count == 0 {
+		newResponse(c, http.StatusBadRequest, "not found contact")
+		return false
+	}
+	return true
+}
+
+// @Summary		  Create warehouse
+// @Description	   this api creates warehouse, warehouse of a branch is linked to its contact. Orders are reserved from warehouses with lower priority first
+// @Tags			Warehouse
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param			data 	body		models.WarehouseRequest	true	"data body"
+// @Success			200		{object}	models.Warehouses
+// @Failure			400,409	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/warehouse [POST]
+func (h *WarehouseController) CreateWarehouse(c *gin.Context) {
+	admin := h.GetAdmin(c)
+	var body models.WarehouseRequest
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !h.checkContact(c, body.ContactID) {
+		return
+	}
+	warehouse := models.Warehouses{
+		Name:      body.Name,
+		ContactID: body.ContactID,
+		Priority:  body.Priority,
+		IsActive:  body.IsActive,
+		CreatedID: &admin.Id,
+		CreatedAt: timeNow(),
+	}
+	err = h.db.WithContext(c).Clauses(clause.Returning{}).Create(&warehouse).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to create warehouse")
+		h.log.Error("failed to create warehouse", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, warehouse)
+}
+
+// @Summary		  Update warehouse
+// @Description	   this api updates warehouse, reservations of placed orders stay in inactive warehouse
+// @Tags			Warehouse
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "warehouse id"
+// @Param			data 	body		models.WarehouseRequest	true	"data body"
+// @Success			200		{object}	models.Warehouses
+// @Failure			400,404	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/warehouse/{id} [PUT]
+func (h *WarehouseController) UpdateWarehouse(c *gin.Context) {
+	admin := h.GetAdmin(c)
+	var body models.WarehouseRequest
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !h.checkContact(c, body.ContactID) {
+		return
+	}
+	columns := map[string]interface{}{
+		"name":       body.Name,
+		"contact_id": body.ContactID,
+		"priority":   body.Priority,
+		"updated_at": timeNow(),
+		"updated_id": admin.Id,
+	}
+	if body.IsActive != nil {
+		columns["is_active"] = body.IsActive
+	}
+	var warehouse models.Warehouses
+	result := h.db.WithContext(c).Clauses(clause.Returning{}).Model(&warehouse).
+		Where("id=? AND deleted_at IS NULL", c.Param("id")).Updates(columns)
+	if result.Error != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to update warehouse")
+		h.log.Error("failed to update warehouse", result.Error.Error())
+		return
+	}
+	if result.RowsAffected == 0 {
+		newResponse(c, http.StatusNotFound, "not found warehouse")
+		return
+	}
+	c.JSON(http.StatusOK, warehouse)
+}
+
+// @Summary		  Get warehouses
+// @Description	   this api returns warehouses by priority
+// @Tags			Warehouse
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param			filter 	query		models.WarehouseFilter	false	"filter"
+// @Success			200		{object}	[]models.Warehouses
+// @Failure			400,409	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/warehouse [GET]
+func (h *WarehouseController) GetWarehouses(c *gin.Context) {
+	var body models.WarehouseFilter
+	err := c.ShouldBindQuery(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	db := h.db.Model(&models.Warehouses{}).Preload("Contact")
+	if !body.WithDeleted {
+		db = db.Where("deleted_at IS NULL")
+	}
+	if body.ContactID != 0 {
+		db = db.Where("contact_id=?", body.ContactID)
+	}
+	if body.IsActive != nil {
+		db = db.Where("is_active=?", *body.IsActive)
+	}
+	warehouses := make([]models.Warehouses, 0)
+	err = db.Order("priority, id").Find(&warehouses).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get warehouses")
+		h.log.Error("failed to get warehouses", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, warehouses)
+}
+
+// @Summary		  Get warehouse
+// @Description	   this api returns warehouse
+// @Tags			Warehouse
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "warehouse id"
+// @Success			200		{object}	models.Warehouses
+// @Failure			400,404	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/warehouse/{id} [GET]
+func (h *WarehouseController) GetWarehouseByID(c *gin.Context) {
+	var warehouse models.Warehouses
+	err := h.db.Preload("Contact").Preload("Created", GetUserFields).First(&warehouse, "id=?", c.Param("id")).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			newResponse(c, http.StatusNotFound, "not found warehouse")
+			return
+		}
+		newResponse(c, http.StatusInternalServerError, "failed to get warehouse")
+		h.log.Error("failed to get warehouse", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, warehouse)
+}
+
+// @Summary		  Delete warehouse
+// @Description	   this api marks warehouse deleted, warehouse with stock can not be deleted
+// @Tags			Warehouse
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "warehouse id"
+// @Success			200		{object}	response
+// @Failure			400,404,409	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/warehouse/{id} [DELETE]
+func (h *WarehouseController) DeleteWarehouse(c *gin.Context) {
+	admin := h.GetAdmin(c)
+	id := c.Param("id")
+	var count int64
+	err := h.db.Model(&models.WarehouseStocks{}).Where("warehouse_id=? AND (on_hand<>0 OR reserved<>0)", id).
+		Count(&count).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get warehouse stock")
+		h.log.Error("failed to get warehouse stock", err.Error())
+		return
+	}
+	if count != 0 {
+		newResponse(c, http.StatusConflict, "warehouse has stock, transfer it first")
+		return
+	}
+	result := h.db.WithContext(c).Model(&models.Warehouses{}).Where("id=? AND deleted_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"deleted_at": timeNow(),
+			"deleted_id": admin.Id,
+		})
+	if result.Error != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to delete warehouse")
+		h.log.Error("failed to delete warehouse", result.Error.Error())
+		return
+	}
+	if result.RowsAffected == 0 {
+		newResponse(c, http.StatusNotFound, "not found warehouse")
+		return
+	}
+	c.JSON(http.StatusOK, response{"success"})
+}
+
+// @Summary		  Create stock transfer
+// @Description	   this api moves products from one warehouse to another, the transfer is applied at once
+// @Tags			Warehouse
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param			data 	body		models.StockTransferRequest	true	"data body"
+// @Success			200		{object}	models.StockTransfers
+// @Failure			400,404	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/warehouse/transfer [POST]
+func (h *WarehouseController) CreateTransfer(c *gin.Context) {
+	admin := h.GetAdmin(c)
+	var body models.StockTransferRequest
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.FromWarehouseID == body.ToWarehouseID {
+		newResponse(c, http.StatusBadRequest, "warehouses of transfer must differ")
+		return
+	}
+	if len(body.Items) == 0 {
+		newResponse(c, http.StatusBadRequest, "transfer has no items")
+		return
+	}
+	quantities := make(map[int]int, len(body.Items))
+	productIDs := make([]int, 0, len(body.Items))
+	for _, item := range body.Items {
+		if item.Quantity <= 0 {
+			newResponse(c, http.StatusBadRequest, fmt.Sprintf("quantity of product %d must be positive", item.ProductID))
+			return
+		}
+		if _, ok := quantities[item.ProductID]; !ok {
+			productIDs = append(productIDs, item.ProductID)
+		}
+		quantities[item.ProductID] += item.Quantity
+	}
+	sort.Ints(productIDs)
+	var count int64
+	err = h.db.Model(&models.Warehouses{}).Where("id IN ? AND deleted_at IS NULL", []int{body.FromWarehouseID, body.ToWarehouseID}).
+		Count(&count).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get warehouses")
+		h.log.Error("failed to get warehouses", err.Error())
+		return
+	}
+	if count != 2 {
+		newResponse(c, http.StatusNotFound, "not found warehouse")
+		return
+	}
+	err = h.db.Model(&models.Products{}).Where("id IN ? AND deleted_at IS NULL", productIDs).Count(&count).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get products")
+		h.log.Error("failed to get products", err.Error())
+		return
+	}
+	if int(count) != len(productIDs) {
+		newResponse(c, http.StatusBadRequest, "not found product")
+		return
+	}
+	transfer := models.StockTransfers{
+		FromWarehouseID: body.FromWarehouseID,
+		ToWarehouseID:   body.ToWarehouseID,
+		Comment:         body.Comment,
+		AdminID:         &admin.Id,
+		CreatedAt:       timeNow(),
+	}
+	for _, productID := range productIDs {
+		transfer.Items = append(transfer.Items, models.StockTransferItems{ProductID: productID, Quantity: quantities[productID]})
+	}
+	// rows of the lower warehouse are locked first so opposite transfers do not deadlock
+	first, second := body.FromWarehouseID, body.ToWarehouseID
+	if first > second {
+		first, second = second, first
+	}
+	tx := h.db.WithContext(c).Begin()
+	err = tx.Create(&transfer).Error
+	for _, item := range transfer.Items {
+		for _, warehouseID := range []int{first, second} {
+			if err != nil {
+				break
+			}
+			warehouseID := warehouseID
+			movement := models.StockMovements{
+				ProductID:   item.ProductID,
+				WarehouseID: &warehouseID,
+				Type:        models.StockMovementTransferIn,
+				Quantity:    item.Quantity,
+				TransferID:  &transfer.ID,
+				AdminID:     &admin.Id,
+				Reason:      body.Comment,
+			}
+			if warehouseID == body.FromWarehouseID {
+				movement.Type = models.StockMovementTransferOut
+				movement.Quantity = -item.Quantity
+			}
+			err = moveStock(tx, &movement, movement.Quantity, 0)
+		}
+	}
+	if err != nil {
+		tx.Rollback()
+		h.stockError(c, err, "failed to create transfer")
+		return
+	}
+	err = tx.Commit().Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to create transfer")
+		h.log.Error("failed to commit transfer", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, transfer)
+}
+
+// @Summary		  Get stock transfers
+// @Description	   this api returns stock transfers, newest first
+// @Tags			Warehouse
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param			filter 	query		models.StockTransferFilter	false	"filter"
+// @Success			200		{object}	models.StockTransferResponse
+// @Failure			400,409	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/warehouse/transfer [GET]
+func (h *WarehouseController) GetTransfers(c *gin.Context) {
+	var body models.StockTransferFilter
+	err := c.ShouldBindQuery(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.Page == 0 {
+		body.Page = 1
+	}
+	if body.PageSize == 0 {
+		body.PageSize = 10
+	}
+	db := h.db.Model(&models.StockTransfers{})
+	if body.WarehouseID != 0 {
+		db = db.Where("(from_warehouse_id=? OR to_warehouse_id=?)", body.WarehouseID, body.WarehouseID)
+	}
+	if body.ProductID != 0 {
+		db = db.Where("id IN (SELECT transfer_id FROM stock_transfer_items WHERE product_id=?)", body.ProductID)
+	}
+	if body.DateFrom != "" {
+		db = db.Where("created_at>=?", body.DateFrom)
+	}
+	if body.DateTo != "" {
+		db = db.Where("created_at<=?", body.DateTo)
+	}
+	var count int64
+	err = db.Count(&count).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get transfers")
+		h.log.Error("failed to count transfers", err.Error())
+		return
+	}
+	transfers := make([]models.StockTransfers, 0)
+	err = db.Preload("Items").Preload("FromWarehouse").Preload("ToWarehouse").Preload("Admin", GetUserFields).
+		Order("id DESC").Limit(body.PageSize).Offset((body.Page - 1) * body.PageSize).Find(&transfers).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get transfers")
+		h.log.Error("failed to get transfers", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, models.StockTransferResponse{
+		Page:      body.Page,
+		PageSize:  body.PageSize,
+		Count:     int(count),
+		Transfers: transfers,
+	})
+}
+
+// @Summary		  Get stock transfer
+// @Description	   this api returns stock transfer with its items
+// @Tags			Warehouse
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "transfer id"
+// @Success			200		{object}	models.StockTransfers
+// @Failure			400,404	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/warehouse/transfer/{id} [GET]
+func (h *WarehouseController) GetTransferByID(c *gin.Context) {
+	var transfer models.StockTransfers
+	err := h.db.Preload("Items").Preload("FromWarehouse").Preload("ToWarehouse").Preload("Admin", GetUserFields).
+		First(&transfer, "id=?", c.Param("id")).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			newResponse(c, http.StatusNotFound, "not found transfer")
+			return
+		}
+		newResponse(c, http.StatusInternalServerError, "failed to get transfer")
+		h.log.Error("failed to get transfer", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, transfer)
+}
+
+// @Summary		  Get product availability by branches
+// @Description	   this api returns branches which have the product in stock
+// @Tags			Product
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "product id"
+// @Success			200		{object}	[]models.BranchAvailability
+// @Failure			400,404	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/product/availability/{id} [GET]
+func (h *WarehouseController) GetProductAvailability(c *gin.Context) {
+	var count int64
+	err := h.db.Model(&models.Products{}).Where("id=? AND is_active=true AND deleted_at IS NULL", c.Param("id")).
+		Count(&count).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get product")
+		h.log.Error("failed to get product", err.Error())
+		return
+	}
+	if count == 0 {
+		newResponse(c, http.StatusNotFound, "not found product")
+		return
+	}
+	var rows []struct {
+		ContactID int
+		Available int
+	}
+	err = h.db.Table("warehouse_stocks AS s").Joins("INNER JOIN warehouses AS w ON w.id=s.warehouse_id").
+		Select("w.contact_id, SUM(s.on_hand-s.reserved) AS available").
+		Where("s.product_id=? AND w.contact_id IS NOT NULL AND w.is_active=true AND w.deleted_at IS NULL", c.Param("id")).
+		Group("w.contact_id").Having("SUM(s.on_hand-s.reserved)>0").Order("MIN(w.priority), w.contact_id").
+		Scan(&rows).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get availability")
+		h.log.Error("failed to get product availability", err.Error())
+		return
+	}
+	contactIDs := make([]int, 0, len(rows))
+	for _, row := range rows {
+		contactIDs = append(contactIDs, row.ContactID)
+	}
+	var contacts []models.Contact
+	if len(contactIDs) != 0 {
+		err = h.db.Where("id IN ?", contactIDs).Find(&contacts).Error
+		if err != nil {
+			newResponse(c, http.StatusInternalServerError, "failed to get availability")
+			h.log.Error("failed to get contacts", err.Error())
+			return
+		}
+	}
+	byID := make(map[int]*models.Contact, len(contacts))
+	for i := range contacts {
+		byID[contacts[i].ID] = &contacts[i]
+	}
+	branches := make([]models.BranchAvailability, 0, len(rows))
+	for _, row := range rows {
+		branches = append(branches, models.BranchAvailability{
+			Contact:      byID[row.ContactID],
+			Availability: h.availability(row.Available),
+		})
+	}
+	c.JSON(http.StatusOK, branches)
+}
+
+// @Summary		  Change order warehouse
+// @Description	   this api moves reservation of the order to the warehouse, it is allowed until the order is shipped
+// @Tags			Order
+// @Security		BearerAuth
+// @Accept			json
+// @Produce			json
+// @Param           id    path     int   true   "order id"
+// @Param			data 	body		models.OrderWarehouseRequest	true	"data body"
+// @Success			200		{object}	models.Orders
+// @Failure			400,404,409	{object}	response
+// @Failure			500		{object}	response
+// @Router			/api/order/warehouse/{id} [PUT]
+func (h *OrderController) SetOrderWarehouse(c *gin.Context) {
+	admin := h.GetAdmin(c)
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, "Invalid id")
+		return
+	}
+	var body models.OrderWarehouseRequest
+	err = c.ShouldBindJSON(&body)
+	if err != nil {
+		newResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	var count int64
+	err = h.db.Model(&models.Warehouses{}).Where("id=? AND is_active=true AND deleted_at IS NULL", body.WarehouseID).
+		Count(&count).Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to get warehouse")
+		h.log.Error("failed to get warehouse", err.Error())
+		return
+	}
+	if count == 0 {
+		newResponse(c, http.StatusBadRequest, "warehouse is not active")
+		return
+	}
+	tx := h.db.WithContext(c).Begin()
+	db, ok := h.scoped(c, tx.Model(&models.Orders{}), scopeOrders)
+	if !ok {
+		tx.Rollback()
+		return
+	}
+	var order models.Orders
+	err = db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&order, "orders.id=? AND orders.deleted_at IS NULL", id).Error
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			newResponse(c, http.StatusNotFound, "not found order")
+			return
+		}
+		newResponse(c, http.StatusInternalServerError, "failed to get order")
+		h.log.Error("failed to get order", err.Error())
+		return
+	}
+	if !orderHoldsStock(order.Status) || order.Status == models.OrderStatusShipped {
+		tx.Rollback()
+		newResponse(c, http.StatusConflict, "warehouse of "+order.Status+" order can not be changed")
+		return
+	}
+	if order.WarehouseID != nil && *order.WarehouseID == body.WarehouseID {
+		tx.Rollback()
+		c.JSON(http.StatusOK, order)
+		return
+	}
+	err = moveOrderStock(tx, &order, models.StockMovementRelease, &admin.Id)
+	if err == nil {
+		order.WarehouseID = &body.WarehouseID
+		err = tx.Model(&models.Orders{}).Where("id=?", order.ID).Updates(map[string]interface{}{
+			"warehouse_id": body.WarehouseID,
+			"updated_at":   timeNow(),
+			"updated_id":   admin.Id,
+		}).Error
+	}
+	if err == nil {
+		err = moveOrderStock(tx, &order, models.StockMovementReserve, &admin.Id)
+	}
+	if err != nil {
+		tx.Rollback()
+		h.stockError(c, err, "failed to change order warehouse")
+		return
+	}
+	err = tx.Commit().Error
+	if err != nil {
+		newResponse(c, http.StatusInternalServerError, "failed to change order warehouse")
+		h.log.Error("failed to commit order warehouse", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
@@ -10,6 +10,7 @@ require (
 	github.com/google/uuid v1.4.0
 	github.com/gosimple/slug v1.14.0
 	github.com/joho/godotenv v1.5.1
+	github.com/jung-kurt/gofpdf v1.16.2
 	github.com/mvrilo/go-redoc v0.1.4
 	github.com/rifflock/lfshook v0.0.0-20180920164130-b9218ef580f5
 	github.com/rs/cors v1.10.1
@@ -1,6 +1,9 @@
 package migrate
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/Asliddin3/energy-maximum/models"
 	"gorm.io/gorm"
 )
@@ -11,7 +14,9 @@ func Migrate(db *gorm.DB) error {
 		&models.PublicOffer{},
 		&models.Products{},
 		&models.Brand{},
+		&models.CustomerGroups{},
 		&models.Customer{},
+		&models.PriceListItems{},
 		&models.Pages{},
 		&models.Roles{},
 		&models.RoleItems{},
@@ -21,15 +26,64 @@ func Migrate(db *gorm.DB) error {
 		&models.Country{},
 		&models.Contact{},
 		&models.Codes{},
+		&models.CodeAttempts{},
+		&models.CodeLockouts{},
+		&models.RefreshTokens{},
+		&models.AdminRecoveryCodes{},
+		&models.AdminLoginAttempts{},
+		&models.AuditLogs{},
+		&models.ImpersonationLogs{},
+		&models.ApiKeys{},
+		&models.ApiKeyItems{},
+		&models.SmsMessages{},
+		&models.SmsTemplates{},
+		&models.PasswordHistory{},
+		&models.PasswordResetTokens{},
 	)
 	if err != nil {
 		return err
 	}
 
+	// order lines were stored without order id, they are kept aside as they can not be linked
+	if db.Migrator().HasTable("order_items") && !db.Migrator().HasColumn("order_items", "order_id") {
+		err = db.Migrator().RenameTable("order_items", "order_items_legacy")
+		if err != nil {
+			return err
+		}
+	}
+	// order status was a number, 0 was new, 2 and 3 were written by finish and cancel
+	var statusType string
+	err = db.Raw("SELECT data_type FROM information_schema.columns WHERE table_name='orders' AND column_name='status'").
+		Scan(&statusType).Error
+	if err != nil {
+		return err
+	}
+	if statusType == "smallint" {
+		err = db.Exec(`ALTER TABLE orders ALTER COLUMN status DROP DEFAULT,
+	ALTER COLUMN status TYPE varchar(20) USING CASE status WHEN 1 THEN 'completed' WHEN 2 THEN 'completed' WHEN 3 THEN 'cancelled' ELSE 'new' END`).Error
+		if err != nil {
+			return err
+		}
+	}
 	err = db.AutoMigrate(
 		&models.About{},
+		&models.Warehouses{},
+		&models.Promotions{},
+		&models.PromotionTargets{},
+		&models.PromoCodes{},
 		&models.Orders{},
 		&models.OrderItems{},
+		&models.PromotionRedemptions{},
+		&models.OrderStatusHistory{},
+		&models.OrderComments{},
+		&models.Invoices{},
+		&models.Carts{},
+		&models.CartItems{},
+		&models.ProductStocks{},
+		&models.WarehouseStocks{},
+		&models.StockTransfers{},
+		&models.StockTransferItems{},
+		&models.StockMovements{},
 		&models.Service{},
 		&models.ProductAdditions{},
 		&models.Analog{},
@@ -39,10 +93,19 @@ func Migrate(db *gorm.DB) error {
 		&models.ProductMedia{},
 		&models.Applicant{},
 		&models.OrderApplicant{},
+		&models.LeadNotes{},
 	)
 	if err != nil {
 		return err
 	}
+	err = migrateWarehouseStock(db)
+	if err != nil {
+		return err
+	}
+	err = seedSmsTemplates(db)
+	if err != nil {
+		return err
+	}
 	err = db.AutoMigrate(
 		&models.Vacancy{},
 		&models.News{},
@@ -58,3 +121,97 @@ func Migrate(db *gorm.DB) error {
 	}
 	return nil
 }
+
+// migrateWarehouseStock creates the main warehouse when there is none, so orders can be placed right
+// after deploy, and moves stock kept per product before warehouses to it. The warehouse is linked to the
+// main branch.
+func migrateWarehouseStock(db *gorm.DB) error {
+	var warehouses int64
+	err := db.Model(&models.Warehouses{}).Count(&warehouses).Error
+	if err != nil || warehouses != 0 {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		warehouse := models.Warehouses{Name: "Main warehouse", CreatedAt: &now}
+		var contactIDs []int
+		err := tx.Model(&models.Contact{}).Where("is_main=true").Order("id").Limit(1).Pluck("id", &contactIDs).Error
+		if err != nil {
+			return err
+		}
+		if len(contactIDs) != 0 {
+			warehouse.ContactID = &contactIDs[0]
+		}
+		err = tx.Create(&warehouse).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Exec(`INSERT INTO warehouse_stocks (warehouse_id, product_id, on_hand, reserved, updated_at)
+	SELECT ?, product_id, on_hand, reserved, updated_at FROM product_stocks`, warehouse.ID).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Exec("UPDATE stock_movements SET warehouse_id=? WHERE warehouse_id IS NULL", warehouse.ID).Error
+		if err != nil {
+			return err
+		}
+		return tx.Exec("UPDATE orders SET warehouse_id=? WHERE id IN (SELECT order_id FROM stock_movements WHERE type=?)",
+			warehouse.ID, models.StockMovementReserve).Error
+	})
+}
+
+// SeedSuperuser makes the admin with the username an active superuser, only a superuser can grant
+// superuser so the first one comes from here. The admin is created with passwordHash when it does not exist,
+// password of an existing admin is not changed.
+func SeedSuperuser(db *gorm.DB, username, passwordHash string) error {
+	var admin models.Admins
+	err := db.Where("username=? AND deleted_at IS NULL", username).Limit(1).Find(&admin).Error
+	if err != nil {
+		return err
+	}
+	active := true
+	now := time.Now()
+	if admin.ID != 0 {
+		return db.Model(&admin).Updates(map[string]interface{}{
+			"is_superuser": true,
+			"is_active":    true,
+			"updated_at":   now,
+		}).Error
+	}
+	if passwordHash == "" {
+		return fmt.Errorf("admin %s does not exist, set SUPERUSER_PASSWORD to create it", username)
+	}
+	return db.Create(&models.Admins{
+		Username:    username,
+		Password:    passwordHash,
+		IsActive:    &active,
+		IsSuperuser: &active,
+		CreatedAt:   &now,
+		UpdatedAt:   &now,
+	}).Error
+}
+
+// seedSmsTemplates creates default notification texts when there are no templates yet.
+func seedSmsTemplates(db *gorm.DB) error {
+	var count int64
+	err := db.Model(&models.SmsTemplates{}).Count(&count).Error
+	if err != nil || count != 0 {
+		return err
+	}
+	templates := []models.SmsTemplates{
+		{Event: models.NotificationOrderCreated, Lang: "ru", Text: "Ваш заказ №{order_id} принят. Сумма: {total}."},
+		{Event: models.NotificationOrderCreated, Lang: "uz", Text: "№{order_id} buyurtmangiz qabul qilindi. Summa: {total}."},
+		{Event: models.NotificationOrderCreated, Lang: "en", Text: "Your order No {order_id} is received. Total: {total}."},
+		{Event: models.NotificationOrderStatus, Lang: "ru", Text: "Заказ №{order_id}: {status}. {comment}"},
+		{Event: models.NotificationOrderStatus, Lang: "uz", Text: "№{order_id} buyurtma: {status}. {comment}"},
+		{Event: models.NotificationOrderStatus, Lang: "en", Text: "Order No {order_id}: {status}. {comment}"},
+		{Event: models.NotificationOrderComment, Lang: "ru", Text: "Заказ №{order_id}: {comment}"},
+		{Event: models.NotificationOrderComment, Lang: "uz", Text: "№{order_id} buyurtma: {comment}"},
+		{Event: models.NotificationOrderComment, Lang: "en", Text: "Order No {order_id}: {comment}"},
+	}
+	now := time.Now()
+	for i := range templates {
+		templates[i].UpdatedAt = &now
+	}
+	return db.Create(&templates).Error
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+const (
+	LoginResultSuccess           = "success"
+	LoginResultTwoFactorRequired = "two_factor_required"
+	LoginResultUnknownUser       = "unknown_user"
+	LoginResultWrongPassword     = "wrong_password"
+	LoginResultWrongTwoFactor    = "wrong_two_factor"
+	LoginResultLocked            = "locked"
+)
+
+// AdminLoginAttempts is the log of admin logins, AdminID is empty for unknown usernames.
+type AdminLoginAttempts struct {
+	ID        int        `gorm:"type:bigint;primaryKey" json:"id"`
+	Admin     *Admins    `gorm:"foreignKey:AdminID;constraint:OnDelete:SET NULL;" json:"-"`
+	AdminID   *int       `gorm:"type:bigint;default:null;index" json:"admin_id"`
+	Username  string     `gorm:"type:varchar(255);index" json:"username"`
+	IP        string     `gorm:"type:varchar(64);index" json:"ip"`
+	UserAgent string     `gorm:"type:varchar(500)" json:"user_agent"`
+	Result    string     `gorm:"type:varchar(30);index" json:"result"`
+	CreatedAt *time.Time `gorm:"type:timestamptz;index" json:"created_at"`
+}
+
+type AdminLoginAttemptFilter struct {
+	AdminID  int    `json:"admin_id" form:"admin_id"`
+	Username string `json:"username" form:"username"`
+	IP       string `json:"ip" form:"ip"`
+	Result   string `json:"result" form:"result"`
+	DateFrom string `json:"date_from" form:"date_from"`
+	DateTo   string `json:"date_to" form:"date_to"`
+	Page     int    `json:"page" form:"page"`
+	PageSize int    `json:"page_size" form:"page_size"`
+}
+
+type AdminLoginAttemptResponse struct {
+	Page     int                  `json:"page"`
+	PageSize int                  `json:"page_size"`
+	Count    int                  `json:"count"`
+	Attempts []AdminLoginAttempts `json:"attempts"`
+}
@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// ApiKeys are credentials of integrations. The key is "<prefix>.<secret>", only the hash of the secret is stored.
+type ApiKeys struct {
+	ID         int           `gorm:"type:bigint;primaryKey" json:"id"`
+	Name       string        `gorm:"type:varchar(255) not null" json:"name"`
+	Prefix     string        `gorm:"type:varchar(32) not null;unique" json:"prefix"`
+	SecretHash string        `gorm:"type:varchar(64) not null" json:"-"`
+	Owner      *Admins       `gorm:"foreignKey:OwnerID" json:"owner"`
+	OwnerID    int           `gorm:"type:bigint not null;index" json:"owner_id"`
+	AllowedIPs string        `gorm:"type:text;default:null" json:"allowed_ips"`
+	ExpiresAt  *time.Time    `gorm:"type:timestamptz;default:null" json:"expires_at"`
+	RevokedAt  *time.Time    `gorm:"type:timestamptz;default:null;index" json:"revoked_at"`
+	LastUsedAt *time.Time    `gorm:"type:timestamptz;default:null" json:"last_used_at"`
+	LastUsedIP string        `gorm:"type:varchar(64);default:null" json:"last_used_ip"`
+	Items      []ApiKeyItems `gorm:"foreignKey:ApiKeyID" json:"items"`
+	Created    *Admins       `gorm:"foreignKey:CreatedID"       json:"created"`
+	CreatedID  *int          `gorm:"type:bigint;default:null;index"  json:"-"`
+	CreatedAt  *time.Time    `gorm:"type:timestamptz;default:null;index" json:"created_at"`
+	Updated    *Admins       `gorm:"foreignKey:UpdatedID"       json:"updated"`
+	UpdatedID  *int          `gorm:"type:bigint;default:null"  json:"-"`
+	UpdatedAt  *time.Time    `gorm:"type:timestamptz;default:null" json:"updated_at"`
+}
+
+// ApiKeyItems are module items the api key may call.
+type ApiKeyItems struct {
+	ID            int          `gorm:"type:bigint;primaryKey" json:"id"`
+	ApiKeyID      int          `gorm:"type:bigint not null;index" json:"api_key_id"`
+	ModuleItemKey string       `gorm:"type:varchar(255); not null;index" json:"key"`
+	ModuleItem    *ModuleItems `gorm:"foreignKey:ModuleItemKey;onDelete:CASCADE" json:"module_item"`
+}
+
+type ApiKeyRequest struct {
+	Name           string     `json:"name" binding:"required"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	AllowedIPs     []string   `json:"allowed_ips" example:"10.0.0.5,192.168.1.0/24"`
+	ModuleItemKeys []string   `json:"module_item_keys"`
+}
+
+type ApiKeyFilter struct {
+	OwnerID     int  `json:"owner_id" form:"owner_id"`
+	WithRevoked bool `json:"with_revoked" form:"with_revoked"`
+	Page        int  `json:"page" form:"page"`
+	PageSize    int  `json:"page_size" form:"page_size"`
+}
+
+type ApiKeyListResponse struct {
+	Page     int       `json:"page"`
+	PageSize int       `json:"page_size"`
+	Count    int       `json:"count"`
+	Keys     []ApiKeys `json:"keys"`
+}
+
+// ApiKeySecretResponse is the only response which contains the key.
+type ApiKeySecretResponse struct {
+	ApiKey *ApiKeys `json:"api_key"`
+	Key    string   `json:"key"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+// AuditLogs is the log of changes made by admins, Changes holds {"column": {"old": .., "new": ..}}.
+type AuditLogs struct {
+	ID         int             `gorm:"type:bigint;primaryKey" json:"id"`
+	EntityType string          `gorm:"type:varchar(100);index:idx_audit_entity" json:"entity_type"`
+	EntityID   string          `gorm:"type:varchar(100);index:idx_audit_entity" json:"entity_id"`
+	Action     string          `gorm:"type:varchar(20);index" json:"action"`
+	Admin      *Admins         `gorm:"foreignKey:AdminID;constraint:OnDelete:SET NULL;" json:"-"`
+	AdminID    *int            `gorm:"type:bigint;default:null;index" json:"admin_id"`
+	ApiKeyID   *int            `gorm:"type:bigint;default:null" json:"api_key_id"`
+	IP         string          `gorm:"type:varchar(64)" json:"ip"`
+	Changes    json.RawMessage `gorm:"type:jsonb" json:"changes" swaggertype:"object"`
+	CreatedAt  *time.Time      `gorm:"type:timestamptz;index" json:"created_at"`
+}
+
+type AuditChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+type AuditLogFilter struct {
+	EntityType string `json:"entity_type" form:"entity_type"`
+	EntityID   string `json:"entity_id" form:"entity_id"`
+	AdminID    int    `json:"admin_id" form:"admin_id"`
+	Action     string `json:"action" form:"action"`
+	DateFrom   string `json:"date_from" form:"date_from"`
+	DateTo     string `json:"date_to" form:"date_to"`
+	Page       int    `json:"page" form:"page"`
+	PageSize   int    `json:"page_size" form:"page_size"`
+}
+
+type AuditLogResponse struct {
+	Page     int         `json:"page"`
+	PageSize int         `json:"page_size"`
+	Count    int         `json:"count"`
+	Logs     []AuditLogs `json:"logs"`
+}
@@ -0,0 +1,88 @@
+package models
+
+import "time"
+
+// Carts belong to a customer or to a guest with Token, guest cart is merged into customer cart on login.
+type Carts struct {
+	ID         int         `gorm:"type:bigint;primaryKey" json:"id"`
+	Customer   *Customer   `gorm:"foreignKey:CustomerID;constraint:OnDelete:CASCADE;" json:"-"`
+	CustomerID *int        `gorm:"type:bigint;default:null;uniqueIndex" json:"customer_id"`
+	Token      *string     `gorm:"type:varchar(64);default:null;uniqueIndex" json:"-"`
+	Items      []CartItems `gorm:"foreignKey:CartID" json:"-"`
+	PromoCode  *string     `gorm:"type:varchar(50);default:null" json:"promo_code"`
+	CreatedAt  *time.Time  `gorm:"type:timestamptz;default:null" json:"created_at"`
+	UpdatedAt  *time.Time  `gorm:"type:timestamptz;default:null;index" json:"updated_at"`
+}
+
+type CartItems struct {
+	ID        int        `gorm:"type:bigint;primaryKey" json:"id"`
+	Cart      *Carts     `gorm:"foreignKey:CartID;constraint:OnDelete:CASCADE;" json:"-"`
+	CartID    int        `gorm:"type:bigint not null;uniqueIndex:idx_cart_product" json:"cart_id"`
+	Product   *Products  `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE;" json:"-"`
+	ProductID int        `gorm:"type:bigint not null;uniqueIndex:idx_cart_product" json:"product_id"`
+	Amount    int        `gorm:"type:integer not null" json:"amount"`
+	CreatedAt *time.Time `gorm:"type:timestamptz;default:null" json:"created_at"`
+	UpdatedAt *time.Time `gorm:"type:timestamptz;default:null" json:"updated_at"`
+}
+
+type CartItemRequest struct {
+	ProductID int `json:"product_id" binding:"required"`
+	Amount    int `json:"amount" binding:"required"`
+}
+
+type CartAmountRequest struct {
+	Amount int `json:"amount" binding:"required"`
+}
+
+type CartCheckoutRequest struct {
+	Description string `json:"description"`
+	// PromoCode replaces the code applied to the cart
+	PromoCode string `json:"promo_code"`
+}
+
+// CartLine is a cart item with current product data, unavailable lines are not counted in total.
+type CartLine struct {
+	ProductID int     `json:"product_id"`
+	NameUz    string  `json:"name_uz"`
+	NameRu    string  `json:"name_ru"`
+	NameEn    string  `json:"name_en"`
+	Image     string  `json:"image"`
+	Price     float64 `json:"price"`
+	Amount    int     `json:"amount"`
+	Total     float64 `json:"total"`
+	Available bool    `json:"available"`
+}
+
+// CartResponse Total is Subtotal less Discount of the applied promo code. PromoError tells why the code
+// gives no discount now.
+type CartResponse struct {
+	ID         int        `json:"id"`
+	CustomerID *int       `json:"customer_id"`
+	Items      []CartLine `json:"items"`
+	Subtotal   float64    `json:"subtotal"`
+	PromoCode  string     `json:"promo_code,omitempty"`
+	PromoError string     `json:"promo_error,omitempty"`
+	Discount   float64    `json:"discount"`
+	Total      float64    `json:"total"`
+	UpdatedAt  *time.Time `json:"updated_at"`
+}
+
+type GuestCartResponse struct {
+	CartToken string `json:"cartToken"`
+}
+
+type CartFilter struct {
+	CustomerID int    `json:"customer_id" form:"customer_id"`
+	WithGuests bool   `json:"with_guests" form:"with_guests"`
+	DateFrom   string `json:"date_from" form:"date_from"`
+	DateTo     string `json:"date_to" form:"date_to"`
+	Page       int    `json:"page" form:"page"`
+	PageSize   int    `json:"page_size" form:"page_size"`
+}
+
+type CartListResponse struct {
+	Page     int            `json:"page"`
+	PageSize int            `json:"page_size"`
+	Count    int            `json:"count"`
+	Carts    []CartResponse `json:"carts"`
+}
@@ -16,6 +16,17 @@ type Customer struct {
 	UpdatedID *int       `gorm:"type:bigint;default:null"  json:"-"`
 	UpdatedAt *time.Time `gorm:"type:timestamptz;default:null" json:"updated_at"`
 	LastVisit *time.Time `gorm:"type:timestamptz;default:null" json:"last_visit"`
+	// PasswordChangedAt invalidates access tokens issued before the password was changed
+	PasswordChangedAt *time.Time `gorm:"type:timestamptz;default:null" json:"-"`
+	// ResponsibleID is the assigned admin, admins with own or team data scope see only assigned rows
+	Responsible   *Admins `gorm:"foreignKey:ResponsibleID;constraint:OnDelete:SET NULL;" json:"-"`
+	ResponsibleID *int    `gorm:"type:bigint;default:null;index" json:"responsible_id"`
+	// Lang is the language of sms notifications, SmsNotifications false opts the customer out of them
+	Lang             string `gorm:"type:varchar(2) not null;default:'ru'" json:"lang"`
+	SmsNotifications *bool  `gorm:"type:boolean not null;default:true" json:"sms_notifications"`
+	// GroupID is the customer group, its price list replaces base prices of products
+	Group   *CustomerGroups `gorm:"foreignKey:GroupID;constraint:OnDelete:SET NULL;" json:"-"`
+	GroupID *int            `gorm:"type:bigint;default:null;index" json:"group_id"`
 }
 
 type CustomerFavorites struct {
@@ -25,14 +36,48 @@ type CustomerFavorites struct {
 	ProductID  *int      `gorm:"type:bigint;default:null" json:"-"`
 }
 
+const (
+	CodePurposeLogin         = "login"
+	CodePurposePasswordReset = "password_reset"
+)
+
+// Codes are one-time codes sent by sms, only the hash of the code is stored.
 type Codes struct {
 	ID        uint32     `gorm:"type:bigint;primaryKey" json:"id"`
 	Phone     int        `gorm:"type:bigint;index" json:"phone"`
-	Code      int        `gorm:"type:integer" json:"code"`
-	CreatedAt *time.Time `gorm:"type:timestamptz;default:null" json:"created_at"`
+	CodeHash  string     `gorm:"type:varchar(100)" json:"-"`
+	Purpose   string     `gorm:"type:varchar(20);default:login;index" json:"purpose"`
+	IP        string     `gorm:"type:varchar(64);index" json:"ip"`
+	Attempts  int        `gorm:"type:integer;default:0" json:"attempts"`
+	ExpiresAt *time.Time `gorm:"type:timestamptz;index" json:"expires_at"`
+	UsedAt    *time.Time `gorm:"type:timestamptz;default:null" json:"used_at"`
+	CreatedAt *time.Time `gorm:"type:timestamptz;default:null;index" json:"created_at"`
+}
+
+// CodeAttempts keeps failed code checks for sliding window limits.
+type CodeAttempts struct {
+	ID        int        `gorm:"type:bigint;primaryKey" json:"id"`
+	Phone     int        `gorm:"type:bigint;index" json:"phone"`
+	IP        string     `gorm:"type:varchar(64);index" json:"ip"`
+	CreatedAt *time.Time `gorm:"type:timestamptz;index" json:"created_at"`
+}
+
+// CodeLockouts blocks sending and checking codes for a phone ("phone:998...") or ip ("ip:1.2.3.4").
+type CodeLockouts struct {
+	Key         string     `gorm:"type:varchar(100);primaryKey" json:"key"`
+	LockedUntil *time.Time `gorm:"type:timestamptz;index" json:"locked_until"`
+	CreatedAt   *time.Time `gorm:"type:timestamptz;default:null" json:"created_at"`
+}
+
+type RetryAfterResponse struct {
+	Message    string `json:"message"`
+	RetryAfter int    `json:"retry_after"`
 }
 type CustomerMetadata struct {
 	Id int
+	// ImpersonatorID is the admin who acts as the customer, ImpersonationID is the session of the token
+	ImpersonatorID  int
+	ImpersonationID string
 }
 type CustomerLogin struct {
 	Phone    string `json:"phone" example:"998995117361"`
@@ -54,9 +99,11 @@ type CustomerRegisterByAdmin struct {
 }
 
 type CustomerRequest struct {
-	Name     string `json:"name" form:"name" `
-	Email    string `json:"email" form:"email"`
-	Birthday string `json:"birthday" form:"birthday"`
+	Name             string `json:"name" form:"name" `
+	Email            string `json:"email" form:"email"`
+	Birthday         string `json:"birthday" form:"birthday"`
+	Lang             string `json:"lang" form:"lang"`
+	SmsNotifications *bool  `json:"sms_notifications" form:"sms_notifications"`
 }
 type CustomerRegisterRequest struct {
 	Name     string `json:"name" form:"name"`
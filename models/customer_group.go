@@ -0,0 +1,100 @@
+package models
+
+import "time"
+
+// Kinds of price list rows. Row of a product wins over its category, nearer category wins over parent one,
+// then brand and at last the row for all products.
+const (
+	PriceKindProduct  = "product"
+	PriceKindCategory = "category"
+	PriceKindBrand    = "brand"
+	PriceKindAll      = "all"
+)
+
+// CustomerGroups such as contractors or resellers buy with prices of their price list instead of Products.Price.
+type CustomerGroups struct {
+	ID          int        `gorm:"type:bigint;primaryKey" json:"id"`
+	Name        string     `gorm:"type:varchar(250) not null;uniqueIndex" json:"name"`
+	Description string     `gorm:"type:text;default:null" json:"description"`
+	IsActive    *bool      `gorm:"type:boolean not null;default:true" json:"is_active"`
+	Customers   int        `gorm:"-" json:"customers"`
+	Created     *Admins    `gorm:"foreignKey:CreatedID"       json:"created"`
+	CreatedID   *int       `gorm:"type:bigint;default:null"  json:"-"`
+	CreatedAt   *time.Time `gorm:"type:timestamptz;default:null" json:"created_at"`
+	UpdatedID   *int       `gorm:"type:bigint;default:null"  json:"-"`
+	UpdatedAt   *time.Time `gorm:"type:timestamptz;default:null" json:"updated_at"`
+}
+
+// PriceListItems are rows of the group price list, Price replaces the base price and Percent is taken off it.
+// Row applies when the order has at least MinQuantity of the product, so rows of one target with different
+// MinQuantity are quantity breaks. TargetID is 0 for the row of all products.
+type PriceListItems struct {
+	ID          int             `gorm:"type:bigint;primaryKey" json:"id"`
+	Group       *CustomerGroups `gorm:"foreignKey:GroupID;constraint:OnDelete:CASCADE;" json:"-"`
+	GroupID     int             `gorm:"type:bigint not null;uniqueIndex:idx_price_list_item" json:"group_id"`
+	Kind        string          `gorm:"type:varchar(20) not null;uniqueIndex:idx_price_list_item" json:"kind"`
+	TargetID    int             `gorm:"type:bigint not null;default:0;uniqueIndex:idx_price_list_item" json:"target_id"`
+	MinQuantity int             `gorm:"type:integer not null;default:1;uniqueIndex:idx_price_list_item" json:"min_quantity"`
+	Price       *float64        `gorm:"type:decimal(16,2);default:null" json:"price"`
+	Percent     *float64        `gorm:"type:decimal(5,2);default:null" json:"percent"`
+	UpdatedID   *int            `gorm:"type:bigint;default:null"  json:"-"`
+	UpdatedAt   *time.Time      `gorm:"type:timestamptz;default:null" json:"updated_at"`
+}
+
+// PriceBreak is the price of the product from MinQuantity pieces.
+type PriceBreak struct {
+	MinQuantity int     `json:"min_quantity"`
+	Price       float64 `json:"price"`
+}
+
+type CustomerGroupRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	IsActive    *bool  `json:"is_active"`
+}
+
+type CustomerGroupFilter struct {
+	Name     string `json:"name" form:"name"`
+	Page     int    `json:"page" form:"page"`
+	PageSize int    `json:"page_size" form:"page_size"`
+}
+
+type CustomerGroupListResponse struct {
+	Page     int              `json:"page"`
+	PageSize int              `json:"page_size"`
+	Count    int              `json:"count"`
+	Groups   []CustomerGroups `json:"groups"`
+}
+
+// CustomerGroupAssignRequest moves customers to the group, empty GroupID returns them to retail prices.
+type CustomerGroupAssignRequest struct {
+	CustomerIDs []int `json:"customer_ids" binding:"required"`
+	GroupID     *int  `json:"group_id"`
+}
+
+// PriceListItemRequest needs exactly one of Price and Percent, MinQuantity is 1 when empty.
+type PriceListItemRequest struct {
+	Kind        string   `json:"kind" binding:"required"`
+	TargetID    int      `json:"target_id"`
+	MinQuantity int      `json:"min_quantity"`
+	Price       *float64 `json:"price"`
+	Percent     *float64 `json:"percent"`
+}
+
+type PriceListFilter struct {
+	Kind     string `json:"kind" form:"kind"`
+	TargetID int    `json:"target_id" form:"target_id"`
+	Page     int    `json:"page" form:"page"`
+	PageSize int    `json:"page_size" form:"page_size"`
+}
+
+type PriceListResponse struct {
+	Page     int              `json:"page"`
+	PageSize int              `json:"page_size"`
+	Count    int              `json:"count"`
+	Items    []PriceListItems `json:"items"`
+}
+
+type PriceListImportResponse struct {
+	Imported int `json:"imported"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// ImpersonationLogs is the trail of admins acting as customers. The first entry of a session is
+// the token issue, then every request made with the token is written.
+type ImpersonationLogs struct {
+	ID         int        `gorm:"type:bigint;primaryKey" json:"id"`
+	SessionID  string     `gorm:"type:varchar(36) not null;index" json:"session_id"`
+	Admin      *Admins    `gorm:"foreignKey:AdminID;constraint:OnDelete:SET NULL;" json:"-"`
+	AdminID    *int       `gorm:"type:bigint;default:null;index" json:"admin_id"`
+	Customer   *Customer  `gorm:"foreignKey:CustomerID;constraint:OnDelete:CASCADE;" json:"-"`
+	CustomerID int        `gorm:"type:bigint not null;index" json:"customer_id"`
+	Method     string     `gorm:"type:varchar(10)" json:"method"`
+	Path       string     `gorm:"type:varchar(500)" json:"path"`
+	Status     int        `gorm:"type:integer" json:"status"`
+	Reason     string     `gorm:"type:varchar(500);default:null" json:"reason"`
+	IP         string     `gorm:"type:varchar(64)" json:"ip"`
+	CreatedAt  *time.Time `gorm:"type:timestamptz;index" json:"created_at"`
+}
+
+type ImpersonateRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+type ImpersonationTokenResponse struct {
+	AccessToken string     `json:"accessToken"`
+	SessionID   string     `json:"sessionId"`
+	ExpiresAt   *time.Time `json:"expiresAt"`
+}
+
+type ImpersonationLogFilter struct {
+	AdminID    int    `json:"admin_id" form:"admin_id"`
+	CustomerID int    `json:"customer_id" form:"customer_id"`
+	SessionID  string `json:"session_id" form:"session_id"`
+	DateFrom   string `json:"date_from" form:"date_from"`
+	DateTo     string `json:"date_to" form:"date_to"`
+	Page       int    `json:"page" form:"page"`
+	PageSize   int    `json:"page_size" form:"page_size"`
+}
+
+type ImpersonationLogResponse struct {
+	Page     int                 `json:"page"`
+	PageSize int                 `json:"page_size"`
+	Count    int                 `json:"count"`
+	Logs     []ImpersonationLogs `json:"logs"`
+}
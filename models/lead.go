@@ -0,0 +1,71 @@
+package models
+
+import "time"
+
+// Lead statuses of order applicants, won is set when the lead is converted to an order.
+const (
+	LeadStatusNew       = "new"
+	LeadStatusContacted = "contacted"
+	LeadStatusQualified = "qualified"
+	LeadStatusWon       = "won"
+	LeadStatusLost      = "lost"
+)
+
+// LeadNotes is the timeline of the lead, status changes are written with FromStatus and ToStatus.
+type LeadNotes struct {
+	ID         int             `gorm:"type:bigint;primaryKey" json:"id"`
+	Lead       *OrderApplicant `gorm:"foreignKey:LeadID;constraint:OnDelete:CASCADE;" json:"-"`
+	LeadID     int             `gorm:"type:bigint not null;index" json:"lead_id"`
+	Text       string          `gorm:"type:text;default:null" json:"text"`
+	FromStatus string          `gorm:"type:varchar(20);default:null" json:"from_status"`
+	ToStatus   string          `gorm:"type:varchar(20);default:null" json:"to_status"`
+	Admin      *Admins         `gorm:"foreignKey:AdminID;constraint:OnDelete:SET NULL;" json:"-"`
+	AdminID    *int            `gorm:"type:bigint;default:null" json:"admin_id"`
+	CreatedAt  *time.Time      `gorm:"type:timestamptz;default:null" json:"created_at"`
+}
+
+type LeadStatusRequest struct {
+	Status  string `json:"status" binding:"required"`
+	Comment string `json:"comment"`
+}
+
+type LeadFollowUpRequest struct {
+	// FollowUpAt null clears the follow up
+	FollowUpAt *time.Time `json:"follow_up_at"`
+}
+
+type LeadNoteRequest struct {
+	Text string `json:"text" binding:"required"`
+}
+
+type LeadConvertRequest struct {
+	// Description of the draft order, message of the lead is used when it is empty
+	Description string `json:"description"`
+}
+
+type LeadConvertResponse struct {
+	Lead     *OrderApplicant `json:"lead"`
+	Customer *Customer       `json:"customer"`
+	Order    *Orders         `json:"order"`
+}
+
+type LeadMetricsFilter struct {
+	DateFrom string `json:"date_from" form:"date_from"`
+	DateTo   string `json:"date_to" form:"date_to"`
+}
+
+// LeadMetrics counts leads of the responsible admin, leads without admin have empty AdminID.
+type LeadMetrics struct {
+	AdminID   *int   `json:"admin_id"`
+	Username  string `json:"username"`
+	Total     int    `json:"total"`
+	New       int    `json:"new"`
+	Contacted int    `json:"contacted"`
+	Qualified int    `json:"qualified"`
+	Won       int    `json:"won"`
+	Lost      int    `json:"lost"`
+	// ConversionRate is the share of won leads
+	ConversionRate float64 `json:"conversion_rate"`
+	// OrderTotal sums orders converted from the leads except cancelled and returned ones
+	OrderTotal float64 `json:"order_total"`
+}
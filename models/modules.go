@@ -24,6 +24,7 @@ type ModuleItems struct {
 	Key         string   `gorm:"type:varchar(255);primaryKey" json:"key"`
 	Description string   `gorm:"type:text" json:"description"`
 	ModuleID    uint32   `gorm:"type:integer ;not null;index" json:"-"`
+	IsStale     *bool    `gorm:"type:boolean;default:false;index" json:"is_stale"`
 }
 
 type CreateModuleItemInput struct {
@@ -46,4 +47,5 @@ type UpdateModuleItemInput struct {
 
 type GetModuleItemFilter struct {
 	ModuleID *uint32 `form:"module_id"`
+	IsStale  *bool   `form:"is_stale"`
 }
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// Events customers are notified about by sms.
+const (
+	NotificationOrderCreated = "order_created"
+	NotificationOrderStatus  = "order_status"
+	NotificationOrderComment = "order_comment"
+)
+
+// SmsTemplates are texts of sms notifications. Placeholders {order_id}, {customer}, {status}, {total}
+// and {comment} are replaced when the sms is sent. Template of order_status event is chosen by the new
+// status of the order, template with empty status is used for statuses without own template.
+type SmsTemplates struct {
+	ID        int        `gorm:"type:bigint;primaryKey" json:"id"`
+	Event     string     `gorm:"type:varchar(50) not null;uniqueIndex:idx_sms_template" json:"event"`
+	Status    string     `gorm:"type:varchar(20) not null;default:'';uniqueIndex:idx_sms_template" json:"status"`
+	Lang      string     `gorm:"type:varchar(2) not null;uniqueIndex:idx_sms_template" json:"lang"`
+	Text      string     `gorm:"type:text not null" json:"text"`
+	IsActive  *bool      `gorm:"type:boolean not null;default:true" json:"is_active"`
+	UpdatedID *int       `gorm:"type:bigint;default:null" json:"-"`
+	UpdatedAt *time.Time `gorm:"type:timestamptz;default:null" json:"updated_at"`
+}
+
+type SmsTemplateRequest struct {
+	Event    string `json:"event" binding:"required"`
+	Status   string `json:"status"`
+	Lang     string `json:"lang" binding:"required"`
+	Text     string `json:"text" binding:"required"`
+	IsActive *bool  `json:"is_active"`
+}
+
+type SmsTemplateFilter struct {
+	Event string `json:"event" form:"event"`
+	Lang  string `json:"lang" form:"lang"`
+}
+
+// OrderComments are messages of admins to the customer of the order.
+type OrderComments struct {
+	ID        int        `gorm:"type:bigint;primaryKey" json:"id"`
+	Order     *Orders    `gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE;" json:"-"`
+	OrderID   int        `gorm:"type:bigint not null;index" json:"order_id"`
+	Text      string     `gorm:"type:varchar(500) not null" json:"text"`
+	Admin     *Admins    `gorm:"foreignKey:AdminID;constraint:OnDelete:SET NULL;" json:"-"`
+	AdminID   *int       `gorm:"type:bigint;default:null" json:"-"`
+	CreatedAt *time.Time `gorm:"type:timestamptz;default:null" json:"created_at"`
+}
+
+type OrderCommentRequest struct {
+	Text string `json:"text" binding:"required,max=500"`
+}
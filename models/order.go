@@ -2,30 +2,65 @@ package models
 
 import "time"
 
+// Order statuses, allowed moves between them are checked on transition.
+const (
+	// OrderStatusDraft is the order opened from a lead, it holds no stock until it moves to new
+	OrderStatusDraft      = "draft"
+	OrderStatusNew        = "new"
+	OrderStatusConfirmed  = "confirmed"
+	OrderStatusProcessing = "processing"
+	OrderStatusShipped    = "shipped"
+	OrderStatusCompleted  = "completed"
+	OrderStatusCancelled  = "cancelled"
+	OrderStatusReturned   = "returned"
+)
+
 type Orders struct {
-	ID          int        `gorm:"type:bigint not null;primaryKey" json:"id"`
-	Customer    *Customer  `gorm:"foreignKey:CustomerID" json:"customer"`
-	CustomerID  int        `gorm:"type:bigint;default:null;index" json:"customer_id"`
-	Description string     `gorm:"type:varchar(500) not null" json:"description"`
-	Status      *int8      `gorm:"type:smallint not null;default:0;index" json:"status"`
-	Total       float64    `gorm:"type:decimal(16,2) not null" json:"total"`
-	Created     *Admins    `gorm:"foreignKey:CreatedID"       json:"created"`
-	CreatedID   *int       `gorm:"type:integer;default:null"  json:"-"`
-	CreatedAt   *time.Time `gorm:"type:timestamptz;default:null;index" json:"created_at"`
-	Updated     *Admins    `gorm:"foreignKey:UpdatedID"       json:"updated"`
-	UpdatedID   *int       `gorm:"type:integer;default:null"  json:"-"`
-	UpdatedAt   *time.Time `gorm:"type:timestamptz;default:null" json:"updated_at"`
-	DeletedID   *int       `gorm:"type:integer;default:null"  json:"-"`
-	Deleted     *Admins    `gorm:"foreignKey:DeletedID"       json:"deleted"`
-	DeletedAt   *time.Time `gorm:"type:timestamptz;default:null" json:"deleted_at"`
+	ID            int         `gorm:"type:bigint not null;primaryKey" json:"id"`
+	Customer      *Customer   `gorm:"foreignKey:CustomerID" json:"customer"`
+	CustomerID    int         `gorm:"type:bigint;default:null;index" json:"customer_id"`
+	Description   string      `gorm:"type:varchar(500) not null" json:"description"`
+	Status        string      `gorm:"type:varchar(20) not null;default:'new';index" json:"status"`
+	Total         float64     `gorm:"type:decimal(16,2) not null" json:"total"`
+	Created       *Admins     `gorm:"foreignKey:CreatedID"       json:"created"`
+	CreatedID     *int        `gorm:"type:integer;default:null"  json:"-"`
+	CreatedAt     *time.Time  `gorm:"type:timestamptz;default:null;index" json:"created_at"`
+	Updated       *Admins     `gorm:"foreignKey:UpdatedID"       json:"updated"`
+	UpdatedID     *int        `gorm:"type:integer;default:null"  json:"-"`
+	UpdatedAt     *time.Time  `gorm:"type:timestamptz;default:null" json:"updated_at"`
+	DeletedID     *int        `gorm:"type:integer;default:null"  json:"-"`
+	Deleted       *Admins     `gorm:"foreignKey:DeletedID"       json:"deleted"`
+	DeletedAt     *time.Time  `gorm:"type:timestamptz;default:null" json:"deleted_at"`
+	Responsible   *Admins     `gorm:"foreignKey:ResponsibleID;constraint:OnDelete:SET NULL;" json:"-"`
+	ResponsibleID *int        `gorm:"type:bigint;default:null;index" json:"responsible_id"`
+	Warehouse     *Warehouses `gorm:"foreignKey:WarehouseID;constraint:OnDelete:SET NULL;" json:"-"`
+	WarehouseID   *int        `gorm:"type:bigint;default:null;index" json:"warehouse_id"`
+	// LeadID is the order applicant the order was converted from
+	Lead   *OrderApplicant `gorm:"foreignKey:LeadID;constraint:OnDelete:SET NULL;" json:"-"`
+	LeadID *int            `gorm:"type:bigint;default:null;index" json:"lead_id"`
+	// Discount of the promotion is already taken from Total
+	Promotion   *Promotions `gorm:"foreignKey:PromotionID;constraint:OnDelete:SET NULL;" json:"-"`
+	PromotionID *int        `gorm:"type:bigint;default:null;index" json:"promotion_id"`
+	PromoCode   *PromoCodes `gorm:"foreignKey:PromoCodeID;constraint:OnDelete:SET NULL;" json:"-"`
+	PromoCodeID *int        `gorm:"type:bigint;default:null" json:"promo_code_id"`
+	Discount    float64     `gorm:"type:decimal(16,2) not null;default:0" json:"discount"`
 }
 
+// OrderApplicant is a callback request, admins lead it through statuses until it is won or lost.
 type OrderApplicant struct {
-	ID        int        `gorm:"type:bigint not null;primaryKey" json:"id"`
-	FullName  string     `gorm:"type:varchar(400)" json:"full_name"`
-	Phone     string     `gorm:"type:varchar(20)" json:"phone"`
-	Message   string     `gorm:"type:text" json:"message"`
-	CreatedAt *time.Time `gorm:"type:timestamptz;default:null" json:"created_at"`
+	ID            int        `gorm:"type:bigint not null;primaryKey" json:"id"`
+	FullName      string     `gorm:"type:varchar(400)" json:"full_name"`
+	Phone         string     `gorm:"type:varchar(20)" json:"phone"`
+	Message       string     `gorm:"type:text" json:"message"`
+	CreatedAt     *time.Time `gorm:"type:timestamptz;default:null" json:"created_at"`
+	Responsible   *Admins    `gorm:"foreignKey:ResponsibleID;constraint:OnDelete:SET NULL;" json:"-"`
+	ResponsibleID *int       `gorm:"type:bigint;default:null;index" json:"responsible_id"`
+	Status        string     `gorm:"type:varchar(20) not null;default:'new';index" json:"status"`
+	FollowUpAt    *time.Time `gorm:"type:timestamptz;default:null;index" json:"follow_up_at"`
+	Customer      *Customer  `gorm:"foreignKey:CustomerID;constraint:OnDelete:SET NULL;" json:"-"`
+	CustomerID    *int       `gorm:"type:bigint;default:null" json:"customer_id"`
+	ConvertedAt   *time.Time `gorm:"type:timestamptz;default:null" json:"converted_at"`
+	UpdatedAt     *time.Time `gorm:"type:timestamptz;default:null" json:"updated_at"`
 }
 
 type OrderApplicantRequest struct {
@@ -33,11 +68,21 @@ type OrderApplicantRequest struct {
 	Phone    string `json:"phone"`
 	Message  string `json:"message"`
 }
+
+// OrderItems are lines of the order, product name and price are copied when the order is made.
 type OrderItems struct {
-	Price  float64   `gorm:"type:decimal(16,2) not null" json:"total"`
-	Amount int       `gorm:"type:integer;default:null" json:"amount"`
-	Item   *Products `gorm:"foreignKey:ItemId" json:"item"`
-	ItemId int       `gorm:"type:integer;default:null" json:"item_id"`
+	ID        int        `gorm:"type:bigint;primaryKey" json:"id"`
+	Order     *Orders    `gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE;" json:"-"`
+	OrderID   int        `gorm:"type:bigint not null;index" json:"order_id"`
+	Item      *Products  `gorm:"foreignKey:ItemId" json:"item,omitempty"`
+	ItemId    int        `gorm:"type:bigint;default:null;index" json:"item_id"`
+	NameUz    string     `gorm:"type:varchar(250);default:null" json:"name_uz"`
+	NameRu    string     `gorm:"type:varchar(250);default:null" json:"name_ru"`
+	NameEn    string     `gorm:"type:varchar(250);default:null" json:"name_en"`
+	Price     float64    `gorm:"type:decimal(16,2) not null" json:"price"`
+	Amount    int        `gorm:"type:integer not null" json:"amount"`
+	Total     float64    `gorm:"type:decimal(16,2) not null" json:"total"`
+	CreatedAt *time.Time `gorm:"type:timestamptz;default:null" json:"created_at"`
 }
 
 type OrderResponse struct {
@@ -53,51 +98,86 @@ type OrderApplicantResponse struct {
 }
 
 type CustomOrderResponse struct {
-	Orders         []Orders `json:"orders"`
-	Page           int      `json:"page"`
-	PageSize       int      `json:"page_size"`
-	Count          int      `json:"count"`
-	ActiveCount    int      `json:"active_count"`
-	FinishedCount  int      `json:"finished_count"`
-	CancelledCount int      `json:"cancelled_count"`
+	Orders   []Orders `json:"orders"`
+	Page     int      `json:"page"`
+	PageSize int      `json:"page_size"`
+	Count    int      `json:"count"`
+	// ActiveCount counts orders which are not completed, cancelled or returned
+	ActiveCount    int            `json:"active_count"`
+	FinishedCount  int            `json:"finished_count"`
+	CancelledCount int            `json:"cancelled_count"`
+	ReturnedCount  int            `json:"returned_count"`
+	StatusCounts   map[string]int `json:"status_counts"`
 }
 
 type OrderRequest struct {
 	Description string              `json:"description"`
-	Total       float64             `json:"total"`
 	Items       []OrderItemsRequest `json:"items"`
+	PromoCode   string              `json:"promo_code"`
 }
 type OrderUpdateRequest struct {
-	CustomerId  int                 `json:"customer_id" form:"customer_id"`
-	Description string              `json:"description" form:"description"`
-	Total       *float64            `json:"total" form:"total"`
-	Items       []OrderItemsRequest `json:"items" form:"items"`
+	CustomerId  int    `json:"customer_id" form:"customer_id"`
+	Description string `json:"description" form:"description"`
+	// Items replace lines of the order when they are sent, total is computed from them
+	Items []OrderItemsRequest `json:"items" form:"items"`
 }
 
 type AdminOrderFilter struct {
-	CustomerID int    `json:"customer_id"`
+	CustomerID int    `json:"customer_id" form:"customer_id"`
 	DateFrom   string `json:"date_from" form:"date_from"`
-	Status     *int   `json:"status" form:"status"`
+	Status     string `json:"status" form:"status"`
 	DateTo     string `json:"date_to" form:"date_to"`
 	Page       int    `json:"page" form:"page"`
 	PageSize   int    `json:"page_size" form:"page_size"`
 }
 
 type OrderApplicantFilter struct {
-	Phone    string `json:"phone" form:"phone"`
-	FullName string `json:"full_name" form:"full_name"`
-	Page     int    `json:"page" form:"page"`
-	PageSize int    `json:"page_size" form:"page_size"`
+	Phone         string `json:"phone" form:"phone"`
+	FullName      string `json:"full_name" form:"full_name"`
+	Status        string `json:"status" form:"status"`
+	ResponsibleID int    `json:"responsible_id" form:"responsible_id"`
+	// FollowUpTo selects leads with follow up planned before the time
+	FollowUpTo string `json:"follow_up_to" form:"follow_up_to"`
+	Page       int    `json:"page" form:"page"`
+	PageSize   int    `json:"page_size" form:"page_size"`
 }
 
 type OrderFilter struct {
-	Status   *int `json:"status" form:"status"`
-	Page     int  `json:"page" form:"page"`
-	PageSize int  `json:"page_size" form:"page_size"`
+	Status   string `json:"status" form:"status"`
+	Page     int    `json:"page" form:"page"`
+	PageSize int    `json:"page_size" form:"page_size"`
 }
 
 type OrderItemsRequest struct {
-	Price  float64 `json:"price" form:"price"`
-	Amount int     `json:"amount" form:"amount"`
-	ItemID int     `json:"item_id" form:"item_id"`
+	Amount int `json:"amount" form:"amount"`
+	ItemID int `json:"item_id" form:"item_id"`
+}
+
+// OrderStatusHistory keeps every status change, AdminID or CustomerID is the author.
+type OrderStatusHistory struct {
+	ID         int        `gorm:"type:bigint;primaryKey" json:"id"`
+	Order      *Orders    `gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE;" json:"-"`
+	OrderID    int        `gorm:"type:bigint not null;index" json:"order_id"`
+	FromStatus string     `gorm:"type:varchar(20);default:null" json:"from_status"`
+	ToStatus   string     `gorm:"type:varchar(20) not null" json:"to_status"`
+	Comment    string     `gorm:"type:varchar(500);default:null" json:"comment"`
+	Admin      *Admins    `gorm:"foreignKey:AdminID;constraint:OnDelete:SET NULL;" json:"-"`
+	AdminID    *int       `gorm:"type:bigint;default:null" json:"admin_id"`
+	CustomerID *int       `gorm:"type:bigint;default:null" json:"customer_id"`
+	CreatedAt  *time.Time `gorm:"type:timestamptz;index" json:"created_at"`
+}
+
+type OrderTransitionRequest struct {
+	Status  string `json:"status" binding:"required"`
+	Comment string `json:"comment"`
+}
+
+// Invoices number orders, the number is given when the first document of the order is printed.
+type Invoices struct {
+	ID        int        `gorm:"type:bigint;primaryKey" json:"id"`
+	Order     *Orders    `gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE;" json:"-"`
+	OrderID   int        `gorm:"type:bigint not null;uniqueIndex" json:"order_id"`
+	Seq       int        `gorm:"type:integer not null;uniqueIndex" json:"-"`
+	Number    string     `gorm:"type:varchar(50) not null;uniqueIndex" json:"number"`
+	CreatedAt *time.Time `gorm:"type:timestamptz;default:null" json:"created_at"`
 }
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// PasswordHistory keeps hashes of previous passwords of admins and customers.
+type PasswordHistory struct {
+	ID           int        `gorm:"type:bigint;primaryKey" json:"id"`
+	SubjectType  string     `gorm:"type:varchar(20) not null;index:idx_password_history_subject" json:"subject_type"`
+	SubjectID    int        `gorm:"type:bigint not null;index:idx_password_history_subject" json:"subject_id"`
+	PasswordHash string     `gorm:"type:varchar(255) not null" json:"-"`
+	CreatedAt    *time.Time `gorm:"type:timestamptz;default:null" json:"created_at"`
+}
+
+// PasswordResetTokens are single-use tokens issued after the reset code is checked, only hashes are stored.
+type PasswordResetTokens struct {
+	ID         int        `gorm:"type:bigint;primaryKey" json:"id"`
+	Customer   *Customer  `gorm:"foreignKey:CustomerID;constraint:OnDelete:CASCADE;" json:"-"`
+	CustomerID int        `gorm:"type:bigint not null;index" json:"customer_id"`
+	TokenHash  string     `gorm:"type:varchar(64) not null;unique" json:"-"`
+	ExpiresAt  *time.Time `gorm:"type:timestamptz" json:"expires_at"`
+	UsedAt     *time.Time `gorm:"type:timestamptz;default:null" json:"used_at"`
+	CreatedAt  *time.Time `gorm:"type:timestamptz;default:null" json:"created_at"`
+}
+
+type ForgotPasswordRequest struct {
+	Phone string `json:"phone" binding:"required" example:"998995117361"`
+}
+
+type ResetCodeRequest struct {
+	Phone string `json:"phone" binding:"required" example:"998995117361"`
+	Code  string `json:"code" binding:"required" example:"997361"`
+}
+
+type ResetTokenResponse struct {
+	ResetToken string     `json:"resetToken"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+}
+
+// ChangePasswordRequest changes password of the logged in customer, CurrentPassword may be empty only when
+// the customer has no password yet.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	Password        string `json:"password" binding:"required"`
+}
+
+type ResetPasswordRequest struct {
+	ResetToken string `json:"resetToken" binding:"required"`
+	Password   string `json:"password" binding:"required"`
+}
@@ -38,6 +38,10 @@ type Products struct {
 	DeletedID        *int       `gorm:"type:integer;default:null"  json:"-"`
 	Deleted          *Admins    `gorm:"foreignKey:DeletedID"       json:"deleted"`
 	DeletedAt        *time.Time `gorm:"type:timestamptz;default:null" json:"deleted_at"`
+	Availability     string     `gorm:"-" json:"availability,omitempty"`
+	// BasePrice is the retail price when Price is taken from the price list of the customer group
+	BasePrice   *float64     `gorm:"-" json:"base_price,omitempty"`
+	PriceBreaks []PriceBreak `gorm:"-" json:"price_breaks,omitempty"`
 }
 type Parameters struct {
 	ID        int        `gorm:"type:bigint not null;primaryKey" json:"id"`
@@ -0,0 +1,154 @@
+package models
+
+import "time"
+
+const (
+	PromotionPercent = "percent"
+	PromotionFixed   = "fixed"
+)
+
+// Targets of a promotion, promotion without targets discounts every product.
+const (
+	PromotionTargetCategory = "category"
+	PromotionTargetBrand    = "brand"
+	PromotionTargetProduct  = "product"
+)
+
+// Promotions discount orders placed with one of their codes. Percent Value is the share of matching
+// lines, fixed Value is taken from matching lines and never exceeds their sum. Usage limits count
+// redemptions of orders which are not cancelled.
+type Promotions struct {
+	ID               int                `gorm:"type:bigint;primaryKey" json:"id"`
+	Name             string             `gorm:"type:varchar(250) not null" json:"name"`
+	Type             string             `gorm:"type:varchar(20) not null" json:"type"`
+	Value            float64            `gorm:"type:decimal(16,2) not null" json:"value"`
+	MinOrderAmount   float64            `gorm:"type:decimal(16,2) not null;default:0" json:"min_order_amount"`
+	StartsAt         *time.Time         `gorm:"type:timestamptz;default:null" json:"starts_at"`
+	EndsAt           *time.Time         `gorm:"type:timestamptz;default:null" json:"ends_at"`
+	UsageLimit       *int               `gorm:"type:integer;default:null" json:"usage_limit"`
+	PerCustomerLimit *int               `gorm:"type:integer;default:null" json:"per_customer_limit"`
+	IsActive         *bool              `gorm:"type:boolean not null;default:true" json:"is_active"`
+	Targets          []PromotionTargets `gorm:"foreignKey:PromotionID" json:"targets"`
+	Codes            []PromoCodes       `gorm:"foreignKey:PromotionID" json:"codes,omitempty"`
+	Created          *Admins            `gorm:"foreignKey:CreatedID"       json:"created"`
+	CreatedID        *int               `gorm:"type:bigint;default:null"  json:"-"`
+	CreatedAt        *time.Time         `gorm:"type:timestamptz;default:null" json:"created_at"`
+	UpdatedID        *int               `gorm:"type:bigint;default:null"  json:"-"`
+	UpdatedAt        *time.Time         `gorm:"type:timestamptz;default:null" json:"updated_at"`
+	DeletedID        *int               `gorm:"type:bigint;default:null"  json:"-"`
+	DeletedAt        *time.Time         `gorm:"type:timestamptz;default:null" json:"deleted_at"`
+}
+
+// PromotionTargets limit promotion to categories with their subcategories, brands or products.
+type PromotionTargets struct {
+	Promotion   *Promotions `gorm:"foreignKey:PromotionID;constraint:OnDelete:CASCADE;" json:"-"`
+	PromotionID int         `gorm:"type:bigint;primaryKey" json:"-"`
+	Kind        string      `gorm:"type:varchar(20);primaryKey" json:"kind"`
+	TargetID    int         `gorm:"type:bigint;primaryKey" json:"target_id"`
+}
+
+// PromoCodes are entered by customers, code with UsageLimit 1 is single use. Codes are stored in upper case.
+type PromoCodes struct {
+	ID          int         `gorm:"type:bigint;primaryKey" json:"id"`
+	Promotion   *Promotions `gorm:"foreignKey:PromotionID;constraint:OnDelete:CASCADE;" json:"-"`
+	PromotionID int         `gorm:"type:bigint not null;index" json:"promotion_id"`
+	Code        string      `gorm:"type:varchar(50) not null;uniqueIndex" json:"code"`
+	UsageLimit  *int        `gorm:"type:integer;default:null" json:"usage_limit"`
+	IsActive    *bool       `gorm:"type:boolean not null;default:true" json:"is_active"`
+	CreatedAt   *time.Time  `gorm:"type:timestamptz;default:null" json:"created_at"`
+}
+
+// PromotionRedemptions are written when an order is placed with a code.
+type PromotionRedemptions struct {
+	ID          int         `gorm:"type:bigint;primaryKey" json:"id"`
+	Promotion   *Promotions `gorm:"foreignKey:PromotionID;constraint:OnDelete:CASCADE;" json:"-"`
+	PromotionID int         `gorm:"type:bigint not null;index" json:"promotion_id"`
+	PromoCode   *PromoCodes `gorm:"foreignKey:PromoCodeID;constraint:OnDelete:SET NULL;" json:"-"`
+	PromoCodeID *int        `gorm:"type:bigint;default:null;index" json:"promo_code_id"`
+	Order       *Orders     `gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE;" json:"-"`
+	OrderID     int         `gorm:"type:bigint not null;uniqueIndex" json:"order_id"`
+	CustomerID  int         `gorm:"type:bigint;default:null;index" json:"customer_id"`
+	Discount    float64     `gorm:"type:decimal(16,2) not null" json:"discount"`
+	CreatedAt   *time.Time  `gorm:"type:timestamptz;default:null;index" json:"created_at"`
+}
+
+type PromotionRequest struct {
+	Name             string                   `json:"name" binding:"required"`
+	Type             string                   `json:"type" binding:"required"`
+	Value            float64                  `json:"value" binding:"required"`
+	MinOrderAmount   float64                  `json:"min_order_amount"`
+	StartsAt         *time.Time               `json:"starts_at"`
+	EndsAt           *time.Time               `json:"ends_at"`
+	UsageLimit       *int                     `json:"usage_limit"`
+	PerCustomerLimit *int                     `json:"per_customer_limit"`
+	IsActive         *bool                    `json:"is_active"`
+	Targets          []PromotionTargetRequest `json:"targets"`
+}
+
+type PromotionTargetRequest struct {
+	Kind     string `json:"kind" binding:"required"`
+	TargetID int    `json:"target_id" binding:"required"`
+}
+
+// PromoCodeRequest creates one code, or Count single use codes with Prefix when Code is empty.
+type PromoCodeRequest struct {
+	Code       string `json:"code"`
+	UsageLimit *int   `json:"usage_limit"`
+	Count      int    `json:"count"`
+	Prefix     string `json:"prefix"`
+}
+
+type PromoCodeUpdateRequest struct {
+	UsageLimit *int  `json:"usage_limit"`
+	IsActive   *bool `json:"is_active"`
+}
+
+type PromotionFilter struct {
+	Name     string `json:"name" form:"name"`
+	IsActive *bool  `json:"is_active" form:"is_active"`
+	Page     int    `json:"page" form:"page"`
+	PageSize int    `json:"page_size" form:"page_size"`
+}
+
+type PromotionListResponse struct {
+	Page       int          `json:"page"`
+	PageSize   int          `json:"page_size"`
+	Count      int          `json:"count"`
+	Promotions []Promotions `json:"promotions"`
+}
+
+type PromoCodeApplyRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type PromotionReportFilter struct {
+	DateFrom string `json:"date_from" form:"date_from"`
+	DateTo   string `json:"date_to" form:"date_to"`
+	Page     int    `json:"page" form:"page"`
+	PageSize int    `json:"page_size" form:"page_size"`
+}
+
+// PromotionRedemptionRow is a redemption with its order, totals of the report skip cancelled orders.
+type PromotionRedemptionRow struct {
+	ID            int        `json:"id"`
+	Code          string     `json:"code"`
+	OrderID       int        `json:"order_id"`
+	OrderStatus   string     `json:"order_status"`
+	OrderTotal    float64    `json:"order_total"`
+	CustomerID    int        `json:"customer_id"`
+	CustomerName  string     `json:"customer_name"`
+	CustomerPhone string     `json:"customer_phone"`
+	Discount      float64    `json:"discount"`
+	CreatedAt     *time.Time `json:"created_at"`
+}
+
+type PromotionReportResponse struct {
+	Page          int                      `json:"page"`
+	PageSize      int                      `json:"page_size"`
+	Count         int                      `json:"count"`
+	Redemptions   int                      `json:"redemptions"`
+	Customers     int                      `json:"customers"`
+	DiscountTotal float64                  `json:"discount_total"`
+	OrdersTotal   float64                  `json:"orders_total"`
+	Items         []PromotionRedemptionRow `json:"items"`
+}
@@ -2,19 +2,31 @@ package models
 
 import "time"
 
+// Data scopes of roles, they limit customers, orders and applicants visible to admins of the role.
+const (
+	DataScopeAll  = "all"
+	DataScopeOwn  = "own"
+	DataScopeTeam = "team"
+)
+
 type Roles struct {
-	ID        int        `gorm:"type:bigint;primaryKey" json:"id"`
-	Key       string     `gorm:"type:varchar(255) not null;unique"               json:"key"`
-	Title     string     `gorm:"type:varchar(255) not null"                      json:"title"`
-	Comment   string     `gorm:"type:text;default:null"                          json:"comment"`
-	IsActive  bool       `gorm:"type:boolean;default:true;index"                json:"is_active"`
-	IsDeleted bool       `gorm:"type:boolean;default:false;index"               json:"is_deleted"`
-	CreatedID *int       `gorm:"type:bigint;default:null;index"  json:"-"`
-	Created   *Admins    `gorm:"foreignKey:CreatedID"       json:"created"`
-	CreatedAt *time.Time `gorm:"type:timestamptz;default:null;index" json:"created_at"`
-	Updated   *Admins    `gorm:"foreignKey:UpdatedID"       json:"updated"`
-	UpdatedID *int       `gorm:"type:bigint;default:null"  json:"-"`
-	UpdatedAt *time.Time `gorm:"type:timestamptz;default:null" json:"updated_at"`
+	ID               int        `gorm:"type:bigint;primaryKey" json:"id"`
+	Key              string     `gorm:"type:varchar(255) not null;unique"               json:"key"`
+	Title            string     `gorm:"type:varchar(255) not null"                      json:"title"`
+	Comment          string     `gorm:"type:text;default:null"                          json:"comment"`
+	IsActive         bool       `gorm:"type:boolean;default:true;index"                json:"is_active"`
+	IsDeleted        bool       `gorm:"type:boolean;default:false;index"               json:"is_deleted"`
+	CreatedID        *int       `gorm:"type:bigint;default:null;index"  json:"-"`
+	Created          *Admins    `gorm:"foreignKey:CreatedID"       json:"created"`
+	CreatedAt        *time.Time `gorm:"type:timestamptz;default:null;index" json:"created_at"`
+	Updated          *Admins    `gorm:"foreignKey:UpdatedID"       json:"updated"`
+	UpdatedID        *int       `gorm:"type:bigint;default:null"  json:"-"`
+	UpdatedAt        *time.Time `gorm:"type:timestamptz;default:null" json:"updated_at"`
+	RequireTwoFactor bool       `gorm:"type:boolean;default:false" json:"require_two_factor"`
+	// ParentID is the role whose module items are inherited
+	Parent    *Roles `gorm:"foreignKey:ParentID;constraint:OnDelete:SET NULL;" json:"-"`
+	ParentID  *int   `gorm:"type:bigint;default:null;index" json:"parent_id"`
+	DataScope string `gorm:"type:varchar(20);default:'all'" json:"data_scope"`
 }
 type RoleItems struct {
 	ID            int          `gorm:"type:bigint;primaryKey" json:"id"`
@@ -36,10 +48,30 @@ type RoleItemRequest struct {
 }
 
 type RolesRequest struct {
-	Title    string `json:"title" form:"title"`
-	Key      string `json:"key" form:"key"`
-	Comment  string `json:"comment" form:"comment"`
-	IsActive bool   `json:"is_active" form:"is_active"`
+	Title            string `json:"title" form:"title"`
+	Key              string `json:"key" form:"key"`
+	Comment          string `json:"comment" form:"comment"`
+	IsActive         bool   `json:"is_active" form:"is_active"`
+	RequireTwoFactor bool   `json:"require_two_factor" form:"require_two_factor"`
+	ParentID         *int   `json:"parent_id" form:"parent_id"`
+	// DataScope is one of all, own, team. Empty means all
+	DataScope string `json:"data_scope" form:"data_scope"`
+	// Template fills module items of the new role, it is used only on create
+	Template string `json:"template" form:"template"`
+}
+
+type RoleCloneRequest struct {
+	Title   string `json:"title" form:"title"`
+	Key     string `json:"key" form:"key"`
+	Comment string `json:"comment" form:"comment"`
+}
+
+// RoleTemplate is a built-in set of modules, every module item of the modules is granted.
+type RoleTemplate struct {
+	Key            string   `json:"key"`
+	Title          string   `json:"title"`
+	Modules        []string `json:"modules"`
+	ModuleItemKeys []string `json:"module_item_keys"`
 }
 
 type RoleFilter struct {
@@ -64,4 +96,19 @@ type UpdateRoleItemsList struct {
 type RoleWithModuleItems struct {
 	Roles
 	ModuleItemKeys []string `json:"module_item_keys"`
+	// InheritedKeys are granted by parent roles
+	InheritedKeys []string `json:"inherited_keys"`
+}
+
+type AdminPermission struct {
+	Key      string `json:"key"`
+	EndPoint string `json:"end_point"`
+	Method   string `json:"method"`
+}
+
+type PermissionDenied struct {
+	Message    string `json:"message"`
+	Permission string `json:"permission"`
+	Method     string `json:"method"`
+	EndPoint   string `json:"end_point"`
 }
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+const (
+	SmsStatusPending = "pending"
+	SmsStatusSent    = "sent"
+	SmsStatusFailed  = "failed"
+)
+
+// SmsMessages is the delivery log of sent sms, secret codes are masked in Text.
+type SmsMessages struct {
+	ID                int    `gorm:"type:bigint;primaryKey" json:"id"`
+	Recipient         string `gorm:"type:varchar(20);index" json:"recipient"`
+	Text              string `gorm:"type:text" json:"text"`
+	Purpose           string `gorm:"type:varchar(50);index" json:"purpose"`
+	Provider          string `gorm:"type:varchar(50)" json:"provider"`
+	ProviderMessageID string `gorm:"type:varchar(100);default:null" json:"provider_message_id"`
+	Status            string `gorm:"type:varchar(20);index" json:"status"`
+	Attempts          int    `gorm:"type:integer;default:0" json:"attempts"`
+	// HasSecret is set when a code is masked in Text, such message can not be sent again
+	HasSecret bool       `gorm:"type:boolean;default:false" json:"has_secret"`
+	LastError string     `gorm:"type:text;default:null" json:"last_error"`
+	SentAt    *time.Time `gorm:"type:timestamptz;default:null" json:"sent_at"`
+	CreatedAt *time.Time `gorm:"type:timestamptz;default:null;index" json:"created_at"`
+	UpdatedAt *time.Time `gorm:"type:timestamptz;default:null" json:"updated_at"`
+}
+
+type SmsMessageFilter struct {
+	Recipient string `json:"recipient" form:"recipient"`
+	Status    string `json:"status" form:"status"`
+	Purpose   string `json:"purpose" form:"purpose"`
+	Page      int    `json:"page" form:"page"`
+	PageSize  int    `json:"page_size" form:"page_size"`
+}
+
+type SmsMessageResponse struct {
+	Page     int           `json:"page"`
+	PageSize int           `json:"page_size"`
+	Count    int           `json:"count"`
+	Messages []SmsMessages `json:"messages"`
+}
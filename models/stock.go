@@ -0,0 +1,107 @@
+package models
+
+import "time"
+
+const (
+	StockMovementReceipt     = "receipt"
+	StockMovementSale        = "sale"
+	StockMovementAdjustment  = "adjustment"
+	StockMovementReturn      = "return"
+	StockMovementReserve     = "reserve"
+	StockMovementRelease     = "release"
+	StockMovementTransferIn  = "transfer_in"
+	StockMovementTransferOut = "transfer_out"
+)
+
+const (
+	AvailabilityInStock    = "in_stock"
+	AvailabilityLowStock   = "low_stock"
+	AvailabilityOutOfStock = "out_of_stock"
+)
+
+// ProductStocks keeps quantity of the product in all warehouses, Reserved is taken by placed orders which are
+// not completed yet.
+type ProductStocks struct {
+	Product   *Products  `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE;" json:"-"`
+	ProductID int        `gorm:"type:bigint;primaryKey" json:"product_id"`
+	OnHand    int        `gorm:"type:integer not null;default:0" json:"on_hand"`
+	Reserved  int        `gorm:"type:integer not null;default:0" json:"reserved"`
+	UpdatedAt *time.Time `gorm:"type:timestamptz;default:null" json:"updated_at"`
+}
+
+// StockMovements is the ledger of stock changes. Quantity is signed, OnHand and Reserved are the values of the
+// warehouse after the movement.
+type StockMovements struct {
+	ID          int             `gorm:"type:bigint;primaryKey" json:"id"`
+	Product     *Products       `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE;" json:"-"`
+	ProductID   int             `gorm:"type:bigint not null;index" json:"product_id"`
+	Warehouse   *Warehouses     `gorm:"foreignKey:WarehouseID;constraint:OnDelete:SET NULL;" json:"-"`
+	WarehouseID *int            `gorm:"type:bigint;default:null;index" json:"warehouse_id"`
+	Type        string          `gorm:"type:varchar(20) not null;index" json:"type"`
+	Quantity    int             `gorm:"type:integer not null" json:"quantity"`
+	OnHand      int             `gorm:"type:integer not null" json:"on_hand"`
+	Reserved    int             `gorm:"type:integer not null" json:"reserved"`
+	Order       *Orders         `gorm:"foreignKey:OrderID;constraint:OnDelete:SET NULL;" json:"-"`
+	OrderID     *int            `gorm:"type:bigint;default:null;index" json:"order_id"`
+	Transfer    *StockTransfers `gorm:"foreignKey:TransferID;constraint:OnDelete:SET NULL;" json:"-"`
+	TransferID  *int            `gorm:"type:bigint;default:null;index" json:"transfer_id"`
+	Admin       *Admins         `gorm:"foreignKey:AdminID;constraint:OnDelete:SET NULL;" json:"admin"`
+	AdminID     *int            `gorm:"type:bigint;default:null;index" json:"admin_id"`
+	Reason      string          `gorm:"type:varchar(500);default:null" json:"reason"`
+	CreatedAt   *time.Time      `gorm:"type:timestamptz;default:null;index" json:"created_at"`
+}
+
+// StockMovementRequest is a manual movement, quantity of adjustment may be negative.
+type StockMovementRequest struct {
+	ProductID   int    `json:"product_id" binding:"required"`
+	WarehouseID int    `json:"warehouse_id" binding:"required"`
+	Type        string `json:"type" binding:"required"`
+	Quantity    int    `json:"quantity" binding:"required"`
+	Reason      string `json:"reason"`
+}
+
+type ProductStockResponse struct {
+	ProductID  int                      `json:"product_id"`
+	NameUz     string                   `json:"name_uz"`
+	NameRu     string                   `json:"name_ru"`
+	NameEn     string                   `json:"name_en"`
+	OnHand     int                      `json:"on_hand"`
+	Reserved   int                      `json:"reserved"`
+	Available  int                      `json:"available"`
+	UpdatedAt  *time.Time               `json:"updated_at"`
+	Warehouses []WarehouseStockResponse `json:"warehouses,omitempty" gorm:"-"`
+}
+
+type StockFilter struct {
+	ProductID int    `json:"product_id" form:"product_id"`
+	Name      string `json:"name" form:"name"`
+	LowStock  bool   `json:"low_stock" form:"low_stock"`
+	Page      int    `json:"page" form:"page"`
+	PageSize  int    `json:"page_size" form:"page_size"`
+}
+
+type StockListResponse struct {
+	Page     int                    `json:"page"`
+	PageSize int                    `json:"page_size"`
+	Count    int                    `json:"count"`
+	Stocks   []ProductStockResponse `json:"stocks"`
+}
+
+type StockMovementFilter struct {
+	ProductID   int    `json:"product_id" form:"product_id"`
+	WarehouseID int    `json:"warehouse_id" form:"warehouse_id"`
+	OrderID     int    `json:"order_id" form:"order_id"`
+	AdminID     int    `json:"admin_id" form:"admin_id"`
+	Type        string `json:"type" form:"type"`
+	DateFrom    string `json:"date_from" form:"date_from"`
+	DateTo      string `json:"date_to" form:"date_to"`
+	Page        int    `json:"page" form:"page"`
+	PageSize    int    `json:"page_size" form:"page_size"`
+}
+
+type StockMovementResponse struct {
+	Page      int              `json:"page"`
+	PageSize  int              `json:"page_size"`
+	Count     int              `json:"count"`
+	Movements []StockMovements `json:"movements"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+const (
+	TokenSubjectAdmin    = "admin"
+	TokenSubjectCustomer = "customer"
+)
+
+// RefreshTokens keeps issued refresh tokens. Tokens rotated from one login share a family,
+// presenting an already used token revokes the whole family.
+type RefreshTokens struct {
+	ID           int        `gorm:"type:bigint;primaryKey" json:"id"`
+	TokenID      string     `gorm:"type:varchar(64) not null;unique" json:"-"`
+	Family       string     `gorm:"type:varchar(64) not null;index" json:"family"`
+	SubjectType  string     `gorm:"type:varchar(20) not null;index:idx_refresh_tokens_subject" json:"subject_type"`
+	SubjectID    int        `gorm:"type:bigint not null;index:idx_refresh_tokens_subject" json:"subject_id"`
+	UserAgent    string     `gorm:"type:varchar(500)" json:"user_agent"`
+	IP           string     `gorm:"type:varchar(100)" json:"ip"`
+	ReplacedByID *int       `gorm:"type:bigint;default:null" json:"replaced_by_id"`
+	ExpiresAt    *time.Time `gorm:"type:timestamptz not null" json:"expires_at"`
+	UsedAt       *time.Time `gorm:"type:timestamptz;default:null" json:"used_at"`
+	RevokedAt    *time.Time `gorm:"type:timestamptz;default:null;index" json:"revoked_at"`
+	CreatedAt    *time.Time `gorm:"type:timestamptz;default:null" json:"created_at"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
@@ -3,29 +3,56 @@ package models
 import "time"
 
 type Admins struct {
+	ID          int        `gorm:"type:bigint;primaryKey" json:"id"`
+	Username    string     `gorm:"type:varchar(255);unique" json:"username"`
+	Password    string     `gorm:"type:varchar(255)" json:"-"`
+	Role        *Roles     `gorm:"foreignKey:RoleID" json:"role"`
+	RoleID      int        `gorm:"type:bigint;default:null" json:"role_id"`
+	CreatedAt   *time.Time `gorm:"type:timestamptz;default:null" json:"created_at"`
+	UpdatedAt   *time.Time `gorm:"type:timestamptz;default:null" json:"updated_at"`
+	LastVisit   *time.Time `gorm:"type:timestamptz;default:null" json:"last_visit"`
+	IsActive    *bool      `gorm:"type:boolean;default:true;index" json:"is_active"`
+	IsSuperuser *bool      `gorm:"type:boolean;default:false" json:"is_superuser"`
+	DeletedAt   *time.Time `gorm:"type:timestamptz;default:null" json:"deleted_at"`
+	TotpSecret  string     `gorm:"type:varchar(100);default:null" json:"-"`
+	TotpEnabled bool       `gorm:"type:boolean;default:false" json:"totp_enabled"`
+	TotpStep    int64      `gorm:"type:bigint;default:0" json:"-"`
+	// FailedLogins counts failed logins in a row, LockedUntil is set when it reaches the threshold
+	FailedLogins int        `gorm:"type:integer;default:0" json:"failed_logins"`
+	LockedUntil  *time.Time `gorm:"type:timestamptz;default:null" json:"locked_until"`
+	// Team groups admins for roles with team data scope
+	Team string `gorm:"type:varchar(100);default:null;index" json:"team"`
+}
+
+// AdminRecoveryCodes are one-time codes for login when authenticator app is lost, only hashes are stored.
+type AdminRecoveryCodes struct {
 	ID        int        `gorm:"type:bigint;primaryKey" json:"id"`
-	Username  string     `gorm:"type:varchar(255);unique" json:"username"`
-	Password  string     `gorm:"type:varchar(255)" json:"-"`
-	Role      *Roles     `gorm:"foreignKey:RoleID" json:"role"`
-	RoleID    int        `gorm:"type:bigint;default:null" json:"role_id"`
+	Admin     *Admins    `gorm:"foreignKey:AdminID;constraint:OnDelete:CASCADE;" json:"-"`
+	AdminID   int        `gorm:"type:bigint not null;index" json:"admin_id"`
+	CodeHash  string     `gorm:"type:varchar(255) not null" json:"-"`
+	UsedAt    *time.Time `gorm:"type:timestamptz;default:null" json:"used_at"`
 	CreatedAt *time.Time `gorm:"type:timestamptz;default:null" json:"created_at"`
-	UpdatedAt *time.Time `gorm:"type:timestamptz;default:null" json:"updated_at"`
-	LastVisit *time.Time `gorm:"type:timestamptz;default:null" json:"last_visit"`
-	IsActive  *bool      `gorm:"type:boolean;default:true;index" json:"is_active"`
-	DeletedAt *time.Time `gorm:"type:timestamptz;default:null" json:"deleted_at"`
 }
 type AdminResponse struct {
 	Admins
 	ModuleItemKeys []string `json:"moduleItemKeys"`
+	// Permissions are effective permissions with items inherited from parent roles
+	Permissions []AdminPermission `json:"permissions"`
 }
 type AdminMetadata struct {
-	Id int
+	Id          int
+	RoleID      int
+	IsSuperuser bool
+	// ApiKeyID is set when the request is authenticated by api key of the admin
+	ApiKeyID int
 }
 type AdminsCreateRequest struct {
-	Username string `json:"username" form:"username"`
-	Password string `json:"password" form:"password"`
-	RoleID   int    `json:"role_id" form:"role_id"`
-	IsActive *bool  `json:"is_active" form:"is_active"`
+	Username    string  `json:"username" form:"username"`
+	Password    string  `json:"password" form:"password"`
+	RoleID      int     `json:"role_id" form:"role_id"`
+	IsActive    *bool   `json:"is_active" form:"is_active"`
+	IsSuperuser *bool   `json:"is_superuser" form:"is_superuser"`
+	Team        *string `json:"team" form:"team"`
 }
 type AdminsRequest struct {
 	Username string `json:"username" form:"username"`
@@ -41,7 +68,42 @@ type AccessToken struct {
 	AccessToken string `json:"accessToken"`
 }
 
+type TwoFactorChallenge struct {
+	ChallengeToken     string `json:"challengeToken"`
+	TwoFactorRequired  bool   `json:"twoFactorRequired"`
+	EnrollmentRequired bool   `json:"enrollmentRequired"`
+}
+
+type TwoFactorLogin struct {
+	ChallengeToken string `json:"challengeToken" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+type TwoFactorEnroll struct {
+	ChallengeToken string `json:"challengeToken" binding:"required"`
+}
+
+type TwoFactorCode struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TwoFactorSetup struct {
+	Secret string `json:"secret"`
+	Uri    string `json:"uri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
 type TokenResponse struct {
 	AccessToken    string   `json:"accessToken"`
+	RefreshToken   string   `json:"refreshToken"`
 	ModuleItemKeys []string `json:"moduleItemKeys"`
+	RecoveryCodes  []string `json:"recoveryCodes,omitempty"`
+}
+
+type AssignRequest struct {
+	// ResponsibleID is the admin to assign, null removes the assignment
+	ResponsibleID *int `json:"responsible_id"`
 }
@@ -29,18 +29,22 @@ type Vacancy struct {
 	Updated          *Admins    `gorm:"foreignKey:UpdatedID"       json:"updated"`
 	UpdatedID        *int       `gorm:"type:bigint;default:null"  json:"-"`
 	UpdatedAt        *time.Time `gorm:"type:timestamptz;default:null" json:"updated_at"`
+	Responsible      *Admins    `gorm:"foreignKey:ResponsibleID;constraint:OnDelete:SET NULL;" json:"-"`
+	ResponsibleID    *int       `gorm:"type:bigint;default:null;index" json:"responsible_id"`
 }
 
 type Applicant struct {
-	ID          int        `gorm:"type:bigint;primaryKey" json:"id"`
-	Name        string     `gorm:"type:varchar(250)" json:"name"`
-	Phone       string     `gorm:"type:varchar(250)" json:"phone"`
-	Status      int8       `gorm:"type:smallint;default:0" json:"status"`
-	Vacancy     *Vacancy   `gorm:"foreignKey:VacancyID;constraint:OnDelete:CASCADE;" json:"vacancy"`
-	VacancyID   *int       `gorm:"type:bigint;default:null" json:"vacancy_id"`
-	Description string     `gorm:"type:varchar(500)" json:"description"`
-	Resume      string     `gorm:"type:varchar(300)" json:"resume"`
-	CreatedAt   *time.Time `gorm:"type:timestamptz;default:null" json:"created_at"`
+	ID            int        `gorm:"type:bigint;primaryKey" json:"id"`
+	Name          string     `gorm:"type:varchar(250)" json:"name"`
+	Phone         string     `gorm:"type:varchar(250)" json:"phone"`
+	Status        int8       `gorm:"type:smallint;default:0" json:"status"`
+	Vacancy       *Vacancy   `gorm:"foreignKey:VacancyID;constraint:OnDelete:CASCADE;" json:"vacancy"`
+	VacancyID     *int       `gorm:"type:bigint;default:null" json:"vacancy_id"`
+	Description   string     `gorm:"type:varchar(500)" json:"description"`
+	Resume        string     `gorm:"type:varchar(300)" json:"resume"`
+	CreatedAt     *time.Time `gorm:"type:timestamptz;default:null" json:"created_at"`
+	Responsible   *Admins    `gorm:"foreignKey:ResponsibleID;constraint:OnDelete:SET NULL;" json:"-"`
+	ResponsibleID *int       `gorm:"type:bigint;default:null;index" json:"responsible_id"`
 }
 type ApplicantFilter struct {
 	Status    *int `form:"status" json:"status"`
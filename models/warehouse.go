@@ -0,0 +1,115 @@
+package models
+
+import "time"
+
+// Warehouses hold stock, warehouse of a branch is linked to its contact. Orders are reserved from
+// active warehouses with lower Priority first.
+type Warehouses struct {
+	ID        int        `gorm:"type:bigint;primaryKey" json:"id"`
+	Name      string     `gorm:"type:varchar(250) not null" json:"name"`
+	Contact   *Contact   `gorm:"foreignKey:ContactID;constraint:OnDelete:SET NULL;" json:"contact"`
+	ContactID *int       `gorm:"type:bigint;default:null;index" json:"contact_id"`
+	Priority  int        `gorm:"type:integer not null;default:0" json:"priority"`
+	IsActive  *bool      `gorm:"type:boolean not null;default:true" json:"is_active"`
+	Created   *Admins    `gorm:"foreignKey:CreatedID"       json:"created"`
+	CreatedID *int       `gorm:"type:bigint;default:null"  json:"-"`
+	CreatedAt *time.Time `gorm:"type:timestamptz;default:null" json:"created_at"`
+	UpdatedID *int       `gorm:"type:bigint;default:null"  json:"-"`
+	UpdatedAt *time.Time `gorm:"type:timestamptz;default:null" json:"updated_at"`
+	DeletedID *int       `gorm:"type:bigint;default:null"  json:"-"`
+	DeletedAt *time.Time `gorm:"type:timestamptz;default:null" json:"deleted_at"`
+}
+
+type WarehouseStocks struct {
+	Warehouse   *Warehouses `gorm:"foreignKey:WarehouseID;constraint:OnDelete:CASCADE;" json:"-"`
+	WarehouseID int         `gorm:"type:bigint;primaryKey" json:"warehouse_id"`
+	Product     *Products   `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE;" json:"-"`
+	ProductID   int         `gorm:"type:bigint;primaryKey;index" json:"product_id"`
+	OnHand      int         `gorm:"type:integer not null;default:0" json:"on_hand"`
+	Reserved    int         `gorm:"type:integer not null;default:0" json:"reserved"`
+	UpdatedAt   *time.Time  `gorm:"type:timestamptz;default:null" json:"updated_at"`
+}
+
+// StockTransfers move products between warehouses, a transfer is applied when it is created.
+type StockTransfers struct {
+	ID              int                  `gorm:"type:bigint;primaryKey" json:"id"`
+	FromWarehouse   *Warehouses          `gorm:"foreignKey:FromWarehouseID" json:"from_warehouse"`
+	FromWarehouseID int                  `gorm:"type:bigint not null;index" json:"from_warehouse_id"`
+	ToWarehouse     *Warehouses          `gorm:"foreignKey:ToWarehouseID" json:"to_warehouse"`
+	ToWarehouseID   int                  `gorm:"type:bigint not null;index" json:"to_warehouse_id"`
+	Comment         string               `gorm:"type:varchar(500);default:null" json:"comment"`
+	Items           []StockTransferItems `gorm:"foreignKey:TransferID" json:"items"`
+	Admin           *Admins              `gorm:"foreignKey:AdminID;constraint:OnDelete:SET NULL;" json:"admin"`
+	AdminID         *int                 `gorm:"type:bigint;default:null" json:"admin_id"`
+	CreatedAt       *time.Time           `gorm:"type:timestamptz;default:null;index" json:"created_at"`
+}
+
+type StockTransferItems struct {
+	ID         int             `gorm:"type:bigint;primaryKey" json:"id"`
+	Transfer   *StockTransfers `gorm:"foreignKey:TransferID;constraint:OnDelete:CASCADE;" json:"-"`
+	TransferID int             `gorm:"type:bigint not null;index" json:"transfer_id"`
+	Product    *Products       `gorm:"foreignKey:ProductID" json:"-"`
+	ProductID  int             `gorm:"type:bigint not null" json:"product_id"`
+	Quantity   int             `gorm:"type:integer not null" json:"quantity"`
+}
+
+type WarehouseRequest struct {
+	Name      string `json:"name" binding:"required"`
+	ContactID *int   `json:"contact_id"`
+	Priority  int    `json:"priority"`
+	IsActive  *bool  `json:"is_active"`
+}
+
+type WarehouseFilter struct {
+	ContactID   int   `json:"contact_id" form:"contact_id"`
+	IsActive    *bool `json:"is_active" form:"is_active"`
+	WithDeleted bool  `json:"with_deleted" form:"with_deleted"`
+}
+
+type WarehouseStockResponse struct {
+	WarehouseID int        `json:"warehouse_id"`
+	Name        string     `json:"name"`
+	ContactID   *int       `json:"contact_id"`
+	OnHand      int        `json:"on_hand"`
+	Reserved    int        `json:"reserved"`
+	Available   int        `json:"available"`
+	UpdatedAt   *time.Time `json:"updated_at"`
+}
+
+type StockTransferRequest struct {
+	FromWarehouseID int                         `json:"from_warehouse_id" binding:"required"`
+	ToWarehouseID   int                         `json:"to_warehouse_id" binding:"required"`
+	Comment         string                      `json:"comment"`
+	Items           []StockTransferItemsRequest `json:"items" binding:"required"`
+}
+
+type StockTransferItemsRequest struct {
+	ProductID int `json:"product_id" binding:"required"`
+	Quantity  int `json:"quantity" binding:"required"`
+}
+
+type StockTransferFilter struct {
+	WarehouseID int    `json:"warehouse_id" form:"warehouse_id"`
+	ProductID   int    `json:"product_id" form:"product_id"`
+	DateFrom    string `json:"date_from" form:"date_from"`
+	DateTo      string `json:"date_to" form:"date_to"`
+	Page        int    `json:"page" form:"page"`
+	PageSize    int    `json:"page_size" form:"page_size"`
+}
+
+type StockTransferResponse struct {
+	Page      int              `json:"page"`
+	PageSize  int              `json:"page_size"`
+	Count     int              `json:"count"`
+	Transfers []StockTransfers `json:"transfers"`
+}
+
+// BranchAvailability is a branch having the product, quantities are not shown to customers.
+type BranchAvailability struct {
+	Contact      *Contact `json:"contact"`
+	Availability string   `json:"availability"`
+}
+
+type OrderWarehouseRequest struct {
+	WarehouseID int `json:"warehouse_id" binding:"required"`
+}
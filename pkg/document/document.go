@@ -0,0 +1,210 @@
+// Package document renders order documents to PDF. DejaVu fonts are embedded so cyrillic and uzbek
+// texts are printed without fonts installed on the server, see https://dejavu-fonts.github.io/License.html
+package document
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+const (
+	fontFamily = "DejaVu"
+	lineHeight = 5.5
+	pageMargin = 15
+)
+
+var (
+	//go:embed fonts/DejaVuSansCondensed.ttf
+	regularFont []byte
+	//go:embed fonts/DejaVuSansCondensed-Bold.ttf
+	boldFont []byte
+)
+
+// Party is the supplier or the customer of the order.
+type Party struct {
+	Name    string
+	Address string
+	Phone   string
+	Email   string
+}
+
+type Line struct {
+	Name   string
+	Amount int
+	Price  float64
+	Total  float64
+}
+
+type Order struct {
+	// Number is the invoice number, delivery note is printed with the same number
+	Number   string
+	OrderID  int
+	Date     time.Time
+	Supplier Party
+	Customer Party
+	Comment  string
+	Lines    []Line
+	// Subtotal is the sum of the lines, Discount of the promotion is taken from it and Total is left
+	Subtotal float64
+	Discount float64
+	Total    float64
+}
+
+// column of the lines table, width is in millimetres.
+type column struct {
+	title string
+	width float64
+	align string
+	value func(i int, line Line) string
+}
+
+// Invoice writes proforma invoice of the order in the language.
+func Invoice(w io.Writer, lang string, order Order) error {
+	l := labelsOf(lang)
+	pdf := newPdf()
+	writeHeader(pdf, l, l.invoice, order)
+	writeLines(pdf, order, []column{
+		{l.no, 10, "C", func(i int, _ Line) string { return fmt.Sprint(i + 1) }},
+		{l.product, 95, "L", func(_ int, line Line) string { return line.Name }},
+		{l.quantity, 20, "R", func(_ int, line Line) string { return fmt.Sprint(line.Amount) }},
+		{l.price, 27.5, "R", func(_ int, line Line) string { return formatMoney(line.Price) }},
+		{l.sum, 27.5, "R", func(_ int, line Line) string { return formatMoney(line.Total) }},
+	})
+	if order.Discount > 0 {
+		pdf.SetFont(fontFamily, "", 10)
+		pdf.CellFormat(0, 7, fmt.Sprintf("%s: %s", l.subtotal, formatMoney(order.Subtotal)), "", 1, "R", false, 0, "")
+		pdf.CellFormat(0, 7, fmt.Sprintf("%s: -%s", l.discount, formatMoney(order.Discount)), "", 1, "R", false, 0, "")
+	}
+	pdf.SetFont(fontFamily, "B", 11)
+	pdf.CellFormat(0, 8, fmt.Sprintf("%s: %s", l.total, formatMoney(order.Total)), "", 1, "R", false, 0, "")
+	writeComment(pdf, l, order)
+	return pdf.Output(w)
+}
+
+// DeliveryNote writes delivery note of the order in the language, prices are not printed.
+func DeliveryNote(w io.Writer, lang string, order Order) error {
+	l := labelsOf(lang)
+	pdf := newPdf()
+	writeHeader(pdf, l, l.deliveryNote, order)
+	var quantity int
+	for _, line := range order.Lines {
+		quantity += line.Amount
+	}
+	writeLines(pdf, order, []column{
+		{l.no, 10, "C", func(i int, _ Line) string { return fmt.Sprint(i + 1) }},
+		{l.product, 140, "L", func(_ int, line Line) string { return line.Name }},
+		{l.quantity, 30, "R", func(_ int, line Line) string { return fmt.Sprint(line.Amount) }},
+	})
+	pdf.SetFont(fontFamily, "B", 11)
+	pdf.CellFormat(0, 8, fmt.Sprintf("%s: %d", l.total, quantity), "", 1, "R", false, 0, "")
+	writeComment(pdf, l, order)
+	pdf.Ln(12)
+	pdf.SetFont(fontFamily, "", 10)
+	pdf.CellFormat(90, lineHeight, l.issued+": ____________________", "", 0, "L", false, 0, "")
+	pdf.CellFormat(0, lineHeight, l.received+": ____________________", "", 1, "L", false, 0, "")
+	return pdf.Output(w)
+}
+
+func newPdf() *gofpdf.Fpdf {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes(fontFamily, "", regularFont)
+	pdf.AddUTF8FontFromBytes(fontFamily, "B", boldFont)
+	pdf.SetMargins(pageMargin, pageMargin, pageMargin)
+	pdf.SetAutoPageBreak(true, pageMargin)
+	pdf.AddPage()
+	return pdf
+}
+
+func writeHeader(pdf *gofpdf.Fpdf, l labels, title string, order Order) {
+	writeParty(pdf, l, l.supplier, order.Supplier)
+	pdf.Ln(6)
+	pdf.SetFont(fontFamily, "B", 15)
+	pdf.CellFormat(0, 9, fmt.Sprintf("%s № %s %s %s", title, order.Number, l.dated, order.Date.Format("02.01.2006")),
+		"", 1, "C", false, 0, "")
+	pdf.SetFont(fontFamily, "", 10)
+	pdf.CellFormat(0, lineHeight, fmt.Sprintf("%s № %d", l.order, order.OrderID), "", 1, "C", false, 0, "")
+	pdf.Ln(6)
+	writeParty(pdf, l, l.customer, order.Customer)
+	pdf.Ln(4)
+}
+
+func writeParty(pdf *gofpdf.Fpdf, l labels, role string, party Party) {
+	pdf.SetFont(fontFamily, "B", 11)
+	pdf.CellFormat(0, lineHeight+1, fmt.Sprintf("%s: %s", role, party.Name), "", 1, "L", false, 0, "")
+	pdf.SetFont(fontFamily, "", 10)
+	for _, field := range []struct{ label, value string }{
+		{l.address, party.Address},
+		{l.phone, party.Phone},
+		{"E-mail", party.Email},
+	} {
+		if field.value != "" {
+			pdf.MultiCell(0, lineHeight, fmt.Sprintf("%s: %s", field.label, field.value), "", "L", false)
+		}
+	}
+}
+
+// writeLines writes table of the lines, long product names are wrapped.
+func writeLines(pdf *gofpdf.Fpdf, order Order, columns []column) {
+	pdf.SetFont(fontFamily, "B", 10)
+	pdf.SetFillColor(235, 235, 235)
+	for _, col := range columns {
+		pdf.CellFormat(col.width, lineHeight+2, col.title, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont(fontFamily, "", 10)
+	_, pageHeight := pdf.GetPageSize()
+	for i, line := range order.Lines {
+		cells := make([][]string, len(columns))
+		rows := 1
+		for j, col := range columns {
+			cells[j] = pdf.SplitText(col.value(i, line), col.width-2)
+			if len(cells[j]) > rows {
+				rows = len(cells[j])
+			}
+		}
+		height := float64(rows) * lineHeight
+		if pdf.GetY()+height > pageHeight-pageMargin {
+			pdf.AddPage()
+		}
+		x, y := pdf.GetXY()
+		for j, col := range columns {
+			pdf.Rect(x, y, col.width, height, "D")
+			pdf.SetXY(x, y)
+			pdf.MultiCell(col.width, lineHeight, strings.Join(cells[j], "\n"), "", col.align, false)
+			x += col.width
+		}
+		pdf.SetXY(pageMargin, y+height)
+	}
+}
+
+func writeComment(pdf *gofpdf.Fpdf, l labels, order Order) {
+	if order.Comment == "" {
+		return
+	}
+	pdf.SetFont(fontFamily, "", 10)
+	pdf.MultiCell(0, lineHeight, fmt.Sprintf("%s: %s", l.comment, order.Comment), "", "L", false)
+}
+
+// formatMoney formats the amount with spaces between thousands, 1234567.5 is 1 234 567.50
+func formatMoney(amount float64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	text := fmt.Sprintf("%.2f", amount)
+	whole, fraction := text[:len(text)-3], text[len(text)-3:]
+	var b strings.Builder
+	for i, digit := range whole {
+		if i != 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteRune(digit)
+	}
+	return sign + b.String() + fraction
+}
@@ -0,0 +1,109 @@
+package document
+
+const (
+	LangRu = "ru"
+	LangUz = "uz"
+	LangEn = "en"
+)
+
+type labels struct {
+	invoice      string
+	deliveryNote string
+	dated        string
+	order        string
+	supplier     string
+	customer     string
+	address      string
+	phone        string
+	no           string
+	product      string
+	quantity     string
+	price        string
+	sum          string
+	subtotal     string
+	discount     string
+	total        string
+	comment      string
+	issued       string
+	received     string
+}
+
+var translations = map[string]labels{
+	LangRu: {
+		invoice:      "Счёт на оплату",
+		deliveryNote: "Накладная",
+		dated:        "от",
+		order:        "Заказ",
+		supplier:     "Поставщик",
+		customer:     "Покупатель",
+		address:      "Адрес",
+		phone:        "Телефон",
+		no:           "№",
+		product:      "Товар",
+		quantity:     "Кол-во",
+		price:        "Цена",
+		sum:          "Сумма",
+		subtotal:     "Сумма без скидки",
+		discount:     "Скидка",
+		total:        "Итого",
+		comment:      "Комментарий",
+		issued:       "Отпустил",
+		received:     "Получил",
+	},
+	LangUz: {
+		invoice:      "To‘lov uchun hisob",
+		deliveryNote: "Yuk xati",
+		dated:        "sana",
+		order:        "Buyurtma",
+		supplier:     "Yetkazib beruvchi",
+		customer:     "Xaridor",
+		address:      "Manzil",
+		phone:        "Telefon",
+		no:           "№",
+		product:      "Mahsulot",
+		quantity:     "Soni",
+		price:        "Narxi",
+		sum:          "Summa",
+		subtotal:     "Chegirmasiz summa",
+		discount:     "Chegirma",
+		total:        "Jami",
+		comment:      "Izoh",
+		issued:       "Topshirdi",
+		received:     "Qabul qildi",
+	},
+	LangEn: {
+		invoice:      "Proforma invoice",
+		deliveryNote: "Delivery note",
+		dated:        "dated",
+		order:        "Order",
+		supplier:     "Supplier",
+		customer:     "Customer",
+		address:      "Address",
+		phone:        "Phone",
+		no:           "No",
+		product:      "Product",
+		quantity:     "Qty",
+		price:        "Price",
+		sum:          "Amount",
+		subtotal:     "Subtotal",
+		discount:     "Discount",
+		total:        "Total",
+		comment:      "Comment",
+		issued:       "Issued by",
+		received:     "Received by",
+	},
+}
+
+// Supported reports whether documents can be written in the language.
+func Supported(lang string) bool {
+	_, ok := translations[lang]
+	return ok
+}
+
+// labelsOf returns labels of the language, russian is used for unknown languages.
+func labelsOf(lang string) labels {
+	if l, ok := translations[lang]; ok {
+		return l
+	}
+	return translations[LangRu]
+}
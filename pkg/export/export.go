@@ -0,0 +1,130 @@
+// Package export writes tables to CSV and XLSX. Rows are written as they come, so large tables are
+// streamed to the client without being kept in memory.
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// Writer writes rows of a table. Cells may be strings, integers, floats, time.Time or pointers to them,
+// nil pointers are written as empty cells. Close must be called after the last row.
+type Writer interface {
+	WriteRow(cells ...interface{}) error
+	Close() error
+}
+
+// Supported reports whether tables can be exported in the format.
+func Supported(format string) bool {
+	return format == FormatCSV || format == FormatXLSX
+}
+
+// ContentType returns media type of the format.
+func ContentType(format string) string {
+	if format == FormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+}
+
+// New returns writer of the format, sheet is the name of the XLSX worksheet.
+func New(w io.Writer, format, sheet string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSV(w)
+	case FormatXLSX:
+		return newXLSX(w, sheet)
+	}
+	return nil, fmt.Errorf("unsupported export format %q", format)
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+// newCSV writes UTF-8 byte order mark first, otherwise Excel opens cyrillic text in a wrong encoding.
+func newCSV(w io.Writer) (*csvWriter, error) {
+	_, err := io.WriteString(w, "\uFEFF")
+	if err != nil {
+		return nil, err
+	}
+	return &csvWriter{w: csv.NewWriter(w)}, nil
+}
+
+func (c *csvWriter) WriteRow(cells ...interface{}) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		cell = deref(cell)
+		record[i] = formatCell(cell)
+		if text, ok := cell.(string); ok {
+			record[i] = escapeFormula(text)
+		}
+	}
+	return c.w.Write(record)
+}
+
+// escapeFormula prefixes text which spreadsheets would run as a formula with an apostrophe, so a customer
+// name like =HYPERLINK(...) is shown as typed. Numbers are not strings here, so negative amounts stay numbers.
+func escapeFormula(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+const timeLayout = "2006-01-02 15:04:05"
+
+func formatCell(cell interface{}) string {
+	switch v := cell.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case time.Time:
+		return v.Format(timeLayout)
+	}
+	return fmt.Sprint(cell)
+}
+
+// deref returns value of the pointer cell, nil pointers become nil.
+func deref(cell interface{}) interface{} {
+	switch v := cell.(type) {
+	case *string:
+		if v != nil {
+			return *v
+		}
+	case *int:
+		if v != nil {
+			return *v
+		}
+	case *float64:
+		if v != nil {
+			return *v
+		}
+	case *time.Time:
+		if v != nil {
+			return *v
+		}
+	default:
+		return cell
+	}
+	return nil
+}
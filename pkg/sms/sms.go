@@ -2,46 +2,116 @@ package sms
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"os"
+	"sync"
+	"time"
 
+	"github.com/Asliddin3/energy-maximum/config"
 	"github.com/google/uuid"
 )
 
+const (
+	ProviderHttp = "http"
+	ProviderFake = "fake"
+)
+
+// Sender delivers one sms and returns the message id known to the provider.
+type Sender interface {
+	Send(ctx context.Context, phone, text string) (string, error)
+	Name() string
+}
+
+// ProviderError is returned when the provider answers with non 2xx status.
+type ProviderError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("sms provider responded with %d: %s", e.StatusCode, e.Body)
+}
+
+// Temporary reports whether the request may succeed later.
+func (e *ProviderError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// IsTemporary reports whether sending should be retried after err.
+func IsTemporary(err error) bool {
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return providerErr.Temporary()
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// New returns sender chosen by SMS_PROVIDER. There is no default provider, so a deployment without the
+// setting does not start instead of writing codes to the log.
+func New(cfg config.Config) (Sender, error) {
+	switch cfg.SmsProvider {
+	case ProviderHttp:
+		return NewSmsSender(cfg), nil
+	case ProviderFake:
+		return NewFakeSender(cfg.SmsFakePath)
+	case "":
+		return nil, fmt.Errorf("SMS_PROVIDER is required, use %s or %s", ProviderHttp, ProviderFake)
+	default:
+		return nil, fmt.Errorf("unknown sms provider: %s", cfg.SmsProvider)
+	}
+}
+
 type Sms struct {
 	Login    string
 	Password string
 	Url      string
-	Port     string
 	Sender   string
+	client   *http.Client
 }
 type SmsRequest struct {
 	Messages []SmsReqMessage `json:"messages"`
 }
 
 type SmsReqMessage struct {
-	Recipient string `json:"recipient"`
-	MessageID string `json:"message-id"`
-	Sms       struct {
-		Originator string `json:"originator"`
-		Content    []struct {
-			Text string `json:"text"`
-		} `json:"content"`
-	} `json:"sms"`
+	Recipient string     `json:"recipient"`
+	MessageID string     `json:"message-id"`
+	Sms       SmsContent `json:"sms"`
+}
+
+type SmsContent struct {
+	Originator string    `json:"originator"`
+	Content    []SmsText `json:"content"`
+}
+
+type SmsText struct {
+	Text string `json:"text"`
 }
 
-func NewSmsSender() *Sms {
+func NewSmsSender(cfg config.Config) *Sms {
 	return &Sms{
-		Login:    "",
-		Password: "",
-		Url:      "",
-		Port:     "",
-		Sender:   "",
+		Login:    cfg.SmsLogin,
+		Password: cfg.SmsPassword,
+		Url:      cfg.SmsUrl,
+		Sender:   cfg.SmsOriginator,
+		client:   &http.Client{Timeout: cfg.SmsTimeout},
 	}
 }
 
-func (s *Sms) SendCode(phone string, text string) error {
+func (s *Sms) Name() string {
+	return ProviderHttp
+}
+
+func (s *Sms) Send(ctx context.Context, phone string, text string) (string, error) {
 	id := uuid.NewString()
 	id = "mxb" + string([]rune(id)[:10])
 	reqData := SmsRequest{
@@ -49,44 +119,74 @@ func (s *Sms) SendCode(phone string, text string) error {
 			{
 				Recipient: phone,
 				MessageID: id,
-				Sms: struct {
-					Originator string `json:"originator"`
-					Content    []struct {
-						Text string `json:"text"`
-					} `json:"content"`
-				}{
+				Sms: SmsContent{
 					Originator: s.Sender,
-					Content: []struct {
-						Text string `json:"text"`
-					}{
-						{
-							Text: text,
-						},
-					},
+					Content:    []SmsText{{Text: text}},
 				},
 			},
 		},
 	}
 	data, err := json.Marshal(&reqData)
 	if err != nil {
-		return err
+		return "", err
 	}
-	payloadBuf := new(bytes.Buffer)
-	json.NewEncoder(payloadBuf).Encode(data)
-	client := &http.Client{}
-	req, err := http.NewRequest("POST", s.Url, payloadBuf)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Url, bytes.NewReader(data))
 	if err != nil {
-		return err
+		return "", err
 	}
-	req.Header.Add("Authorization", fmt.Sprintf("Basic %s:%s", s.Login, s.Password))
+	req.SetBasicAuth(s.Login, s.Password)
 	req.Header.Add("Cache-Control", "no-cache")
 	req.Header.Add("Content-Type", "application/json")
-	res, e := client.Do(req)
-	if e != nil {
-		return e
+	res, err := s.client.Do(req)
+	if err != nil {
+		return "", err
 	}
-
 	defer res.Body.Close()
-	fmt.Println("MESSAGE sended ")
-	return nil
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return "", &ProviderError{StatusCode: res.StatusCode, Body: string(body)}
+	}
+	return id, nil
+}
+
+// FakeSender writes messages as json lines to a file or stdout instead of sending them.
+type FakeSender struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewFakeSender appends messages to path, empty path or "stdout" writes to stdout.
+func NewFakeSender(path string) (*FakeSender, error) {
+	if path == "" || path == "stdout" {
+		return &FakeSender{out: os.Stdout}, nil
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("fake sms: %w", err)
+	}
+	return &FakeSender{out: file}, nil
+}
+
+func (f *FakeSender) Name() string {
+	return ProviderFake
+}
+
+func (f *FakeSender) Send(ctx context.Context, phone, text string) (string, error) {
+	id := "fake-" + uuid.NewString()
+	line, err := json.Marshal(map[string]string{
+		"id":      id,
+		"phone":   phone,
+		"text":    text,
+		"sent_at": time.Now().Format(time.RFC3339),
+	})
+	if err != nil {
+		return "", err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, err = f.out.Write(append(line, '\n'))
+	if err != nil {
+		return "", err
+	}
+	return id, nil
 }
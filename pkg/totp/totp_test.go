@@ -0,0 +1,75 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+const testSecret = "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"
+
+func TestCodeAt(t *testing.T) {
+	// RFC 6238 test vector for SHA1, the secret is ASCII "12345678901234567890"
+	secret := encoding.EncodeToString([]byte("12345678901234567890"))
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		got, err := CodeAt(secret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("CodeAt() error = %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("CodeAt(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	current := Step(now)
+	code := func(step int64) string {
+		value, err := CodeAt(testSecret, step)
+		if err != nil {
+			t.Fatalf("CodeAt() error = %v", err)
+		}
+		return value
+	}
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", code(current), 0, current, true},
+		{"previous step within skew", code(current - 1), 0, current - 1, true},
+		{"next step within skew", code(current + 1), 0, current + 1, true},
+		{"step outside skew", code(current - 2), 0, 0, false},
+		{"code with spaces", code(current)[:3] + " " + code(current)[3:], 0, current, true},
+		{"replay of used step", code(current), current, 0, false},
+		{"older step after newer one is used", code(current - 1), current, 0, false},
+		{"newer step after older one is used", code(current + 1), current, current + 1, true},
+		{"short code", "12345", 0, 0, false},
+		{"wrong code", "000000", 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(testSecret, tt.code, now, tt.lastStep)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("Validate() = %d, %v, want %d, %v", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+
+	t.Run("invalid secret", func(t *testing.T) {
+		if _, ok := Validate("not base32!", code(current), now, 0); ok {
+			t.Error("Validate() accepts code for invalid secret")
+		}
+	})
+}
@@ -1,55 +1,190 @@
 package utils
 
 import (
+	"crypto/rsa"
 	"encoding/base64"
 	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt"
-	
 )
 
-func CreateToken(ttl time.Duration, payload interface{}, privateKey string) (string, error) {
-	decodedPrivateKey, err := base64.StdEncoding.DecodeString(privateKey)
+// KeyRingConfig describes where keys of one token type come from.
+// PrivateKey and PublicKey are base64 PEM of the signing key. Dir may hold more keys as
+// "<kid>.pem" public keys and "<kid>.key" private keys. VerifyKeys is a list of
+// "kid=base64 PEM" pairs separated by comma, used to keep rotated keys valid.
+type KeyRingConfig struct {
+	KeyID      string
+	PrivateKey string
+	PublicKey  string
+	Dir        string
+	VerifyKeys string
+}
+
+// KeyRing signs tokens with one key and verifies them with every active key, the key is picked by kid header.
+type KeyRing struct {
+	signingKID string
+	signingKey *rsa.PrivateKey
+	publicKeys map[string]*rsa.PublicKey
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func decodeKey(key string) ([]byte, error) {
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
 	if err != nil {
-		return "", fmt.Errorf("could not decode key: %w", err)
+		return nil, fmt.Errorf("could not decode key: %w", err)
 	}
-	key, err := jwt.ParseRSAPrivateKeyFromPEM(decodedPrivateKey)
-	if err != nil {
-		return "", fmt.Errorf("create: parse key: %w", err)
+	return decoded, nil
+}
+
+func NewKeyRing(cfg KeyRingConfig) (*KeyRing, error) {
+	if cfg.KeyID == "" {
+		return nil, fmt.Errorf("key ring: missing signing key id")
+	}
+	ring := &KeyRing{
+		signingKID: cfg.KeyID,
+		publicKeys: make(map[string]*rsa.PublicKey),
+	}
+	privateKeys := make(map[string]*rsa.PrivateKey)
+
+	if cfg.Dir != "" {
+		files, err := os.ReadDir(cfg.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("key ring: read dir: %w", err)
+		}
+		for _, file := range files {
+			ext := filepath.Ext(file.Name())
+			kid := strings.TrimSuffix(file.Name(), ext)
+			if file.IsDir() || (ext != ".pem" && ext != ".key") {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(cfg.Dir, file.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("key ring: read %s: %w", file.Name(), err)
+			}
+			if ext == ".key" {
+				key, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+				if err != nil {
+					return nil, fmt.Errorf("key ring: parse %s: %w", file.Name(), err)
+				}
+				privateKeys[kid] = key
+				continue
+			}
+			key, err := jwt.ParseRSAPublicKeyFromPEM(data)
+			if err != nil {
+				return nil, fmt.Errorf("key ring: parse %s: %w", file.Name(), err)
+			}
+			ring.publicKeys[kid] = key
+		}
+	}
+
+	for _, pair := range strings.Split(cfg.VerifyKeys, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		kid, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("key ring: verify key %q must look like kid=key", pair)
+		}
+		decoded, err := decodeKey(value)
+		if err != nil {
+			return nil, err
+		}
+		key, err := jwt.ParseRSAPublicKeyFromPEM(decoded)
+		if err != nil {
+			return nil, fmt.Errorf("key ring: parse verify key %s: %w", kid, err)
+		}
+		ring.publicKeys[strings.TrimSpace(kid)] = key
+	}
+
+	if cfg.PrivateKey != "" {
+		decoded, err := decodeKey(cfg.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(decoded)
+		if err != nil {
+			return nil, fmt.Errorf("create: parse key: %w", err)
+		}
+		privateKeys[cfg.KeyID] = key
+	}
+	if cfg.PublicKey != "" {
+		decoded, err := decodeKey(cfg.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		key, err := jwt.ParseRSAPublicKeyFromPEM(decoded)
+		if err != nil {
+			return nil, fmt.Errorf("validate: parse key: %w", err)
+		}
+		ring.publicKeys[cfg.KeyID] = key
 	}
 
+	ring.signingKey = privateKeys[cfg.KeyID]
+	if ring.signingKey == nil {
+		return nil, fmt.Errorf("key ring: no private key for signing key id %s", cfg.KeyID)
+	}
+	if _, ok := ring.publicKeys[cfg.KeyID]; !ok {
+		ring.publicKeys[cfg.KeyID] = &ring.signingKey.PublicKey
+	}
+	return ring, nil
+}
+
+// CreateToken signs token with the signing key and stamps its kid into the header.
+func (k *KeyRing) CreateToken(ttl time.Duration, payload interface{}, extra map[string]interface{}) (string, error) {
 	now := time.Now().UTC()
 
 	claims := make(jwt.MapClaims)
+	for key, val := range extra {
+		claims[key] = val
+	}
 	claims["sub"] = payload
 	claims["exp"] = now.Add(ttl).Unix()
 	claims["iat"] = now.Unix()
 	claims["nbf"] = now.Unix()
 
-	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = k.signingKID
+	signed, err := token.SignedString(k.signingKey)
 	if err != nil {
 		return "", fmt.Errorf("create: sign token: %w", err)
 	}
 
-	return token, nil
+	return signed, nil
 }
 
-func GetClaims(token string, publicKey string) (jwt.MapClaims, error) {
-	decodedPublicKey, err := base64.StdEncoding.DecodeString(publicKey)
-	if err != nil {
-		return nil, fmt.Errorf("could not decode: %w", err)
-	}
-
-	key, err := jwt.ParseRSAPublicKeyFromPEM(decodedPublicKey)
-	if err != nil {
-		return nil, fmt.Errorf("validate: parse key: %w", err)
-	}
-
+// GetClaims validates token with the key named by its kid. Tokens without kid were signed
+// before key rotation and are checked with the current signing key.
+func (k *KeyRing) GetClaims(token string) (jwt.MapClaims, error) {
 	parsedToken, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
 		if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
 			return nil, fmt.Errorf("unexpected method: %s", t.Header["alg"])
 		}
+		kid, _ := t.Header["kid"].(string)
+		if kid == "" {
+			kid = k.signingKID
+		}
+		key, ok := k.publicKeys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id: %s", kid)
+		}
 		return key, nil
 	})
 	if err != nil {
@@ -64,18 +199,24 @@ func GetClaims(token string, publicKey string) (jwt.MapClaims, error) {
 	return claims, nil
 }
 
-func ValidateToken(token string, publicKey string) (interface{}, error) {
-	claims, err := GetClaims(token, publicKey)
-	if err != nil {
-		return nil, err
+// JWKS returns public keys of the ring in JSON Web Key Set format.
+func (k *KeyRing) JWKS() JWKSet {
+	kids := make([]string, 0, len(k.publicKeys))
+	for kid := range k.publicKeys {
+		kids = append(kids, kid)
 	}
-	return claims["sub"], nil
-}
-
-func GetRole(token string, publicKey string) (interface{}, error) {
-	claims, err := GetClaims(token, publicKey)
-	if err != nil {
-		return nil, err
+	sort.Strings(kids)
+	set := JWKSet{Keys: make([]JWK, 0, len(kids))}
+	for _, kid := range kids {
+		key := k.publicKeys[kid]
+		set.Keys = append(set.Keys, JWK{
+			Kty: "RSA",
+			Kid: kid,
+			Use: "sig",
+			Alg: jwt.SigningMethodRS256.Alg(),
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
 	}
-	return claims["role"], nil
+	return set
 }
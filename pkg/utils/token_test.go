@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// testKey returns base64 PEM of a new private key and of its public key.
+func testKey(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	encode := func(kind string, der []byte) string {
+		return base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}))
+	}
+	return encode("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key)), encode("PUBLIC KEY", public)
+}
+
+func TestKeyRingGetClaims(t *testing.T) {
+	oldPrivate, oldPublic := testKey(t)
+	newPrivate, _ := testKey(t)
+	oldRing, err := NewKeyRing(KeyRingConfig{KeyID: "old", PrivateKey: oldPrivate})
+	if err != nil {
+		t.Fatalf("NewKeyRing() error = %v", err)
+	}
+	// the signing key is rotated, old key is still accepted while tokens signed with it live
+	rotatedRing, err := NewKeyRing(KeyRingConfig{KeyID: "new", PrivateKey: newPrivate, VerifyKeys: "old=" + oldPublic})
+	if err != nil {
+		t.Fatalf("NewKeyRing() error = %v", err)
+	}
+	// the old key is dropped after rotation
+	newRing, err := NewKeyRing(KeyRingConfig{KeyID: "new", PrivateKey: newPrivate})
+	if err != nil {
+		t.Fatalf("NewKeyRing() error = %v", err)
+	}
+	sign := func(ring *KeyRing, ttl time.Duration) string {
+		token, err := ring.CreateToken(ttl, 1, map[string]interface{}{"role": "admin"})
+		if err != nil {
+			t.Fatalf("CreateToken() error = %v", err)
+		}
+		return token
+	}
+	resign := func(token string, header func(*jwt.Token)) string {
+		parsed, _, err := new(jwt.Parser).ParseUnverified(token, jwt.MapClaims{})
+		if err != nil {
+			t.Fatalf("parse token: %v", err)
+		}
+		header(parsed)
+		signed, err := parsed.SignedString(newRing.signingKey)
+		if err != nil {
+			t.Fatalf("sign token: %v", err)
+		}
+		return signed
+	}
+	withoutKid := resign(sign(newRing, time.Minute), func(token *jwt.Token) { delete(token.Header, "kid") })
+	unknownKid := resign(sign(newRing, time.Minute), func(token *jwt.Token) { token.Header["kid"] = "missing" })
+	// token signed with the new key but naming the old one must not pass
+	wrongKid := resign(sign(newRing, time.Minute), func(token *jwt.Token) { token.Header["kid"] = "old" })
+
+	tests := []struct {
+		name    string
+		ring    *KeyRing
+		token   string
+		wantErr string
+	}{
+		{"current key", newRing, sign(newRing, time.Minute), ""},
+		{"rotated key is still verified", rotatedRing, sign(oldRing, time.Minute), ""},
+		{"new key on rotated ring", rotatedRing, sign(rotatedRing, time.Minute), ""},
+		{"rotated key is dropped", newRing, sign(oldRing, time.Minute), "unknown key id: old"},
+		{"unknown kid", rotatedRing, unknownKid, "unknown key id: missing"},
+		{"kid of other key", rotatedRing, wrongKid, "verification error"},
+		{"token without kid uses signing key", newRing, withoutKid, ""},
+		{"token without kid of other key", oldRing, withoutKid, "verification error"},
+		{"expired token", newRing, sign(newRing, -time.Minute), "expired"},
+		{"garbage", newRing, "not.a.token", "validate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := tt.ring.GetClaims(tt.token)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("GetClaims() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetClaims() error = %v", err)
+			}
+			if claims["role"] != "admin" || claims["sub"] != float64(1) {
+				t.Errorf("GetClaims() = %v", claims)
+			}
+		})
+	}
+}
+
+func TestNewKeyRing(t *testing.T) {
+	private, public := testKey(t)
+	tests := []struct {
+		name    string
+		cfg     KeyRingConfig
+		wantErr string
+	}{
+		{"signing key", KeyRingConfig{KeyID: "a", PrivateKey: private}, ""},
+		{"missing key id", KeyRingConfig{PrivateKey: private}, "missing signing key id"},
+		{"missing private key", KeyRingConfig{KeyID: "a", PublicKey: public}, "no private key"},
+		{"verify key without kid", KeyRingConfig{KeyID: "a", PrivateKey: private, VerifyKeys: "b"}, "must look like kid=key"},
+		{"verify key is not base64", KeyRingConfig{KeyID: "a", PrivateKey: private, VerifyKeys: "b=%%%"}, "could not decode key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKeyRing(tt.cfg)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("NewKeyRing() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}